@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain"
+	domainnostr "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip46"
+	"github.com/pkg/errors"
+)
+
+const bunkerCommand = "bunker"
+
+const bunkerUsage = `usage: rsslay [-dsn <datasource name>] bunker [-derive-only]
+
+Runs the NIP-46 bunker which signs the events of the feeds so that the relay
+doesn't need SECRET nor the private keys. It prints the bunker URL to set as
+NIP46_BUNKER_URL and serves the requests sent through BUNKER_RELAY until it
+is stopped.
+
+The keys of the feeds are derived from SECRET and SECRETS. The private keys
+stored in the database, such as the ones of imported feeds, are decrypted
+with KEY_ENCRYPTION_KEYS unless -derive-only is given. BUNKER_PRIVATE_KEY
+and BUNKER_SECRET identify the bunker and must not change for the URL to
+stay valid.`
+
+// bunkerConfig only contains the settings required to sign the events of the
+// feeds.
+type bunkerConfig struct {
+	Secret            string   `envconfig:"SECRET" default:""`
+	Secrets           []string `envconfig:"SECRETS" default:""`
+	KeyEncryptionKeys []string `envconfig:"KEY_ENCRYPTION_KEYS" default:""`
+	DatabaseDirectory string   `envconfig:"DB_DIR" default:"db/rsslay.sqlite"`
+	PrivateKey        string   `envconfig:"BUNKER_PRIVATE_KEY" default:""`
+	ConnectionSecret  string   `envconfig:"BUNKER_SECRET" default:""`
+	Relay             string   `envconfig:"BUNKER_RELAY" default:""`
+}
+
+func runBunkerCommand(args []string) error {
+	flags := flag.NewFlagSet(bunkerCommand, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), bunkerUsage) }
+	deriveOnly := flags.Bool("derive-only", false, "only sign with the keys derived from the secrets")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New(bunkerUsage)
+	}
+
+	var config bunkerConfig
+	if err := loadCommandSettings(&config); err != nil {
+		return err
+	}
+
+	switch {
+	case config.Secret == "":
+		return errors.New("SECRET is required to derive the keys of the feeds")
+	case config.ConnectionSecret == "":
+		return errors.New("BUNKER_SECRET is required to authorize the relay")
+	case !nostr.IsValidRelayURL(config.Relay):
+		return fmt.Errorf("BUNKER_RELAY must be a relay URL like wss://relay.example.com, got %q", config.Relay)
+	}
+
+	if _, err := nostr.GetPublicKey(config.PrivateKey); err != nil || len(config.PrivateKey) != 64 {
+		return errors.New("BUNKER_PRIVATE_KEY must be a private key in hex")
+	}
+
+	secrets, err := newSecrets(config.Secret, config.Secrets)
+	if err != nil {
+		return err
+	}
+
+	var keys nip46.Keys
+	if !*deriveOnly {
+		keyring, err := newKeyring(config.KeyEncryptionKeys)
+		if err != nil {
+			return err
+		}
+
+		connection := *dsn
+		if connection == "" {
+			connection = config.DatabaseDirectory
+		}
+
+		db := openDatabase(connection)
+		defer db.Close()
+
+		keys = storedKeys(adapters.NewFeedDefinitionStorage(db, keyring))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	bunker, err := nip46.NewBunker(nip46.NewRelayTransport(config.Relay), config.PrivateKey, config.ConnectionSecret, keys, deriveKeys(secrets))
+	if err != nil {
+		return err
+	}
+	if err := bunker.Start(ctx); err != nil {
+		return errors.Wrap(err, "error starting the bunker")
+	}
+
+	fmt.Println(bunker.URL(config.Relay).String())
+	<-ctx.Done()
+	return nil
+}
+
+// storedKeys signs with the private keys stored in the database, which the
+// feeds imported with their private key or moved more than once have.
+func storedKeys(storage *adapters.FeedDefinitionStorage) nip46.Keys {
+	return func(publicKey string) (string, bool) {
+		key, err := domainnostr.NewPublicKeyFromHex(publicKey)
+		if err != nil {
+			return "", false
+		}
+
+		definition, err := storage.Get(key)
+		if err != nil {
+			log.Printf("[DEBUG] no stored private key for %s: %v", publicKey, err)
+			return "", false
+		}
+		if !definition.HasPrivateKey() {
+			return "", false
+		}
+		return definition.PrivateKey().Hex(), true
+	}
+}
+
+func deriveKeys(secrets domain.Secrets) nip46.Derive {
+	keyDeriver := app.NewSecretKeyDeriver(secrets)
+	return func(address string) ([]nip46.DerivedKey, error) {
+		derived, err := keyDeriver.Derive(address)
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []nip46.DerivedKey
+		for _, key := range derived {
+			keys = append(keys, nip46.DerivedKey{
+				Address:    key.Address,
+				Version:    key.Version,
+				PublicKey:  key.PublicKey.Hex(),
+				PrivateKey: key.PrivateKey.Hex(),
+			})
+		}
+		return keys, nil
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/go-multierror"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/internal/handlers"
+	"github.com/piraces/rsslay/pkg/config"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/nip46"
+	"github.com/pkg/errors"
+)
+
+// configPath is the path of the configuration file, empty if there is none.
+func configPath() string {
+	if *configFile != "" {
+		return *configFile
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+// loadCommandSettings loads the settings of a command, which shares some of
+// them with the relay and reads the same configuration file.
+func loadCommandSettings(spec any) error {
+	_, err := config.LoadSubset(spec, &Relay{}, configPath())
+	return err
+}
+
+// loadSettings loads and validates the settings of the relay.
+func (r *Relay) loadSettings() error {
+	loaded, err := config.Load(r, configPath())
+	if err != nil {
+		return err
+	}
+	if err := r.validate(); err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+	r.config = loaded
+	return nil
+}
+
+// validate reports all the settings with invalid values at once.
+func (r *Relay) validate() error {
+	var result *multierror.Error
+	invalid := func(name string, format string, args ...any) {
+		result = multierror.Append(result, fmt.Errorf("%s %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	// the bunker holds the secrets when the events are signed by it
+	if r.Secret == "" && r.Nip46BunkerUrl == "" {
+		invalid("SECRET", "is required unless NIP46_BUNKER_URL is set")
+	} else if r.Secret != "" {
+		if _, err := r.secrets(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if r.EnableBot && r.Secret == "" && r.BotPrivateKey == "" {
+		invalid("BOT_PRIVATE_KEY", "is required to run the bot without SECRET")
+	}
+
+	if _, err := newKeyring(r.KeyEncryptionKeys); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if r.MainDomainName != "" && strings.ContainsAny(r.MainDomainName, ":/") {
+		invalid("MAIN_DOMAIN_NAME", "must be a host name like rsslay.example.com, got %q", r.MainDomainName)
+	}
+
+	// anyone can publish with a new key, the proof of work makes each event
+	// cost something and the rate limit bounds each key
+	if r.AcceptUserEvents && r.UserEventsMinPow <= 0 {
+		invalid("USER_EVENTS_MIN_POW", "must be above 0 to accept user events")
+	}
+	if r.AcceptUserEvents && r.UserEventsRateLimit <= 0 {
+		invalid("USER_EVENTS_RATE_LIMIT", "must be above 0 to accept user events")
+	}
+
+	if r.OwnerPublicKey != "" && !nostr.IsValidPublicKeyHex(r.OwnerPublicKey) {
+		invalid("OWNER_PUBLIC_KEY", "must be a public key in lowercase hex, got %q", r.OwnerPublicKey)
+	}
+
+	for _, relay := range r.RelaysToPublish {
+		if relay != "" && !nostr.IsValidRelayURL(relay) {
+			invalid("RELAYS_TO_PUBLISH_TO", "must list relay URLs like wss://relay.example.com, got %q", relay)
+		}
+	}
+
+	for _, instance := range r.NitterInstances {
+		if u, err := url.Parse("https://" + instance); instance == "" || err != nil || u.Host != instance {
+			invalid("NITTER_INSTANCES", "must list host names like nitter.example.com, got %q", instance)
+		}
+	}
+
+	if r.BotPrivateKey != "" {
+		if _, err := nostr.GetPublicKey(r.BotPrivateKey); err != nil || len(r.BotPrivateKey) != 64 {
+			invalid("BOT_PRIVATE_KEY", "must be a private key in hex")
+		}
+	}
+
+	if r.Nip46BunkerUrl != "" {
+		if _, err := nip46.ParseBunkerURL(r.Nip46BunkerUrl); err != nil {
+			invalid("NIP46_BUNKER_URL", "is invalid: %v", err)
+		}
+	}
+
+	if _, err := r.denyLists(); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	nonNegative := []struct {
+		name  string
+		value int64
+	}{
+		{"DEFAULT_WAIT_TIME_BETWEEN_BATCHES", r.DefaultWaitTimeBetweenBatches},
+		{"DEFAULT_WAIT_TIME_FOR_RELAY_RESPONSE", r.DefaultWaitTimeForRelayResponse},
+		{"MAX_EVENTS_TO_REPLAY", int64(r.MaxEventsToReplay)},
+		{"MAX_SUBROUTINES", int64(r.MaxSubroutines)},
+		{"DELETE_FAILING_FEEDS_AFTER_DAYS", int64(r.DeleteFailingFeedsAfterDays)},
+		{"FEED_SUSPEND_AFTER_FAILURES", int64(r.FeedSuspendAfterFailures)},
+		{"FEED_RETRY_BACKOFF", r.FeedRetryBackoff},
+		{"FEED_MAX_RETRY_BACKOFF", r.FeedMaxRetryBackoff},
+		{"USER_EVENTS_MIN_POW", int64(r.UserEventsMinPow)},
+		{"MAX_FEEDS", int64(r.MaxFeeds)},
+		{"CREATE_FORM_POW_DIFFICULTY", int64(r.CreateFormPowDifficulty)},
+	}
+	for _, setting := range nonNegative {
+		if setting.value < 0 {
+			invalid(setting.name, "can't be negative, got %d", setting.value)
+		}
+	}
+
+	positive := []struct {
+		name  string
+		value int64
+	}{
+		{"MAX_CONTENT_LENGTH", int64(r.MaxContentLength)},
+		{"USER_EVENTS_MAX_CONTENT_LENGTH", int64(r.UserEventsMaxContentLength)},
+		{"USER_EVENTS_RATE_LIMIT_WINDOW", r.UserEventsRateLimitWindow},
+		{"BOT_RATE_LIMIT_WINDOW", r.BotRateLimitWindow},
+		{"FEED_CREATION_RATE_LIMIT_WINDOW", r.FeedCreationRateLimitWindow},
+		{"IMPORT_RATE_LIMIT_WINDOW", r.ImportRateLimitWindow},
+		{"API_RATE_LIMIT_WINDOW", r.APIRateLimitWindow},
+		{"NIP46_TIMEOUT", r.Nip46Timeout},
+	}
+	for _, setting := range positive {
+		if setting.value <= 0 {
+			invalid(setting.name, "must be positive, got %d", setting.value)
+		}
+	}
+
+	if r.UserEventsMinPow > 256 || r.CreateFormPowDifficulty > 256 {
+		result = multierror.Append(result, errors.New("proof of work difficulties can't exceed 256 bits"))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// denyLists parses the banned domains and the address rules of the
+// configuration.
+func (r *Relay) denyLists() (app.ConfiguredDenyLists, error) {
+	var result *multierror.Error
+	var lists app.ConfiguredDenyLists
+
+	for _, s := range r.BannedDomains {
+		if s == "" {
+			continue
+		}
+		domain, err := domainfeed.NewDomain(s)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("BANNED_DOMAINS has an invalid domain %q: %w", s, err))
+			continue
+		}
+		lists.BannedDomains = append(lists.BannedDomains, domain)
+	}
+
+	for _, s := range r.AddressRules {
+		if s == "" {
+			continue
+		}
+		rule, err := parseAddressRule(s)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("ADDRESS_RULES has an invalid rule %q: %w", s, err))
+			continue
+		}
+		lists.AddressRules = append(lists.AddressRules, rule)
+	}
+
+	return lists, result.ErrorOrNil()
+}
+
+// applyDenyLists stores the banned domains and the address rules of the
+// configuration next to the ones managed through the NIP-86 API.
+func (r *Relay) applyDenyLists(handler *app.HandlerApplyConfiguredDenyLists) error {
+	lists, err := r.denyLists()
+	if err != nil {
+		return err
+	}
+	return handler.Handle(lists)
+}
+
+func parseAddressRule(s string) (domainfeed.AddressRule, error) {
+	a, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return domainfeed.AddressRule{}, errors.New("must be action:pattern")
+	}
+
+	action, err := domainfeed.NewAddressRuleAction(a)
+	if err != nil {
+		return domainfeed.AddressRule{}, err
+	}
+
+	pattern, err := domainfeed.NewAddressPattern(p)
+	if err != nil {
+		return domainfeed.AddressRule{}, err
+	}
+
+	return domainfeed.AddressRule{Pattern: pattern, Action: action}, nil
+}
+
+// watchConfig reloads the configuration file on SIGHUP and, unless
+// CONFIG_WATCH is disabled, whenever it changes.
+func (r *Relay) watchConfig(ctx context.Context) {
+	path := r.config.File
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var changes <-chan struct{}
+	if r.ConfigWatch {
+		c, err := watchFile(ctx, path)
+		if err != nil {
+			log.Printf("[ERROR] only reloading the configuration file on SIGHUP: %v", err)
+		}
+		changes = c
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			log.Printf("[INFO] reloading the configuration file %s", path)
+		case <-changes:
+			log.Printf("[INFO] the configuration file %s changed, reloading it", path)
+		}
+
+		if err := r.reloadConfig(); err != nil {
+			log.Printf("[ERROR] keeping the current configuration: %v", err)
+		}
+	}
+}
+
+// configDebounce is how long the configuration file must stay unchanged
+// before it is reloaded, editors often write files in several steps.
+const configDebounce = 500 * time.Millisecond
+
+// watchFile notifies the changes of the file. Its directory is watched as
+// editors and orchestrators replace files by renaming others over them.
+func watchFile(ctx context.Context, path string) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the file watcher")
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, errors.Wrapf(err, "error watching %s", filepath.Dir(path))
+	}
+
+	changes := make(chan struct{})
+	go func() {
+		defer watcher.Close()
+
+		debounce := time.NewTimer(configDebounce)
+		debounce.Stop()
+
+		name := filepath.Clean(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == name && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					debounce.Reset(configDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[ERROR] error watching the configuration file: %v", err)
+			case <-debounce.C:
+				select {
+				case changes <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// reloadConfig applies the settings of the configuration file which can be
+// changed without a restart, the configuration is only applied if all of it
+// is valid.
+func (r *Relay) reloadConfig() error {
+	next := &Relay{}
+	loaded, err := config.Load(next, r.config.File)
+	if err != nil {
+		return err
+	}
+	if err := next.validate(); err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	// the templates are parsed again even if the directory didn't change so
+	// that SIGHUP picks up the edited templates
+	templates, err := handlers.ParseTemplates(next.TemplatesDirectory)
+	if err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	reloadable, fixed, err := config.Changed(r, next)
+	if err != nil {
+		return err
+	}
+
+	if len(fixed) > 0 {
+		log.Printf("[WARN] restart to apply the changes to %s", strings.Join(fixed, ", "))
+	}
+
+	r.settingsLock.Lock()
+	if err := config.CopyReloadable(r, next); err != nil {
+		r.settingsLock.Unlock()
+		return err
+	}
+	r.config = r.config.With(loaded, reloadable)
+	r.restartRequired = fixed
+	r.settingsLock.Unlock()
+
+	r.handler.SetTemplates(templates)
+	if err := r.applySettings(); err != nil {
+		return err
+	}
+
+	if len(reloadable) > 0 {
+		log.Printf("[INFO] applied the changes to %s", strings.Join(reloadable, ", "))
+	}
+	return nil
+}
+
+// applySettings passes the settings which can be reloaded to the handlers
+// which use them.
+func (r *Relay) applySettings() error {
+	r.settingsLock.RLock()
+	defer r.settingsLock.RUnlock()
+
+	a := r.store.app
+	a.UpdateFeeds.SetNitterInstances(r.NitterInstances)
+	a.UpdateFeeds.SetRelays(r.feedRelays())
+	a.CreateFeedDefinition.SetRateLimit(r.FeedCreationRateLimit, time.Duration(r.FeedCreationRateLimitWindow)*time.Millisecond)
+	a.ImportFeeds.SetRateLimit(r.ImportRateLimit, time.Duration(r.ImportRateLimitWindow)*time.Millisecond)
+	a.SaveUserEvent.SetRateLimit(r.UserEventsRateLimit, time.Duration(r.UserEventsRateLimitWindow)*time.Millisecond)
+	a.ProcessDirectMessage.SetRateLimit(r.BotRateLimit, time.Duration(r.BotRateLimitWindow)*time.Millisecond)
+	r.handler.SetAPIQuota(handlers.APIQuota{Limit: r.APIRateLimit, Window: time.Duration(r.APIRateLimitWindow) * time.Millisecond})
+	return r.applyDenyLists(a.ApplyConfiguredDenyLists)
+}
+
+// Configuration returns the effective configuration without the secrets.
+func (r *Relay) Configuration() (handlers.Configuration, error) {
+	r.settingsLock.RLock()
+	defer r.settingsLock.RUnlock()
+
+	settings, err := r.config.Settings(r)
+	if err != nil {
+		return handlers.Configuration{}, err
+	}
+
+	return handlers.Configuration{
+		File:            r.config.File,
+		LoadedAt:        r.config.LoadedAt,
+		Settings:        settings,
+		RestartRequired: append([]string(nil), r.restartRequired...),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfigKeepsTheSettingsOfInvalidFiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		document string
+		err      string
+	}{
+		{name: "invalid YAML", document: "secret: [\n", err: "invalid configuration file"},
+		{name: "unknown setting", document: "secret: s\nnitter_instance: b.example.com\n", err: `unknown setting "nitter_instance"`},
+		{name: "invalid setting", document: "secret: s\nnitter_instances: [b.example.com]\napi_rate_limit_window: -1\n", err: "API_RATE_LIMIT_WINDOW must be positive"},
+		{name: "invalid address rule", document: "secret: s\nnitter_instances: [b.example.com]\naddress_rules: [block:b.example.com]\n", err: `ADDRESS_RULES has an invalid rule "block:b.example.com"`},
+		{name: "missing templates", document: "secret: s\nnitter_instances: [b.example.com]\ntemplates_dir: " + filepath.Join(t.TempDir(), "missing") + "\n", err: "error parsing the templates"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rsslay.yaml")
+			writeConfig(t, path, "secret: s\nnitter_instances: [a.example.com]\napi_rate_limit: 5\n")
+
+			r := &Relay{}
+			loaded, err := config.Load(r, path)
+			require.NoError(t, err)
+			r.config = loaded
+
+			writeConfig(t, path, testCase.document)
+			err = r.reloadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.err)
+
+			assert.Equal(t, []string{"a.example.com"}, r.NitterInstances)
+			assert.Equal(t, 5, r.APIRateLimit)
+			assert.Equal(t, loaded, r.config)
+		})
+	}
+}
+
+func TestCommandsReadTheConfigurationFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rsslay.yaml")
+	writeConfig(t, path, "secret: s\ndb_dir: other.sqlite\nnitter_instances: [a.example.com]\n")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SECRETS", "2:from env")
+
+	var settings keysConfig
+	require.NoError(t, loadCommandSettings(&settings))
+	assert.Equal(t, "s", settings.Secret)
+	assert.Equal(t, "other.sqlite", settings.DatabaseDirectory)
+	assert.Equal(t, []string{"2:from env"}, settings.Secrets)
+
+	_, ok := os.LookupEnv("DB_DIR")
+	assert.False(t, ok, "the environment isn't modified")
+}
+
+func writeConfig(t *testing.T, path, document string) {
+	require.NoError(t, os.WriteFile(path, []byte(document), 0600))
+}
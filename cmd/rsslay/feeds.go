@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"text/tabwriter"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/custom_cache"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	pubsubadapters "github.com/piraces/rsslay/pkg/new/adapters/pubsub"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domainnostr "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+const (
+	addCommand     = "add"
+	removeCommand  = "remove"
+	listCommand    = "list"
+	refreshCommand = "refresh"
+	convertCommand = "convert"
+)
+
+const addUsage = `usage: rsslay [-dsn <datasource name>] add <url>
+
+Creates the feed found at the url the same way the relay does so the banned
+domains, address rules, MAX_FEEDS and MODERATE_FEEDS apply. Prints the
+existing feed if it was already created.`
+
+const removeUsage = `usage: rsslay [-dsn <datasource name>] remove <feed url or public key>
+
+Deletes the feed along with its events.`
+
+const listUsage = `usage: rsslay [-dsn <datasource name>] list [-failing]
+
+Lists the feeds with their health, with -failing only the ones which aren't
+healthy, failing for the longest time first.`
+
+const refreshUsage = `usage: rsslay [-dsn <datasource name>] refresh <feed url or public key>
+
+Fetches the feed right away and prints its health afterwards. With SQLite the
+relay keeps the events in memory so it only serves the new events after its
+next update, the metadata and health are saved right away.`
+
+const convertUsage = `usage: rsslay convert [-mode longform|note] <url>
+
+Converts the feed found at the url and prints its kind 0 event followed by
+the events of its items as JSON lines, signed with the keys the relay would
+derive for it. Nothing is saved and the database isn't used.`
+
+// feedsConfig only contains the settings required to manage the stored
+// feeds, commands fetching feeds use the settings of the relay instead.
+type feedsConfig struct {
+	DatabaseDirectory string   `envconfig:"DB_DIR" default:"db/rsslay.sqlite"`
+	KeyEncryptionKeys []string `envconfig:"KEY_ENCRYPTION_KEYS" default:""`
+}
+
+func runAddCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(addUsage)
+	}
+
+	address, err := domainfeed.NewAddress(args[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid url")
+	}
+
+	r, err := relaySettings()
+	if err != nil {
+		return err
+	}
+
+	_, keyDeriver, err := r.newSigner(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "error creating the signer")
+	}
+
+	db, feedDefinitionStorage, err := r.openFeedDefinitionStorage()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	bannedDomainStorage := adapters.NewBannedDomainStorage(db)
+	addressRuleStorage := adapters.NewAddressRuleStorage(db)
+	if err := r.applyDenyLists(app.NewHandlerApplyConfiguredDenyLists(bannedDomainStorage, addressRuleStorage)); err != nil {
+		return err
+	}
+
+	handler := r.newHandlerCreateFeedDefinition(
+		keyDeriver,
+		feedDefinitionStorage,
+		bannedDomainStorage,
+		addressRuleStorage,
+	)
+	definition, err := handler.Handle(app.CreateFeedDefinition{Address: address, Submitter: operatorIdentity()})
+	if err != nil {
+		return err
+	}
+
+	printFeeds(os.Stdout, []*domainfeed.FeedDefinition{definition})
+	return nil
+}
+
+func runRemoveCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(removeUsage)
+	}
+
+	config, err := feedsSettings()
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeyring(config.KeyEncryptionKeys)
+	if err != nil {
+		return err
+	}
+
+	connection := feedsConnection(config)
+	db := openDatabase(connection)
+	defer db.Close()
+
+	feedDefinitionStorage := adapters.NewFeedDefinitionStorage(db, keys)
+	definition, err := findFeed(feedDefinitionStorage, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := app.NewHandlerDeleteFeed(feedDefinitionStorage, newEventStorage(connection, db)).Handle(definition.PublicKey()); err != nil {
+		return err
+	}
+
+	fmt.Printf("removed %s %s\n", definition.PublicKey().Hex(), definition.Address().String())
+	return nil
+}
+
+func runListCommand(args []string) error {
+	flags := flag.NewFlagSet(listCommand, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), listUsage) }
+	failing := flags.Bool("failing", false, "only list the feeds which aren't healthy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New(listUsage)
+	}
+
+	config, err := feedsSettings()
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeyring(config.KeyEncryptionKeys)
+	if err != nil {
+		return err
+	}
+
+	db := openDatabase(feedsConnection(config))
+	defer db.Close()
+
+	feedDefinitionStorage := adapters.NewFeedDefinitionStorage(db, keys)
+
+	var definitions []*domainfeed.FeedDefinition
+	if *failing {
+		definitions, err = app.NewHandlerListFailingFeeds(feedDefinitionStorage).Handle()
+	} else {
+		definitions, err = app.NewHandlerListFeeds(feedDefinitionStorage).Handle()
+	}
+	if err != nil {
+		return err
+	}
+
+	printFeeds(os.Stdout, definitions)
+	return nil
+}
+
+func runRefreshCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(refreshUsage)
+	}
+
+	ctx := context.Background()
+
+	r, err := relaySettings()
+	if err != nil {
+		return err
+	}
+
+	db, feedDefinitionStorage, err := r.openFeedDefinitionStorage()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	definition, err := findFeed(feedDefinitionStorage, args[0])
+	if err != nil {
+		return err
+	}
+
+	if r.converterSelector, err = r.newConverterSelector(); err != nil {
+		return err
+	}
+
+	if r.signer, _, err = r.newSigner(ctx); err != nil {
+		return errors.Wrap(err, "error creating the signer")
+	}
+
+	handlerUpdateFeeds := r.newHandlerUpdateFeeds(
+		db,
+		feedDefinitionStorage,
+		newEventStorage(databaseConnection(r), db),
+		pubsubadapters.NewReceivedEventPubSub(),
+		adapters.NewBannedDomainStorage(db),
+		r.newOwnerSigners(),
+	)
+	refreshErr := app.NewHandlerRefreshFeed(feedDefinitionStorage, handlerUpdateFeeds).Handle(ctx, definition.PublicKey())
+	if errors.Is(refreshErr, app.ErrFeedDisabled) || errors.Is(refreshErr, app.ErrFeedPending) {
+		return refreshErr
+	}
+
+	definition, err = app.NewHandlerGetFeed(feedDefinitionStorage).Handle(definition.PublicKey())
+	if err != nil {
+		return err
+	}
+
+	printFeeds(os.Stdout, []*domainfeed.FeedDefinition{definition})
+	return refreshErr
+}
+
+func runConvertCommand(args []string) error {
+	flags := flag.NewFlagSet(convertCommand, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), convertUsage) }
+	mode := flags.String("mode", "", "output mode, selected for the feed by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New(convertUsage)
+	}
+
+	address, err := domainfeed.NewAddress(flags.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "invalid url")
+	}
+
+	ctx := context.Background()
+
+	r, err := relaySettings()
+	if err != nil {
+		return err
+	}
+
+	converterSelector, err := r.newConverterSelector()
+	if err != nil {
+		return err
+	}
+
+	eventSigner, keyDeriver, err := r.newSigner(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error creating the signer")
+	}
+
+	handler := app.NewHandlerConvertFeed(
+		r.EnableAutoNIP05Registration,
+		r.DefaultProfilePictureUrl,
+		r.MainDomainName,
+		r.feedRelays(),
+		keyDeriver,
+		converterSelector,
+		eventSigner,
+	)
+	converted, err := handler.Handle(ctx, app.ConvertFeed{Address: address, Mode: feed.OutputMode(*mode)})
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, evt := range converted.Events {
+		if err := encoder.Encode(evt); err != nil {
+			return errors.Wrap(err, "error writing the event")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "error writing the events")
+	}
+
+	log.Printf("[INFO] converted %s to %d events as %s for %s", converted.FeedURL, len(converted.Events), converted.Mode, converted.Definition.PublicKey().Nip19())
+	return nil
+}
+
+// relaySettings loads the settings of the relay for the commands which fetch
+// feeds, so that they are converted the same way.
+func relaySettings() (*Relay, error) {
+	if err := relayInstance.loadSettings(); err != nil {
+		return nil, err
+	}
+	if relayInstance.RedisConnectionString != "" {
+		custom_cache.RedisConnectionString = &relayInstance.RedisConnectionString
+	}
+	return relayInstance, nil
+}
+
+func (r *Relay) openFeedDefinitionStorage() (*sql.DB, *adapters.FeedDefinitionStorage, error) {
+	keys, err := newKeyring(r.KeyEncryptionKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db := openDatabase(databaseConnection(r))
+	return db, r.newFeedDefinitionStorage(db, keys), nil
+}
+
+func feedsSettings() (feedsConfig, error) {
+	var config feedsConfig
+	if err := loadCommandSettings(&config); err != nil {
+		return feedsConfig{}, err
+	}
+	return config, nil
+}
+
+func feedsConnection(config feedsConfig) string {
+	if *dsn == "" {
+		return config.DatabaseDirectory
+	}
+	return *dsn
+}
+
+// findFeed finds a feed by its public key, in hex or as an npub, or by the
+// url it is fetched from.
+func findFeed(storage app.FeedDefinitionStorage, s string) (*domainfeed.FeedDefinition, error) {
+	var definition *domainfeed.FeedDefinition
+	if publicKey, err := domainnostr.NewPublicKeyFromHexOrNip19(s); err == nil {
+		definition, err = app.NewHandlerGetFeed(storage).Handle(publicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting the feed")
+		}
+		return definition, nil
+	}
+
+	address, err := domainfeed.NewAddress(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a public key nor a url")
+	}
+
+	definition, err = storage.GetByAddress(address)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the feed")
+	}
+	return definition, nil
+}
+
+// operatorIdentity identifies the user running the command as the submitter
+// of the feeds they create.
+func operatorIdentity() auth.Identity {
+	name := "operator"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	return auth.NewOperatorIdentity(name)
+}
+
+func printFeeds(w io.Writer, definitions []*domainfeed.FeedDefinition) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PUBLIC KEY\tSTATUS\tHEALTH\tFAILURES\tLAST FETCHED\tURL\tLAST ERROR")
+	for _, definition := range definitions {
+		status := "active"
+		switch {
+		case definition.Pending():
+			status = "pending"
+		case definition.Disabled():
+			status = "disabled"
+		}
+
+		health := definition.Health()
+		state := string(health.State)
+		if state == "" {
+			state = "-"
+		}
+
+		metadata := definition.Metadata()
+		lastFetched := "never"
+		if !metadata.LastFetchedAt.IsZero() {
+			lastFetched = metadata.LastFetchedAt.UTC().Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			definition.PublicKey().Hex(),
+			status,
+			state,
+			health.ConsecutiveFailures,
+			lastFetched,
+			definition.Address().String(),
+			metadata.LastError,
+		)
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/piraces/rsslay/pkg/keyring"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+const keysCommand = "keys"
+
+const keysUsage = `usage: rsslay [-dsn <datasource name>] keys <command>
+
+Commands:
+  status  counts the private keys of feeds by secret and encryption version
+  rotate  encrypts all private keys with the newest KEY_ENCRYPTION_KEYS entry
+  <url>   prints the public key of the feed, or the one it would get once
+          created which requires SECRET`
+
+// keysConfig only contains the settings required to access the stored keys.
+type keysConfig struct {
+	Secret            string   `envconfig:"SECRET" default:""`
+	Secrets           []string `envconfig:"SECRETS" default:""`
+	DatabaseDirectory string   `envconfig:"DB_DIR" default:"db/rsslay.sqlite"`
+	KeyEncryptionKeys []string `envconfig:"KEY_ENCRYPTION_KEYS" default:""`
+}
+
+func runKeysCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(keysUsage)
+	}
+
+	var config keysConfig
+	if err := loadCommandSettings(&config); err != nil {
+		return err
+	}
+
+	keys, err := newKeyring(config.KeyEncryptionKeys)
+	if err != nil {
+		return err
+	}
+
+	connection := *dsn
+	if connection == "" {
+		connection = config.DatabaseDirectory
+	}
+
+	db := openDatabase(connection)
+	defer db.Close()
+
+	storage := adapters.NewFeedDefinitionStorage(db, keys)
+
+	switch args[0] {
+	case "status":
+		status, err := storage.PrivateKeyStatus()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SECRET VERSION\tFEEDS")
+		for _, version := range sortedVersions(status.KeyVersions) {
+			name := strconv.Itoa(version)
+			if version == 0 {
+				// imported with their private key from another instance
+				name = "imported"
+			}
+			fmt.Fprintf(w, "%s\t%d\n", name, status.KeyVersions[version])
+		}
+		fmt.Fprintln(w, "\nENCRYPTION KEY VERSION\tFEEDS")
+		for _, version := range sortedVersions(status.Encryption) {
+			name := strconv.Itoa(version)
+			switch {
+			case version == 0:
+				name = "plaintext"
+			case version == keys.Current():
+				name += " (current)"
+			}
+			fmt.Fprintf(w, "%s\t%d\n", name, status.Encryption[version])
+		}
+		_ = w.Flush()
+
+		if status.Missing > 0 {
+			fmt.Printf("\n%d feeds have no stored private key, their keys are derived by the bunker\n", status.Missing)
+		}
+		if outdated := status.Outdated(keys.Current()); outdated > 0 {
+			fmt.Printf("\n%d private keys aren't encrypted with the current key, run 'rsslay keys rotate'\n", outdated)
+		}
+		return nil
+	case "rotate":
+		reencrypted, err := storage.ReencryptPrivateKeys()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("encrypted %d private keys with key version %d\n", reencrypted, keys.Current())
+		return nil
+	default:
+		address, err := domainfeed.NewAddress(args[0])
+		if err != nil {
+			return errors.New(keysUsage)
+		}
+		return printFeedKeys(config, storage, address)
+	}
+}
+
+func printFeedKeys(config keysConfig, storage app.FeedDefinitionStorage, address domainfeed.Address) error {
+	if config.Secret == "" {
+		// the keys of existing feeds are known without the secrets
+		definition, err := storage.GetByAddress(address)
+		if errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+			return errors.New("SECRET is required to derive the keys of feeds which weren't created")
+		}
+		if err != nil {
+			return errors.Wrap(err, "error getting the feed")
+		}
+		printKeys(app.FeedKeys{PublicKey: definition.PublicKey(), KeyVersion: definition.KeyVersion(), Definition: definition}, address)
+		return nil
+	}
+
+	secrets, err := newSecrets(config.Secret, config.Secrets)
+	if err != nil {
+		return err
+	}
+
+	feedKeys, err := app.NewHandlerGetFeedKeys(app.NewSecretKeyDeriver(secrets), storage).Handle(address)
+	if err != nil {
+		return err
+	}
+
+	printKeys(feedKeys, address)
+	return nil
+}
+
+func printKeys(feedKeys app.FeedKeys, address domainfeed.Address) {
+	status := "not created, derived assuming that the url is the one of the feed"
+	if feedKeys.Definition != nil {
+		address = feedKeys.Definition.Address()
+		status = "created"
+	}
+
+	secretVersion := strconv.Itoa(feedKeys.KeyVersion)
+	if feedKeys.KeyVersion == 0 {
+		secretVersion = "imported"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "URL\t%s\n", address.String())
+	fmt.Fprintf(w, "NPUB\t%s\n", feedKeys.PublicKey.Nip19())
+	fmt.Fprintf(w, "PUBLIC KEY\t%s\n", feedKeys.PublicKey.Hex())
+	fmt.Fprintf(w, "SECRET VERSION\t%s\n", secretVersion)
+	fmt.Fprintf(w, "STATUS\t%s\n", status)
+	_ = w.Flush()
+}
+
+// newKeyring creates the keyring from "version:key" entries.
+func newKeyring(entries []string) (*keyring.Keyring, error) {
+	keys, err := parseVersioned("KEY_ENCRYPTION_KEYS", entries)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := keyring.New(keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid KEY_ENCRYPTION_KEYS")
+	}
+	return k, nil
+}
+
+// parseVersioned parses settings made of "version:value" entries.
+func parseVersioned(name string, entries []string) (map[int]string, error) {
+	values := make(map[int]string)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		s, value, ok := strings.Cut(entry, ":")
+		version, err := strconv.Atoi(s)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid %s entry, the format is version:value", name)
+		}
+
+		if _, ok := values[version]; ok {
+			return nil, fmt.Errorf("duplicated %s version %d", name, version)
+		}
+		values[version] = value
+	}
+	return values, nil
+}
+
+func sortedVersions(counts map[int]int) []int {
+	var versions []int
+	for version := range counts {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	_ "embed"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"sync"
@@ -18,53 +21,133 @@ import (
 	_ "github.com/fiatjaf/relayer"
 	"github.com/hashicorp/logutils"
 	"github.com/hellofresh/health-go/v5"
-	"github.com/kelseyhightower/envconfig"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip11"
 	"github.com/piraces/rsslay/internal/handlers"
+	"github.com/piraces/rsslay/pkg/config"
 	"github.com/piraces/rsslay/pkg/custom_cache"
+	"github.com/piraces/rsslay/pkg/database"
 	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/keyring"
 	"github.com/piraces/rsslay/pkg/metrics"
 	"github.com/piraces/rsslay/pkg/new/adapters"
 	pubsubadapters "github.com/piraces/rsslay/pkg/new/adapters/pubsub"
 	"github.com/piraces/rsslay/pkg/new/app"
 	"github.com/piraces/rsslay/pkg/new/domain"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
 	"github.com/piraces/rsslay/pkg/new/ports"
 	pubsub2 "github.com/piraces/rsslay/pkg/new/ports/pubsub"
+	"github.com/piraces/rsslay/pkg/nip46"
+	"github.com/piraces/rsslay/pkg/pow"
 	"github.com/piraces/rsslay/pkg/replayer"
-	"github.com/piraces/rsslay/scripts"
+	"github.com/piraces/rsslay/pkg/signer"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/slices"
 )
 
 // Command line flags.
 var (
-	dsn = flag.String("dsn", "", "datasource name")
+	dsn        = flag.String("dsn", "", "datasource name")
+	configFile = flag.String("config", "", "configuration file, defaults to CONFIG_FILE")
 )
 
-const assetsDir = "/assets/"
+const usage = `usage: rsslay [-dsn <datasource name>] [-config <file>] [<command>]
+
+Starts the relay unless a command is given, commands use the same settings.
+The settings are read from the environment and from the YAML configuration
+file, if any.
+
+Commands:
+  add      creates a feed
+  remove   deletes a feed
+  list     lists the feeds with their health
+  refresh  fetches a feed right away
+  convert  prints the events which a feed is converted to
+  keys     prints the keys of a feed and manages the private keys
+  migrate  manages the database schema
+  export   exports the feeds
+  import   imports the feeds of an export
+  bunker   signs the events of the feeds for the relay in a separate process
+
+Run 'rsslay <command> -h' for the usage of the command.`
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// commands are run instead of the relay.
+var commands = map[string]command{
+	addCommand:     {addUsage, runAddCommand},
+	removeCommand:  {removeUsage, runRemoveCommand},
+	listCommand:    {listUsage, runListCommand},
+	refreshCommand: {refreshUsage, runRefreshCommand},
+	convertCommand: {convertUsage, runConvertCommand},
+	keysCommand:    {keysUsage, runKeysCommand},
+	migrateCommand: {migrateUsage, runMigrateCommand},
+	exportCommand:  {exportUsage, runExportCommand},
+	importCommand:  {importUsage, runImportCommand},
+	bunkerCommand:  {bunkerUsage, runBunkerCommand},
+}
+
+const (
+	assetsDir              = "/assets/"
+	createFormChallengeTTL = time.Hour
+)
 
 type Relay struct {
-	Secret                          string   `envconfig:"SECRET" required:"true"`
+	Secret                          string   `envconfig:"SECRET" default:"" redact:"true"`              // required unless NIP46_BUNKER_URL is set
+	Secrets                         []string `envconfig:"SECRETS" default:"" redact:"true"`             // newer secrets as version:secret, SECRET is version 1
+	KeyEncryptionKeys               []string `envconfig:"KEY_ENCRYPTION_KEYS" default:"" redact:"true"` // as version:key, the newest one encrypts private keys
 	DatabaseDirectory               string   `envconfig:"DB_DIR" default:"db/rsslay.sqlite"`
 	DefaultProfilePictureUrl        string   `envconfig:"DEFAULT_PROFILE_PICTURE_URL" default:"https://i.imgur.com/MaceU96.png"`
 	Version                         string   `envconfig:"VERSION" default:"unknown"`
-	ReplayToRelays                  bool     `envconfig:"REPLAY_TO_RELAYS" default:"false"`
-	RelaysToPublish                 []string `envconfig:"RELAYS_TO_PUBLISH_TO" default:""`
-	NitterInstances                 []string `envconfig:"NITTER_INSTANCES" default:""`
-	DefaultWaitTimeBetweenBatches   int64    `envconfig:"DEFAULT_WAIT_TIME_BETWEEN_BATCHES" default:"60000"`
-	DefaultWaitTimeForRelayResponse int64    `envconfig:"DEFAULT_WAIT_TIME_FOR_RELAY_RESPONSE" default:"3000"`
-	MaxEventsToReplay               int      `envconfig:"MAX_EVENTS_TO_REPLAY" default:"20"`
+	ReplayToRelays                  bool     `envconfig:"REPLAY_TO_RELAYS" default:"false" reload:"true"`
+	RelaysToPublish                 []string `envconfig:"RELAYS_TO_PUBLISH_TO" default:"" reload:"true"`
+	NitterInstances                 []string `envconfig:"NITTER_INSTANCES" default:"" reload:"true"`
+	DefaultWaitTimeBetweenBatches   int64    `envconfig:"DEFAULT_WAIT_TIME_BETWEEN_BATCHES" default:"60000" reload:"true"`
+	DefaultWaitTimeForRelayResponse int64    `envconfig:"DEFAULT_WAIT_TIME_FOR_RELAY_RESPONSE" default:"3000" reload:"true"`
+	MaxEventsToReplay               int      `envconfig:"MAX_EVENTS_TO_REPLAY" default:"20" reload:"true"`
 	EnableAutoNIP05Registration     bool     `envconfig:"ENABLE_AUTO_NIP05_REGISTRATION" default:"false"`
 	MainDomainName                  string   `envconfig:"MAIN_DOMAIN_NAME" default:""`
 	OwnerPublicKey                  string   `envconfig:"OWNER_PUBLIC_KEY" default:""`
-	MaxSubroutines                  int      `envconfig:"MAX_SUBROUTINES" default:"20"`
+	MaxSubroutines                  int      `envconfig:"MAX_SUBROUTINES" default:"20" reload:"true"`
 	RelayName                       string   `envconfig:"INFO_RELAY_NAME" default:"rsslay"`
 	Contact                         string   `envconfig:"INFO_CONTACT" default:"~"`
 	MaxContentLength                int      `envconfig:"MAX_CONTENT_LENGTH" default:"250"`
-	DeleteFailingFeeds              bool     `envconfig:"DELETE_FAILING_FEEDS" default:"false"`
-	RedisConnectionString           string   `envconfig:"REDIS_CONNECTION_STRING" default:""`
+	DeleteFailingFeeds              bool     `envconfig:"DELETE_FAILING_FEEDS" default:"false"` // deprecated, use DELETE_FAILING_FEEDS_AFTER_DAYS
+	DeleteFailingFeedsAfterDays     int      `envconfig:"DELETE_FAILING_FEEDS_AFTER_DAYS" default:"0"`
+	FeedSuspendAfterFailures        int      `envconfig:"FEED_SUSPEND_AFTER_FAILURES" default:"5"`
+	FeedRetryBackoff                int64    `envconfig:"FEED_RETRY_BACKOFF" default:"3600000"`
+	FeedMaxRetryBackoff             int64    `envconfig:"FEED_MAX_RETRY_BACKOFF" default:"86400000"`
+	RedisConnectionString           string   `envconfig:"REDIS_CONNECTION_STRING" default:"" redact:"true"`
+	AcceptUserEvents                bool     `envconfig:"ACCEPT_USER_EVENTS" default:"false"`
+	UserEventsMaxContentLength      int      `envconfig:"USER_EVENTS_MAX_CONTENT_LENGTH" default:"4096"`
+	UserEventsMaxTags               int      `envconfig:"USER_EVENTS_MAX_TAGS" default:"50"`
+	UserEventsMinPow                int      `envconfig:"USER_EVENTS_MIN_POW" default:"0"`
+	UserEventsRateLimit             int      `envconfig:"USER_EVENTS_RATE_LIMIT" default:"10" reload:"true"`
+	UserEventsRateLimitWindow       int64    `envconfig:"USER_EVENTS_RATE_LIMIT_WINDOW" default:"60000" reload:"true"`
+	EnableBot                       bool     `envconfig:"ENABLE_BOT" default:"false"`
+	BotRateLimit                    int      `envconfig:"BOT_RATE_LIMIT" default:"5" reload:"true"`
+	BotRateLimitWindow              int64    `envconfig:"BOT_RATE_LIMIT_WINDOW" default:"60000" reload:"true"`
+	BotPrivateKey                   string   `envconfig:"BOT_PRIVATE_KEY" default:"" redact:"true"`  // derived from SECRET if empty
+	Nip46BunkerUrl                  string   `envconfig:"NIP46_BUNKER_URL" default:"" redact:"true"` // signs the feed events with a remote signer instead of in process
+	Nip46Timeout                    int64    `envconfig:"NIP46_TIMEOUT" default:"10000"`
+	MaxFeeds                        int      `envconfig:"MAX_FEEDS" default:"0"`
+	ModerateFeeds                   bool     `envconfig:"MODERATE_FEEDS" default:"false"`
+	FeedCreationRateLimit           int      `envconfig:"FEED_CREATION_RATE_LIMIT" default:"10" reload:"true"`
+	FeedCreationRateLimitWindow     int64    `envconfig:"FEED_CREATION_RATE_LIMIT_WINDOW" default:"3600000" reload:"true"`
+	ImportRateLimit                 int      `envconfig:"IMPORT_RATE_LIMIT" default:"2" reload:"true"`
+	ImportRateLimitWindow           int64    `envconfig:"IMPORT_RATE_LIMIT_WINDOW" default:"3600000" reload:"true"`
+	CreateFormPowDifficulty         int      `envconfig:"CREATE_FORM_POW_DIFFICULTY" default:"0"`
+	ClientIPHeader                  string   `envconfig:"CLIENT_IP_HEADER" default:""` // set by the reverse proxy, for example Fly-Client-IP
+	APIRateLimit                    int      `envconfig:"API_RATE_LIMIT" default:"120" reload:"true"`
+	APIRateLimitWindow              int64    `envconfig:"API_RATE_LIMIT_WINDOW" default:"60000" reload:"true"`
+	BannedDomains                   []string `envconfig:"BANNED_DOMAINS" default:"" reload:"true"`
+	AddressRules                    []string `envconfig:"ADDRESS_RULES" default:"" reload:"true"` // as action:pattern, for example deny:*.example.com/spam/*
+	TemplatesDirectory              string   `envconfig:"TEMPLATES_DIR" default:"" reload:"true"` // templates of the web pages replacing the built-in ones
+	ConfigWatch                     bool     `envconfig:"CONFIG_WATCH" default:"true"`            // reloads the configuration file when it changes, it is always reloaded on SIGHUP
 
 	updates            chan nostr.Event
 	db                 *sql.DB
@@ -75,6 +158,13 @@ type Relay struct {
 	cache              *cache.Cache[string]
 	handler            *handlers.Handler
 	store              *store
+	signer             signer.Signer
+
+	// settingsLock guards the settings which change when the configuration
+	// is reloaded
+	settingsLock    sync.RWMutex
+	config          config.Config
+	restartRequired []string
 }
 
 var relayInstance = &Relay{
@@ -118,24 +208,50 @@ func (r *Relay) Name() string {
 }
 
 func (r *Relay) OnInitialized(s *relayer.Server) {
+	s.Router().Path("/").Methods(http.MethodPost).Headers("Content-Type", handlers.ManagementContentType).HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleManagement(writer, request, &r.OwnerPublicKey)
+	})
 	s.Router().Path("/").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		r.handler.HandleWebpage(writer, request, &r.MainDomainName)
 	})
 	s.Router().Path("/create").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		r.handler.HandleCreateFeed(writer, request, dsn)
 	})
+	s.Router().Path("/import").Methods(http.MethodGet, http.MethodPost).HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleImport(writer, request, dsn)
+	})
 	s.Router().Path("/search").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		r.handler.HandleSearch(writer, request)
 	})
+	s.Router().Path("/preview").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandlePreview(writer, request)
+	})
+	s.Router().Path("/moderation").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleModeration(writer, request)
+	})
+	s.Router().Path("/p/{npub}").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleProfilePage(writer, request, &r.MainDomainName)
+	})
+	s.Router().Path("/e/{pointer}").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleItemPage(writer, request, &r.MainDomainName)
+	})
 	s.Router().
 		PathPrefix(assetsDir).
 		Handler(http.StripPrefix(assetsDir, http.FileServer(http.Dir("./web/"+assetsDir))))
 	s.Router().Path("/healthz").HandlerFunc(relayInstance.healthCheck.HandlerFunc)
-	s.Router().Path("/api/feed").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+	apiMiddleware := r.handler.APIMiddleware(&r.OwnerPublicKey)
+	s.Router().Path("/api/feed").Handler(apiMiddleware(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		r.handler.HandleApiFeed(writer, request, dsn)
-	})
+	})))
+	r.handler.RegisterAPIv1(s.Router(), &r.OwnerPublicKey, dsn)
+	s.Router().Path("/api/feed/owner").Handler(apiMiddleware(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleFeedOwner(writer, request)
+	})))
+	s.Router().Path("/api/feed/profile").Handler(apiMiddleware(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		r.handler.HandleFeedProfile(writer, request)
+	})))
 	s.Router().Path("/.well-known/nostr.json").HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		handlers.HandleNip05(writer, request, r.db, &r.OwnerPublicKey, &r.EnableAutoNIP05Registration)
+		r.handler.HandleNip05(writer, request, &r.OwnerPublicKey, &r.EnableAutoNIP05Registration, &r.MainDomainName)
 	})
 	s.Router().Path("/metrics").Handler(promhttp.Handler())
 }
@@ -144,69 +260,322 @@ func (r *Relay) Init() error {
 	ctx := context.TODO()
 
 	flag.Parse()
-	err := envconfig.Process("", r)
-	if err != nil {
-		return fmt.Errorf("couldn't process envconfig: %w", err)
-	} else {
-		log.Printf("[INFO] Running VERSION %s:\n - DSN=%s\n - DB_DIR=%s\n\n", r.Version, *dsn, r.DatabaseDirectory)
+	if err := r.loadSettings(); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Running VERSION %s:\n - DSN=%s\n - DB_DIR=%s\n\n", r.Version, redactDSN(*dsn), r.DatabaseDirectory)
+	if r.config.File != "" {
+		log.Printf("[INFO] configuration file %s loaded", r.config.File)
 	}
 
 	ConfigureCache()
 
 	db := InitDatabase(r)
-	feedDefinitionStorage := adapters.NewFeedDefinitionStorage(db)
-	eventStorage := adapters.NewEventStorage()
+	keys, err := newKeyring(r.KeyEncryptionKeys)
+	if err != nil {
+		return err
+	}
+
+	feedDefinitionStorage := r.newFeedDefinitionStorage(db, keys)
+	filled, err := feedDefinitionStorage.FillCanonicalAddresses()
+	if err != nil {
+		return errors.Wrap(err, "error filling the canonical addresses of the feeds")
+	}
+	if filled > 0 {
+		log.Printf("[INFO] filled the canonical addresses of %d feeds", filled)
+	}
+	// the bunker is the only one decrypting the private keys
+	if r.Nip46BunkerUrl == "" {
+		if err := checkPrivateKeys(feedDefinitionStorage, keys); err != nil {
+			return err
+		}
+	}
+
+	eventStorage := newEventStorage(databaseConnection(r), db)
+	userEventStorage := adapters.NewUserEventStorage(db)
+	bannedDomainStorage := adapters.NewBannedDomainStorage(db)
+	addressRuleStorage := adapters.NewAddressRuleStorage(db)
+	apiKeyStorage := adapters.NewAPIKeyStorage(db)
+	auditLogStorage := adapters.NewAuditLogStorage(db)
 	receivedEventPubSub := pubsubadapters.NewReceivedEventPubSub()
 
-	secret, err := domain.NewSecret(r.Secret)
+	var keyDeriver app.KeyDeriver
+	r.signer, keyDeriver, err = r.newSigner(ctx)
 	if err != nil {
-		return errors.Wrap(err, "error creating a secret")
+		return errors.Wrap(err, "error creating the signer")
 	}
 
-	r.converterSelector = feed.NewConverterSelector(feed.NewLongFormConverter())
+	botPrivateKey, err := r.botPrivateKey()
+	if err != nil {
+		return err
+	}
 
-	handlerCreateFeedDefinition := app.NewHandlerCreateFeedDefinition(secret, feedDefinitionStorage)
-	handlerUpdateFeeds := app.NewHandlerUpdateFeeds(
-		r.DeleteFailingFeeds,
-		r.NitterInstances,
-		r.EnableAutoNIP05Registration,
-		r.DefaultProfilePictureUrl,
+	r.converterSelector, err = r.newConverterSelector()
+	if err != nil {
+		return err
+	}
+
+	ownerSigners := r.newOwnerSigners()
+
+	handlerCreateFeedDefinition := r.newHandlerCreateFeedDefinition(keyDeriver, feedDefinitionStorage, bannedDomainStorage, addressRuleStorage)
+	handlerImportFeeds := app.NewHandlerImportFeeds(
+		app.ImportPolicy{
+			RateLimit:       r.ImportRateLimit,
+			RateLimitWindow: time.Duration(r.ImportRateLimitWindow) * time.Millisecond,
+		},
+		handlerCreateFeedDefinition,
+	)
+	handlerImportFeedDefinitions := app.NewHandlerImportFeedDefinitions(keyDeriver, feedDefinitionStorage, bannedDomainStorage, addressRuleStorage)
+	handlerUpdateFeeds := r.newHandlerUpdateFeeds(db, feedDefinitionStorage, eventStorage, receivedEventPubSub, bannedDomainStorage, ownerSigners)
+	handlerSaveUserEvent := app.NewHandlerSaveUserEvent(
+		app.UserEventPolicy{
+			MaxContentLength: r.UserEventsMaxContentLength,
+			MaxTags:          r.UserEventsMaxTags,
+			MinPow:           r.UserEventsMinPow,
+			RateLimit:        r.UserEventsRateLimit,
+			RateLimitWindow:  time.Duration(r.UserEventsRateLimitWindow) * time.Millisecond,
+		},
+		feedDefinitionStorage,
+		eventStorage,
+		userEventStorage,
+	)
+	handlerProcessDirectMessage, err := app.NewHandlerProcessDirectMessage(
+		keyDeriver,
+		botPrivateKey,
 		r.MainDomainName,
-		db,
+		r.BotRateLimit,
+		time.Duration(r.BotRateLimitWindow)*time.Millisecond,
+		handlerCreateFeedDefinition,
 		feedDefinitionStorage,
-		r.converterSelector,
 		eventStorage,
-		receivedEventPubSub,
+		userEventStorage,
+		r.updates,
 	)
-	handlerGetEvents := app.NewHandlerGetEvents(eventStorage)
+	if err != nil {
+		return errors.Wrap(err, "error creating the bot")
+	}
+	// the submitters of feeds are told about the decisions of the moderators
+	// through the bot
+	var submitterNotifier app.SubmitterNotifier
+	if r.EnableBot {
+		submitterNotifier = handlerProcessDirectMessage
+	}
+	handlerApproveFeed := app.NewHandlerApproveFeed(feedDefinitionStorage, handlerUpdateFeeds, submitterNotifier)
+	handlerRejectFeed := app.NewHandlerRejectFeed(feedDefinitionStorage, submitterNotifier)
+	handlerSetFeedSlug := app.NewHandlerSetFeedSlug(feedDefinitionStorage)
+	handlerAssignFeedSlugs := app.NewHandlerAssignFeedSlugs(feedDefinitionStorage)
+	handlerSetFeedDisabled := app.NewHandlerSetFeedDisabled(feedDefinitionStorage, eventStorage)
+	handlerDeleteFeed := app.NewHandlerDeleteFeed(feedDefinitionStorage, eventStorage)
+	handlerRefreshFeed := app.NewHandlerRefreshFeed(feedDefinitionStorage, handlerUpdateFeeds)
+	handlerRegisterFeedOwner := app.NewHandlerRegisterFeedOwner(feedDefinitionStorage, eventStorage, ownerSigners, handlerUpdateFeeds)
+	handlerRemoveFeedOwner := app.NewHandlerRemoveFeedOwner(feedDefinitionStorage, eventStorage, ownerSigners, handlerUpdateFeeds)
+	handlerSetFeedProfile := app.NewHandlerSetFeedProfile(feedDefinitionStorage, handlerUpdateFeeds)
+	handlerBanDomain := app.NewHandlerBanDomain(bannedDomainStorage)
+	handlerAllowDomain := app.NewHandlerAllowDomain(bannedDomainStorage)
+	handlerAddAddressRule := app.NewHandlerAddAddressRule(addressRuleStorage)
+	handlerRemoveAddressRule := app.NewHandlerRemoveAddressRule(addressRuleStorage)
+	handlerApplyConfiguredDenyLists := app.NewHandlerApplyConfiguredDenyLists(bannedDomainStorage, addressRuleStorage)
+	handlerCreateAPIKey := app.NewHandlerCreateAPIKey(apiKeyStorage)
+	handlerRevokeAPIKey := app.NewHandlerRevokeAPIKey(apiKeyStorage)
+	handlerAddAuditLogEntry := app.NewHandlerAddAuditLogEntry(auditLogStorage)
+	handlerGetEvents := app.NewHandlerGetEvents(eventStorage, userEventStorage)
 	handlerOnNewEventCreated := app.NewHandlerOnNewEventCreated(r.updates)
 	handlerGetTotalFeedCount := app.NewHandlerGetTotalFeedCount(feedDefinitionStorage)
 	handlerGetRandomFeeds := app.NewHandlerGetRandomFeeds(feedDefinitionStorage)
 	handlerSearchFeeds := app.NewHandlerSearchFeeds(feedDefinitionStorage)
+	handlerListFeeds := app.NewHandlerListFeeds(feedDefinitionStorage)
+	handlerListFeedsPage := app.NewHandlerListFeedsPage(feedDefinitionStorage)
+	handlerGetFeed := app.NewHandlerGetFeed(feedDefinitionStorage)
+	handlerGetFeedItem := app.NewHandlerGetFeedItem(feedDefinitionStorage, eventStorage)
+	handlerPreviewFeed := app.NewHandlerPreviewFeed(
+		r.EnableAutoNIP05Registration,
+		r.DefaultProfilePictureUrl,
+		r.MainDomainName,
+		r.ModerateFeeds,
+		keyDeriver,
+		feedDefinitionStorage,
+		bannedDomainStorage,
+		addressRuleStorage,
+		r.converterSelector,
+	)
+	handlerListBannedDomains := app.NewHandlerListBannedDomains(bannedDomainStorage)
+	handlerListAddressRules := app.NewHandlerListAddressRules(addressRuleStorage)
+	handlerListAPIKeys := app.NewHandlerListAPIKeys(apiKeyStorage)
+	handlerAuthenticateAPIKey := app.NewHandlerAuthenticateAPIKey(apiKeyStorage)
+	handlerListFailingFeeds := app.NewHandlerListFailingFeeds(feedDefinitionStorage)
+	handlerListDuplicateFeeds := app.NewHandlerListDuplicateFeeds(feedDefinitionStorage)
+	handlerGetFeedBySlug := app.NewHandlerGetFeedBySlug(feedDefinitionStorage)
+	handlerExportFeedDefinitions := app.NewHandlerExportFeedDefinitions(feedDefinitionStorage)
 
 	updateFeedsTimer := ports.NewUpdateFeedsTimer(handlerUpdateFeeds)
 	receivedEventSubscriber := pubsub2.NewReceivedEventSubscriber(receivedEventPubSub, handlerOnNewEventCreated)
 
 	app := app.App{
-		CreateFeedDefinition: handlerCreateFeedDefinition,
-		UpdateFeeds:          handlerUpdateFeeds,
-		GetEvents:            handlerGetEvents,
-		GetTotalFeedCount:    handlerGetTotalFeedCount,
-		GetRandomFeeds:       handlerGetRandomFeeds,
-		SearchFeeds:          handlerSearchFeeds,
+		CreateFeedDefinition:     handlerCreateFeedDefinition,
+		ImportFeeds:              handlerImportFeeds,
+		ImportFeedDefinitions:    handlerImportFeedDefinitions,
+		UpdateFeeds:              handlerUpdateFeeds,
+		SaveUserEvent:            handlerSaveUserEvent,
+		SetFeedDisabled:          handlerSetFeedDisabled,
+		DeleteFeed:               handlerDeleteFeed,
+		RefreshFeed:              handlerRefreshFeed,
+		ApproveFeed:              handlerApproveFeed,
+		RejectFeed:               handlerRejectFeed,
+		RegisterFeedOwner:        handlerRegisterFeedOwner,
+		RemoveFeedOwner:          handlerRemoveFeedOwner,
+		SetFeedProfile:           handlerSetFeedProfile,
+		BanDomain:                handlerBanDomain,
+		AllowDomain:              handlerAllowDomain,
+		AddAddressRule:           handlerAddAddressRule,
+		RemoveAddressRule:        handlerRemoveAddressRule,
+		ApplyConfiguredDenyLists: handlerApplyConfiguredDenyLists,
+		CreateAPIKey:             handlerCreateAPIKey,
+		RevokeAPIKey:             handlerRevokeAPIKey,
+		AddAuditLogEntry:         handlerAddAuditLogEntry,
+		ProcessDirectMessage:     handlerProcessDirectMessage,
+		SetFeedSlug:              handlerSetFeedSlug,
+		AssignFeedSlugs:          handlerAssignFeedSlugs,
+		GetEvents:                handlerGetEvents,
+		GetTotalFeedCount:        handlerGetTotalFeedCount,
+		GetRandomFeeds:           handlerGetRandomFeeds,
+		SearchFeeds:              handlerSearchFeeds,
+		ListFeeds:                handlerListFeeds,
+		ListFeedsPage:            handlerListFeedsPage,
+		GetFeedItem:              handlerGetFeedItem,
+		GetFeed:                  handlerGetFeed,
+		PreviewFeed:              handlerPreviewFeed,
+		ListBannedDomains:        handlerListBannedDomains,
+		ListAddressRules:         handlerListAddressRules,
+		ListAPIKeys:              handlerListAPIKeys,
+		AuthenticateAPIKey:       handlerAuthenticateAPIKey,
+		ListFailingFeeds:         handlerListFailingFeeds,
+		ListDuplicateFeeds:       handlerListDuplicateFeeds,
+		GetFeedBySlug:            handlerGetFeedBySlug,
+		ExportFeedDefinitions:    handlerExportFeedDefinitions,
+	}
+
+	if err := r.applyDenyLists(handlerApplyConfiguredDenyLists); err != nil {
+		return err
+	}
+
+	templates, err := handlers.ParseTemplates(r.TemplatesDirectory)
+	if err != nil {
+		return err
+	}
+
+	if err := handlerAssignFeedSlugs.Handle(); err != nil {
+		return errors.Wrap(err, "error assigning slugs to feeds")
+	}
+
+	if r.EnableBot {
+		if err := handlerProcessDirectMessage.PublishProfile(r.RelayName); err != nil {
+			return errors.Wrap(err, "error publishing the bot profile")
+		}
+		log.Printf("[INFO] bot enabled, send direct messages to %s", handlerProcessDirectMessage.PublicKey().Nip19())
 	}
 
 	r.db = db
-	r.handler = handlers.NewHandler(app)
-	r.store = newStore(app)
+	r.handler = handlers.NewHandler(
+		app,
+		pow.New(r.challengeSecret(), r.CreateFormPowDifficulty, createFormChallengeTTL),
+		r.ClientIPHeader,
+		handlers.APIQuota{Limit: r.APIRateLimit, Window: time.Duration(r.APIRateLimitWindow) * time.Millisecond},
+		templates,
+		r,
+	)
+	r.store = newStore(app, r.EnableBot)
 
 	go updateFeedsTimer.Run(ctx)
 	go receivedEventSubscriber.Run(ctx)
+	if r.config.File != "" {
+		go r.watchConfig(ctx)
+	}
 
 	return nil
 }
 
+// feedRelays lists the relays where the events of the feeds can be found.
+func (r *Relay) feedRelays() []string {
+	var relays []string
+	if r.MainDomainName != "" {
+		relays = append(relays, "wss://"+r.MainDomainName)
+	}
+	if r.ReplayToRelays {
+		for _, relay := range r.RelaysToPublish {
+			if relay != "" && !slices.Contains(relays, relay) {
+				relays = append(relays, relay)
+			}
+		}
+	}
+	return relays
+}
+
+func (r *Relay) newConverterSelector() (*feed.ConverterSelector, error) {
+	noteConverter, err := feed.NewNoteConverter(r.MaxContentLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the note converter")
+	}
+	return feed.NewConverterSelector(feed.NewLongFormConverter(), noteConverter), nil
+}
+
+// newOwnerSigners connects to the remote signers of the feed owners.
+func (r *Relay) newOwnerSigners() *nip46.Pool {
+	return nip46.NewPool(time.Duration(r.Nip46Timeout)*time.Millisecond, func(relay string) nip46.Transport {
+		return nip46.NewRelayTransport(relay)
+	})
+}
+
+func (r *Relay) newHandlerCreateFeedDefinition(
+	keyDeriver app.KeyDeriver,
+	feedDefinitionStorage app.FeedDefinitionStorage,
+	bannedDomainStorage app.BannedDomainStorage,
+	addressRuleStorage app.AddressRuleStorage,
+) *app.HandlerCreateFeedDefinition {
+	return app.NewHandlerCreateFeedDefinition(
+		app.FeedCreationPolicy{
+			MaxFeeds:        r.MaxFeeds,
+			RateLimit:       r.FeedCreationRateLimit,
+			RateLimitWindow: time.Duration(r.FeedCreationRateLimitWindow) * time.Millisecond,
+			Moderated:       r.ModerateFeeds,
+		},
+		keyDeriver,
+		feedDefinitionStorage,
+		bannedDomainStorage,
+		addressRuleStorage,
+	)
+}
+
+// newHandlerUpdateFeeds requires the converter selector and the signer.
+func (r *Relay) newHandlerUpdateFeeds(
+	db *sql.DB,
+	feedDefinitionStorage app.FeedDefinitionStorage,
+	eventStorage app.EventStorage,
+	eventPublisher app.EventPublisher,
+	bannedDomainStorage app.BannedDomainStorage,
+	ownerSigners app.OwnerSigners,
+) *app.HandlerUpdateFeeds {
+	return app.NewHandlerUpdateFeeds(
+		r.healthPolicy(),
+		r.NitterInstances,
+		r.EnableAutoNIP05Registration,
+		r.DefaultProfilePictureUrl,
+		r.MainDomainName,
+		r.feedRelays(),
+		db,
+		feedDefinitionStorage,
+		r.converterSelector,
+		eventStorage,
+		eventPublisher,
+		bannedDomainStorage,
+		r.signer,
+		ownerSigners,
+	)
+}
+
 func (r *Relay) AttemptReplayEvents(events []replayer.EventWithPrivateKey) {
+	relayInstance.settingsLock.RLock()
+	defer relayInstance.settingsLock.RUnlock()
+
 	if relayInstance.ReplayToRelays && relayInstance.routineQueueLength < relayInstance.MaxSubroutines && len(events) > 0 {
 		r.routineQueueLength++
 		metrics.ReplayRoutineQueueLength.Set(float64(r.routineQueueLength))
@@ -218,11 +587,20 @@ func (r *Relay) AttemptReplayEvents(events []replayer.EventWithPrivateKey) {
 			WaitTime:                 relayInstance.DefaultWaitTimeBetweenBatches,
 			WaitTimeForRelayResponse: relayInstance.DefaultWaitTimeForRelayResponse,
 			Events:                   events,
+			Signer:                   r.signer,
 		})
 	}
 }
 
-func (r *Relay) AcceptEvent(_ *nostr.Event) bool {
+func (r *Relay) AcceptEvent(event *nostr.Event) bool {
+	if r.EnableBot && r.store.app.ProcessDirectMessage.IsAddressedToBot(event) {
+		return true
+	}
+	// the rest of the policy is enforced when saving so that clients get a
+	// meaningful rejection message
+	if r.AcceptUserEvents && slices.Contains(app.UserEventKinds, event.Kind) {
+		return true
+	}
 	metrics.InvalidEventsRequests.Inc()
 	return false
 }
@@ -242,11 +620,19 @@ func (r *Relay) GetNIP11InformationDocument() nip11.RelayInformationDocument {
 		Description:   "Relay that creates virtual nostr profiles for each RSS feed submitted, powered by the relayer framework",
 		PubKey:        relayInstance.OwnerPublicKey,
 		Contact:       relayInstance.Contact,
-		SupportedNIPs: []int{5, 9, 11, 12, 15, 16, 19, 20},
+		SupportedNIPs: []int{5, 9, 11, 12, 15, 16, 19, 20, 24, 65, 86},
 		Software:      "git+https://github.com/piraces/rsslay.git",
 		Version:       relayInstance.Version,
 	}
 
+	if relayInstance.AcceptUserEvents && relayInstance.UserEventsMinPow > 0 {
+		infoDocument.SupportedNIPs = append(infoDocument.SupportedNIPs, 13)
+	}
+
+	if relayInstance.EnableBot {
+		infoDocument.SupportedNIPs = append(infoDocument.SupportedNIPs, 4, 17, 44, 59)
+	}
+
 	if relayInstance.OwnerPublicKey == "" {
 		infoDocument.PubKey = "~"
 	}
@@ -255,6 +641,24 @@ func (r *Relay) GetNIP11InformationDocument() nip11.RelayInformationDocument {
 }
 
 func main() {
+	flag.Usage = func() { fmt.Fprintln(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+	if flag.NArg() > 0 {
+		cmd, ok := commands[flag.Arg(0)]
+		if !ok {
+			log.Fatalf("[FATAL] unknown command %q\n\n%s", flag.Arg(0), usage)
+		}
+		args := flag.Args()[1:]
+		if len(args) == 1 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			fmt.Println(cmd.usage)
+			return
+		}
+		if err := cmd.run(args); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+		return
+	}
+
 	CreateHealthCheck()
 	ConfigureLogging()
 	defer func(db *sql.DB) {
@@ -270,38 +674,204 @@ func main() {
 }
 
 func InitDatabase(r *Relay) *sql.DB {
-	finalConnection := dsn
 	if *dsn == "" {
 		log.Print("[INFO] dsn required is not present... defaulting to DB_DIR")
-		finalConnection = &r.DatabaseDirectory
 	}
 
-	// Create empty dir if not exists
-	dbPath := path.Dir(*finalConnection)
-	err := os.MkdirAll(dbPath, 0660)
+	finalConnection := databaseConnection(r)
+	sqlDb := openDatabase(finalConnection)
+
+	migrator, err := database.NewMigrator(sqlDb, finalConnection)
 	if err != nil {
-		log.Printf("[INFO] unable to initialize DB_DIR at: %s. Error: %v", dbPath, err)
+		log.Fatalf("[FATAL] cannot load migrations: %v", err)
 	}
 
-	// Connect to SQLite database.
-	sqlDb, err := sql.Open("sqlite3", *finalConnection)
+	applied, err := migrator.Up()
 	if err != nil {
-		log.Fatalf("[FATAL] open db: %v", err)
+		log.Fatalf("[FATAL] cannot migrate schema: %v", err)
 	}
 
-	log.Printf("[INFO] database opened at %s", *finalConnection)
+	for _, migration := range applied {
+		log.Printf("[INFO] applied migration %d (%s)", migration.Version, migration.Name)
+	}
 
-	// Run migrations
-	if _, err := sqlDb.Exec(scripts.SchemaSQL); err != nil {
-		log.Fatalf("[FATAL] cannot migrate schema: %v", err)
+	return sqlDb
+}
+
+// botPrivateKey is derived from SECRET rather than from the newest secret so
+// that adding SECRETS doesn't change the npub of the bot.
+func (r *Relay) botPrivateKey() (string, error) {
+	if r.BotPrivateKey != "" {
+		return r.BotPrivateKey, nil
+	}
+	if r.Secret == "" {
+		// the bot is disabled, which validate makes sure of
+		return nostr.GeneratePrivateKey(), nil
 	}
 
-	if _, err := sqlDb.Exec(scripts.CheckNitterColumnSQL); err != nil {
-		_, err := sqlDb.Exec(scripts.CreateNitterColumnSQL)
+	secrets, err := r.secrets()
+	if err != nil {
+		return "", err
+	}
+	first, _ := secrets.Version(1)
+	return app.BotPrivateKey(first.Secret), nil
+}
+
+// challengeSecret signs the proof of work challenges of the creation form,
+// without SECRET the challenges are only valid until the relay restarts.
+func (r *Relay) challengeSecret() string {
+	if r.Secret != "" {
+		return r.Secret
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (r *Relay) secrets() (domain.Secrets, error) {
+	return newSecrets(r.Secret, r.Secrets)
+}
+
+// newSecrets returns SECRET as version 1 along with the newer SECRETS.
+func newSecrets(secret string, secrets []string) (domain.Secrets, error) {
+	entries, err := parseVersioned("SECRETS", secrets)
+	if err != nil {
+		return domain.Secrets{}, err
+	}
+
+	if _, ok := entries[1]; ok {
+		return domain.Secrets{}, errors.New("SECRETS can't contain version 1 as it is SECRET")
+	}
+	entries[1] = secret
+
+	var versions []domain.VersionedSecret
+	for version, s := range entries {
+		secret, err := domain.NewSecret(s)
 		if err != nil {
-			log.Fatalf("[FATAL] cannot migrate schema from previous versions: %v", err)
+			return domain.Secrets{}, errors.Wrapf(err, "invalid secret version %d", version)
 		}
+		versions = append(versions, domain.VersionedSecret{Version: version, Secret: secret})
 	}
 
+	return domain.NewSecrets(versions...)
+}
+
+// newSigner signs the events of the feeds in process unless a NIP-46 bunker
+// is configured.
+// newSigner returns the signer of the feed events along with the deriver of
+// the keys of the feeds. Both are in process unless NIP46_BUNKER_URL is set,
+// in which case the bunker holds the secrets and SECRET isn't needed.
+func (r *Relay) newSigner(ctx context.Context) (signer.Signer, app.KeyDeriver, error) {
+	if r.Nip46BunkerUrl == "" {
+		secrets, err := r.secrets()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error creating the secrets")
+		}
+		return signer.NewLocal(), app.NewSecretKeyDeriver(secrets), nil
+	}
+
+	bunkerURL, err := nip46.ParseBunkerURL(r.Nip46BunkerUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport := nip46.NewRelayTransport(bunkerURL.Relays[0])
+	client, err := nip46.NewClient(transport, bunkerURL.PublicKey, bunkerURL.Secret, time.Duration(r.Nip46Timeout)*time.Millisecond)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := client.Connect(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("[INFO] signing events with the bunker %s through %s", bunkerURL.PublicKey, bunkerURL.Relays[0])
+	return client, adapters.NewBunkerKeyDeriver(client), nil
+}
+
+// newFeedDefinitionStorage doesn't load the private keys of the feeds when
+// the events are signed by a bunker.
+func (r *Relay) newFeedDefinitionStorage(db *sql.DB, keys *keyring.Keyring) *adapters.FeedDefinitionStorage {
+	if r.Nip46BunkerUrl != "" {
+		return adapters.NewFeedDefinitionStorageWithoutPrivateKeys(db, keys)
+	}
+	return adapters.NewFeedDefinitionStorage(db, keys)
+}
+
+// checkPrivateKeys makes sure that all the private keys can be decrypted.
+func checkPrivateKeys(storage *adapters.FeedDefinitionStorage, keys *keyring.Keyring) error {
+	status, err := storage.PrivateKeyStatus()
+	if err != nil {
+		return errors.Wrap(err, "error checking the private keys")
+	}
+
+	for version, count := range status.Encryption {
+		if !keys.HasVersion(version) {
+			return fmt.Errorf("%d private keys are encrypted with key version %d which isn't in KEY_ENCRYPTION_KEYS", count, version)
+		}
+	}
+
+	if outdated := status.Outdated(keys.Current()); outdated > 0 && keys.Current() > 0 {
+		log.Printf("[WARN] %d private keys aren't encrypted with the current key encryption key, run 'rsslay keys rotate'", outdated)
+	}
+	return nil
+}
+
+func (r *Relay) healthPolicy() domainfeed.HealthPolicy {
+	deleteAfterDays := r.DeleteFailingFeedsAfterDays
+	if r.DeleteFailingFeeds && deleteAfterDays == 0 {
+		log.Print("[WARN] DELETE_FAILING_FEEDS is deprecated, failing feeds will be deleted after 30 days, use DELETE_FAILING_FEEDS_AFTER_DAYS instead")
+		deleteAfterDays = 30
+	}
+
+	return domainfeed.HealthPolicy{
+		SuspendAfter: r.FeedSuspendAfterFailures,
+		Backoff:      time.Duration(r.FeedRetryBackoff) * time.Millisecond,
+		MaxBackoff:   time.Duration(r.FeedMaxRetryBackoff) * time.Millisecond,
+		DeleteAfter:  time.Duration(deleteAfterDays) * 24 * time.Hour,
+	}
+}
+
+func databaseConnection(r *Relay) string {
+	if *dsn == "" {
+		return r.DatabaseDirectory
+	}
+	return *dsn
+}
+
+// newEventStorage keeps feed events in memory when using SQLite, a PostgreSQL
+// database may be shared by several instances so they are stored in it.
+func newEventStorage(connection string, db *sql.DB) app.EventStorage {
+	if database.DialectOf(connection) == database.Postgres {
+		return adapters.NewSQLEventStorage(db)
+	}
+	return adapters.NewEventStorage()
+}
+
+func openDatabase(connection string) *sql.DB {
+	if database.DialectOf(connection) == database.SQLite {
+		// Create empty dir if not exists
+		dbPath := path.Dir(connection)
+		err := os.MkdirAll(dbPath, 0660)
+		if err != nil {
+			log.Printf("[INFO] unable to initialize DB_DIR at: %s. Error: %v", dbPath, err)
+		}
+	}
+
+	sqlDb, err := database.Open(connection)
+	if err != nil {
+		log.Fatalf("[FATAL] open db: %v", err)
+	}
+
+	log.Printf("[INFO] database opened at %s", redactDSN(connection))
+
 	return sqlDb
 }
+
+// redactDSN hides the password of PostgreSQL datasource names.
+func redactDSN(connection string) string {
+	u, err := url.Parse(connection)
+	if err != nil || u.User == nil {
+		return connection
+	}
+	return u.Redacted()
+}
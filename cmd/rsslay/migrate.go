@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/piraces/rsslay/pkg/database"
+	"github.com/pkg/errors"
+)
+
+const migrateCommand = "migrate"
+
+const migrateUsage = `usage: rsslay [-dsn <datasource name>] migrate <command>
+
+Commands:
+  status  lists the migrations and whether they were applied
+  up      applies all pending migrations`
+
+// migrateConfig only contains the settings required to find the database so
+// that migrations can be managed without configuring the whole relay.
+type migrateConfig struct {
+	DatabaseDirectory string `envconfig:"DB_DIR" default:"db/rsslay.sqlite"`
+}
+
+func runMigrateCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	var config migrateConfig
+	if err := loadCommandSettings(&config); err != nil {
+		return err
+	}
+
+	connection := *dsn
+	if connection == "" {
+		connection = config.DatabaseDirectory
+	}
+
+	db := openDatabase(connection)
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, connection)
+	if err != nil {
+		return errors.Wrap(err, "cannot load migrations")
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := migrator.Status()
+		if status != nil {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+			for _, s := range status {
+				appliedAt := "pending"
+				if s.Applied {
+					appliedAt = "unknown"
+					if !s.AppliedAt.IsZero() {
+						appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+					}
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+			}
+			_ = w.Flush()
+		}
+		return err
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied migration %d (%s)\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Printf("database is up to date (version %d)\n", migrator.Latest())
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
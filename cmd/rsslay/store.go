@@ -1,6 +1,9 @@
 package main
 
 import (
+	"log"
+
+	"github.com/fiatjaf/relayer/storage"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/piraces/rsslay/pkg/metrics"
 	"github.com/piraces/rsslay/pkg/new/app"
@@ -9,20 +12,47 @@ import (
 )
 
 type store struct {
-	app app.App
+	app       app.App
+	enableBot bool
 }
 
-func newStore(app app.App) *store {
-	return &store{app: app}
+func newStore(app app.App, enableBot bool) *store {
+	return &store{app: app, enableBot: enableBot}
 }
 
 func (b store) Init() error {
 	return nil
 }
 
-func (b store) SaveEvent(_ *nostr.Event) error {
-	metrics.InvalidEventsRequests.Inc()
-	return errors.New("blocked: we don't accept any events")
+func (b store) SaveEvent(libevent *nostr.Event) error {
+	event, err := nostrdomain.NewEvent(*libevent)
+	if err != nil {
+		metrics.InvalidEventsRequests.Inc()
+		return errors.Wrap(err, "invalid: malformed event")
+	}
+
+	if b.enableBot && b.app.ProcessDirectMessage.IsAddressedToBot(libevent) {
+		// answering may require fetching a feed so it can't block the
+		// connection, the message itself isn't stored
+		metrics.BotMessagesReceived.Inc()
+		go func() {
+			if err := b.app.ProcessDirectMessage.Handle(event); err != nil {
+				log.Printf("[DEBUG] failure to process direct message %s: %v", libevent.ID, err)
+			}
+		}()
+		return nil
+	}
+
+	if err := b.app.SaveUserEvent.Handle(event); err != nil {
+		if errors.Is(err, nostrdomain.ErrDuplicateEvent) {
+			return storage.ErrDupEvent
+		}
+		metrics.InvalidEventsRequests.Inc()
+		return err
+	}
+
+	metrics.UserEventsSaved.Inc()
+	return nil
 }
 
 func (b store) DeleteEvent(_, _ string) error {
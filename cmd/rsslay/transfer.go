@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/database"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/adapters/feedexport"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/pkg/errors"
+)
+
+const (
+	exportCommand = "export"
+	importCommand = "import"
+
+	exportFormatJSON = "json"
+	exportFormatOPML = "opml"
+)
+
+const exportUsage = `usage: rsslay [-dsn <datasource name>] export [-format json|opml] [-keys] [-o <file>]
+
+Exports all feeds to standard output or to the file. The JSON document can
+be imported by another instance, with -keys it contains the private keys of
+the feeds encrypted with EXPORT_PASSPHRASE. The OPML document only lists the
+feed URLs.`
+
+const importUsage = `usage: rsslay [-dsn <datasource name>] import <file>
+
+Imports the feeds of a JSON document created by 'rsslay export', EXPORT_PASSPHRASE
+decrypts its private keys. Feeds which already exist are skipped so a document
+can be imported again.`
+
+// transferConfig only contains the settings required to export and import
+// the feeds.
+type transferConfig struct {
+	Secret            string   `envconfig:"SECRET" default:""`
+	Secrets           []string `envconfig:"SECRETS" default:""`
+	KeyEncryptionKeys []string `envconfig:"KEY_ENCRYPTION_KEYS" default:""`
+	DatabaseDirectory string   `envconfig:"DB_DIR" default:"db/rsslay.sqlite"`
+	ExportPassphrase  string   `envconfig:"EXPORT_PASSPHRASE" default:""`
+}
+
+func runExportCommand(args []string) error {
+	flags := flag.NewFlagSet(exportCommand, flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), exportUsage) }
+	format := flags.String("format", exportFormatJSON, "json or opml")
+	includeKeys := flags.Bool("keys", false, "export the private keys")
+	output := flags.String("o", "", "file to write to instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 || (*format != exportFormatJSON && *format != exportFormatOPML) {
+		return errors.New(exportUsage)
+	}
+
+	if *includeKeys && *format != exportFormatJSON {
+		return errors.New("private keys can only be exported to JSON")
+	}
+
+	config, err := transferSettings()
+	if err != nil {
+		return err
+	}
+
+	if *includeKeys && config.ExportPassphrase == "" {
+		return errors.New("EXPORT_PASSPHRASE is required to export the private keys")
+	}
+
+	keys, err := newKeyring(config.KeyEncryptionKeys)
+	if err != nil {
+		return err
+	}
+
+	db := openDatabase(transferConnection(config))
+	defer db.Close()
+
+	feeds, err := app.NewHandlerExportFeedDefinitions(adapters.NewFeedDefinitionStorage(db, keys)).Handle(*includeKeys)
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		// the file may contain private keys
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return errors.Wrap(err, "error creating the file")
+		}
+		defer file.Close()
+		w = file
+	}
+
+	buffered := bufio.NewWriter(w)
+	if err := writeExport(buffered, feeds, *format, config.ExportPassphrase); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return errors.Wrap(err, "error writing the export")
+	}
+
+	if *output != "" {
+		fmt.Printf("exported %d feeds to %s\n", len(feeds), *output)
+	}
+	return nil
+}
+
+func writeExport(w io.Writer, feeds []app.ExportedFeed, format string, passphrase string) error {
+	if format == exportFormatOPML {
+		b, err := feedexport.OPML(feeds, time.Now()).Marshal()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return feedexport.Encode(w, feeds, passphrase, time.Now())
+}
+
+func runImportCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(importUsage)
+	}
+
+	config, err := transferSettings()
+	if err != nil {
+		return err
+	}
+
+	if config.Secret == "" {
+		return errors.New("SECRET is required to derive the keys of the imported feeds")
+	}
+
+	secrets, err := newSecrets(config.Secret, config.Secrets)
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeyring(config.KeyEncryptionKeys)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrap(err, "error opening the file")
+	}
+	defer file.Close()
+
+	feeds, err := feedexport.Decode(bufio.NewReader(file), config.ExportPassphrase)
+	if err != nil {
+		return err
+	}
+
+	connection := transferConnection(config)
+	db := openDatabase(connection)
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, connection)
+	if err != nil {
+		return errors.Wrap(err, "cannot load migrations")
+	}
+	if _, err := migrator.Up(); err != nil {
+		return errors.Wrap(err, "cannot migrate schema")
+	}
+
+	handler := app.NewHandlerImportFeedDefinitions(
+		app.NewSecretKeyDeriver(secrets),
+		adapters.NewFeedDefinitionStorage(db, keys),
+		adapters.NewBannedDomainStorage(db),
+		adapters.NewAddressRuleStorage(db),
+	)
+	results, err := handler.Handle(feeds)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[app.ImportStatus]int)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tPUBLIC KEY\tURL\tDETAILS")
+	for _, result := range results {
+		counts[result.Status]++
+
+		publicKey := result.Feed.PublicKey.Hex()
+		if result.Definition != nil {
+			publicKey = result.Definition.PublicKey().Hex()
+		}
+
+		var details string
+		switch {
+		case result.Err != nil:
+			details = result.Err.Error()
+		case result.ConflictsWith != nil:
+			details = "conflicts with " + result.ConflictsWith.PublicKey().Hex()
+		case result.Status == app.ImportStatusRederived:
+			details = "new keys, was " + result.Feed.PublicKey.Hex()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Status, publicKey, result.Feed.Address.String(), details)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%d created, %d rederived, %d existing, %d conflicts, %d rejected, %d failed\n",
+		counts[app.ImportStatusCreated],
+		counts[app.ImportStatusRederived],
+		counts[app.ImportStatusExists],
+		counts[app.ImportStatusConflict],
+		counts[app.ImportStatusRejected],
+		counts[app.ImportStatusFailed],
+	)
+	return nil
+}
+
+func transferSettings() (transferConfig, error) {
+	var config transferConfig
+	if err := loadCommandSettings(&config); err != nil {
+		return transferConfig{}, err
+	}
+	return config, nil
+}
+
+func transferConnection(config transferConfig) string {
+	if *dsn == "" {
+		return config.DatabaseDirectory
+	}
+	return *dsn
+}
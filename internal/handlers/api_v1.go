@@ -0,0 +1,1011 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	APIv1Prefix = "/api/v1"
+
+	defaultAPIPageSize    = 50
+	defaultAPIEventsLimit = 20
+	maxAPIEventsLimit     = 100
+	recentItemsLimit      = 10
+	defaultPendingPage    = 10
+	pendingPreviewItems   = 3
+	minSearchQueryLength  = 5
+	maxAPIBodySize        = 64 * 1024
+)
+
+// apiRoute describes an endpoint of the REST API. The routes are registered
+// and documented in the OpenAPI document from these descriptions so that
+// both always agree.
+type apiRoute struct {
+	operation string
+	method    string
+	path      string
+	summary   string
+	// scope is the scope which clients need, routes without one are
+	// available to everyone
+	scope auth.Scope
+	// status is the status code of successful responses, 200 by default
+	status int
+	query  []apiParameter
+	// request and response are zero values of the bodies, nil if there is
+	// none
+	request  any
+	response any
+	// optionalRequest is true if the request body may be left out
+	optionalRequest bool
+	// accepted is the zero value of the body of 202 responses, returned
+	// through apiAccepted when the request is queued instead of done
+	accepted any
+	// longRunning routes may take minutes, past the timeouts of the server
+	longRunning bool
+	handle      func(f *Handler, r *http.Request) (any, error)
+}
+
+type apiParameter struct {
+	name        string
+	kind        apiParameterKind
+	description string
+}
+
+type apiParameterKind string
+
+const (
+	apiParameterString   apiParameterKind = "string"
+	apiParameterInteger  apiParameterKind = "integer"
+	apiParameterBoolean  apiParameterKind = "boolean"
+	apiParameterIntegers apiParameterKind = "integers"
+)
+
+var (
+	limitParameter  = apiParameter{name: "limit", kind: apiParameterInteger, description: "Maximum number of results."}
+	offsetParameter = apiParameter{name: "offset", kind: apiParameterInteger, description: "Number of results to skip."}
+)
+
+var apiV1Routes = []apiRoute{
+	{
+		operation: "listFeeds",
+		method:    http.MethodGet,
+		path:      "/feeds",
+		summary:   "List feeds ordered by address.",
+		query: []apiParameter{
+			limitParameter,
+			offsetParameter,
+			{name: "health", kind: apiParameterString, description: "Only feeds in this health state: healthy, degraded or suspended."},
+			{name: "disabled", kind: apiParameterBoolean, description: "Only disabled or enabled feeds."},
+			{name: "owned", kind: apiParameterBoolean, description: "Only feeds with or without a verified owner."},
+			{name: "pending", kind: apiParameterBoolean, description: "Only pending or approved feeds, pending feeds are only listed for clients with the manage scope."},
+		},
+		response: apiFeedPage{},
+		handle:   (*Handler).apiListFeeds,
+	},
+	{
+		operation: "createFeed",
+		method:    http.MethodPost,
+		path:      "/feeds",
+		summary:   "Create the feed of a URL, or return it if it already exists. Feeds await approval when the relay is moderated.",
+		status:    http.StatusCreated,
+		request:   apiCreateFeedRequest{},
+		response:  apiFeed{},
+		accepted:  apiSubmission{},
+		handle:    (*Handler).apiCreateFeed,
+	},
+	{
+		operation:   "importFeeds",
+		method:      http.MethodPost,
+		path:        "/import",
+		summary:     "Create the feeds of an OPML document and return an unsigned contact list or NIP-51 follow set following them. Every feed counts towards the creation limits.",
+		request:     apiImportRequest{},
+		response:    apiImport{},
+		longRunning: true,
+		handle:      (*Handler).apiImportFeeds,
+	},
+	{
+		operation: "searchFeeds",
+		method:    http.MethodGet,
+		path:      "/search",
+		summary:   "Search enabled feeds by address, title and description.",
+		query: []apiParameter{
+			{name: "q", kind: apiParameterString, description: "Text to search, at least 5 characters."},
+			limitParameter,
+		},
+		response: apiFeedList{},
+		handle:   (*Handler).apiSearchFeeds,
+	},
+	{
+		operation: "previewFeed",
+		method:    http.MethodGet,
+		path:      "/preview",
+		summary:   "Convert the feed of a URL to unsigned events without creating it.",
+		query: []apiParameter{
+			{name: "url", kind: apiParameterString, description: "Address of the feed or of a page linking to it."},
+			{name: "mode", kind: apiParameterString, description: "Output mode of the items: longform or note, the one used for the feed by default."},
+			{name: "items", kind: apiParameterInteger, description: "Maximum number of items, 5 by default and 20 at most."},
+		},
+		response: apiFeedPreview{},
+		handle:   (*Handler).apiPreviewFeed,
+	},
+	{
+		operation: "getFeed",
+		method:    http.MethodGet,
+		path:      "/feeds/{pubkey}",
+		summary:   "Get the details of a feed along with its recent items.",
+		response:  apiFeedDetails{},
+		handle:    (*Handler).apiGetFeed,
+	},
+	{
+		operation: "deleteFeed",
+		method:    http.MethodDelete,
+		path:      "/feeds/{pubkey}",
+		summary:   "Delete a feed and its events.",
+		scope:     auth.ScopeManage,
+		status:    http.StatusNoContent,
+		handle:    (*Handler).apiDeleteFeed,
+	},
+	{
+		operation: "disableFeed",
+		method:    http.MethodPost,
+		path:      "/feeds/{pubkey}/disable",
+		summary:   "Stop serving and updating a feed.",
+		scope:     auth.ScopeManage,
+		response:  apiFeed{},
+		handle:    (*Handler).apiDisableFeed,
+	},
+	{
+		operation: "enableFeed",
+		method:    http.MethodPost,
+		path:      "/feeds/{pubkey}/enable",
+		summary:   "Serve and update a disabled feed again.",
+		scope:     auth.ScopeManage,
+		response:  apiFeed{},
+		handle:    (*Handler).apiEnableFeed,
+	},
+	{
+		operation: "refreshFeed",
+		method:    http.MethodPost,
+		path:      "/feeds/{pubkey}/refresh",
+		summary:   "Fetch a feed right away.",
+		scope:     auth.ScopeManage,
+		response:  apiFeed{},
+		handle:    (*Handler).apiRefreshFeed,
+	},
+	{
+		operation: "listFeedEvents",
+		method:    http.MethodGet,
+		path:      "/feeds/{pubkey}/events",
+		summary:   "List the events of a feed, newest first.",
+		query: []apiParameter{
+			limitParameter,
+			{name: "kind", kind: apiParameterIntegers, description: "Only events of these kinds."},
+			{name: "since", kind: apiParameterInteger, description: "Only events created at or after this unix timestamp."},
+			{name: "until", kind: apiParameterInteger, description: "Only events created at or before this unix timestamp."},
+		},
+		response: apiEventList{},
+		handle:   (*Handler).apiListFeedEvents,
+	},
+	{
+		operation: "listPendingFeeds",
+		method:    http.MethodGet,
+		path:      "/pending",
+		summary:   "List the feeds awaiting approval along with a preview of each of them.",
+		scope:     auth.ScopeManage,
+		query: []apiParameter{
+			limitParameter,
+			offsetParameter,
+		},
+		response: apiPendingFeedPage{},
+		handle:   (*Handler).apiListPendingFeeds,
+	},
+	{
+		operation: "approveFeed",
+		method:    http.MethodPost,
+		path:      "/feeds/{pubkey}/approve",
+		summary:   "Approve a pending feed, its submitter gets its profile if they submitted it through nostr.",
+		scope:     auth.ScopeManage,
+		response:  apiFeed{},
+		handle:    (*Handler).apiApproveFeed,
+	},
+	{
+		operation:       "rejectFeed",
+		method:          http.MethodPost,
+		path:            "/feeds/{pubkey}/reject",
+		summary:         "Reject and delete a pending feed.",
+		scope:           auth.ScopeManage,
+		status:          http.StatusNoContent,
+		request:         apiRejectFeedRequest{},
+		optionalRequest: true,
+		handle:          (*Handler).apiRejectFeed,
+	},
+	{
+		operation:       "exportFeeds",
+		method:          http.MethodPost,
+		path:            "/instance/export",
+		summary:         "Export all feeds as a JSON document which another instance can import, optionally with their private keys encrypted with a passphrase, or their URLs as an OPML document.",
+		scope:           auth.ScopeAdmin,
+		request:         apiExportRequest{},
+		optionalRequest: true,
+		response:        apiExport{},
+		longRunning:     true,
+		handle:          (*Handler).apiExportFeeds,
+	},
+	{
+		operation:   "importFeedDefinitions",
+		method:      http.MethodPost,
+		path:        "/instance/import",
+		summary:     "Import the feeds of an exported JSON document. Existing feeds are skipped, feeds whose keys can't be kept get new keys derived from the secret of this relay.",
+		scope:       auth.ScopeAdmin,
+		request:     apiInstanceImportRequest{},
+		response:    apiInstanceImport{},
+		longRunning: true,
+		handle:      (*Handler).apiImportFeedDefinitions,
+	},
+	{
+		operation: "getConfig",
+		method:    http.MethodGet,
+		path:      "/config",
+		summary:   "Get the effective configuration of the relay with the values of secrets redacted.",
+		scope:     auth.ScopeAdmin,
+		response:  apiConfig{},
+		handle:    (*Handler).apiGetConfig,
+	},
+}
+
+// apiErrorBody is the schema of every error returned by the API.
+type apiErrorBody struct {
+	Error *apiError `json:"error"`
+}
+
+type apiError struct {
+	status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code string, message string) *apiError {
+	return &apiError{status: status, Code: code, Message: message}
+}
+
+func invalidRequest(message string) *apiError {
+	return newAPIError(http.StatusBadRequest, "invalid_request", message)
+}
+
+type apiFeed struct {
+	PubKey        string      `json:"pubkey"`
+	NPubKey       string      `json:"npub"`
+	Url           string      `json:"url"`
+	Slug          string      `json:"slug,omitempty"`
+	Disabled      bool        `json:"disabled"`
+	Pending       bool        `json:"pending,omitempty"`
+	Title         string      `json:"title,omitempty"`
+	Description   string      `json:"description,omitempty"`
+	Link          string      `json:"link,omitempty"`
+	Image         string      `json:"image,omitempty"`
+	Language      string      `json:"language,omitempty"`
+	ItemCount     int         `json:"item_count"`
+	LastFetchedAt int64       `json:"last_fetched_at,omitempty"`
+	LastSuccessAt int64       `json:"last_success_at,omitempty"`
+	Health        apiHealth   `json:"health"`
+	MovedFrom     string      `json:"moved_from,omitempty"`
+	MovedAt       int64       `json:"moved_at,omitempty"`
+	Owner         *apiOwner   `json:"owner,omitempty"`
+	Profile       *apiProfile `json:"profile,omitempty"`
+}
+
+type apiHealth struct {
+	State               string `json:"state"`
+	ErrorKind           string `json:"error_kind,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	FailingSince        int64  `json:"failing_since,omitempty"`
+	NextFetchAt         int64  `json:"next_fetch_at,omitempty"`
+}
+
+type apiOwner struct {
+	NPubKey    string `json:"npub"`
+	Method     string `json:"method"`
+	Signing    string `json:"signing"`
+	VerifiedAt int64  `json:"verified_at"`
+}
+
+type apiProfile struct {
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+	Lud16   string `json:"lud16,omitempty"`
+	Website string `json:"website,omitempty"`
+}
+
+type apiFeedDetails struct {
+	apiFeed
+	RecentItems []apiItem `json:"recent_items"`
+}
+
+type apiItem struct {
+	ID        string `json:"id"`
+	Kind      int    `json:"kind"`
+	CreatedAt int64  `json:"created_at"`
+	Title     string `json:"title,omitempty"`
+	Content   string `json:"content"`
+}
+
+type apiFeedList struct {
+	Feeds []apiFeed `json:"feeds"`
+}
+
+type apiFeedPage struct {
+	Feeds  []apiFeed `json:"feeds"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+}
+
+type apiEventList struct {
+	Events []nostrlib.Event `json:"events"`
+}
+
+// apiFeedPreview has no keys for feeds which await approval or would once
+// created.
+type apiFeedPreview struct {
+	PubKey     string           `json:"pubkey,omitempty"`
+	NPubKey    string           `json:"npub,omitempty"`
+	Url        string           `json:"url"`
+	Existing   bool             `json:"existing"`
+	Pending    bool             `json:"pending,omitempty"`
+	Mode       string           `json:"mode"`
+	Metadata   nostrlib.Event   `json:"metadata"`
+	Items      []nostrlib.Event `json:"items"`
+	TotalItems int              `json:"total_items"`
+}
+
+type apiCreateFeedRequest struct {
+	Url string `json:"url"`
+}
+
+// apiSubmission is returned instead of the feed when it awaits approval, its
+// keys are only given out once approved.
+type apiSubmission struct {
+	Url    string `json:"url"`
+	Status string `json:"status"`
+}
+
+type apiPendingFeed struct {
+	apiFeed
+	Submitter string          `json:"submitter,omitempty"`
+	Preview   *apiFeedPreview `json:"preview,omitempty"`
+	// PreviewError explains why the feed couldn't be previewed.
+	PreviewError string `json:"preview_error,omitempty"`
+}
+
+type apiPendingFeedPage struct {
+	Feeds  []apiPendingFeed `json:"feeds"`
+	Total  int              `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type apiRejectFeedRequest struct {
+	// Reason is sent to the submitter.
+	Reason string `json:"reason,omitempty"`
+}
+
+// apiAccepted is returned by the handlers of routes with an accepted body
+// when the request is queued instead of done.
+type apiAccepted struct {
+	body any
+}
+
+// RegisterAPIv1 adds the routes of the REST API along with its OpenAPI
+// document to the router.
+func (f *Handler) RegisterAPIv1(router *mux.Router, ownerPubKey *string, dsn *string) {
+	api := router.PathPrefix(APIv1Prefix).Subrouter()
+	api.Use(f.APIMiddleware(ownerPubKey))
+	for _, route := range apiV1Routes {
+		route := route
+		api.Path(route.path).Methods(route.method).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f.handleAPIRoute(w, r, route, dsn)
+		})
+	}
+	api.Path("/openapi.json").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openAPIDocument(apiV1Routes))
+	})
+	api.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, newAPIError(http.StatusNotFound, "not_found", "no such endpoint"))
+	})
+}
+
+func (f *Handler) handleAPIRoute(w http.ResponseWriter, r *http.Request, route apiRoute, dsn *string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// writes must reach the primary node of replicated SQLite databases
+	if route.method != http.MethodGet && handleRedirectToPrimaryNode(w, dsn) {
+		return
+	}
+
+	identity := f.identity(r)
+	if route.scope != "" {
+		if err := requireScope(identity, route.scope); err != nil {
+			metrics.APIRequests.With(prometheus.Labels{"operation": route.operation, "code": strconv.Itoa(err.status)}).Inc()
+			writeAPIError(w, err)
+			return
+		}
+	}
+
+	if route.longRunning {
+		extendDeadline(w)
+	}
+
+	result, err := route.handle(f, r)
+
+	if route.scope != "" {
+		f.addAuditLogEntry(auditName(identity), route.method+" "+APIv1Prefix+route.path, mux.Vars(r), err)
+	}
+
+	if err != nil {
+		apiErr := toAPIError(err)
+		metrics.APIRequests.With(prometheus.Labels{"operation": route.operation, "code": strconv.Itoa(apiErr.status)}).Inc()
+		writeAPIError(w, apiErr)
+		return
+	}
+
+	status := route.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if accepted, ok := result.(apiAccepted); ok {
+		status, result = http.StatusAccepted, accepted.body
+	}
+	metrics.APIRequests.With(prometheus.Labels{"operation": route.operation, "code": strconv.Itoa(status)}).Inc()
+
+	w.WriteHeader(status)
+	if route.response != nil || status == http.StatusAccepted {
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, domainfeed.ErrFeedDefinitionNotFound):
+		return newAPIError(http.StatusNotFound, "not_found", "feed not found")
+	case errors.Is(err, app.ErrDomainBanned):
+		return newAPIError(http.StatusForbidden, "domain_banned", err.Error())
+	case errors.Is(err, app.ErrMissingScope):
+		return newAPIError(http.StatusForbidden, "forbidden", err.Error())
+	case errors.Is(err, app.ErrAddressNotAccepted):
+		return newAPIError(http.StatusForbidden, "address_not_accepted", err.Error())
+	case errors.Is(err, app.ErrCreationRateLimited), errors.Is(err, app.ErrImportRateLimited):
+		return newAPIError(http.StatusTooManyRequests, "rate_limited", err.Error())
+	case errors.Is(err, app.ErrFeedLimitReached):
+		return newAPIError(http.StatusServiceUnavailable, "feed_limit_reached", err.Error())
+	case errors.Is(err, app.ErrNoFeedFound):
+		return newAPIError(http.StatusUnprocessableEntity, "no_feed_found", err.Error())
+	case errors.Is(err, app.ErrFeedDisabled):
+		return newAPIError(http.StatusConflict, "feed_disabled", err.Error())
+	case errors.Is(err, app.ErrFeedPending):
+		return newAPIError(http.StatusConflict, "feed_pending", err.Error())
+	case errors.Is(err, app.ErrFeedNotPending):
+		return newAPIError(http.StatusConflict, "feed_not_pending", err.Error())
+	case errors.Is(err, app.ErrInvalidAddress):
+		return invalidRequest(err.Error())
+	case errors.Is(err, app.ErrItemNotFound):
+		return newAPIError(http.StatusNotFound, "not_found", err.Error())
+	default:
+		log.Printf("[ERROR] api request failed: %v", err)
+		return newAPIError(http.StatusInternalServerError, "internal_error", err.Error())
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, err *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.status)
+	_ = json.NewEncoder(w).Encode(apiErrorBody{Error: err})
+}
+
+func (f *Handler) apiListFeeds(r *http.Request) (any, error) {
+	query := r.URL.Query()
+
+	filter := domainfeed.ListFilter{}
+	var err error
+	if filter.Limit, err = intQueryParam(r, "limit", defaultAPIPageSize); err != nil {
+		return nil, err
+	}
+	if filter.Limit < 1 || filter.Limit > app.MaxFeedsPageSize {
+		return nil, invalidRequest("limit must be between 1 and " + strconv.Itoa(app.MaxFeedsPageSize))
+	}
+	if filter.Offset, err = intQueryParam(r, "offset", 0); err != nil {
+		return nil, err
+	}
+	if filter.Offset < 0 {
+		return nil, invalidRequest("offset can't be negative")
+	}
+
+	switch health := domainfeed.HealthState(query.Get("health")); health {
+	case "", domainfeed.HealthStateHealthy, domainfeed.HealthStateDegraded, domainfeed.HealthStateSuspended:
+		filter.Health = health
+	default:
+		return nil, invalidRequest("health must be healthy, degraded or suspended")
+	}
+
+	if filter.Disabled, err = boolQueryParam(r, "disabled"); err != nil {
+		return nil, err
+	}
+	if filter.Owned, err = boolQueryParam(r, "owned"); err != nil {
+		return nil, err
+	}
+	if filter.Pending, err = boolQueryParam(r, "pending"); err != nil {
+		return nil, err
+	}
+	if !f.identity(r).Has(auth.ScopeManage) {
+		approved := false
+		filter.Pending = &approved
+	}
+
+	page, err := f.app.ListFeedsPage.Handle(filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feeds")
+	}
+
+	return apiFeedPage{
+		Feeds:  toAPIFeeds(page.Feeds),
+		Total:  page.Total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+func (f *Handler) apiCreateFeed(r *http.Request) (any, error) {
+	metrics.CreateRequestsAPI.Inc()
+
+	var request apiCreateFeedRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAPIBodySize)).Decode(&request); err != nil {
+		return nil, invalidRequest("invalid JSON request")
+	}
+
+	address, err := domainfeed.NewAddress(request.Url)
+	if err != nil {
+		return nil, invalidRequest(err.Error())
+	}
+
+	definition, err := f.app.CreateFeedDefinition.Handle(app.CreateFeedDefinition{
+		Address:   address,
+		Submitter: f.identity(r),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if definition.Pending() {
+		return apiAccepted{apiSubmission{Url: definition.Address().String(), Status: "pending"}}, nil
+	}
+	return toAPIFeed(definition), nil
+}
+
+func (f *Handler) apiSearchFeeds(r *http.Request) (any, error) {
+	metrics.SearchRequests.Inc()
+
+	query := r.URL.Query().Get("q")
+	if len(query) < minSearchQueryLength {
+		return nil, invalidRequest("q must have at least " + strconv.Itoa(minSearchQueryLength) + " characters")
+	}
+
+	limit, err := intQueryParam(r, "limit", defaultAPIPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > app.MaxFeedsPageSize {
+		return nil, invalidRequest("limit must be between 1 and " + strconv.Itoa(app.MaxFeedsPageSize))
+	}
+
+	definitions, err := f.app.SearchFeeds.Handle(query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error searching feeds")
+	}
+	return apiFeedList{Feeds: toAPIFeeds(definitions)}, nil
+}
+
+func (f *Handler) apiPreviewFeed(r *http.Request) (any, error) {
+	metrics.PreviewRequests.Inc()
+
+	preview, err := f.previewFeed(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAPIFeedPreview(preview), nil
+}
+
+func toAPIFeedPreview(preview app.FeedPreview) apiFeedPreview {
+	result := apiFeedPreview{
+		Url:        preview.FeedURL,
+		Existing:   preview.Existing,
+		Pending:    preview.Pending,
+		Mode:       string(preview.Mode),
+		Metadata:   preview.Metadata,
+		Items:      preview.Items,
+		TotalItems: preview.TotalItems,
+	}
+	if !preview.Pending {
+		result.PubKey = preview.Definition.PublicKey().Hex()
+		result.NPubKey = preview.Definition.PublicKey().Nip19()
+	}
+	if result.Items == nil {
+		result.Items = []nostrlib.Event{}
+	}
+	return result
+}
+
+func (f *Handler) apiGetFeed(r *http.Request) (any, error) {
+	definition, err := f.apiFeedDefinition(r)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := f.feedEvents(definition, nostrlib.Filter{Kinds: []int{nostrlib.KindTextNote, feed.KindLongFormTextContent}}, recentItemsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	details := apiFeedDetails{apiFeed: toAPIFeed(definition), RecentItems: []apiItem{}}
+	for _, event := range events {
+		item := apiItem{
+			ID:        event.ID,
+			Kind:      event.Kind,
+			CreatedAt: int64(event.CreatedAt),
+			Content:   event.Content,
+		}
+		if title := event.Tags.GetFirst([]string{"title", ""}); title != nil {
+			item.Title = title.Value()
+		}
+		details.RecentItems = append(details.RecentItems, item)
+	}
+	return details, nil
+}
+
+func (f *Handler) apiDeleteFeed(r *http.Request) (any, error) {
+	publicKey, err := apiPublicKey(r)
+	if err != nil {
+		return nil, err
+	}
+	return nil, f.app.DeleteFeed.Handle(publicKey)
+}
+
+func (f *Handler) apiDisableFeed(r *http.Request) (any, error) {
+	return f.apiSetFeedDisabled(r, true)
+}
+
+func (f *Handler) apiEnableFeed(r *http.Request) (any, error) {
+	return f.apiSetFeedDisabled(r, false)
+}
+
+func (f *Handler) apiSetFeedDisabled(r *http.Request, disabled bool) (any, error) {
+	publicKey, err := apiPublicKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.app.SetFeedDisabled.Handle(publicKey, disabled); err != nil {
+		return nil, err
+	}
+	return f.apiFeedAfterChange(publicKey)
+}
+
+func (f *Handler) apiRefreshFeed(r *http.Request) (any, error) {
+	publicKey, err := apiPublicKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.app.RefreshFeed.Handle(r.Context(), publicKey); err != nil {
+		return nil, err
+	}
+	return f.apiFeedAfterChange(publicKey)
+}
+
+func (f *Handler) apiListPendingFeeds(r *http.Request) (any, error) {
+	pending := true
+	filter := domainfeed.ListFilter{Pending: &pending}
+
+	var err error
+	if filter.Limit, err = intQueryParam(r, "limit", defaultPendingPage); err != nil {
+		return nil, err
+	}
+	if filter.Limit < 1 || filter.Limit > app.MaxFeedsPageSize {
+		return nil, invalidRequest("limit must be between 1 and " + strconv.Itoa(app.MaxFeedsPageSize))
+	}
+	if filter.Offset, err = intQueryParam(r, "offset", 0); err != nil {
+		return nil, err
+	}
+	if filter.Offset < 0 {
+		return nil, invalidRequest("offset can't be negative")
+	}
+
+	page, err := f.app.ListFeedsPage.Handle(filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing pending feeds")
+	}
+
+	result := apiPendingFeedPage{
+		Feeds:  []apiPendingFeed{},
+		Total:  page.Total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	for _, definition := range page.Feeds {
+		feed := apiPendingFeed{apiFeed: toAPIFeed(definition), Submitter: definition.Submitter()}
+
+		preview, err := f.app.PreviewFeed.Handle(app.PreviewFeed{Address: definition.Address(), Items: pendingPreviewItems})
+		if err != nil {
+			feed.PreviewError = err.Error()
+		} else {
+			apiPreview := toAPIFeedPreview(preview)
+			feed.Preview = &apiPreview
+		}
+
+		result.Feeds = append(result.Feeds, feed)
+	}
+	return result, nil
+}
+
+func (f *Handler) apiApproveFeed(r *http.Request) (any, error) {
+	publicKey, err := apiPublicKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, err := f.app.ApproveFeed.Handle(r.Context(), publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return toAPIFeed(definition), nil
+}
+
+func (f *Handler) apiRejectFeed(r *http.Request) (any, error) {
+	publicKey, err := apiPublicKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var request apiRejectFeedRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAPIBodySize)).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		return nil, invalidRequest("invalid JSON request")
+	}
+
+	return nil, f.app.RejectFeed.Handle(publicKey, request.Reason)
+}
+
+func (f *Handler) apiListFeedEvents(r *http.Request) (any, error) {
+	definition, err := f.apiFeedDefinition(r)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := intQueryParam(r, "limit", defaultAPIEventsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > maxAPIEventsLimit {
+		return nil, invalidRequest("limit must be between 1 and " + strconv.Itoa(maxAPIEventsLimit))
+	}
+
+	var filter nostrlib.Filter
+	for _, value := range r.URL.Query()["kind"] {
+		kind, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidRequest("kind must be an integer")
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+	if filter.Since, err = timestampQueryParam(r, "since"); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = timestampQueryParam(r, "until"); err != nil {
+		return nil, err
+	}
+
+	events, err := f.feedEvents(definition, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []nostrlib.Event{}
+	}
+	return apiEventList{Events: events}, nil
+}
+
+// feedEvents returns the newest events of the feed, including the ones its
+// owner signs through their remote signer.
+func (f *Handler) feedEvents(definition *domainfeed.FeedDefinition, filter nostrlib.Filter, limit int) ([]nostrlib.Event, error) {
+	filter.Authors = []string{definition.PublicKey().Hex()}
+	if owner := definition.Owner(); owner.SignsRemotely() {
+		filter.Authors = append(filter.Authors, owner.PublicKey.Hex())
+	}
+
+	events, err := f.app.GetEvents.Handle(nostr.NewFilter(&filter))
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the feed events")
+	}
+
+	var result []nostrlib.Event
+	for _, event := range events {
+		result = append(result, event.Libevent())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt > result[j].CreatedAt
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// apiFeedDefinition returns the feed of the request, pending feeds are only
+// visible to clients with the manage scope.
+func (f *Handler) apiFeedDefinition(r *http.Request) (*domainfeed.FeedDefinition, error) {
+	publicKey, err := apiPublicKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, err := f.app.GetFeed.Handle(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if definition.Pending() && !f.identity(r).Has(auth.ScopeManage) {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+	return definition, nil
+}
+
+// apiFeedAfterChange returns the feed once an action changed it.
+func (f *Handler) apiFeedAfterChange(publicKey nostr.PublicKey) (any, error) {
+	definition, err := f.app.GetFeed.Handle(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return toAPIFeed(definition), nil
+}
+
+func apiPublicKey(r *http.Request) (nostr.PublicKey, error) {
+	publicKey, err := nostr.NewPublicKeyFromHexOrNip19(mux.Vars(r)["pubkey"])
+	if err != nil {
+		return nostr.PublicKey{}, invalidRequest("pubkey must be the hex or npub public key of a feed")
+	}
+	return publicKey, nil
+}
+
+func intQueryParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, invalidRequest(name + " must be an integer")
+	}
+	return i, nil
+}
+
+func timestampQueryParam(r *http.Request, name string) (*nostrlib.Timestamp, error) {
+	value, err := intQueryParam(r, name, -1)
+	if err != nil || value < 0 {
+		return nil, err
+	}
+
+	timestamp := nostrlib.Timestamp(value)
+	return &timestamp, nil
+}
+
+func boolQueryParam(r *http.Request, name string) (*bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, invalidRequest(name + " must be true or false")
+	}
+	return &b, nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func toAPIFeeds(definitions []*domainfeed.FeedDefinition) []apiFeed {
+	result := []apiFeed{}
+	for _, definition := range definitions {
+		result = append(result, toAPIFeed(definition))
+	}
+	return result
+}
+
+func toAPIFeed(definition *domainfeed.FeedDefinition) apiFeed {
+	metadata := definition.Metadata()
+	health := definition.Health()
+
+	result := apiFeed{
+		PubKey:        definition.PublicKey().Hex(),
+		NPubKey:       definition.PublicKey().Nip19(),
+		Url:           definition.Address().String(),
+		Slug:          definition.Slug().String(),
+		Disabled:      definition.Disabled(),
+		Pending:       definition.Pending(),
+		Title:         metadata.Title,
+		Description:   metadata.Description,
+		Link:          metadata.Link,
+		Image:         metadata.Image,
+		Language:      metadata.Language,
+		ItemCount:     metadata.ItemCount,
+		LastFetchedAt: unixOrZero(metadata.LastFetchedAt),
+		LastSuccessAt: unixOrZero(metadata.LastSuccessAt),
+		Health: apiHealth{
+			State:               string(health.State),
+			ErrorKind:           string(health.ErrorKind),
+			LastError:           metadata.LastError,
+			ConsecutiveFailures: health.ConsecutiveFailures,
+			FailingSince:        unixOrZero(health.FailingSince),
+			NextFetchAt:         unixOrZero(health.NextFetchAt),
+		},
+	}
+
+	if move := definition.Move(); !move.IsZero() {
+		result.MovedFrom = move.From.String()
+		result.MovedAt = move.At.Unix()
+	}
+
+	if owner := definition.Owner(); !owner.IsZero() {
+		result.Owner = &apiOwner{
+			NPubKey:    owner.PublicKey.Nip19(),
+			Method:     string(owner.Method),
+			Signing:    ownerSigning(owner),
+			VerifiedAt: unixOrZero(owner.VerifiedAt),
+		}
+	}
+
+	if profile := definition.Profile(); !profile.IsZero() {
+		result.Profile = &apiProfile{
+			Name:    profile.Name,
+			About:   profile.About,
+			Picture: profile.Picture,
+			Banner:  profile.Banner,
+			Lud16:   profile.Lud16,
+			Website: profile.Website,
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip98"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type identityContextKey struct{}
+
+// APIMiddleware authenticates the clients of the API routes and applies the
+// quota of each of them. Requests are authenticated with an API key
+// ("Authorization: Bearer <key>") or with NIP-98, the others are anonymous
+// and identified by their address.
+func (f *Handler) APIMiddleware(ownerPubKey *string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, apiErr := f.authenticate(r, *ownerPubKey)
+			if apiErr != nil {
+				writeAPIError(w, apiErr)
+				return
+			}
+
+			if !f.apiLimiter.AllowN(identity.QuotaKey(), f.quotaOf(identity)) {
+				metrics.APIQuotaRejections.With(prometheus.Labels{"kind": string(identity.Kind())}).Inc()
+				writeAPIError(w, newAPIError(http.StatusTooManyRequests, "quota_exceeded", "too many requests, try again later"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity)))
+		})
+	}
+}
+
+// authenticate identifies the client of the request. The body of NIP-98
+// requests is kept so that it can be read again.
+func (f *Handler) authenticate(r *http.Request, ownerPubKey string) (auth.Identity, *apiError) {
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	switch {
+	case scheme == "":
+		return auth.NewAnonymousIdentity(clientIP(r, f.clientIPHeader)), nil
+	case strings.EqualFold(scheme, "Bearer"):
+		identity, err := f.app.AuthenticateAPIKey.Handle(strings.TrimSpace(credentials))
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidAPIKey) {
+				return auth.Identity{}, newAPIError(http.StatusUnauthorized, "unauthorized", err.Error())
+			}
+			return auth.Identity{}, toAPIError(err)
+		}
+		return identity, nil
+	case strings.EqualFold(scheme, "Nostr"):
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxAPIBodySize))
+		if err != nil {
+			return auth.Identity{}, invalidRequest("error reading the request body")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		pubKey, err := nip98.ValidateRequest(r, body)
+		if err != nil {
+			return auth.Identity{}, newAPIError(http.StatusUnauthorized, "unauthorized", err.Error())
+		}
+
+		publicKey, err := nostr.NewPublicKeyFromHex(pubKey)
+		if err != nil {
+			return auth.Identity{}, newAPIError(http.StatusUnauthorized, "unauthorized", err.Error())
+		}
+		identity := auth.NewNostrIdentity(publicKey, ownerPubKey != "" && pubKey == ownerPubKey)
+		return identity.WithClientIP(clientIP(r, f.clientIPHeader)), nil
+	default:
+		return auth.Identity{}, newAPIError(http.StatusUnauthorized, "unauthorized", "authorization must be a Bearer API key or a NIP-98 Nostr event")
+	}
+}
+
+// identity returns the client authenticated by the API middleware, the
+// routes without it are only used anonymously.
+func (f *Handler) identity(r *http.Request) auth.Identity {
+	if identity, ok := r.Context().Value(identityContextKey{}).(auth.Identity); ok {
+		return identity
+	}
+	return auth.NewAnonymousIdentity(clientIP(r, f.clientIPHeader))
+}
+
+func (f *Handler) quotaOf(identity auth.Identity) int {
+	if quota := identity.Quota(); quota > 0 {
+		return quota
+	}
+
+	f.apiQuotaLock.RLock()
+	defer f.apiQuotaLock.RUnlock()
+	return f.apiQuota.Limit
+}
+
+// requireScope checks that the client may use routes which need the scope.
+func requireScope(identity auth.Identity, scope auth.Scope) *apiError {
+	if identity.Has(scope) {
+		return nil
+	}
+	if identity.Anonymous() {
+		return newAPIError(http.StatusUnauthorized, "unauthorized", "authenticate with an API key or NIP-98")
+	}
+	return newAPIError(http.StatusForbidden, "forbidden", "the credentials don't have the "+string(scope)+" scope")
+}
+
+// auditName identifies the client in the audit log, nostr clients by their
+// public key as they always were.
+func auditName(identity auth.Identity) string {
+	if publicKey, ok := identity.PublicKey(); ok {
+		return publicKey.Hex()
+	}
+	return identity.String()
+}
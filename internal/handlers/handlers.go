@@ -1,33 +1,77 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/nbd-wtf/go-nostr/nip05"
-	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/config"
+	"github.com/piraces/rsslay/pkg/database"
 	"github.com/piraces/rsslay/pkg/metrics"
 	"github.com/piraces/rsslay/pkg/new/app"
 	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/pow"
+	"github.com/piraces/rsslay/pkg/ratelimit"
 	"github.com/piraces/rsslay/web/templates"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-var t = template.Must(template.ParseFS(templates.Templates, "*.tmpl"))
+var builtInTemplates = template.Must(template.ParseFS(templates.Templates, "*.tmpl"))
+
+// ParseTemplates parses the templates of the web pages in the directory over
+// the ones built into the binary, so that it only needs the pages it changes.
+// The built-in templates are returned if the directory is empty.
+func ParseTemplates(dir string) (*template.Template, error) {
+	if dir == "" {
+		return builtInTemplates, nil
+	}
+
+	// the built-in templates can't be cloned once executed
+	t, err := template.ParseFS(templates.Templates, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	t, err = t.ParseGlob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the templates in %s: %w", dir, err)
+	}
+	return t, nil
+}
 
 type Entry struct {
-	PubKey       string
-	NPubKey      string
-	Url          string
-	Error        bool
-	ErrorMessage string
-	ErrorCode    int
+	PubKey        string
+	NPubKey       string
+	Url           string
+	Title         string
+	Description   string
+	Link          string
+	Image         string
+	Language      string
+	ItemCount     int
+	LastFetchedAt time.Time
+	LastSuccessAt time.Time
+	LastError     string
+	Health        string
+	ErrorKind     string
+	NextFetchAt   time.Time
+	MovedFrom     string
+	Pending       bool
+	Error         bool
+	ErrorMessage  string
+	ErrorCode     int
 }
 
 type PageData struct {
@@ -35,6 +79,13 @@ type PageData struct {
 	FilteredCount  uint64
 	Entries        []Entry
 	MainDomainName string
+	Challenge      Challenge
+}
+
+// Challenge is the proof of work which the create form must solve.
+type Challenge struct {
+	Value      string
+	Difficulty int
 }
 
 type FeedDefnitionStorage interface {
@@ -42,18 +93,90 @@ type FeedDefnitionStorage interface {
 	Search()
 }
 
+// APIQuota is the number of API requests which every client can make within
+// the window unless their API key has a quota of its own, 0 means no quota.
+type APIQuota struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Configuration is the effective configuration of the relay.
+type Configuration struct {
+	// File is the path of the configuration file, empty if there is none.
+	File     string
+	LoadedAt time.Time
+	// Settings have the values of secrets redacted.
+	Settings []config.Setting
+	// RestartRequired lists the settings which changed since the relay
+	// started but only apply after a restart.
+	RestartRequired []string
+}
+
+// ConfigurationSource returns the effective configuration of the relay.
+type ConfigurationSource interface {
+	Configuration() (Configuration, error)
+}
+
 type Handler struct {
-	app app.App
+	app            app.App
+	challenges     *pow.Challenges
+	clientIPHeader string
+	configuration  ConfigurationSource
+	apiLimiter     *ratelimit.Limiter
+
+	apiQuotaLock sync.RWMutex
+	apiQuota     APIQuota
+
+	templatesLock sync.RWMutex
+	templates     *template.Template
 }
 
+// NewHandler creates the handler, the client IP header is the header set by
+// the reverse proxy in front of the relay with the address of the clients.
 func NewHandler(
 	app app.App,
+	challenges *pow.Challenges,
+	clientIPHeader string,
+	apiQuota APIQuota,
+	templates *template.Template,
+	configuration ConfigurationSource,
 ) *Handler {
 	return &Handler{
-		app: app,
+		app:            app,
+		challenges:     challenges,
+		clientIPHeader: clientIPHeader,
+		configuration:  configuration,
+		apiLimiter:     ratelimit.New(apiQuota.Limit, apiQuota.Window),
+		apiQuota:       apiQuota,
+		templates:      templates,
 	}
 }
 
+// SetAPIQuota changes the quota of the clients without a quota of their own.
+func (f *Handler) SetAPIQuota(apiQuota APIQuota) {
+	f.apiQuotaLock.Lock()
+	defer f.apiQuotaLock.Unlock()
+
+	f.apiQuota = apiQuota
+	f.apiLimiter.SetLimit(apiQuota.Limit, apiQuota.Window)
+}
+
+// SetTemplates replaces the templates of the web pages.
+func (f *Handler) SetTemplates(templates *template.Template) {
+	f.templatesLock.Lock()
+	defer f.templatesLock.Unlock()
+
+	f.templates = templates
+}
+
+func (f *Handler) executeTemplate(w http.ResponseWriter, name string, data any) error {
+	f.templatesLock.RLock()
+	templates := f.templates
+	f.templatesLock.RUnlock()
+
+	return templates.ExecuteTemplate(w, name, data)
+}
+
 func (f *Handler) HandleWebpage(w http.ResponseWriter, r *http.Request, mainDomainName *string) {
 	mustRedirect := handleOtherRegion(w, r)
 	if mustRedirect {
@@ -78,9 +201,10 @@ func (f *Handler) HandleWebpage(w http.ResponseWriter, r *http.Request, mainDoma
 		Count:          uint64(totalCount),
 		Entries:        toEntries(randomFeedDefinitions),
 		MainDomainName: *mainDomainName,
+		Challenge:      f.challenge(),
 	}
 
-	_ = t.ExecuteTemplate(w, "index.html.tmpl", data)
+	_ = f.executeTemplate(w, "index.html.tmpl", data)
 }
 
 func (f *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
@@ -114,7 +238,13 @@ func (f *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 		Entries:       toEntries(feedDefinitions),
 	}
 
-	_ = t.ExecuteTemplate(w, "search.html.tmpl", data)
+	_ = f.executeTemplate(w, "search.html.tmpl", data)
+}
+
+// HandleModeration serves the page where moderators approve or reject
+// pending feeds, it uses the API which authenticates them.
+func (f *Handler) HandleModeration(w http.ResponseWriter, r *http.Request) {
+	_ = f.executeTemplate(w, "moderation.html.tmpl", nil)
 }
 
 func (f *Handler) HandleCreateFeed(w http.ResponseWriter, r *http.Request, dsn *string) {
@@ -125,8 +255,19 @@ func (f *Handler) HandleCreateFeed(w http.ResponseWriter, r *http.Request, dsn *
 
 	metrics.CreateRequests.Inc()
 
+	query := r.URL.Query()
+	if err := f.challenges.Verify(query.Get("challenge"), query.Get("url"), query.Get("nonce")); err != nil {
+		metrics.FeedCreationRejections.With(prometheus.Labels{"reason": "proof_of_work"}).Inc()
+		_ = f.executeTemplate(w, "created.html.tmpl", Entry{
+			Error:        true,
+			ErrorMessage: "The proof of work of the form is not valid, please try again: " + err.Error(),
+			ErrorCode:    http.StatusForbidden,
+		})
+		return
+	}
+
 	entry := f.createFeed(r)
-	_ = t.ExecuteTemplate(w, "created.html.tmpl", entry)
+	_ = f.executeTemplate(w, "created.html.tmpl", entry)
 }
 
 func (f *Handler) HandleApiFeed(w http.ResponseWriter, r *http.Request, dsn *string) {
@@ -137,7 +278,7 @@ func (f *Handler) HandleApiFeed(w http.ResponseWriter, r *http.Request, dsn *str
 	}
 }
 
-func HandleNip05(w http.ResponseWriter, r *http.Request, db *sql.DB, ownerPubKey *string, enableAutoRegistration *bool) {
+func (f *Handler) HandleNip05(w http.ResponseWriter, r *http.Request, ownerPubKey *string, enableAutoRegistration *bool, mainDomainName *string) {
 	metrics.WellKnownRequests.Inc()
 	name := r.URL.Query().Get("name")
 	name, _ = url.QueryUnescape(name)
@@ -151,17 +292,20 @@ func HandleNip05(w http.ResponseWriter, r *http.Request, db *sql.DB, ownerPubKey
 
 	var response []byte
 	if name != "" && name != "_" && *enableAutoRegistration {
-		row := db.QueryRow("SELECT publickey FROM feeds WHERE url like '%' || $1 || '%'", name)
-
-		var entity feed.Entity
-		err := row.Scan(&entity.PublicKey)
-		if err == nil {
+		if definition, ok := f.feedBySlug(name); ok {
+			publicKey := definition.PublicKey().Hex()
 			nip05WellKnownResponse = nip05.WellKnownResponse{
 				Names: map[string]string{
-					name: entity.PublicKey,
+					name: publicKey,
 				},
 				Relays: nil,
 			}
+
+			if *mainDomainName != "" {
+				nip05WellKnownResponse.Relays = map[string][]string{
+					publicKey: {"wss://" + *mainDomainName},
+				}
+			}
 		}
 	}
 
@@ -169,6 +313,23 @@ func HandleNip05(w http.ResponseWriter, r *http.Request, db *sql.DB, ownerPubKey
 	_, _ = w.Write(response)
 }
 
+func (f *Handler) feedBySlug(name string) (*domainfeed.FeedDefinition, bool) {
+	slug, err := domainfeed.NewSlug(name)
+	if err != nil {
+		return nil, false
+	}
+
+	definition, err := f.app.GetFeedBySlug.Handle(slug)
+	if err != nil {
+		if !errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+			log.Printf("[ERROR] failure to get feed by slug %q: %v", name, err)
+		}
+		return nil, false
+	}
+
+	return definition, true
+}
+
 func (f *Handler) handleCreateFeedEntry(w http.ResponseWriter, r *http.Request, dsn *string) {
 	mustRedirect := handleRedirectToPrimaryNode(w, dsn)
 	if mustRedirect {
@@ -183,6 +344,8 @@ func (f *Handler) handleCreateFeedEntry(w http.ResponseWriter, r *http.Request,
 
 	if entry.ErrorCode >= 400 {
 		w.WriteHeader(entry.ErrorCode)
+	} else if entry.Pending {
+		w.WriteHeader(http.StatusAccepted)
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
@@ -203,16 +366,57 @@ func (f *Handler) createFeed(r *http.Request) Entry {
 		}
 	}
 
-	feedDefinition, err := f.app.CreateFeedDefinition.Handle(address)
+	feedDefinition, err := f.app.CreateFeedDefinition.Handle(app.CreateFeedDefinition{
+		Address:   address,
+		Submitter: f.identity(r),
+	})
 	if err != nil {
+		errorCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, app.ErrDomainBanned), errors.Is(err, app.ErrAddressNotAccepted), errors.Is(err, app.ErrMissingScope):
+			errorCode = http.StatusForbidden
+		case errors.Is(err, app.ErrCreationRateLimited):
+			errorCode = http.StatusTooManyRequests
+		case errors.Is(err, app.ErrFeedLimitReached):
+			errorCode = http.StatusServiceUnavailable
+		}
 		return Entry{
 			Error:        true,
 			ErrorMessage: err.Error(),
-			ErrorCode:    http.StatusInternalServerError,
+			ErrorCode:    errorCode,
 		}
 	}
 
-	return toEntry(*feedDefinition)
+	entry := toEntry(*feedDefinition)
+	if entry.Pending {
+		// the keys are only given out once the feed is approved
+		entry.PubKey, entry.NPubKey = "", ""
+	}
+	return entry
+}
+
+func (f *Handler) challenge() Challenge {
+	if !f.challenges.Enabled() {
+		return Challenge{}
+	}
+	return Challenge{Value: f.challenges.Issue(), Difficulty: f.challenges.Difficulty()}
+}
+
+// clientIP returns the address of the client. Behind a reverse proxy it is
+// read from the header set by the proxy, only the last address of a list is
+// trusted as the previous ones are set by the client.
+func clientIP(r *http.Request, header string) string {
+	if header != "" {
+		if values := strings.Split(r.Header.Get(header), ","); strings.TrimSpace(values[len(values)-1]) != "" {
+			return strings.TrimSpace(values[len(values)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 func handleOtherRegion(w http.ResponseWriter, r *http.Request) bool {
@@ -226,6 +430,12 @@ func handleOtherRegion(w http.ResponseWriter, r *http.Request) bool {
 }
 
 func handleRedirectToPrimaryNode(w http.ResponseWriter, dsn *string) bool {
+	// PostgreSQL accepts writes from every node, only replicated SQLite
+	// databases have a primary.
+	if database.DialectOf(*dsn) == database.Postgres {
+		return false
+	}
+
 	// If this node is not primary, look up and redirect to the current primary.
 	primaryFilename := filepath.Join(filepath.Dir(*dsn), ".primary")
 	primary, err := os.ReadFile(primaryFilename)
@@ -251,9 +461,31 @@ func toEntries(definitions []*domainfeed.FeedDefinition) []Entry {
 }
 
 func toEntry(definition domainfeed.FeedDefinition) Entry {
+	metadata := definition.Metadata()
 	return Entry{
-		PubKey:  definition.PublicKey().Hex(),
-		NPubKey: definition.PublicKey().Nip19(),
-		Url:     definition.Address().String(),
+		PubKey:        definition.PublicKey().Hex(),
+		NPubKey:       definition.PublicKey().Nip19(),
+		Url:           definition.Address().String(),
+		Title:         metadata.Title,
+		Description:   metadata.Description,
+		Link:          metadata.Link,
+		Image:         metadata.Image,
+		Language:      metadata.Language,
+		ItemCount:     metadata.ItemCount,
+		LastFetchedAt: metadata.LastFetchedAt,
+		LastSuccessAt: metadata.LastSuccessAt,
+		LastError:     metadata.LastError,
+		Health:        string(definition.Health().State),
+		ErrorKind:     string(definition.Health().ErrorKind),
+		NextFetchAt:   definition.Health().NextFetchAt,
+		MovedFrom:     movedFrom(definition.Move()),
+		Pending:       definition.Pending(),
+	}
+}
+
+func movedFrom(move domainfeed.Move) string {
+	if move.IsZero() {
+		return ""
 	}
+	return move.From.String()
 }
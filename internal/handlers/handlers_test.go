@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	"github.com/piraces/rsslay/pkg/nip98"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/create", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", clientIP(r, ""))
+	assert.Equal(t, "192.0.2.1", clientIP(r, "X-Forwarded-For"))
+
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	assert.Equal(t, "192.0.2.1", clientIP(r, ""), "the header is ignored unless configured")
+	assert.Equal(t, "203.0.113.7", clientIP(r, "X-Forwarded-For"), "only the address added by the proxy is trusted")
+}
+
+func TestRequireScope(t *testing.T) {
+	anonymous := auth.NewAnonymousIdentity("192.0.2.1")
+	assert.Nil(t, requireScope(anonymous, auth.ScopeCreate))
+	assert.Equal(t, http.StatusUnauthorized, requireScope(anonymous, auth.ScopeManage).status)
+
+	key, _, err := auth.NewAPIKey("importer", auth.MustNewScopes("create"), 0, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, requireScope(auth.NewAPIKeyIdentity(key), auth.ScopeManage).status)
+}
+
+func TestAPIQuotaOfNostrIdentities(t *testing.T) {
+	ownerPrivateKey := nostrlib.GeneratePrivateKey()
+	ownerPubKey, err := nostrlib.GetPublicKey(ownerPrivateKey)
+	require.NoError(t, err)
+
+	f := NewHandler(app.App{}, nil, "", APIQuota{Limit: 1, Window: time.Minute}, builtInTemplates, nil)
+	api := f.APIMiddleware(&ownerPubKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(privateKey string) int {
+		r := httptest.NewRequest(http.MethodGet, "https://relay.example.com/api/v1/feeds", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		event := nip98.NewEvent(http.MethodGet, "https://relay.example.com/api/v1/feeds", nil)
+		require.NoError(t, event.Sign(privateKey))
+		r.Header.Set("Authorization", nip98.Header(event))
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(nostrlib.GeneratePrivateKey()))
+	assert.Equal(t, http.StatusTooManyRequests, send(nostrlib.GeneratePrivateKey()), "new keys share the quota of their address")
+	assert.Equal(t, http.StatusOK, send(ownerPrivateKey), "the owner has a quota of their own")
+}
+
+func TestParseTemplatesReplacesTheBuiltInPages(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "moderation.html.tmpl"), []byte("custom moderation"), 0600))
+
+	templates, err := ParseTemplates(dir)
+	require.NoError(t, err)
+
+	var custom strings.Builder
+	require.NoError(t, templates.ExecuteTemplate(&custom, "moderation.html.tmpl", nil))
+	assert.Equal(t, "custom moderation", custom.String())
+	assert.NotNil(t, templates.Lookup("index.html.tmpl"), "the other pages are built in")
+
+	var builtIn strings.Builder
+	require.NoError(t, builtInTemplates.ExecuteTemplate(&builtIn, "moderation.html.tmpl", nil))
+	assert.NotEqual(t, "custom moderation", builtIn.String())
+
+	_, err = ParseTemplates(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	maxOPMLSize = 1 << 20
+	// importDeadline replaces the short timeouts of the server for imports,
+	// which submit hundreds of feeds.
+	importDeadline = 10 * time.Minute
+
+	followListContacts  = "contacts"
+	followListFollowSet = "followset"
+	defaultFollowSet    = "rsslay"
+)
+
+type ImportPageData struct {
+	Challenge    Challenge
+	Error        bool
+	ErrorMessage string
+
+	Results  []ImportResult
+	Imported int
+	Pending  int
+	Failed   int
+	// FollowList is the unsigned event following the imported feeds, signed
+	// and published by the browser.
+	FollowList     string
+	FollowListKind string
+}
+
+type ImportResult struct {
+	Url          string
+	Title        string
+	PubKey       string
+	NPubKey      string
+	Pending      bool
+	ErrorMessage string
+}
+
+// HandleImport shows the import form and creates the feeds of the uploaded
+// OPML document.
+func (f *Handler) HandleImport(w http.ResponseWriter, r *http.Request, dsn *string) {
+	if r.Method != http.MethodPost {
+		_ = f.executeTemplate(w, "import.html.tmpl", ImportPageData{Challenge: f.challenge()})
+		return
+	}
+
+	if handleRedirectToPrimaryNode(w, dsn) {
+		return
+	}
+
+	metrics.ImportRequests.Inc()
+	extendDeadline(w)
+
+	data := ImportPageData{Challenge: f.challenge()}
+	results, followList, err := f.importForm(w, r)
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "import.html.tmpl", data)
+		return
+	}
+
+	for _, result := range results {
+		entry := ImportResult{Url: result.Subscription.XMLURL, Title: result.Subscription.Name()}
+		switch {
+		case result.Err != nil:
+			entry.ErrorMessage = toAPIError(result.Err).Message
+			data.Failed++
+		case result.Definition.Pending():
+			entry.Pending = true
+			data.Pending++
+		default:
+			entry.PubKey = result.Definition.PublicKey().Hex()
+			entry.NPubKey = result.Definition.PublicKey().Nip19()
+			data.Imported++
+		}
+		data.Results = append(data.Results, entry)
+	}
+
+	if followList != nil {
+		data.FollowList = indentedJSON(followList)
+		data.FollowListKind = r.FormValue("follow_list")
+	}
+
+	_ = f.executeTemplate(w, "import.html.tmpl", data)
+}
+
+func (f *Handler) importForm(w http.ResponseWriter, r *http.Request) ([]app.ImportedFeed, *nostrlib.Event, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxOPMLSize+64*1024)
+	if err := r.ParseMultipartForm(maxOPMLSize); err != nil {
+		return nil, nil, invalidRequest("the OPML file must be smaller than 1 MB")
+	}
+
+	file, _, err := r.FormFile("opml")
+	if err != nil {
+		return nil, nil, invalidRequest("choose the OPML file exported by your feed reader")
+	}
+	defer file.Close()
+
+	document, err := io.ReadAll(io.LimitReader(file, maxOPMLSize))
+	if err != nil {
+		return nil, nil, invalidRequest("error reading the OPML file")
+	}
+
+	// the work is bound to the document so that it can't be replayed with
+	// other ones while the challenge is valid
+	if err := f.challenges.Verify(r.FormValue("challenge"), documentHash(document), r.FormValue("nonce")); err != nil {
+		metrics.FeedCreationRejections.With(prometheus.Labels{"reason": "proof_of_work"}).Inc()
+		return nil, nil, newAPIError(http.StatusForbidden, "proof_of_work", "The proof of work of the form is not valid, please try again: "+err.Error())
+	}
+
+	return f.importFeeds(r, bytes.NewReader(document), r.FormValue("follow_list"), r.FormValue("name"))
+}
+
+// documentHash is the hex SHA-256 hash of the uploaded document, the subject
+// of the proof of work of the import form.
+func documentHash(document []byte) string {
+	hash := sha256.Sum256(document)
+	return hex.EncodeToString(hash[:])
+}
+
+// importFeeds creates the feeds of the OPML document along with the list
+// following them, nil if none of them could be followed.
+func (f *Handler) importFeeds(r *http.Request, document io.Reader, listKind string, name string) ([]app.ImportedFeed, *nostrlib.Event, error) {
+	if listKind != followListContacts && listKind != followListFollowSet {
+		return nil, nil, invalidRequest("the follow list must be contacts or followset")
+	}
+
+	opml, err := domainfeed.ParseOPML(io.LimitReader(document, maxOPMLSize))
+	if err != nil {
+		return nil, nil, invalidRequest(err.Error())
+	}
+
+	results, err := f.app.ImportFeeds.Handle(r.Context(), app.ImportFeeds{
+		Subscriptions: opml.Subscriptions(),
+		Submitter:     f.identity(r),
+	})
+	if errors.Is(err, app.ErrImportRateLimited) || errors.Is(err, app.ErrMissingScope) {
+		return nil, nil, toAPIError(err)
+	}
+	if err != nil {
+		return nil, nil, invalidRequest(err.Error())
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = strings.TrimSpace(opml.Head.Title)
+	}
+	return results, followList(results, listKind, name), nil
+}
+
+// followList returns the unsigned kind 3 contact list or NIP-51 follow set
+// (kind 30000) following the imported feeds. Contact lists replace the
+// previous one of the user so the browser merges them before signing.
+func followList(results []app.ImportedFeed, listKind string, name string) *nostrlib.Event {
+	var tags nostrlib.Tags
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result.Err != nil || result.Definition.Pending() {
+			continue
+		}
+		// duplicates of the same feed lead to the same profile
+		if publicKey := result.Definition.PublicKey().Hex(); !seen[publicKey] {
+			seen[publicKey] = true
+			tags = append(tags, nostrlib.Tag{"p", publicKey})
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	event := nostrlib.Event{
+		Kind:      nostrlib.KindContactList,
+		CreatedAt: nostrlib.Now(),
+		Tags:      tags,
+	}
+
+	if listKind == followListFollowSet {
+		if name == "" {
+			name = defaultFollowSet
+		}
+		event.Kind = nostrlib.KindCategorizedPeopleList
+		event.Tags = append(nostrlib.Tags{{"d", name}, {"title", name}}, tags...)
+	}
+	return &event
+}
+
+// extendDeadline lets long requests outlive the timeouts of the server.
+func extendDeadline(w http.ResponseWriter) {
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Now().Add(importDeadline))
+	_ = controller.SetWriteDeadline(time.Now().Add(importDeadline))
+}
+
+type apiImportRequest struct {
+	Opml       string `json:"opml"`
+	FollowList string `json:"follow_list"`
+	Name       string `json:"name,omitempty"`
+}
+
+type apiImport struct {
+	Feeds      []apiImportedFeed `json:"feeds"`
+	FollowList *nostrlib.Event   `json:"follow_list,omitempty"`
+}
+
+type apiImportedFeed struct {
+	Url    string    `json:"url"`
+	Title  string    `json:"title,omitempty"`
+	Status string    `json:"status"`
+	Feed   *apiFeed  `json:"feed,omitempty"`
+	Error  *apiError `json:"error,omitempty"`
+}
+
+func (f *Handler) apiImportFeeds(r *http.Request) (any, error) {
+	metrics.ImportRequests.Inc()
+
+	var request apiImportRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 2*maxOPMLSize)).Decode(&request); err != nil {
+		return nil, invalidRequest("invalid JSON request")
+	}
+
+	if request.FollowList == "" {
+		request.FollowList = followListContacts
+	}
+
+	results, list, err := f.importFeeds(r, strings.NewReader(request.Opml), request.FollowList, request.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	response := apiImport{Feeds: []apiImportedFeed{}, FollowList: list}
+	for _, result := range results {
+		feed := apiImportedFeed{Url: result.Subscription.XMLURL, Title: result.Subscription.Name()}
+		switch {
+		case result.Err != nil:
+			feed.Status, feed.Error = "failed", toAPIError(result.Err)
+		case result.Definition.Pending():
+			feed.Status = "pending"
+		default:
+			apiFeed := toAPIFeed(result.Definition)
+			feed.Status, feed.Feed = "imported", &apiFeed
+		}
+		response.Feeds = append(response.Feeds, feed)
+	}
+	return response, nil
+}
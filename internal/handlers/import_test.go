@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip13"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/pow"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowList(t *testing.T) {
+	imported := someFeedDefinition(t)
+	pending := someFeedDefinition(t)
+	pending.SetPending(true)
+
+	results := []app.ImportedFeed{
+		{Definition: imported},
+		{Definition: pending},
+		{Err: errors.New("could not find a feed URL in there")},
+		// a duplicate of the first one
+		{Definition: imported},
+	}
+
+	contacts := followList(results, followListContacts, "My feeds")
+	require.NotNil(t, contacts)
+	assert.Equal(t, nostrlib.KindContactList, contacts.Kind)
+	assert.Equal(t, nostrlib.Tags{{"p", imported.PublicKey().Hex()}}, contacts.Tags)
+	assert.Empty(t, contacts.PubKey)
+	assert.Empty(t, contacts.Sig)
+
+	followSet := followList(results, followListFollowSet, "My feeds")
+	require.NotNil(t, followSet)
+	assert.Equal(t, nostrlib.KindCategorizedPeopleList, followSet.Kind)
+	assert.Equal(t, nostrlib.Tags{
+		{"d", "My feeds"},
+		{"title", "My feeds"},
+		{"p", imported.PublicKey().Hex()},
+	}, followSet.Tags)
+
+	followSet = followList(results, followListFollowSet, "")
+	assert.Equal(t, "rsslay", tagValue(*followSet, "d"))
+
+	assert.Nil(t, followList(results[1:3], followListContacts, ""))
+}
+
+func TestImportFormBindsTheProofOfWorkToTheDocument(t *testing.T) {
+	const difficulty = 16
+	challenges := pow.New("some secret", difficulty, time.Hour)
+	f := NewHandler(app.App{}, challenges, "", APIQuota{Limit: 1, Window: time.Minute}, builtInTemplates, nil)
+	challenge := challenges.Issue()
+
+	submit := func(document string, solvedFor string) *apiError {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		require.NoError(t, writer.WriteField("challenge", challenge))
+		require.NoError(t, writer.WriteField("nonce", solve(challenge, solvedFor, difficulty)))
+		// rejected once the proof of work is accepted, before importing
+		require.NoError(t, writer.WriteField("follow_list", "none"))
+		file, err := writer.CreateFormFile("opml", "subscriptions.opml")
+		require.NoError(t, err)
+		_, err = file.Write([]byte(document))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		r := httptest.NewRequest(http.MethodPost, "/import", &body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		_, _, err = f.importForm(httptest.NewRecorder(), r)
+		var apiErr *apiError
+		require.ErrorAs(t, err, &apiErr)
+		return apiErr
+	}
+
+	assert.Equal(t, "invalid_request", submit("<opml/>", documentHash([]byte("<opml/>"))).Code)
+	assert.Equal(t, "proof_of_work", submit("<opml/>", documentHash([]byte("<opml></opml>"))).Code)
+	assert.Equal(t, "proof_of_work", submit("<opml/>", "import").Code)
+}
+
+func solve(challenge string, subject string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		hash := sha256.Sum256([]byte(challenge + ":" + subject + ":" + nonce))
+		if nip13.Difficulty(hex.EncodeToString(hash[:])) >= difficulty {
+			return nonce
+		}
+	}
+}
+
+func someFeedDefinition(t *testing.T) *domainfeed.FeedDefinition {
+	privateKeyHex := nostrlib.GeneratePrivateKey()
+	publicKeyHex, err := nostrlib.GetPublicKey(privateKeyHex)
+	require.NoError(t, err)
+
+	privateKey, err := nostr.NewPrivateKeyFromHex(privateKeyHex)
+	require.NoError(t, err)
+	publicKey, err := nostr.NewPublicKeyFromHex(publicKeyHex)
+	require.NoError(t, err)
+	address, err := domainfeed.NewAddress("https://example.com/" + publicKeyHex[:8])
+	require.NoError(t, err)
+
+	definition, err := domainfeed.NewFeedDefinition(publicKey, privateKey, address, false)
+	require.NoError(t, err)
+	return definition
+}
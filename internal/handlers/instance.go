@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/adapters/feedexport"
+)
+
+const maxExportSize = 64 << 20
+
+type apiExportRequest struct {
+	// Format is json, the default, or opml.
+	Format string `json:"format,omitempty"`
+	// Keys exports the private keys encrypted with the passphrase.
+	Keys       bool   `json:"keys,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+type apiExport struct {
+	Format   string `json:"format"`
+	Feeds    int    `json:"feeds"`
+	Document string `json:"document"`
+}
+
+type apiInstanceImportRequest struct {
+	// Document is a JSON document returned by the export.
+	Document   string `json:"document"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+type apiInstanceImport struct {
+	Feeds []apiImportedFeedDefinition `json:"feeds"`
+}
+
+type apiConfig struct {
+	File     string             `json:"file,omitempty"`
+	LoadedAt int64              `json:"loaded_at"`
+	Settings []apiConfigSetting `json:"settings"`
+	// RestartRequired lists the settings which changed since the relay
+	// started but only apply after a restart.
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+type apiConfigSetting struct {
+	Name string `json:"name"`
+	// Value is a string, integer, boolean or list of strings, the values of
+	// secrets are redacted.
+	Value any `json:"value"`
+	// Source is env, file or default.
+	Source string `json:"source"`
+	// Reloadable settings change without a restart when the configuration
+	// is reloaded.
+	Reloadable bool `json:"reloadable"`
+	Redacted   bool `json:"redacted,omitempty"`
+}
+
+type apiImportedFeedDefinition struct {
+	Url string `json:"url"`
+	// PubKey is the public key of the feed on this instance, the exported
+	// one if it was neither created nor existing.
+	PubKey         string    `json:"pubkey"`
+	Status         string    `json:"status"`
+	PreviousPubKey string    `json:"previous_pubkey,omitempty"`
+	ConflictsWith  string    `json:"conflicts_with,omitempty"`
+	Error          *apiError `json:"error,omitempty"`
+}
+
+func (f *Handler) apiExportFeeds(r *http.Request) (any, error) {
+	var request apiExportRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAPIBodySize)).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		return nil, invalidRequest("invalid JSON request")
+	}
+
+	if request.Format == "" {
+		request.Format = "json"
+	}
+	if request.Format != "json" && request.Format != "opml" {
+		return nil, invalidRequest("the format must be json or opml")
+	}
+	if request.Keys && request.Format != "json" {
+		return nil, invalidRequest("private keys can only be exported to JSON")
+	}
+	if request.Keys && request.Passphrase == "" {
+		return nil, invalidRequest(feedexport.ErrPassphraseRequired.Error())
+	}
+
+	feeds, err := f.app.ExportFeedDefinitions.Handle(request.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var document []byte
+	if request.Format == "opml" {
+		document, err = feedexport.OPML(feeds, time.Now()).Marshal()
+	} else {
+		var buf bytes.Buffer
+		err = feedexport.Encode(&buf, feeds, request.Passphrase, time.Now())
+		document = buf.Bytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return apiExport{Format: request.Format, Feeds: len(feeds), Document: string(document)}, nil
+}
+
+func (f *Handler) apiImportFeedDefinitions(r *http.Request) (any, error) {
+	var request apiInstanceImportRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxExportSize)).Decode(&request); err != nil {
+		return nil, invalidRequest("invalid JSON request")
+	}
+
+	feeds, err := feedexport.Decode(strings.NewReader(request.Document), request.Passphrase)
+	if err != nil {
+		return nil, invalidRequest(err.Error())
+	}
+
+	results, err := f.app.ImportFeedDefinitions.Handle(feeds)
+	if err != nil {
+		return nil, err
+	}
+
+	response := apiInstanceImport{Feeds: []apiImportedFeedDefinition{}}
+	for _, result := range results {
+		feed := apiImportedFeedDefinition{
+			Url:    result.Feed.Address.String(),
+			PubKey: result.Feed.PublicKey.Hex(),
+			Status: string(result.Status),
+		}
+		if result.Definition != nil && !result.Definition.PublicKey().Equal(result.Feed.PublicKey) {
+			feed.PubKey, feed.PreviousPubKey = result.Definition.PublicKey().Hex(), result.Feed.PublicKey.Hex()
+		}
+		if result.ConflictsWith != nil {
+			feed.ConflictsWith = result.ConflictsWith.PublicKey().Hex()
+		}
+		if result.Err != nil {
+			feed.Error = toAPIError(result.Err)
+		}
+		response.Feeds = append(response.Feeds, feed)
+	}
+	return response, nil
+}
+
+func (f *Handler) apiGetConfig(_ *http.Request) (any, error) {
+	configuration, err := f.configuration.Configuration()
+	if err != nil {
+		return nil, err
+	}
+
+	result := apiConfig{
+		File:            configuration.File,
+		LoadedAt:        configuration.LoadedAt.Unix(),
+		Settings:        []apiConfigSetting{},
+		RestartRequired: configuration.RestartRequired,
+	}
+	for _, setting := range configuration.Settings {
+		result.Settings = append(result.Settings, apiConfigSetting{
+			Name:       setting.Name,
+			Value:      setting.Value,
+			Source:     string(setting.Source),
+			Reloadable: setting.Reloadable,
+			Redacted:   setting.Redacted,
+		})
+	}
+	return result, nil
+}
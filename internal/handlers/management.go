@@ -0,0 +1,592 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+const (
+	ManagementContentType = "application/nostr+json+rpc"
+	maxManagementBodySize = 64 * 1024
+)
+
+type managementRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type managementResponse struct {
+	Result any    `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+type managementMethod func(f *Handler, r *http.Request, params []json.RawMessage) (any, error)
+
+// managementMethods implements NIP-86 (relay management API). Feeds are
+// managed through the standard pubkey methods as well as through more
+// specific ones.
+var managementMethods = map[string]managementMethod{
+	"listfeeds":          (*Handler).managementListFeeds,
+	"disablefeed":        (*Handler).managementDisableFeed,
+	"enablefeed":         (*Handler).managementEnableFeed,
+	"deletefeed":         (*Handler).managementDeleteFeed,
+	"refreshfeed":        (*Handler).managementRefreshFeed,
+	"setfeedslug":        (*Handler).managementSetFeedSlug,
+	"listfailingfeeds":   (*Handler).managementListFailingFeeds,
+	"listduplicatefeeds": (*Handler).managementListDuplicateFeeds,
+	"banpubkey":          (*Handler).managementDisableFeed,
+	"allowpubkey":        (*Handler).managementEnableFeed,
+	"listbannedpubkeys":  (*Handler).managementListDisabledFeeds,
+	"bandomain":          (*Handler).managementBanDomain,
+	"allowdomain":        (*Handler).managementAllowDomain,
+	"listbanneddomains":  (*Handler).managementListBannedDomains,
+	"addaddressrule":     (*Handler).managementAddAddressRule,
+	"removeaddressrule":  (*Handler).managementRemoveAddressRule,
+	"listaddressrules":   (*Handler).managementListAddressRules,
+	"createapikey":       (*Handler).managementCreateAPIKey,
+	"listapikeys":        (*Handler).managementListAPIKeys,
+	"revokeapikey":       (*Handler).managementRevokeAPIKey,
+	"listpendingfeeds":   (*Handler).managementListPendingFeeds,
+	"approvefeed":        (*Handler).managementApproveFeed,
+	"rejectfeed":         (*Handler).managementRejectFeed,
+}
+
+func init() {
+	// registered here as it refers to the map itself
+	managementMethods["supportedmethods"] = (*Handler).managementSupportedMethods
+}
+
+type managementFeed struct {
+	PubKey        string `json:"pubkey"`
+	NPubKey       string `json:"npub"`
+	Url           string `json:"url"`
+	Slug          string `json:"slug,omitempty"`
+	Disabled      bool   `json:"disabled"`
+	Pending       bool   `json:"pending,omitempty"`
+	Health        string `json:"health"`
+	Title         string `json:"title,omitempty"`
+	LastSuccessAt int64  `json:"last_success_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	MovedFrom     string `json:"moved_from,omitempty"`
+	MovedAt       int64  `json:"moved_at,omitempty"`
+	Owner         string `json:"owner,omitempty"`
+	OwnerSigning  string `json:"owner_signing,omitempty"`
+	Submitter     string `json:"submitter,omitempty"`
+}
+
+type managementDuplicateFeeds struct {
+	Reason string           `json:"reason"`
+	Feeds  []managementFeed `json:"feeds"`
+}
+
+type managementPubKeyWithReason struct {
+	PubKey string `json:"pubkey"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type managementBannedDomain struct {
+	Domain    string `json:"domain"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type managementAddressRule struct {
+	Pattern   string `json:"pattern"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type managementAPIKey struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Quota     int      `json:"quota,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+	// Token is only returned when the key is created.
+	Token string `json:"token,omitempty"`
+}
+
+type managementFailingFeed struct {
+	PubKey              string `json:"pubkey"`
+	Url                 string `json:"url"`
+	Health              string `json:"health"`
+	Error               string `json:"error"`
+	ErrorKind           string `json:"error_kind"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	FailedAt            int64  `json:"failed_at"`
+	FailingSince        int64  `json:"failing_since"`
+	NextFetchAt         int64  `json:"next_fetch_at"`
+}
+
+func (f *Handler) HandleManagement(w http.ResponseWriter, r *http.Request, ownerPubKey *string) {
+	metrics.ManagementRequests.Inc()
+	w.Header().Set("Content-Type", ManagementContentType)
+
+	if *ownerPubKey == "" {
+		writeManagementResponse(w, http.StatusNotFound, managementResponse{Error: "management API is disabled, OWNER_PUBLIC_KEY is not set"})
+		return
+	}
+
+	// the relay owner through NIP-98 or API keys with the admin scope
+	identity, apiErr := f.authenticate(r, *ownerPubKey)
+	if apiErr != nil {
+		writeManagementResponse(w, http.StatusUnauthorized, managementResponse{Error: apiErr.Error()})
+		return
+	}
+
+	if !identity.Has(auth.ScopeAdmin) {
+		writeManagementResponse(w, http.StatusUnauthorized, managementResponse{Error: "only the relay owner can manage the relay"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxManagementBodySize))
+	if err != nil {
+		writeManagementResponse(w, http.StatusBadRequest, managementResponse{Error: "error reading the request body"})
+		return
+	}
+
+	var request managementRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeManagementResponse(w, http.StatusBadRequest, managementResponse{Error: "invalid JSON-RPC request"})
+		return
+	}
+
+	method, ok := managementMethods[request.Method]
+	if !ok {
+		writeManagementResponse(w, http.StatusOK, managementResponse{Error: fmt.Sprintf("method '%s' is not supported", request.Method)})
+		return
+	}
+
+	result, err := method(f, r, request.Params)
+
+	f.addAuditLogEntry(auditName(identity), request.Method, request.Params, err)
+
+	if err != nil {
+		writeManagementResponse(w, http.StatusOK, managementResponse{Error: err.Error()})
+		return
+	}
+
+	writeManagementResponse(w, http.StatusOK, managementResponse{Result: result})
+}
+
+func (f *Handler) addAuditLogEntry(pubKey string, method string, rawParams any, methodErr error) {
+	params, _ := json.Marshal(rawParams)
+
+	entry := app.AuditLogEntry{
+		PublicKey: pubKey,
+		Method:    method,
+		Params:    string(params),
+		CreatedAt: time.Now(),
+	}
+
+	if methodErr != nil {
+		entry.Error = methodErr.Error()
+	}
+
+	if err := f.app.AddAuditLogEntry.Handle(entry); err != nil {
+		log.Printf("[ERROR] failure to add audit log entry for method %q: %v", method, err)
+	}
+}
+
+func (f *Handler) managementSupportedMethods(_ *http.Request, _ []json.RawMessage) (any, error) {
+	var methods []string
+	for method := range managementMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods, nil
+}
+
+func (f *Handler) managementListFeeds(_ *http.Request, _ []json.RawMessage) (any, error) {
+	definitions, err := f.app.ListFeeds.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feeds")
+	}
+
+	result := []managementFeed{}
+	for _, definition := range definitions {
+		result = append(result, toManagementFeed(definition))
+	}
+	return result, nil
+}
+
+func toManagementFeed(definition *domainfeed.FeedDefinition) managementFeed {
+	feed := managementFeed{
+		PubKey:    definition.PublicKey().Hex(),
+		NPubKey:   definition.PublicKey().Nip19(),
+		Url:       definition.Address().String(),
+		Slug:      definition.Slug().String(),
+		Disabled:  definition.Disabled(),
+		Pending:   definition.Pending(),
+		Health:    string(definition.Health().State),
+		Title:     definition.Metadata().Title,
+		LastError: definition.Metadata().LastError,
+	}
+	if lastSuccessAt := definition.Metadata().LastSuccessAt; !lastSuccessAt.IsZero() {
+		feed.LastSuccessAt = lastSuccessAt.Unix()
+	}
+	if move := definition.Move(); !move.IsZero() {
+		feed.MovedFrom = move.From.String()
+		feed.MovedAt = move.At.Unix()
+	}
+	if owner := definition.Owner(); !owner.IsZero() {
+		feed.Owner = owner.PublicKey.Nip19()
+		feed.OwnerSigning = ownerSigning(owner)
+	}
+	feed.Submitter = definition.Submitter()
+	return feed
+}
+
+func (f *Handler) managementListDisabledFeeds(_ *http.Request, _ []json.RawMessage) (any, error) {
+	definitions, err := f.app.ListFeeds.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feeds")
+	}
+
+	result := []managementPubKeyWithReason{}
+	for _, definition := range definitions {
+		if definition.Disabled() {
+			result = append(result, managementPubKeyWithReason{PubKey: definition.PublicKey().Hex()})
+		}
+	}
+	return result, nil
+}
+
+func (f *Handler) managementListPendingFeeds(_ *http.Request, _ []json.RawMessage) (any, error) {
+	definitions, err := f.app.ListFeeds.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feeds")
+	}
+
+	result := []managementFeed{}
+	for _, definition := range definitions {
+		if definition.Pending() {
+			result = append(result, toManagementFeed(definition))
+		}
+	}
+	return result, nil
+}
+
+func (f *Handler) managementApproveFeed(r *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.app.ApproveFeed.Handle(r.Context(), publicKey); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func (f *Handler) managementRejectFeed(_ *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.RejectFeed.Handle(publicKey, optionalStringParam(params, 1))
+}
+
+func (f *Handler) managementDisableFeed(_ *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.SetFeedDisabled.Handle(publicKey, true)
+}
+
+func (f *Handler) managementEnableFeed(_ *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.SetFeedDisabled.Handle(publicKey, false)
+}
+
+func (f *Handler) managementDeleteFeed(_ *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.DeleteFeed.Handle(publicKey)
+}
+
+func (f *Handler) managementRefreshFeed(r *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.RefreshFeed.Handle(r.Context(), publicKey)
+}
+
+func (f *Handler) managementSetFeedSlug(_ *http.Request, params []json.RawMessage) (any, error) {
+	publicKey, err := publicKeyParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := stringParam(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	slug, err := domainfeed.NewSlug(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return true, f.app.SetFeedSlug.Handle(publicKey, slug)
+}
+
+func (f *Handler) managementListFailingFeeds(_ *http.Request, _ []json.RawMessage) (any, error) {
+	definitions, err := f.app.ListFailingFeeds.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing failing feeds")
+	}
+
+	result := []managementFailingFeed{}
+	for _, definition := range definitions {
+		health := definition.Health()
+		result = append(result, managementFailingFeed{
+			PubKey:              definition.PublicKey().Hex(),
+			Url:                 definition.Address().String(),
+			Health:              string(health.State),
+			Error:               definition.Metadata().LastError,
+			ErrorKind:           string(health.ErrorKind),
+			ConsecutiveFailures: health.ConsecutiveFailures,
+			FailedAt:            definition.Metadata().LastFetchedAt.Unix(),
+			FailingSince:        health.FailingSince.Unix(),
+			NextFetchAt:         health.NextFetchAt.Unix(),
+		})
+	}
+	return result, nil
+}
+
+func (f *Handler) managementListDuplicateFeeds(_ *http.Request, _ []json.RawMessage) (any, error) {
+	duplicates, err := f.app.ListDuplicateFeeds.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing duplicate feeds")
+	}
+
+	result := []managementDuplicateFeeds{}
+	for _, duplicate := range duplicates {
+		feeds := []managementFeed{}
+		for _, definition := range duplicate.Feeds {
+			feeds = append(feeds, toManagementFeed(definition))
+		}
+		result = append(result, managementDuplicateFeeds{Reason: duplicate.Reason, Feeds: feeds})
+	}
+	return result, nil
+}
+
+func (f *Handler) managementBanDomain(_ *http.Request, params []json.RawMessage) (any, error) {
+	domain, err := domainParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.BanDomain.Handle(domain, optionalStringParam(params, 1))
+}
+
+func (f *Handler) managementAllowDomain(_ *http.Request, params []json.RawMessage) (any, error) {
+	domain, err := domainParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.AllowDomain.Handle(domain)
+}
+
+func (f *Handler) managementListBannedDomains(_ *http.Request, _ []json.RawMessage) (any, error) {
+	bannedDomains, err := f.app.ListBannedDomains.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing banned domains")
+	}
+
+	result := []managementBannedDomain{}
+	for _, bannedDomain := range bannedDomains {
+		result = append(result, managementBannedDomain{
+			Domain:    bannedDomain.Domain.String(),
+			Reason:    bannedDomain.Reason,
+			CreatedAt: bannedDomain.CreatedAt.Unix(),
+		})
+	}
+	return result, nil
+}
+
+func (f *Handler) managementAddAddressRule(_ *http.Request, params []json.RawMessage) (any, error) {
+	pattern, err := addressPatternParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := stringParam(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := domainfeed.NewAddressRuleAction(s)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.AddAddressRule.Handle(pattern, action, optionalStringParam(params, 2))
+}
+
+func (f *Handler) managementRemoveAddressRule(_ *http.Request, params []json.RawMessage) (any, error) {
+	pattern, err := addressPatternParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.RemoveAddressRule.Handle(pattern)
+}
+
+func (f *Handler) managementListAddressRules(_ *http.Request, _ []json.RawMessage) (any, error) {
+	rules, err := f.app.ListAddressRules.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing address rules")
+	}
+
+	result := []managementAddressRule{}
+	for _, rule := range rules {
+		result = append(result, managementAddressRule{
+			Pattern:   rule.Pattern.String(),
+			Action:    string(rule.Action),
+			Reason:    rule.Reason,
+			CreatedAt: rule.CreatedAt.Unix(),
+		})
+	}
+	return result, nil
+}
+
+func (f *Handler) managementCreateAPIKey(_ *http.Request, params []json.RawMessage) (any, error) {
+	name, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := stringParam(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := auth.NewScopes(strings.Split(s, ",")...)
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := optionalIntParam(params, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	key, token, err := f.app.CreateAPIKey.Handle(app.CreateAPIKey{Name: name, Scopes: scopes, Quota: quota})
+	if err != nil {
+		return nil, err
+	}
+
+	result := toManagementAPIKey(key)
+	result.Token = token
+	return result, nil
+}
+
+func (f *Handler) managementListAPIKeys(_ *http.Request, _ []json.RawMessage) (any, error) {
+	keys, err := f.app.ListAPIKeys.Handle()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing API keys")
+	}
+
+	result := []managementAPIKey{}
+	for _, key := range keys {
+		result = append(result, toManagementAPIKey(key))
+	}
+	return result, nil
+}
+
+func (f *Handler) managementRevokeAPIKey(_ *http.Request, params []json.RawMessage) (any, error) {
+	id, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return true, f.app.RevokeAPIKey.Handle(id)
+}
+
+func toManagementAPIKey(key auth.APIKey) managementAPIKey {
+	return managementAPIKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes.List(),
+		Quota:     key.Quota,
+		CreatedAt: key.CreatedAt.Unix(),
+	}
+}
+
+func writeManagementResponse(w http.ResponseWriter, statusCode int, response managementResponse) {
+	w.WriteHeader(statusCode)
+	body, _ := json.Marshal(response)
+	_, _ = w.Write(body)
+}
+
+func stringParam(params []json.RawMessage, i int) (string, error) {
+	if len(params) <= i {
+		return "", fmt.Errorf("missing parameter %d", i)
+	}
+
+	var s string
+	if err := json.Unmarshal(params[i], &s); err != nil {
+		return "", fmt.Errorf("parameter %d must be a string", i)
+	}
+	return s, nil
+}
+
+func optionalStringParam(params []json.RawMessage, i int) string {
+	s, _ := stringParam(params, i)
+	return s
+}
+
+func optionalIntParam(params []json.RawMessage, i int) (int, error) {
+	if len(params) <= i {
+		return 0, nil
+	}
+
+	var n int
+	if err := json.Unmarshal(params[i], &n); err != nil {
+		return 0, fmt.Errorf("parameter %d must be an integer", i)
+	}
+	return n, nil
+}
+
+func publicKeyParam(params []json.RawMessage) (nostr.PublicKey, error) {
+	s, err := stringParam(params, 0)
+	if err != nil {
+		return nostr.PublicKey{}, err
+	}
+	return nostr.NewPublicKeyFromHexOrNip19(s)
+}
+
+func domainParam(params []json.RawMessage) (domainfeed.Domain, error) {
+	s, err := stringParam(params, 0)
+	if err != nil {
+		return domainfeed.Domain{}, err
+	}
+	return domainfeed.NewDomain(s)
+}
+
+func addressPatternParam(params []json.RawMessage) (domainfeed.AddressPattern, error) {
+	s, err := stringParam(params, 0)
+	if err != nil {
+		return domainfeed.AddressPattern{}, err
+	}
+	return domainfeed.NewAddressPattern(s)
+}
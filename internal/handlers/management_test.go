@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/nip98"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleManagement(t *testing.T) {
+	ownerPrivateKey := nostrlib.GeneratePrivateKey()
+	ownerPubKey, err := nostrlib.GetPublicKey(ownerPrivateKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		ownerPubKey    string
+		privateKey     string
+		body           string
+		expectedStatus int
+		expectedResult any
+		expectedError  string
+		expectedBanned []string
+		expectedAudit  []string
+	}{
+		{
+			name:           "supported method",
+			ownerPubKey:    ownerPubKey,
+			privateKey:     ownerPrivateKey,
+			body:           `{"method":"bandomain","params":["spam.example.com","spam"]}`,
+			expectedStatus: http.StatusOK,
+			expectedResult: true,
+			expectedBanned: []string{"spam.example.com"},
+			expectedAudit:  []string{"bandomain"},
+		},
+		{
+			name:           "invalid params",
+			ownerPubKey:    ownerPubKey,
+			privateKey:     ownerPrivateKey,
+			body:           `{"method":"bandomain","params":[]}`,
+			expectedStatus: http.StatusOK,
+			expectedError:  "missing parameter 0",
+			expectedAudit:  []string{"bandomain: missing parameter 0"},
+		},
+		{
+			name:           "unknown method",
+			ownerPubKey:    ownerPubKey,
+			privateKey:     ownerPrivateKey,
+			body:           `{"method":"banevent","params":["spam.example.com"]}`,
+			expectedStatus: http.StatusOK,
+			expectedError:  "method 'banevent' is not supported",
+		},
+		{
+			name:           "invalid request",
+			ownerPubKey:    ownerPubKey,
+			privateKey:     ownerPrivateKey,
+			body:           `["bandomain"]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid JSON-RPC request",
+		},
+		{
+			name:           "pubkey which isn't the owner",
+			ownerPubKey:    ownerPubKey,
+			privateKey:     nostrlib.GeneratePrivateKey(),
+			body:           `{"method":"bandomain","params":["spam.example.com"]}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "only the relay owner can manage the relay",
+		},
+		{
+			name:           "no authorization",
+			ownerPubKey:    ownerPubKey,
+			body:           `{"method":"bandomain","params":["spam.example.com"]}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "only the relay owner can manage the relay",
+		},
+		{
+			name:           "no owner",
+			privateKey:     ownerPrivateKey,
+			body:           `{"method":"bandomain","params":["spam.example.com"]}`,
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "management API is disabled",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bannedDomains := newFakeBannedDomainStorage()
+			auditLog := &fakeAuditLogStorage{}
+			f := NewHandler(
+				app.App{
+					BanDomain:        app.NewHandlerBanDomain(bannedDomains),
+					AddAuditLogEntry: app.NewHandlerAddAuditLogEntry(auditLog),
+				},
+				nil,
+				"",
+				APIQuota{},
+				builtInTemplates,
+				nil,
+			)
+
+			r := httptest.NewRequest(http.MethodPost, "https://relay.example.com/", strings.NewReader(testCase.body))
+			r.Header.Set("Content-Type", ManagementContentType)
+			if testCase.privateKey != "" {
+				event := nip98.NewEvent(http.MethodPost, "https://relay.example.com/", []byte(testCase.body))
+				require.NoError(t, event.Sign(testCase.privateKey))
+				r.Header.Set("Authorization", nip98.Header(event))
+			}
+
+			w := httptest.NewRecorder()
+			f.HandleManagement(w, r, &testCase.ownerPubKey)
+
+			assert.Equal(t, testCase.expectedStatus, w.Code)
+			assert.Equal(t, ManagementContentType, w.Header().Get("Content-Type"))
+
+			var response managementResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, testCase.expectedResult, response.Result)
+			if testCase.expectedError == "" {
+				assert.Empty(t, response.Error)
+			} else {
+				assert.Contains(t, response.Error, testCase.expectedError)
+			}
+
+			assert.Equal(t, testCase.expectedBanned, bannedDomains.domains())
+			assert.Equal(t, testCase.expectedAudit, auditLog.entries)
+		})
+	}
+}
+
+func TestSupportedMethodsListsAllMethods(t *testing.T) {
+	methods, err := (&Handler{}).managementSupportedMethods(nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, methods, len(managementMethods))
+	assert.Contains(t, methods, "supportedmethods")
+	assert.Contains(t, methods, "banpubkey")
+}
+
+type fakeBannedDomainStorage struct {
+	banned map[string]domainfeed.BannedDomain
+}
+
+func newFakeBannedDomainStorage() *fakeBannedDomainStorage {
+	return &fakeBannedDomainStorage{banned: make(map[string]domainfeed.BannedDomain)}
+}
+
+func (s *fakeBannedDomainStorage) Ban(domain domainfeed.Domain, reason string) error {
+	s.banned[domain.String()] = domainfeed.BannedDomain{Domain: domain, Reason: reason, CreatedAt: time.Now()}
+	return nil
+}
+
+func (s *fakeBannedDomainStorage) Allow(domain domainfeed.Domain) error {
+	delete(s.banned, domain.String())
+	return nil
+}
+
+func (s *fakeBannedDomainStorage) List() ([]domainfeed.BannedDomain, error) {
+	var result []domainfeed.BannedDomain
+	for _, b := range s.banned {
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+func (s *fakeBannedDomainStorage) IsBanned(address domainfeed.Address) (bool, error) {
+	for _, b := range s.banned {
+		if b.Domain.Matches(address) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeBannedDomainStorage) domains() []string {
+	var result []string
+	for domain := range s.banned {
+		result = append(result, domain)
+	}
+	return result
+}
+
+// fakeAuditLogStorage records the methods called, followed by their errors.
+type fakeAuditLogStorage struct {
+	entries []string
+}
+
+func (s *fakeAuditLogStorage) Put(entry app.AuditLogEntry) error {
+	if entry.Error != "" {
+		s.entries = append(s.entries, entry.Method+": "+entry.Error)
+	} else {
+		s.entries = append(s.entries, entry.Method)
+	}
+	return nil
+}
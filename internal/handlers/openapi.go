@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const openAPISchemasPath = "#/components/schemas/"
+
+var pathParameterPattern = regexp.MustCompile(`{([^}]+)}`)
+
+// openAPIDocument describes the routes of the REST API as an OpenAPI 3
+// document. The schemas of the bodies are generated from their Go types
+// following their JSON tags.
+func openAPIDocument(routes []apiRoute) map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+
+	for _, route := range routes {
+		path := APIv1Prefix + route.path
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(route.method)] = openAPIOperation(route, schemas)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "rsslay",
+			"version": "1",
+		},
+		"paths": paths,
+		// authenticating is optional, anonymous clients are identified by
+		// their address
+		"security": []map[string][]string{{}, {"nip98": {}}, {"apiKey": {}}},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"nip98": map[string]any{
+					"type":        "http",
+					"scheme":      "Nostr",
+					"description": "NIP-98 HTTP auth event, the relay owner has every scope.",
+				},
+				"apiKey": map[string]any{
+					"type":        "http",
+					"scheme":      "Bearer",
+					"description": "API key issued by the operator with its own scopes and quota.",
+				},
+			},
+		},
+	}
+}
+
+func openAPIOperation(route apiRoute, schemas map[string]any) map[string]any {
+	operation := map[string]any{
+		"operationId": route.operation,
+		"summary":     route.summary,
+	}
+
+	parameters := []map[string]any{}
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(route.path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, parameter := range route.query {
+		parameters = append(parameters, openAPIQueryParameter(parameter))
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if route.request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": !route.optionalRequest,
+			"content":  openAPIContent(reflect.TypeOf(route.request), schemas),
+		}
+	}
+
+	status := route.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if route.response != nil {
+		success["content"] = openAPIContent(reflect.TypeOf(route.response), schemas)
+	}
+	responses := map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     openAPIContent(reflect.TypeOf(apiErrorBody{}), schemas),
+		},
+	}
+	if route.accepted != nil {
+		responses[strconv.Itoa(http.StatusAccepted)] = map[string]any{
+			"description": http.StatusText(http.StatusAccepted),
+			"content":     openAPIContent(reflect.TypeOf(route.accepted), schemas),
+		}
+	}
+	operation["responses"] = responses
+
+	if route.scope != "" {
+		operation["description"] = "Requires the " + string(route.scope) + " scope."
+		operation["security"] = []map[string][]string{{"nip98": {}}, {"apiKey": {}}}
+	}
+
+	return operation
+}
+
+func openAPIQueryParameter(parameter apiParameter) map[string]any {
+	result := map[string]any{
+		"name":        parameter.name,
+		"in":          "query",
+		"description": parameter.description,
+	}
+
+	switch parameter.kind {
+	case apiParameterIntegers:
+		result["schema"] = map[string]any{"type": "array", "items": map[string]any{"type": "integer"}}
+		result["explode"] = true
+	default:
+		result["schema"] = map[string]any{"type": string(parameter.kind)}
+	}
+	return result
+}
+
+func openAPIContent(t reflect.Type, schemas map[string]any) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": openAPISchema(t, schemas)},
+	}
+}
+
+// openAPISchema returns the schema of a type, structs are added to the
+// schemas and referenced.
+func openAPISchema(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return openAPISchema(t.Elem(), schemas)
+	case reflect.Struct:
+		name := openAPISchemaName(t)
+		if _, ok := schemas[name]; !ok {
+			// registered first so that recursive types end
+			schemas[name] = nil
+			schemas[name] = openAPIStructSchema(t, schemas)
+		}
+		return map[string]any{"$ref": openAPISchemasPath + name}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func openAPIStructSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	addOpenAPIProperties(t, schemas, properties, &required)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func addOpenAPIProperties(t reflect.Type, schemas map[string]any, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// embedded structs without a name are flattened like encoding/json does
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addOpenAPIProperties(field.Type, schemas, properties, required)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = openAPISchema(field.Type, schemas)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// openAPISchemaName names the schemas of the API types without their "api"
+// prefix, for example "Feed" for apiFeed.
+func openAPISchemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "api")
+	if name == "" {
+		return "Object"
+	}
+	return name
+}
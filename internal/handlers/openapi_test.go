@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentDescribesEveryRoute(t *testing.T) {
+	raw, err := json.Marshal(openAPIDocument(apiV1Routes))
+	require.NoError(t, err)
+
+	var document struct {
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &document))
+
+	for _, route := range apiV1Routes {
+		operation, ok := document.Paths[APIv1Prefix+route.path][strings.ToLower(route.method)]
+		require.True(t, ok, route.operation)
+		assert.Equal(t, route.operation, operation["operationId"])
+		assert.Equal(t, route.scope != "", operation["security"] != nil, route.operation)
+	}
+
+	// every reference points to a generated schema
+	for _, match := range regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(raw), -1) {
+		assert.Contains(t, document.Components.Schemas, match[1])
+	}
+
+	// queued requests have a response of their own
+	responses := document.Paths[APIv1Prefix+"/feeds"]["post"]["responses"].(map[string]any)
+	assert.Contains(t, responses, "201")
+	assert.Contains(t, responses, "202")
+
+	feed := document.Components.Schemas["Feed"]
+	require.NotNil(t, feed)
+	assert.Contains(t, feed["required"], "pubkey")
+	assert.NotContains(t, feed["required"], "slug")
+	assert.NotContains(t, feed["required"], "owner")
+
+	// embedded structs are flattened
+	details := document.Components.Schemas["FeedDetails"]["properties"].(map[string]any)
+	assert.Contains(t, details, "pubkey")
+	assert.Contains(t, details, "recent_items")
+
+	errorBody := document.Components.Schemas["ErrorBody"]["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/Error"}, errorBody["error"])
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip98"
+)
+
+const maxOwnerBodySize = 16 * 1024
+
+type ownerRequest struct {
+	Feed       string   `json:"feed"`
+	BunkerURL  string   `json:"bunker_url,omitempty"`
+	Delegation []string `json:"delegation,omitempty"`
+}
+
+type profileRequest struct {
+	Feed    string `json:"feed"`
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+	Lud16   string `json:"lud16,omitempty"`
+	Website string `json:"website,omitempty"`
+}
+
+type profileResponse struct {
+	Feed    string `json:"feed,omitempty"`
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+	Lud16   string `json:"lud16,omitempty"`
+	Website string `json:"website,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ownerResponse struct {
+	Feed    string `json:"feed,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+	Method  string `json:"method,omitempty"`
+	Signing string `json:"signing,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HandleFeedOwner lets the verified owner of a feed publish it with their
+// own key (POST) or go back to the key of the feed (DELETE). Requests are
+// authenticated with NIP-98 by the owner.
+func (f *Handler) HandleFeedOwner(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeOwnerResponse(w, http.StatusMethodNotAllowed, ownerResponse{Error: "method not supported"})
+		return
+	}
+
+	body, owner, statusCode, err := authenticateOwner(r)
+	if err != nil {
+		writeOwnerResponse(w, statusCode, ownerResponse{Error: err.Error()})
+		return
+	}
+
+	var request ownerRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeOwnerResponse(w, http.StatusBadRequest, ownerResponse{Error: "invalid JSON request"})
+		return
+	}
+
+	feedPublicKey, err := nostr.NewPublicKeyFromHexOrNip19(request.Feed)
+	if err != nil {
+		writeOwnerResponse(w, http.StatusBadRequest, ownerResponse{Error: "feed must be the public key of the feed"})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := f.app.RemoveFeedOwner.Handle(r.Context(), feedPublicKey, owner); err != nil {
+			writeOwnerResponse(w, ownerErrorStatus(err), ownerResponse{Error: err.Error()})
+			return
+		}
+		writeOwnerResponse(w, http.StatusOK, ownerResponse{Feed: feedPublicKey.Nip19()})
+		return
+	}
+
+	definition, err := f.app.RegisterFeedOwner.Handle(r.Context(), app.RegisterFeedOwner{
+		Feed:       feedPublicKey,
+		Owner:      owner,
+		BunkerURL:  request.BunkerURL,
+		Delegation: request.Delegation,
+	})
+	if err != nil {
+		writeOwnerResponse(w, ownerErrorStatus(err), ownerResponse{Error: err.Error()})
+		return
+	}
+
+	writeOwnerResponse(w, http.StatusOK, ownerResponse{
+		Feed:    definition.PublicKey().Nip19(),
+		Owner:   definition.Owner().PublicKey.Nip19(),
+		Method:  string(definition.Owner().Method),
+		Signing: ownerSigning(definition.Owner()),
+	})
+}
+
+// HandleFeedProfile lets the verified owner of a feed replace the profile
+// taken from the feed (POST or PUT) or go back to it (DELETE). Requests are
+// authenticated with NIP-98 by the owner.
+func (f *Handler) HandleFeedProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeProfileResponse(w, http.StatusMethodNotAllowed, profileResponse{Error: "method not supported"})
+		return
+	}
+
+	body, owner, statusCode, err := authenticateOwner(r)
+	if err != nil {
+		writeProfileResponse(w, statusCode, profileResponse{Error: err.Error()})
+		return
+	}
+
+	var request profileRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeProfileResponse(w, http.StatusBadRequest, profileResponse{Error: "invalid JSON request"})
+		return
+	}
+
+	feedPublicKey, err := nostr.NewPublicKeyFromHexOrNip19(request.Feed)
+	if err != nil {
+		writeProfileResponse(w, http.StatusBadRequest, profileResponse{Error: "feed must be the public key of the feed"})
+		return
+	}
+
+	var profile domainfeed.Profile
+	if r.Method != http.MethodDelete {
+		profile, err = domainfeed.NewProfile(request.Name, request.About, request.Picture, request.Banner, request.Lud16, request.Website)
+		if err != nil {
+			writeProfileResponse(w, http.StatusBadRequest, profileResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	definition, err := f.app.SetFeedProfile.Handle(r.Context(), app.SetFeedProfile{
+		Feed:    feedPublicKey,
+		Owner:   owner,
+		Profile: profile,
+	})
+	if err != nil {
+		writeProfileResponse(w, ownerErrorStatus(err), profileResponse{Error: err.Error()})
+		return
+	}
+
+	writeProfileResponse(w, http.StatusOK, toProfileResponse(definition))
+}
+
+// authenticateOwner reads the body of a NIP-98 authenticated request and
+// returns the public key which signed it.
+func authenticateOwner(r *http.Request) ([]byte, nostr.PublicKey, int, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxOwnerBodySize))
+	if err != nil {
+		return nil, nostr.PublicKey{}, http.StatusBadRequest, errors.New("error reading the request body")
+	}
+
+	pubKey, err := nip98.ValidateRequest(r, body)
+	if err != nil {
+		return nil, nostr.PublicKey{}, http.StatusUnauthorized, err
+	}
+
+	owner, err := nostr.NewPublicKeyFromHex(pubKey)
+	if err != nil {
+		return nil, nostr.PublicKey{}, http.StatusUnauthorized, err
+	}
+
+	return body, owner, http.StatusOK, nil
+}
+
+func ownerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domainfeed.ErrFeedDefinitionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, feed.ErrOwnershipNotProven), errors.Is(err, app.ErrNotFeedOwner):
+		return http.StatusForbidden
+	case errors.Is(err, app.ErrOwnerPublishesAnotherFeed):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// ownerSigning tells how the events are published with the key of the owner.
+func ownerSigning(owner domainfeed.Owner) string {
+	switch {
+	case owner.SignsRemotely():
+		return "nip46"
+	case !owner.Delegation.IsZero():
+		return "nip26"
+	default:
+		return ""
+	}
+}
+
+func toProfileResponse(definition *domainfeed.FeedDefinition) profileResponse {
+	profile := definition.Profile()
+	return profileResponse{
+		Feed:    definition.PublicKey().Nip19(),
+		Name:    profile.Name,
+		About:   profile.About,
+		Picture: profile.Picture,
+		Banner:  profile.Banner,
+		Lud16:   profile.Lud16,
+		Website: profile.Website,
+	}
+}
+
+func writeProfileResponse(w http.ResponseWriter, statusCode int, response profileResponse) {
+	w.WriteHeader(statusCode)
+	body, _ := json.Marshal(response)
+	_, _ = w.Write(body)
+}
+
+func writeOwnerResponse(w http.ResponseWriter, statusCode int, response ownerResponse) {
+	w.WriteHeader(statusCode)
+	body, _ := json.Marshal(response)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"html"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/microcosm-cc/bluemonday"
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/metrics"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+)
+
+const (
+	profilePageItems     = 20
+	pageDescriptionChars = 200
+)
+
+var pagePlainTextPolicy = bluemonday.StrictPolicy()
+
+// OpenGraph describes a page to the sites which show previews of links.
+type OpenGraph struct {
+	Type        string
+	Title       string
+	Description string
+	Image       string
+	URL         string
+}
+
+type ProfilePageData struct {
+	OpenGraph    OpenGraph
+	Error        bool
+	ErrorMessage string
+
+	PubKey   string
+	NPubKey  string
+	NProfile string
+	Url      string
+	Profile  nostrlib.ProfileMetadata
+	About    template.HTML
+	Items    []PageItem
+}
+
+type ItemPageData struct {
+	OpenGraph    OpenGraph
+	Error        bool
+	ErrorMessage string
+
+	NPubKey  string
+	NProfile string
+	Profile  nostrlib.ProfileMetadata
+	Item     PageItem
+}
+
+type PageItem struct {
+	Kind      int
+	CreatedAt time.Time
+	Title     string
+	Summary   string
+	Image     string
+	// Link is the address of the original item.
+	Link    string
+	Content template.HTML
+	// Pointer is the naddr of articles and the nevent of notes.
+	Pointer string
+}
+
+// HandleProfilePage renders the profile and the latest items of a feed from
+// the events served by the relay, for people without a nostr client.
+func (f *Handler) HandleProfilePage(w http.ResponseWriter, r *http.Request, mainDomainName *string) {
+	metrics.ProfilePageRequests.Inc()
+
+	var data ProfilePageData
+	definition, err := f.servedFeed(mux.Vars(r)["npub"])
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "profile.html.tmpl", data)
+		return
+	}
+
+	relays := pageRelays(*mainDomainName)
+	data.PubKey = definition.PublicKey().Hex()
+	data.NPubKey = definition.PublicKey().Nip19()
+	data.NProfile, _ = nip19.EncodeProfile(data.PubKey, relays)
+	data.Url = definition.Address().String()
+
+	profile, err := f.feedProfile(definition)
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "profile.html.tmpl", data)
+		return
+	}
+	data.Profile = profile
+	data.About = renderMarkdown(profile.About)
+
+	events, err := f.feedEvents(definition, nostrlib.Filter{Kinds: []int{nostrlib.KindTextNote, feed.KindLongFormTextContent}}, profilePageItems)
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "profile.html.tmpl", data)
+		return
+	}
+	for _, event := range events {
+		data.Items = append(data.Items, toPageItem(event, relays))
+	}
+
+	data.OpenGraph = OpenGraph{
+		Type:        "profile",
+		Title:       profile.Name,
+		Description: excerpt(profile.About),
+		Image:       profile.Picture,
+		URL:         pageURL(r, *mainDomainName, "/p/"+data.NPubKey),
+	}
+
+	_ = f.executeTemplate(w, "profile.html.tmpl", data)
+}
+
+// HandleItemPage renders a single item of a feed identified by its nevent,
+// naddr or note.
+func (f *Handler) HandleItemPage(w http.ResponseWriter, r *http.Request, mainDomainName *string) {
+	metrics.ItemPageRequests.Inc()
+
+	var data ItemPageData
+	filter, err := itemFilter(mux.Vars(r)["pointer"])
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "item.html.tmpl", data)
+		return
+	}
+
+	item, err := f.app.GetFeedItem.Handle(nostr.NewFilter(&filter))
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "item.html.tmpl", data)
+		return
+	}
+
+	relays := pageRelays(*mainDomainName)
+	data.NPubKey = item.Definition.PublicKey().Nip19()
+	data.NProfile, _ = nip19.EncodeProfile(item.Definition.PublicKey().Hex(), relays)
+	data.Item = toPageItem(item.Event.Libevent(), relays)
+
+	profile, err := f.feedProfile(item.Definition)
+	if err != nil {
+		data.Error, data.ErrorMessage = pageError(w, err)
+		_ = f.executeTemplate(w, "item.html.tmpl", data)
+		return
+	}
+	data.Profile = profile
+
+	data.OpenGraph = OpenGraph{
+		Type:        "article",
+		Title:       data.Item.Title,
+		Description: data.Item.Summary,
+		Image:       data.Item.Image,
+		URL:         pageURL(r, *mainDomainName, "/e/"+data.Item.Pointer),
+	}
+	if data.OpenGraph.Description == "" {
+		data.OpenGraph.Description = excerpt(item.Event.Libevent().Content)
+	}
+	if data.OpenGraph.Image == "" {
+		data.OpenGraph.Image = profile.Picture
+	}
+
+	_ = f.executeTemplate(w, "item.html.tmpl", data)
+}
+
+// pageError writes the status of the error and returns the message shown in
+// its place, internal errors aren't shown.
+func pageError(w http.ResponseWriter, err error) (bool, string) {
+	apiErr := toAPIError(err)
+	w.WriteHeader(apiErr.status)
+	if apiErr.status == http.StatusInternalServerError {
+		return true, "Something went wrong, please try again later."
+	}
+	return true, apiErr.Message
+}
+
+// servedFeed returns the feed of a npub, nprofile or hex public key unless it
+// isn't served.
+func (f *Handler) servedFeed(s string) (*domainfeed.FeedDefinition, error) {
+	if strings.HasPrefix(s, "nprofile") {
+		if _, value, err := nip19.Decode(s); err == nil {
+			s = value.(nostrlib.ProfilePointer).PublicKey
+		}
+	}
+
+	publicKey, err := nostr.NewPublicKeyFromHexOrNip19(s)
+	if err != nil {
+		return nil, invalidRequest("the address must end with the npub of a feed")
+	}
+
+	definition, err := f.app.GetFeed.Handle(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if definition.Disabled() || definition.Pending() {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+	return definition, nil
+}
+
+// feedProfile returns the profile published by the feed, or the one built
+// from its metadata if it wasn't fetched yet.
+func (f *Handler) feedProfile(definition *domainfeed.FeedDefinition) (nostrlib.ProfileMetadata, error) {
+	events, err := f.app.GetEvents.Handle(nostr.NewFilter(&nostrlib.Filter{
+		Authors: []string{definition.PublicKey().Hex()},
+		Kinds:   []int{nostrlib.KindSetMetadata},
+	}))
+	if err != nil {
+		return nostrlib.ProfileMetadata{}, err
+	}
+
+	var newest *nostrlib.Event
+	for _, event := range events {
+		event := event.Libevent()
+		if newest == nil || event.CreatedAt > newest.CreatedAt {
+			newest = &event
+		}
+	}
+
+	if newest != nil {
+		if profile, err := nostrlib.ParseMetadata(*newest); err == nil {
+			return *profile, nil
+		}
+	}
+
+	metadata := definition.Metadata()
+	profile := nostrlib.ProfileMetadata{
+		Name:    metadata.Title,
+		About:   metadata.Description,
+		Picture: metadata.Image,
+		Website: metadata.Link,
+	}
+	if profile.Name == "" {
+		profile.Name = definition.Address().String()
+	}
+	return profile, nil
+}
+
+// itemFilter decodes the nevent, naddr or note identifying an item.
+func itemFilter(pointer string) (nostrlib.Filter, error) {
+	prefix, value, err := nip19.Decode(pointer)
+	if err != nil {
+		return nostrlib.Filter{}, invalidRequest("the address must end with the nevent, naddr or note of an item")
+	}
+
+	switch prefix {
+	case "note":
+		return nostrlib.Filter{IDs: []string{value.(string)}}, nil
+	case "nevent":
+		return nostrlib.Filter{IDs: []string{value.(nostrlib.EventPointer).ID}}, nil
+	case "naddr":
+		entity := value.(nostrlib.EntityPointer)
+		return nostrlib.Filter{
+			Authors: []string{entity.PublicKey},
+			Kinds:   []int{entity.Kind},
+			Tags:    nostrlib.TagMap{"d": []string{entity.Identifier}},
+		}, nil
+	default:
+		return nostrlib.Filter{}, invalidRequest("the address must end with the nevent, naddr or note of an item")
+	}
+}
+
+func toPageItem(event nostrlib.Event, relays []string) PageItem {
+	item := PageItem{
+		Kind:      event.Kind,
+		CreatedAt: event.CreatedAt.Time().UTC(),
+		Title:     tagValue(event, "title"),
+		Summary:   tagValue(event, "summary"),
+		Image:     tagValue(event, "image"),
+		Link:      tagValue(event, "proxy"),
+		Content:   renderMarkdown(event.Content),
+	}
+
+	if publishedAt, err := strconv.ParseInt(tagValue(event, "published_at"), 10, 64); err == nil {
+		item.CreatedAt = time.Unix(publishedAt, 0).UTC()
+	}
+
+	if item.Title == "" {
+		item.Title = excerpt(strings.SplitN(strings.TrimSpace(event.Content), "\n", 2)[0])
+	}
+
+	if d := tagValue(event, "d"); event.Kind == feed.KindLongFormTextContent && d != "" {
+		item.Pointer, _ = nip19.EncodeEntity(event.PubKey, event.Kind, d, relays)
+	} else {
+		item.Pointer, _ = nip19.EncodeEvent(event.ID, relays, event.PubKey)
+	}
+	return item
+}
+
+func tagValue(event nostrlib.Event, name string) string {
+	if tag := event.Tags.GetFirst([]string{name, ""}); tag != nil {
+		return tag.Value()
+	}
+	return ""
+}
+
+// excerpt shortens markdown to plain text fitting in the previews of links.
+func excerpt(markdown string) string {
+	text := html.UnescapeString(pagePlainTextPolicy.Sanitize(string(renderMarkdown(markdown))))
+	s := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(s) <= pageDescriptionChars {
+		return s
+	}
+	return string([]rune(s)[:pageDescriptionChars-1]) + "…"
+}
+
+func pageRelays(mainDomainName string) []string {
+	if mainDomainName == "" {
+		return nil
+	}
+	return []string{"wss://" + mainDomainName}
+}
+
+// pageURL is the absolute address of a page, on the main domain when there
+// is one.
+func pageURL(r *http.Request, mainDomainName string, path string) string {
+	if mainDomainName != "" {
+		return "https://" + mainDomainName + path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
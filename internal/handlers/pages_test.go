@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pageTestPubKey  = "73e247ee8c4ff09a50525bed7b0869c371864c0bf2b4d6a2639acaed07613958"
+	pageTestEventID = "8fa5ed9ed2d4c12b1ef2bd2cd71c4c1e8e9e0a5ba2bbf3d1e5f7c70a6b5b0ed5"
+)
+
+func TestItemFilter(t *testing.T) {
+	note, err := nip19.EncodeNote(pageTestEventID)
+	require.NoError(t, err)
+	nevent, err := nip19.EncodeEvent(pageTestEventID, []string{"wss://rsslay.example.com"}, pageTestPubKey)
+	require.NoError(t, err)
+	naddr, err := nip19.EncodeEntity(pageTestPubKey, 30023, "https://example.com/post", nil)
+	require.NoError(t, err)
+	npub, err := nip19.EncodePublicKey(pageTestPubKey)
+	require.NoError(t, err)
+
+	filter, err := itemFilter(note)
+	require.NoError(t, err)
+	assert.Equal(t, nostrlib.Filter{IDs: []string{pageTestEventID}}, filter)
+
+	filter, err = itemFilter(nevent)
+	require.NoError(t, err)
+	assert.Equal(t, nostrlib.Filter{IDs: []string{pageTestEventID}}, filter)
+
+	filter, err = itemFilter(naddr)
+	require.NoError(t, err)
+	assert.Equal(t, nostrlib.Filter{
+		Authors: []string{pageTestPubKey},
+		Kinds:   []int{30023},
+		Tags:    nostrlib.TagMap{"d": []string{"https://example.com/post"}},
+	}, filter)
+
+	_, err = itemFilter(npub)
+	assert.Error(t, err)
+	_, err = itemFilter("not-a-pointer")
+	assert.Error(t, err)
+}
+
+func TestToPageItem(t *testing.T) {
+	event := nostrlib.Event{
+		ID:        pageTestEventID,
+		PubKey:    pageTestPubKey,
+		CreatedAt: 1700000000,
+		Kind:      30023,
+		Tags: nostrlib.Tags{
+			{"d", "https://example.com/post"},
+			{"title", "A post"},
+			{"published_at", "1600000000"},
+			{"proxy", "https://example.com/post", "rss"},
+		},
+		Content: "Some **content**",
+	}
+
+	item := toPageItem(event, []string{"wss://rsslay.example.com"})
+
+	assert.Equal(t, "A post", item.Title)
+	assert.Equal(t, "https://example.com/post", item.Link)
+	assert.Equal(t, int64(1600000000), item.CreatedAt.Unix())
+	assert.Contains(t, string(item.Content), "<strong>content</strong>")
+
+	prefix, value, err := nip19.Decode(item.Pointer)
+	require.NoError(t, err)
+	assert.Equal(t, "naddr", prefix)
+	assert.Equal(t, "https://example.com/post", value.(nostrlib.EntityPointer).Identifier)
+
+	event.Kind = nostrlib.KindTextNote
+	event.Tags = nil
+	item = toPageItem(event, nil)
+
+	assert.Equal(t, "Some content", item.Title)
+	prefix, _, err = nip19.Decode(item.Pointer)
+	require.NoError(t, err)
+	assert.Equal(t, "nevent", prefix)
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, "A post & more", excerpt("**A post** &\n\n_more_"))
+	assert.Equal(t, pageDescriptionChars, len([]rune(excerpt(strings.Repeat("word ", 100)))))
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	nostrlib "github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	previewMarkdown = goldmark.New(goldmark.WithExtensions(extension.Linkify, extension.Strikethrough))
+	previewPolicy   = bluemonday.UGCPolicy()
+)
+
+type PreviewPageData struct {
+	Url          string
+	Mode         string
+	Modes        []string
+	Error        bool
+	ErrorMessage string
+
+	PubKey     string
+	NPubKey    string
+	FeedURL    string
+	Existing   bool
+	Pending    bool
+	Profile    nostrlib.ProfileMetadata
+	About      template.HTML
+	Metadata   string
+	Items      []PreviewItem
+	TotalItems int
+	Challenge  Challenge
+}
+
+type PreviewItem struct {
+	Kind      int
+	CreatedAt time.Time
+	Title     string
+	Content   template.HTML
+	Event     string
+}
+
+// HandlePreview shows how a feed would look on nostr without creating it.
+func (f *Handler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	metrics.PreviewRequests.Inc()
+
+	data := PreviewPageData{
+		Url:   r.URL.Query().Get("url"),
+		Modes: []string{string(feed.OutputModeLongForm), string(feed.OutputModeNote)},
+	}
+
+	preview, err := f.previewFeed(r)
+	if err != nil {
+		status := toAPIError(err).status
+		w.WriteHeader(status)
+		data.Error = true
+		data.ErrorMessage = err.Error()
+		_ = f.executeTemplate(w, "preview.html.tmpl", data)
+		return
+	}
+
+	data.Challenge = f.challenge()
+	data.Mode = string(preview.Mode)
+	if !preview.Pending {
+		data.PubKey = preview.Definition.PublicKey().Hex()
+		data.NPubKey = preview.Definition.PublicKey().Nip19()
+	}
+	data.FeedURL = preview.FeedURL
+	data.Existing = preview.Existing
+	data.Pending = preview.Pending
+	data.Metadata = indentedJSON(preview.Metadata)
+	data.TotalItems = preview.TotalItems
+
+	if profile, err := nostrlib.ParseMetadata(preview.Metadata); err == nil {
+		data.Profile = *profile
+		data.About = renderMarkdown(profile.About)
+	}
+
+	for _, evt := range preview.Items {
+		item := PreviewItem{
+			Kind:      evt.Kind,
+			CreatedAt: evt.CreatedAt.Time().UTC(),
+			Content:   renderMarkdown(evt.Content),
+			Event:     indentedJSON(evt),
+		}
+		if title := evt.Tags.GetFirst([]string{"title", ""}); title != nil {
+			item.Title = title.Value()
+		}
+		data.Items = append(data.Items, item)
+	}
+
+	_ = f.executeTemplate(w, "preview.html.tmpl", data)
+}
+
+// previewFeed converts the feed of the url query parameter using the output
+// mode and number of items of the mode and items query parameters.
+func (f *Handler) previewFeed(r *http.Request) (app.FeedPreview, error) {
+	query := r.URL.Query()
+
+	address, err := domainfeed.NewAddress(query.Get("url"))
+	if err != nil {
+		return app.FeedPreview{}, invalidRequest(err.Error())
+	}
+
+	items, err := intQueryParam(r, "items", app.DefaultFeedPreviewItems)
+	if err != nil {
+		return app.FeedPreview{}, err
+	}
+	if items < 1 || items > app.MaxFeedPreviewItems {
+		return app.FeedPreview{}, invalidRequest("items must be between 1 and " + strconv.Itoa(app.MaxFeedPreviewItems))
+	}
+
+	preview, err := f.app.PreviewFeed.Handle(app.PreviewFeed{
+		Address: address,
+		Mode:    feed.OutputMode(query.Get("mode")),
+		Items:   items,
+	})
+	if errors.Is(err, feed.ErrUnknownOutputMode) {
+		return app.FeedPreview{}, invalidRequest("mode must be longform or note")
+	}
+	return preview, err
+}
+
+// renderMarkdown renders the content of an event the way most clients would,
+// feeds are not trusted so the result is sanitized.
+func renderMarkdown(content string) template.HTML {
+	var buf bytes.Buffer
+	if err := previewMarkdown.Convert([]byte(content), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+	return template.HTML(previewPolicy.SanitizeBytes(buf.Bytes()))
+}
+
+func indentedJSON(v any) string {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b)
+}
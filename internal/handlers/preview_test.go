@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	html := string(renderMarkdown("**Title**\n\nSee https://example.com\n\n<script>alert(1)</script><img src=x onerror=alert(1)>"))
+
+	assert.Contains(t, html, "<strong>Title</strong>")
+	assert.Contains(t, html, `<a href="https://example.com" rel="nofollow">https://example.com</a>`)
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "onerror")
+}
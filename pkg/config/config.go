@@ -0,0 +1,401 @@
+// Package config loads settings declared with envconfig tags from the
+// environment and from an optional YAML configuration file.
+//
+// The keys of the file are the names of the environment variables, in
+// lowercase or uppercase, and lists are YAML sequences:
+//
+//	nitter_instances:
+//	  - nitter.example.com
+//	api_rate_limit: 120
+//
+// Environment variables take precedence over the file, which takes
+// precedence over the defaults. The environment is never modified. Fields tagged with `reload:"true"` can be
+// changed without a restart and the values of fields tagged with
+// `redact:"true"` are never shown.
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces the values of redacted settings which are set.
+const Redacted = "[redacted]"
+
+// Source is where the value of a setting comes from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+)
+
+// Config describes how settings were loaded.
+type Config struct {
+	// File is the path of the configuration file, empty if there is none.
+	File     string
+	LoadedAt time.Time
+
+	sources map[string]Source
+}
+
+// Setting is the effective value of a setting.
+type Setting struct {
+	Name       string
+	Value      any
+	Source     Source
+	Reloadable bool
+	Redacted   bool
+}
+
+// Load fills spec, a pointer to a struct with envconfig tags, from the
+// defaults, the configuration file at path unless path is empty and the
+// environment, each one taking precedence over the previous one. The file may
+// only contain settings of spec. The environment is only read, so reloading
+// the file doesn't race with the code reading it.
+func Load(spec any, path string) (Config, error) {
+	return LoadSubset(spec, spec, path)
+}
+
+// LoadSubset fills spec like Load, but the file may contain any setting of
+// all, a pointer to a struct with envconfig tags which spec shares some of
+// its settings with, and the ones spec doesn't have are ignored.
+func LoadSubset(spec any, all any, path string) (Config, error) {
+	fields, err := fieldsOf(spec)
+	if err != nil {
+		return Config{}, err
+	}
+
+	known, err := fieldsOf(all)
+	if err != nil {
+		return Config{}, err
+	}
+
+	nodes := make(map[string]*yaml.Node)
+	if path != "" {
+		nodes, err = readFile(path, known)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	config := Config{File: path, LoadedAt: time.Now(), sources: make(map[string]Source)}
+	value := reflect.ValueOf(spec).Elem()
+	for _, field := range fields {
+		if err := config.fill(value.Field(field.index), field, nodes[field.name], path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	return config, nil
+}
+
+// fill sets the value of the field from the environment, the node of the
+// file or the default, the way envconfig does: fields which are set nowhere
+// and have no default are left alone.
+func (c Config) fill(value reflect.Value, f field, node *yaml.Node, path string) error {
+	if s, ok := os.LookupEnv(f.name); ok {
+		c.sources[f.name] = SourceEnv
+		if err := setValue(value, s); err != nil {
+			return errors.Wrapf(err, "invalid value of %s", f.name)
+		}
+		return nil
+	}
+
+	if node != nil {
+		c.sources[f.name] = SourceFile
+		target := reflect.New(value.Type())
+		if err := node.Decode(target.Interface()); err != nil {
+			return fmt.Errorf("%s:%d: %s: expected %s", path, node.Line, strings.ToLower(f.name), typeName(value.Type()))
+		}
+		value.Set(target.Elem())
+		return nil
+	}
+
+	if f.defaultValue != "" {
+		return errors.Wrapf(setValue(value, f.defaultValue), "invalid default of %s", f.name)
+	}
+
+	if f.required {
+		return fmt.Errorf("required key %s missing value", f.name)
+	}
+	return nil
+}
+
+// Source returns where the value of the setting comes from.
+func (c Config) Source(name string) Source {
+	if source, ok := c.sources[name]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// With returns the configuration with the sources of the named settings
+// taken from next, which was loaded after it.
+func (c Config) With(next Config, names []string) Config {
+	result := Config{File: next.File, LoadedAt: next.LoadedAt, sources: make(map[string]Source)}
+	for name, source := range c.sources {
+		result.sources[name] = source
+	}
+	for _, name := range names {
+		if source, ok := next.sources[name]; ok {
+			result.sources[name] = source
+		} else {
+			delete(result.sources, name)
+		}
+	}
+	return result
+}
+
+// Settings returns the values of the settings of spec.
+func (c Config) Settings(spec any) ([]Setting, error) {
+	fields, err := fieldsOf(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	value := reflect.ValueOf(spec).Elem()
+
+	var settings []Setting
+	for _, field := range fields {
+		setting := Setting{
+			Name:       field.name,
+			Value:      value.Field(field.index).Interface(),
+			Source:     c.Source(field.name),
+			Reloadable: field.reloadable,
+			Redacted:   field.redacted,
+		}
+		if field.redacted && !value.Field(field.index).IsZero() {
+			setting.Value = Redacted
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// Changed returns the names of the settings whose values differ between
+// previous and next, pointers to structs of the same type, split into the
+// ones which can be reloaded and the ones which require a restart.
+func Changed(previous, next any) (reloadable []string, fixed []string, err error) {
+	if reflect.TypeOf(previous) != reflect.TypeOf(next) {
+		return nil, nil, errors.New("the settings must have the same type")
+	}
+
+	fields, err := fieldsOf(previous)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a, b := reflect.ValueOf(previous).Elem(), reflect.ValueOf(next).Elem()
+	for _, field := range fields {
+		if reflect.DeepEqual(a.Field(field.index).Interface(), b.Field(field.index).Interface()) {
+			continue
+		}
+		if field.reloadable {
+			reloadable = append(reloadable, field.name)
+		} else {
+			fixed = append(fixed, field.name)
+		}
+	}
+	return reloadable, fixed, nil
+}
+
+// CopyReloadable copies the values of the settings which can be reloaded
+// from src to dst, pointers to structs of the same type.
+func CopyReloadable(dst, src any) error {
+	if reflect.TypeOf(dst) != reflect.TypeOf(src) {
+		return errors.New("the settings must have the same type")
+	}
+
+	fields, err := fieldsOf(dst)
+	if err != nil {
+		return err
+	}
+
+	a, b := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, field := range fields {
+		if field.reloadable {
+			a.Field(field.index).Set(b.Field(field.index))
+		}
+	}
+	return nil
+}
+
+type field struct {
+	index        int
+	name         string
+	typ          reflect.Type
+	defaultValue string
+	required     bool
+	reloadable   bool
+	redacted     bool
+}
+
+func fieldsOf(spec any) ([]field, error) {
+	value := reflect.ValueOf(spec)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("the settings must be a pointer to a struct")
+	}
+
+	t := value.Elem().Type()
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("envconfig")
+		if !f.IsExported() || name == "" {
+			continue
+		}
+		fields = append(fields, field{
+			index:        i,
+			name:         name,
+			typ:          f.Type,
+			defaultValue: f.Tag.Get("default"),
+			required:     f.Tag.Get("required") == "true",
+			reloadable:   f.Tag.Get("reload") == "true",
+			redacted:     f.Tag.Get("redact") == "true",
+		})
+	}
+	return fields, nil
+}
+
+// readFile returns the nodes of the settings of the file by the names of
+// their environment variables, once their types are checked.
+func readFile(path string, fields []field) (map[string]*yaml.Node, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the configuration file")
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(b, &document); err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file %s", path)
+	}
+
+	values := make(map[string]*yaml.Node)
+
+	// an empty file has no content
+	if len(document.Content) == 0 {
+		return values, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: the configuration must be a mapping of settings", path, root.Line)
+	}
+
+	byName := make(map[string]field)
+	for _, f := range fields {
+		byName[f.name] = f
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, node := root.Content[i], root.Content[i+1]
+
+		f, ok := byName[strings.ToUpper(key.Value)]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown setting %q", path, key.Line, key.Value)
+		}
+
+		if _, ok := values[f.name]; ok {
+			return nil, fmt.Errorf("%s:%d: %q is set twice", path, key.Line, key.Value)
+		}
+
+		// settings without a value keep their default
+		if node.Tag == "!!null" {
+			continue
+		}
+
+		if err := node.Decode(reflect.New(f.typ).Interface()); err != nil {
+			var typeErr *yaml.TypeError
+			if errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("%s:%d: %s: expected %s", path, node.Line, key.Value, typeName(f.typ))
+			}
+			return nil, fmt.Errorf("%s:%d: %s: %v", path, node.Line, key.Value, err)
+		}
+		values[f.name] = node
+	}
+
+	return values, nil
+}
+
+// setValue parses the value of an environment variable or of a default the
+// way envconfig does, lists are separated by commas.
+func setValue(value reflect.Value, s string) error {
+	if value.Kind() == reflect.Slice {
+		items := reflect.MakeSlice(value.Type(), 0, 0)
+		if strings.TrimSpace(s) != "" {
+			for _, item := range strings.Split(s, ",") {
+				parsed := reflect.New(value.Type().Elem()).Elem()
+				if err := setValue(parsed, item); err != nil {
+					return err
+				}
+				items = reflect.Append(items, parsed)
+			}
+		}
+		value.Set(items)
+		return nil
+	}
+
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("settings of type %s aren't supported", value.Type())
+	}
+	return nil
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice:
+		return "a list of " + t.Elem().Kind().String() + "s"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
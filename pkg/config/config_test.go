@@ -0,0 +1,148 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type settings struct {
+	Secret    string   `envconfig:"RSSLAY_TEST_SECRET" required:"true" redact:"true"`
+	Instances []string `envconfig:"RSSLAY_TEST_INSTANCES" default:"" reload:"true"`
+	Limit     int      `envconfig:"RSSLAY_TEST_LIMIT" default:"10" reload:"true"`
+	Name      string   `envconfig:"RSSLAY_TEST_NAME" default:"rsslay"`
+	Enabled   bool     `envconfig:"RSSLAY_TEST_ENABLED" default:"false"`
+
+	internal int
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("RSSLAY_TEST_NAME", "from env")
+	path := writeFile(t, `
+rsslay_test_secret: secret
+rsslay_test_instances:
+  - a.example.com
+  - b.example.com,c.example.com
+RSSLAY_TEST_NAME: from file
+rsslay_test_enabled: true
+rsslay_test_limit:
+`)
+
+	var s settings
+	c, err := config.Load(&s, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secret", s.Secret)
+	assert.Equal(t, []string{"a.example.com", "b.example.com,c.example.com"}, s.Instances, "list items are kept as they are")
+	assert.Equal(t, "from env", s.Name, "the environment takes precedence")
+	assert.True(t, s.Enabled)
+	assert.Equal(t, 10, s.Limit, "settings without a value keep their default")
+
+	assert.Equal(t, config.SourceFile, c.Source("RSSLAY_TEST_SECRET"))
+	assert.Equal(t, config.SourceEnv, c.Source("RSSLAY_TEST_NAME"))
+	assert.Equal(t, config.SourceDefault, c.Source("RSSLAY_TEST_LIMIT"))
+
+	_, ok := os.LookupEnv("RSSLAY_TEST_SECRET")
+	assert.False(t, ok, "the environment isn't modified")
+
+	all, err := c.Settings(&s)
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+	assert.Equal(t, config.Setting{Name: "RSSLAY_TEST_SECRET", Value: config.Redacted, Source: config.SourceFile, Redacted: true}, all[0])
+	assert.Equal(t, config.Setting{Name: "RSSLAY_TEST_LIMIT", Value: 10, Source: config.SourceDefault, Reloadable: true}, all[2])
+
+	// settings removed from the file return to their defaults
+	require.NoError(t, os.WriteFile(path, []byte("rsslay_test_secret: other\n"), 0600))
+	var reloaded settings
+	c, err = config.Load(&reloaded, path)
+	require.NoError(t, err)
+	assert.Equal(t, "other", reloaded.Secret)
+	assert.Empty(t, reloaded.Instances)
+	assert.False(t, reloaded.Enabled)
+	assert.Equal(t, config.SourceDefault, c.Source("RSSLAY_TEST_ENABLED"))
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	t.Setenv("RSSLAY_TEST_SECRET", "secret")
+
+	testCases := []struct {
+		name     string
+		document string
+		err      string
+	}{
+		{name: "unknown setting", document: "rsslay_test_limit: 1\nrsslay_test_other: 1\n", err: `:2: unknown setting "rsslay_test_other"`},
+		{name: "wrong type", document: "rsslay_test_limit: many\n", err: ":1: rsslay_test_limit: expected an integer"},
+		{name: "scalar instead of a list", document: "rsslay_test_instances: a.example.com\n", err: ":1: rsslay_test_instances: expected a list of strings"},
+		{name: "set twice", document: "rsslay_test_limit: 1\nRSSLAY_TEST_LIMIT: 2\n", err: `:2: "RSSLAY_TEST_LIMIT" is set twice`},
+		{name: "not a mapping", document: "- a\n", err: "the configuration must be a mapping of settings"},
+		{name: "invalid YAML", document: "a: [\n", err: "invalid configuration file"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var s settings
+			_, err := config.Load(&s, writeFile(t, testCase.document))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.err)
+		})
+	}
+
+	var s settings
+	_, err := config.Load(&s, filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadRequiredSettings(t *testing.T) {
+	var s settings
+	_, err := config.Load(&s, writeFile(t, "rsslay_test_name: a\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required key RSSLAY_TEST_SECRET missing value")
+
+	c, err := config.Load(&s, writeFile(t, "rsslay_test_secret: secret\n"))
+	require.NoError(t, err, "the file can set required settings")
+	assert.Equal(t, config.SourceFile, c.Source("RSSLAY_TEST_SECRET"))
+}
+
+func TestLoadSubset(t *testing.T) {
+	t.Setenv("RSSLAY_TEST_ONLY_SUBSET", "from env")
+	path := writeFile(t, "rsslay_test_secret: secret\nrsslay_test_limit: 5\n")
+
+	var subset struct {
+		Limit int    `envconfig:"RSSLAY_TEST_LIMIT" default:"10"`
+		Other string `envconfig:"RSSLAY_TEST_ONLY_SUBSET" default:""`
+	}
+	c, err := config.LoadSubset(&subset, &settings{}, path)
+	require.NoError(t, err)
+	assert.Equal(t, 5, subset.Limit)
+	assert.Equal(t, "from env", subset.Other)
+	assert.Equal(t, config.SourceFile, c.Source("RSSLAY_TEST_LIMIT"))
+
+	_, err = config.LoadSubset(&subset, &settings{}, writeFile(t, "rsslay_test_only_subset: a\n"))
+	assert.ErrorContains(t, err, `unknown setting "rsslay_test_only_subset"`)
+}
+
+func TestChanged(t *testing.T) {
+	previous := settings{Secret: "a", Instances: []string{"a.example.com"}, Limit: 1, Name: "a"}
+	next := previous
+	next.Instances = []string{"b.example.com"}
+	next.Name = "b"
+
+	reloadable, fixed, err := config.Changed(&previous, &next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RSSLAY_TEST_INSTANCES"}, reloadable)
+	assert.Equal(t, []string{"RSSLAY_TEST_NAME"}, fixed)
+
+	require.NoError(t, config.CopyReloadable(&previous, &next))
+	assert.Equal(t, []string{"b.example.com"}, previous.Instances)
+	assert.Equal(t, "a", previous.Name, "settings requiring a restart aren't copied")
+}
+
+func writeFile(t *testing.T, document string) string {
+	path := filepath.Join(t.TempDir(), "rsslay.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(document), 0600))
+	return path
+}
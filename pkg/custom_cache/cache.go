@@ -2,6 +2,7 @@ package custom_cache
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -51,6 +52,21 @@ func Set(key string, value string) error {
 	return setToBigCache(key, value)
 }
 
+func Delete(key string) error {
+	if !Initialized {
+		InitializeCache()
+	}
+	if MainCacheRedis != nil {
+		return MainCacheRedis.Delete(context.Background(), key)
+	}
+
+	err := MainCache.Delete(context.Background(), key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return nil
+	}
+	return err
+}
+
 func initializeBigCache() {
 	bigcacheClient, _ := bigcache.NewBigCache(bigcache.DefaultConfig(30 * time.Minute))
 	bigcacheStore := bigcache_store.NewBigcache(bigcacheClient)
@@ -0,0 +1,72 @@
+// Package database opens the database selected by a datasource name.
+//
+// Datasource names using the postgres:// or postgresql:// schemes select
+// PostgreSQL, anything else is treated as the path of an SQLite database.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/piraces/rsslay/pkg/migrations"
+	"github.com/piraces/rsslay/scripts"
+	"github.com/pkg/errors"
+)
+
+type Dialect string
+
+// migrationsLockID identifies the advisory lock which serializes the
+// migrations of the instances sharing a PostgreSQL database.
+const migrationsLockID = 5_318_008_731
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// DialectOf returns the database selected by the datasource name.
+func DialectOf(dsn string) Dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return Postgres
+	}
+	return SQLite
+}
+
+func (d Dialect) driver() string {
+	if d == Postgres {
+		return "pgx"
+	}
+	return "sqlite3"
+}
+
+// Open opens the database without checking that it can be reached.
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open(DialectOf(dsn).driver(), dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the database")
+	}
+	return db, nil
+}
+
+// NewMigrator returns a migrator using the migrations of the database
+// selected by the datasource name.
+func NewMigrator(db *sql.DB, dsn string) (*migrations.Migrator, error) {
+	files, err := fs.Sub(scripts.Migrations, "migrations/"+string(DialectOf(dsn)))
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the migrations directory")
+	}
+	migrator, err := migrations.NewMigrator(db, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite databases are only used by one instance
+	if DialectOf(dsn) == Postgres {
+		migrator.SetLock(fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", migrationsLockID))
+	}
+	return migrator, nil
+}
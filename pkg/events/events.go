@@ -2,6 +2,8 @@ package events
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/url"
 	"strings"
@@ -13,26 +15,33 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func GetParsedFeedForPubKey(pubKey string, db *sql.DB, deleteFailingFeeds bool, nitterInstances []string) (*gofeed.Feed, feed.Entity) {
+var (
+	// ErrInvalidFeedURL is returned for feeds whose stored URL isn't valid.
+	ErrInvalidFeedURL = errors.New("invalid feed url")
+	// ErrFeedLookup wraps the errors of looking up the feed in the database,
+	// any other error comes from fetching or parsing the feed.
+	ErrFeedLookup = errors.New("error looking up the feed")
+)
+
+func GetParsedFeedForPubKey(pubKey string, db *sql.DB, nitterInstances []string) (*gofeed.Feed, feed.Entity, error) {
 	pubKey = strings.TrimSpace(pubKey)
-	row := db.QueryRow("SELECT privatekey, url, nitter FROM feeds WHERE publickey=$1", pubKey)
+	// the private key isn't loaded as it may be encrypted, feeds are signed
+	// with the key of their definition
+	row := db.QueryRow("SELECT url, nitter FROM feeds WHERE publickey=$1", pubKey)
 
 	var entity feed.Entity
-	err := row.Scan(&entity.PrivateKey, &entity.URL, &entity.Nitter)
+	err := row.Scan(&entity.URL, &entity.Nitter)
 	if err != nil && err == sql.ErrNoRows {
-		return nil, entity
+		return nil, entity, fmt.Errorf("%w: %w", ErrFeedLookup, err)
 	} else if err != nil {
 		log.Printf("[ERROR] failed when trying to retrieve row with pubkey '%s': %v", pubKey, err)
 		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
-		return nil, entity
+		return nil, entity, fmt.Errorf("%w: %w", ErrFeedLookup, err)
 	}
 
 	if !helpers.IsValidHttpUrl(entity.URL) {
 		log.Printf("[INFO] retrieved invalid url from database %q", entity.URL)
-		if deleteFailingFeeds {
-			feed.DeleteInvalidFeed(entity.URL, db)
-		}
-		return nil, entity
+		return nil, entity, ErrInvalidFeedURL
 	}
 
 	parsedFeed, err := feed.ParseFeed(entity.URL)
@@ -51,10 +60,7 @@ func GetParsedFeedForPubKey(pubKey string, db *sql.DB, deleteFailingFeeds bool,
 
 	if err != nil {
 		log.Printf("[DEBUG] failed to parse feed at url %q: %v", entity.URL, err)
-		if deleteFailingFeeds {
-			feed.DeleteInvalidFeed(entity.URL, db)
-		}
-		return nil, entity
+		return nil, entity, err
 	}
 
 	if strings.Contains(parsedFeed.Description, "Twitter feed") && !entity.Nitter {
@@ -62,12 +68,12 @@ func GetParsedFeedForPubKey(pubKey string, db *sql.DB, deleteFailingFeeds bool,
 		entity.Nitter = true
 	}
 
-	return parsedFeed, entity
+	return parsedFeed, entity, nil
 }
 
 func updateDatabaseEntry(entity *feed.Entity, db *sql.DB) {
 	log.Printf("[DEBUG] attempting to set feed at url %q with publicKey %s as nitter instance", entity.URL, entity.PublicKey)
-	if _, err := db.Exec(`UPDATE feeds SET nitter = ? WHERE publickey = ?`, 1, entity.PublicKey); err != nil {
+	if _, err := db.Exec(`UPDATE feeds SET nitter = $1 WHERE publickey = $2`, true, entity.PublicKey); err != nil {
 		log.Printf("[ERROR] failure while updating record on db to set as nitter feed: %v", err)
 		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
 	} else {
@@ -2,7 +2,6 @@ package events
 
 import (
 	"errors"
-	"fmt"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -11,13 +10,12 @@ import (
 )
 
 const samplePubKey = "73e247ee8c4ff09a50525bed7b0869c371864c0bf2b4d6a2639acaed07613958"
-const samplePrivateKey = "4d0888c07093941c9db16fcffb96fdf8af49a6839e865ea6110c7ab7cbd2d3d3"
 const sampleValidNitterFeedUrl = "https://nitter.moomoo.me/Twitter/rss"
 const sampleInvalidNitterFeedUrl = "https://example.com/Twitter/rss"
 const sampleValidUrl = "https://mastodon.social/"
 
 var nitterInstances = []string{"birdsite.xanny.family", "notabird.site", "nitter.moomoo.me", "nitter.fly.dev"}
-var sqlRows = []string{"privatekey", "url", "nitter"}
+var sqlRows = []string{"url", "nitter"}
 
 func TestGetParsedFeedForNitterPubKey(t *testing.T) {
 	t.Skip()
@@ -26,17 +24,17 @@ func TestGetParsedFeedForNitterPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, sampleValidNitterFeedUrl, true)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnRows(rows)
+	rows.AddRow(sampleValidNitterFeedUrl, true)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnRows(rows)
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.NotNil(t, parsedFeed)
+	assert.NoError(t, err)
 	assert.Equal(t, feed.Entity{
-		PublicKey:  "",
-		PrivateKey: samplePrivateKey,
-		URL:        sampleValidNitterFeedUrl,
-		Nitter:     true,
+		PublicKey: "",
+		URL:       sampleValidNitterFeedUrl,
+		Nitter:    true,
 	}, entity)
 	_ = db.Close()
 }
@@ -48,19 +46,19 @@ func TestGetParsedFeedForExistingOutdatedNitterPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, sampleValidNitterFeedUrl, false)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnRows(rows)
+	rows.AddRow(sampleValidNitterFeedUrl, false)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnRows(rows)
 	mock.ExpectExec("UPDATE feeds").WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.NotNil(t, parsedFeed)
+	assert.NoError(t, err)
 	assert.Equal(t, feed.Entity{
-		PublicKey:  "",
-		PrivateKey: samplePrivateKey,
-		URL:        sampleValidNitterFeedUrl,
-		Nitter:     true,
+		PublicKey: "",
+		URL:       sampleValidNitterFeedUrl,
+		Nitter:    true,
 	}, entity)
 	_ = db.Close()
 }
@@ -72,18 +70,18 @@ func TestGetParsedFeedForErrorExistingOutdatedNitterPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, sampleValidNitterFeedUrl, false)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnRows(rows)
+	rows.AddRow(sampleValidNitterFeedUrl, false)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnRows(rows)
 	mock.ExpectExec("UPDATE feeds").WillReturnError(errors.New("error"))
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.NotNil(t, parsedFeed)
+	assert.NoError(t, err)
 	assert.Equal(t, feed.Entity{
-		PublicKey:  "",
-		PrivateKey: samplePrivateKey,
-		URL:        sampleValidNitterFeedUrl,
-		Nitter:     true,
+		PublicKey: "",
+		URL:       sampleValidNitterFeedUrl,
+		Nitter:    true,
 	}, entity)
 	_ = db.Close()
 }
@@ -94,12 +92,13 @@ func TestGetParsedFeedSQLErrorForNitterPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, sampleValidNitterFeedUrl, false)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnError(errors.New("error"))
+	rows.AddRow(sampleValidNitterFeedUrl, false)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnError(errors.New("error"))
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.Nil(t, parsedFeed)
+	assert.Error(t, err)
 	assert.Empty(t, entity)
 	_ = db.Close()
 }
@@ -110,17 +109,17 @@ func TestGetParsedFeedInvalidFeedUrlForNitterPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, sampleInvalidNitterFeedUrl, false)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnRows(rows)
+	rows.AddRow(sampleInvalidNitterFeedUrl, false)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnRows(rows)
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.Nil(t, parsedFeed)
+	assert.Error(t, err)
 	assert.Equal(t, feed.Entity{
-		PublicKey:  "",
-		PrivateKey: samplePrivateKey,
-		URL:        sampleInvalidNitterFeedUrl,
-		Nitter:     false,
+		PublicKey: "",
+		URL:       sampleInvalidNitterFeedUrl,
+		Nitter:    false,
 	}, entity)
 	_ = db.Close()
 }
@@ -131,19 +130,17 @@ func TestGetParsedFeedInvalidFeedUrlForStandardPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, sampleValidUrl, false)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnRows(rows)
-	expectedDeleteQuery := fmt.Sprintf("DELETE FROM feeds WHERE url=%s", sampleValidUrl)
-	mock.ExpectQuery(expectedDeleteQuery)
+	rows.AddRow(sampleValidUrl, false)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnRows(rows)
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.Nil(t, parsedFeed)
+	assert.Error(t, err)
 	assert.Equal(t, feed.Entity{
-		PublicKey:  "",
-		PrivateKey: samplePrivateKey,
-		URL:        sampleValidUrl,
-		Nitter:     false,
+		PublicKey: "",
+		URL:       sampleValidUrl,
+		Nitter:    false,
 	}, entity)
 	_ = db.Close()
 }
@@ -154,19 +151,17 @@ func TestGetParsedFeedInvalidUrlForStandardPubKey(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	rows := sqlmock.NewRows(sqlRows)
-	rows.AddRow(samplePrivateKey, "not a url", false)
-	mock.ExpectQuery("SELECT privatekey, url, nitter FROM feeds").WillReturnRows(rows)
-	expectedDeleteQuery := fmt.Sprintf("DELETE FROM feeds WHERE url=%s", sampleValidUrl)
-	mock.ExpectQuery(expectedDeleteQuery)
+	rows.AddRow("not a url", false)
+	mock.ExpectQuery("SELECT url, nitter FROM feeds").WillReturnRows(rows)
 	mock.ExpectClose()
 
-	parsedFeed, entity := GetParsedFeedForPubKey(samplePubKey, db, true, nitterInstances)
+	parsedFeed, entity, err := GetParsedFeedForPubKey(samplePubKey, db, nitterInstances)
 	assert.Nil(t, parsedFeed)
+	assert.ErrorIs(t, err, ErrInvalidFeedURL)
 	assert.Equal(t, feed.Entity{
-		PublicKey:  "",
-		PrivateKey: samplePrivateKey,
-		URL:        "not a url",
-		Nitter:     false,
+		PublicKey: "",
+		URL:       "not a url",
+		Nitter:    false,
 	}, entity)
 	_ = db.Close()
 }
@@ -25,16 +25,46 @@ type ItemToEventConverter interface {
 	Convert(pubkey string, item *gofeed.Item, feed *gofeed.Feed, defaultCreatedAt time.Time, originalUrl string) nostr.Event
 }
 
+// OutputMode is the kind of events the items of a feed are converted to.
+type OutputMode string
+
+const (
+	OutputModeLongForm OutputMode = "longform"
+	OutputModeNote     OutputMode = "note"
+)
+
+var ErrUnknownOutputMode = errors.New("unknown output mode")
+
 type ConverterSelector struct {
 	longFormConverter ItemToEventConverter
+	noteConverter     ItemToEventConverter
 }
 
-func NewConverterSelector(longFormConverter ItemToEventConverter) *ConverterSelector {
-	return &ConverterSelector{longFormConverter: longFormConverter}
+func NewConverterSelector(longFormConverter ItemToEventConverter, noteConverter ItemToEventConverter) *ConverterSelector {
+	return &ConverterSelector{longFormConverter: longFormConverter, noteConverter: noteConverter}
+}
+
+// Mode returns the output mode used for the items of the feed.
+func (s *ConverterSelector) Mode(feed *gofeed.Feed) OutputMode {
+	return OutputModeLongForm
 }
 
 func (s *ConverterSelector) Select(feed *gofeed.Feed) ItemToEventConverter {
-	return s.longFormConverter
+	converter, _ := s.SelectMode(s.Mode(feed))
+	return converter
+}
+
+// SelectMode returns the converter of an output mode regardless of the one
+// which would be selected for a feed.
+func (s *ConverterSelector) SelectMode(mode OutputMode) (ItemToEventConverter, error) {
+	switch mode {
+	case OutputModeLongForm:
+		return s.longFormConverter, nil
+	case OutputModeNote:
+		return s.noteConverter, nil
+	default:
+		return nil, ErrUnknownOutputMode
+	}
 }
 
 type NoteConverter struct {
@@ -315,3 +315,24 @@ func TestLongFormConverter(t *testing.T) {
 		assert.Equal(t, tc.expectedTags, event.Tags)
 	}
 }
+
+func TestConverterSelectorSelectMode(t *testing.T) {
+	noteConverter, err := NewNoteConverter(250)
+	require.NoError(t, err)
+	longFormConverter := NewLongFormConverter()
+	selector := NewConverterSelector(longFormConverter, noteConverter)
+
+	converter, err := selector.SelectMode(OutputModeNote)
+	require.NoError(t, err)
+	require.Equal(t, noteConverter, converter)
+
+	converter, err = selector.SelectMode(OutputModeLongForm)
+	require.NoError(t, err)
+	require.Equal(t, longFormConverter, converter)
+
+	require.Equal(t, OutputModeLongForm, selector.Mode(&sampleDefaultFeed))
+	require.Equal(t, longFormConverter, selector.Select(&sampleDefaultFeed))
+
+	_, err = selector.SelectMode("other")
+	require.ErrorIs(t, err, ErrUnknownOutputMode)
+}
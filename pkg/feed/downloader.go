@@ -8,31 +8,67 @@ import (
 	"time"
 )
 
+const maxRedirects = 10
+
 type Downloader struct {
 }
 
+// HTTPStatusError is returned when a feed is served with an unsuccessful
+// status code.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("http error %d", e.StatusCode)
+}
+
 func NewDownloader() *Downloader {
 	return &Downloader{}
 }
 
-func (*Downloader) Download(url string) (io.ReadCloser, error) {
-	client := http.Client{Timeout: 30 * time.Second}
+// Download returns the body served at the url. If the url only leads to the
+// body through permanent redirects the final url is returned as well so that
+// the caller can stop using the old one.
+func (*Downloader) Download(url string) (io.ReadCloser, string, error) {
+	permanent := true
+	client := http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if !isPermanentRedirect(req.Response.StatusCode) {
+				permanent = false
+			}
+			return nil
+		},
+	}
 
 	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	req.Header.Set("User-Agent", "rsslay")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		resp.Body.Close()
-		return nil, fmt.Errorf("http error %d", resp.StatusCode)
+		return nil, "", HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	var movedTo string
+	if finalUrl := resp.Request.URL.String(); permanent && finalUrl != url {
+		movedTo = finalUrl
 	}
 
-	return resp.Body, nil
+	return resp.Body, movedTo, nil
+}
+
+func isPermanentRedirect(statusCode int) bool {
+	return statusCode == http.StatusMovedPermanently || statusCode == http.StatusPermanentRedirect
 }
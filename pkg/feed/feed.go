@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -25,8 +24,16 @@ import (
 )
 
 const (
-	causesLink       = "https://www.causes.com/api/v2/articles?feed_id=recency"
-	causesNumWorkers = 10
+	causesLink           = "https://www.causes.com/api/v2/articles?feed_id=recency"
+	causesNumWorkers     = 10
+	siteImageCachePrefix = "site-image:"
+
+	// permanentRedirectKey is stored in the custom fields of parsed feeds
+	// so that permanent redirects survive the cache.
+	permanentRedirectKey = "rsslay:permanent-redirect"
+
+	// KindRelayList is defined in NIP-65.
+	KindRelayList = 10002
 )
 
 var (
@@ -144,10 +151,93 @@ func getFeedParser(feedURL string) FeedParser {
 	}
 }
 
-func EntryFeedToSetMetadata(pubkey string, feed *gofeed.Feed, originalUrl string, enableAutoRegistration bool, defaultProfilePictureUrl string, mainDomainName string) nostr.Event {
+// GetSiteImageURL returns the image advertised by the Open Graph or Twitter
+// card tags of a website, if any. Results are cached as fetching the page is
+// expensive.
+func GetSiteImageURL(url string) string {
+	cacheKey := siteImageCachePrefix + url
+	if imageUrl, err := custom_cache.Get(cacheKey); err == nil {
+		return imageUrl
+	}
+
+	imageUrl := fetchSiteImageURL(url)
+	if err := custom_cache.Set(cacheKey, imageUrl); err != nil {
+		log.Printf("[ERROR] failure to store into cache site image: %v", err)
+		metrics.AppErrors.With(prometheus.Labels{"type": "CACHE_SET"}).Inc()
+	}
+
+	return imageUrl
+}
+
+func fetchSiteImageURL(url string) string {
+	if !helpers.IsValidHttpUrl(url) {
+		return ""
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 || !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return ""
+	}
+
+	for _, selector := range []string{"meta[property='og:image']", "meta[name='twitter:image']"} {
+		href, _ := doc.Find(selector).Attr("content")
+		if href == "" {
+			continue
+		}
+		if !strings.HasPrefix(href, "http") {
+			href, _ = helpers.UrlJoin(url, href)
+		}
+		return href
+	}
+
+	return ""
+}
+
+// ProfileOverrides are the profile fields set by the owner of the feed which
+// replace the ones taken from the feed. Empty fields aren't overridden.
+type ProfileOverrides struct {
+	Name    string
+	About   string
+	Picture string
+	Banner  string
+	Lud16   string
+	Website string
+}
+
+// MetadataOptions are the parts of the profile of a feed which don't come
+// from the parsed feed itself.
+type MetadataOptions struct {
+	// OriginalUrl is the address the feed is fetched from.
+	OriginalUrl string
+	// EnableAutoRegistration adds the NIP-05 identifier made of the slug and
+	// the main domain name.
+	EnableAutoRegistration   bool
+	Slug                     string
+	MainDomainName           string
+	DefaultProfilePictureUrl string
+	// SiteImageUrl is the image of the website, used as the banner.
+	SiteImageUrl string
+	// MovedFrom is the previous address of a feed which moved.
+	MovedFrom string
+	// MigratedTo is the npub of the owner who now publishes the feed.
+	MigratedTo string
+	Overrides  ProfileOverrides
+}
+
+func EntryFeedToSetMetadata(pubkey string, feed *gofeed.Feed, options MetadataOptions) nostr.Event {
 	// Handle Nitter special cases (http schema)
 	if strings.Contains(feed.Description, "Twitter feed") {
-		if strings.HasPrefix(originalUrl, "https://") {
+		if strings.HasPrefix(options.OriginalUrl, "https://") {
 			feed.Description = strings.ReplaceAll(feed.Description, "http://", "https://")
 			feed.Title = strings.ReplaceAll(feed.Title, "http://", "https://")
 			if feed.Image != nil {
@@ -167,21 +257,44 @@ func EntryFeedToSetMetadata(pubkey string, feed *gofeed.Feed, originalUrl string
 
 		theFeedTitle = "/r/" + subredditParsePart2[0]
 	}
-	metadata := map[string]string{
-		"name":  theFeedTitle + " (RSS Feed)",
-		"about": theDescription + "\n\n" + feed.Link,
+	about := theDescription + "\n\n" + feed.Link
+	if options.Overrides.About != "" {
+		about = options.Overrides.About
+	}
+	if options.MovedFrom != "" {
+		about += fmt.Sprintf("\n\nThis feed moved from %s to %s.", options.MovedFrom, options.OriginalUrl)
+	}
+	if options.MigratedTo != "" {
+		about += fmt.Sprintf("\n\nThis feed is now published by its owner nostr:%s.", options.MigratedTo)
+	}
+
+	metadata := map[string]any{
+		"name":         theFeedTitle + " (RSS Feed)",
+		"display_name": theFeedTitle,
+		"about":        about,
+		"bot":          true,
+	}
+
+	if helpers.IsValidHttpUrl(feed.Link) {
+		metadata["website"] = feed.Link
 	}
 
-	if enableAutoRegistration {
-		metadata["nip05"] = fmt.Sprintf("%s@%s", originalUrl, mainDomainName)
+	if options.EnableAutoRegistration && options.Slug != "" {
+		metadata["nip05"] = fmt.Sprintf("%s@%s", options.Slug, options.MainDomainName)
 	}
 
 	if feed.Image != nil {
 		metadata["picture"] = feed.Image.URL
-	} else if defaultProfilePictureUrl != "" {
-		metadata["picture"] = defaultProfilePictureUrl
+	} else if options.DefaultProfilePictureUrl != "" {
+		metadata["picture"] = options.DefaultProfilePictureUrl
+	}
+
+	if banner := feedBannerURL(feed, options.SiteImageUrl); banner != "" {
+		metadata["banner"] = banner
 	}
 
+	options.Overrides.apply(metadata)
+
 	content, _ := json.Marshal(metadata)
 
 	createdAt := time.Unix(time.Now().Unix(), 0)
@@ -201,6 +314,69 @@ func EntryFeedToSetMetadata(pubkey string, feed *gofeed.Feed, originalUrl string
 	return evt
 }
 
+// apply merges the overrides over the metadata taken from the feed, the
+// about is merged beforehand to keep the notes about the feed.
+func (o ProfileOverrides) apply(metadata map[string]any) {
+	if o.Name != "" {
+		metadata["name"] = o.Name
+		metadata["display_name"] = o.Name
+	}
+
+	for key, value := range map[string]string{
+		"picture": o.Picture,
+		"banner":  o.Banner,
+		"lud16":   o.Lud16,
+		"website": o.Website,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+}
+
+// feedBannerURL prefers the podcast artwork and then the image of the site
+// as the feed image is already used as the profile picture.
+func feedBannerURL(feed *gofeed.Feed, siteImageUrl string) string {
+	var picture string
+	if feed.Image != nil {
+		picture = feed.Image.URL
+	}
+
+	for _, candidate := range []string{itunesImageURL(feed), siteImageUrl} {
+		if candidate != "" && candidate != picture {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func itunesImageURL(feed *gofeed.Feed) string {
+	if feed.ITunesExt == nil {
+		return ""
+	}
+	return feed.ITunesExt.Image
+}
+
+// EntryFeedToRelayList creates a NIP-65 relay list event pointing clients
+// using the outbox model to the relays which serve the feed events.
+func EntryFeedToRelayList(pubkey string, relays []string) nostr.Event {
+	tags := nostr.Tags{}
+	for _, relay := range relays {
+		tags = append(tags, nostr.Tag{"r", relay})
+	}
+
+	evt := nostr.Event{
+		PubKey:    pubkey,
+		CreatedAt: nostr.Now(),
+		Kind:      KindRelayList,
+		Tags:      tags,
+		Content:   "",
+	}
+	evt.ID = string(evt.Serialize())
+
+	return evt
+}
+
 func PrivateKeyFromFeed(url string, secret string) string {
 	m := hmac.New(sha256.New, []byte(secret))
 	m.Write([]byte(url))
@@ -208,15 +384,6 @@ func PrivateKeyFromFeed(url string, secret string) string {
 	return hex.EncodeToString(r)
 }
 
-func DeleteInvalidFeed(url string, db *sql.DB) {
-	if _, err := db.Exec(`DELETE FROM feeds WHERE url=?`, url); err != nil {
-		log.Printf("[ERROR] failure to delete invalid feed: %v", err)
-		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
-	} else {
-		log.Printf("[DEBUG] deleted invalid feed with url %q", url)
-	}
-}
-
 type FeedParser interface {
 	Parse() (*gofeed.Feed, error)
 }
@@ -231,14 +398,32 @@ func NewDefaultFeedParser(downloader *Downloader, url string) *DefaultFeedParser
 }
 
 func (d *DefaultFeedParser) Parse() (*gofeed.Feed, error) {
-	body, err := d.downloader.Download(d.url)
+	body, movedTo, err := d.downloader.Download(d.url)
 	if err != nil {
 		return nil, err
 	}
 	defer body.Close()
 
 	fp.RSSTranslator = NewCustomTranslator()
-	return fp.Parse(body)
+	feed, err := fp.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if movedTo != "" {
+		if feed.Custom == nil {
+			feed.Custom = make(map[string]string)
+		}
+		feed.Custom[permanentRedirectKey] = movedTo
+	}
+
+	return feed, nil
+}
+
+// PermanentRedirect returns the url which the feed was permanently
+// redirected to when it was fetched, if any.
+func PermanentRedirect(feed *gofeed.Feed) string {
+	return feed.Custom[permanentRedirectKey]
 }
 
 type causesResponseOrError struct {
@@ -350,7 +535,7 @@ func (d *CausesFeedParser) work(page int) (causesResponse, error) {
 func (d *CausesFeedParser) get(url string) (causesResponse, error) {
 	var resp causesResponse
 
-	body, err := d.downloader.Download(url)
+	body, _, err := d.downloader.Download(url)
 	if err != nil {
 		return resp, err
 	}
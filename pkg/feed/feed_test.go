@@ -1,13 +1,14 @@
 package feed
 
 import (
-	"database/sql"
-	"errors"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/mmcdole/gofeed"
+	"github.com/nbd-wtf/go-nostr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,6 +83,33 @@ func TestParseFeedWithCachedUrlReturnsCachedParsedFeed(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestDefaultFeedParserRecordsPermanentRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.Handle("/temporary", http.RedirectHandler("/old", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>moved</title></channel></rss>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/new", expected: ""},
+		{path: "/old", expected: server.URL + "/new"},
+		{path: "/temporary", expected: ""},
+	}
+	for _, tc := range testCases {
+		feed, err := NewDefaultFeedParser(NewDownloader(), server.URL+tc.path).Parse()
+		assert.NoError(t, err)
+		assert.Equal(t, "moved", feed.Title)
+		assert.Equal(t, tc.expected, PermanentRedirect(feed), tc.path)
+	}
+}
+
 func TestEntryFeedToSetMetadata(t *testing.T) {
 	testCases := []struct {
 		pubKey                   string
@@ -109,49 +137,93 @@ func TestEntryFeedToSetMetadata(t *testing.T) {
 		},
 	}
 	for _, tc := range testCases {
-		metadata := EntryFeedToSetMetadata(tc.pubKey, tc.feed, tc.originalUrl, tc.enableAutoRegistration, tc.defaultProfilePictureUrl, tc.defaultMainDomain)
+		metadata := EntryFeedToSetMetadata(tc.pubKey, tc.feed, MetadataOptions{
+			OriginalUrl:              tc.originalUrl,
+			EnableAutoRegistration:   tc.enableAutoRegistration,
+			Slug:                     "example-com",
+			MainDomainName:           tc.defaultMainDomain,
+			DefaultProfilePictureUrl: tc.defaultProfilePictureUrl,
+		})
 		assert.NotEmpty(t, metadata)
 		assert.Equal(t, samplePubKey, metadata.PubKey)
 		assert.Equal(t, 0, metadata.Kind)
 		assert.Empty(t, metadata.Sig)
+
+		var content map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(metadata.Content), &content))
+		assert.Equal(t, true, content["bot"])
+		assert.Equal(t, tc.feed.Title, content["display_name"])
+		assert.Equal(t, "example-com@"+tc.defaultMainDomain, content["nip05"])
 	}
 }
 
-func TestPrivateKeyFromFeed(t *testing.T) {
-	sk := PrivateKeyFromFeed(sampleUrlForPublicKey, testSecret)
-	assert.Equal(t, samplePrivateKeyForPubKey, sk)
+func TestEntryFeedToSetMetadataBanner(t *testing.T) {
+	feed := sampleDefaultFeed
+	feed.Image = &gofeed.Image{URL: "https://example.com/logo.png"}
+
+	metadata := EntryFeedToSetMetadata(samplePubKey, &feed, MetadataOptions{OriginalUrl: feed.FeedLink, SiteImageUrl: "https://example.com/og.png"})
+
+	var content map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(metadata.Content), &content))
+	assert.Equal(t, "https://example.com/logo.png", content["picture"])
+	assert.Equal(t, "https://example.com/og.png", content["banner"])
 }
 
-func TestDeleteExistingInvalidFeed(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			t.Fatalf("an error '%s' was not expected when closing a stub database connection", err)
-		}
-	}(db)
-
-	mock.ExpectExec("DELETE FROM feeds").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectClose()
-	DeleteInvalidFeed(sampleUrlForPublicKey, db)
-}
-
-func TestDeleteNonExistingInvalidFeed(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+func TestEntryFeedToSetMetadataMoved(t *testing.T) {
+	feed := sampleDefaultFeed
+
+	metadata := EntryFeedToSetMetadata(samplePubKey, &feed, MetadataOptions{OriginalUrl: "https://new.example/feed.xml", MovedFrom: "https://old.example/rss"})
+
+	var content map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(metadata.Content), &content))
+	assert.Contains(t, content["about"], "This feed moved from https://old.example/rss to https://new.example/feed.xml.")
+}
+
+func TestEntryFeedToSetMetadataMigrated(t *testing.T) {
+	feed := sampleDefaultFeed
+
+	metadata := EntryFeedToSetMetadata(samplePubKey, &feed, MetadataOptions{OriginalUrl: feed.FeedLink, MigratedTo: "npub1owner"})
+
+	var content map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(metadata.Content), &content))
+	assert.Contains(t, content["about"], "This feed is now published by its owner nostr:npub1owner.")
+}
+
+func TestEntryFeedToSetMetadataOverrides(t *testing.T) {
+	feed := sampleDefaultFeed
+	overrides := ProfileOverrides{
+		Name:   "Example",
+		About:  "The example blog",
+		Banner: "https://example.com/banner.png",
+		Lud16:  "tips@example.com",
 	}
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			t.Fatalf("an error '%s' was not expected when closing a stub database connection", err)
-		}
-	}(db)
-
-	mock.ExpectExec("DELETE FROM feeds").WillReturnError(errors.New(""))
-	mock.ExpectClose()
-	DeleteInvalidFeed(sampleUrlForPublicKey, db)
+
+	metadata := EntryFeedToSetMetadata(samplePubKey, &feed, MetadataOptions{
+		OriginalUrl:              feed.FeedLink,
+		DefaultProfilePictureUrl: "https://image.example",
+		MigratedTo:               "npub1owner",
+		Overrides:                overrides,
+	})
+
+	var content map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(metadata.Content), &content))
+	assert.Equal(t, "Example", content["name"])
+	assert.Equal(t, "Example", content["display_name"])
+	assert.Equal(t, "The example blog\n\nThis feed is now published by its owner nostr:npub1owner.", content["about"])
+	assert.Equal(t, "https://image.example", content["picture"])
+	assert.Equal(t, "https://example.com/banner.png", content["banner"])
+	assert.Equal(t, "tips@example.com", content["lud16"])
+	assert.Equal(t, true, content["bot"])
+}
+
+func TestEntryFeedToRelayList(t *testing.T) {
+	relayList := EntryFeedToRelayList(samplePubKey, []string{"wss://rsslay.nostr.moe", "wss://mirror.example"})
+	assert.Equal(t, KindRelayList, relayList.Kind)
+	assert.Equal(t, samplePubKey, relayList.PubKey)
+	assert.Equal(t, nostr.Tags{{"r", "wss://rsslay.nostr.moe"}, {"r", "wss://mirror.example"}}, relayList.Tags)
+}
+
+func TestPrivateKeyFromFeed(t *testing.T) {
+	sk := PrivateKeyFromFeed(sampleUrlForPublicKey, testSecret)
+	assert.Equal(t, samplePrivateKeyForPubKey, sk)
 }
@@ -0,0 +1,169 @@
+package feed
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"github.com/nbd-wtf/go-nostr/nip05"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/piraces/rsslay/pkg/helpers"
+)
+
+const (
+	OwnershipProofFeed  = "feed"
+	OwnershipProofMeta  = "meta"
+	OwnershipProofNIP05 = "nip05"
+
+	maxWellKnownSize = 1 << 20
+)
+
+var ErrOwnershipNotProven = errors.New("no proof of ownership found in the feed, the HTML of its site or the /.well-known/nostr.json of that host")
+
+// VerifyOwner looks for a proof that the owner of the public key controls
+// the feed and returns the kind of proof found. The proofs are, in order, a
+// <nostr:pubkey> element or a "nostr:npub..." link in the feed, a
+// <meta name="nostr"> tag in the HTML of its site and the "_" or slug entry
+// of the /.well-known/nostr.json of the host of the site. Only the site the
+// feed links to is checked, the root of a host shared by many feeds proves
+// nothing about each of them. The feed is fetched again as the owner usually
+// just added the proof.
+func VerifyOwner(feedURL string, slug string, publicKey string) (string, error) {
+	parsedFeed, err := NewDefaultFeedParser(NewDownloader(), feedURL).Parse()
+	if err != nil {
+		return "", err
+	}
+
+	if feedProvesOwner(parsedFeed, publicKey) {
+		return OwnershipProofFeed, nil
+	}
+
+	site := parsedFeed.Link
+	if !helpers.IsValidHttpUrl(site) {
+		return "", ErrOwnershipNotProven
+	}
+
+	if siteMetaProvesOwner(site, publicKey) {
+		return OwnershipProofMeta, nil
+	}
+	if wellKnownProvesOwner(site, slug, publicKey) {
+		return OwnershipProofNIP05, nil
+	}
+
+	return "", ErrOwnershipNotProven
+}
+
+func feedProvesOwner(parsedFeed *gofeed.Feed, publicKey string) bool {
+	for _, extensions := range parsedFeed.Extensions["nostr"] {
+		for _, extension := range extensions {
+			if matchesPublicKey(extension.Value, publicKey) {
+				return true
+			}
+		}
+	}
+
+	// atom feeds list all their links while RSS feeds keep the atom ones as
+	// extensions
+	links := parsedFeed.Links
+	for _, extension := range parsedFeed.Extensions["atom"]["link"] {
+		links = append(links, extension.Attrs["href"])
+	}
+	for _, link := range links {
+		if strings.HasPrefix(link, "nostr:") && matchesPublicKey(link, publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func siteMetaProvesOwner(site string, publicKey string) bool {
+	resp, err := client.Get(site)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 || !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return false
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return false
+	}
+
+	found := false
+	doc.Find("meta[name='nostr']").Each(func(_ int, selection *goquery.Selection) {
+		content, _ := selection.Attr("content")
+		for _, value := range strings.Fields(content) {
+			found = found || matchesPublicKey(value, publicKey)
+		}
+	})
+	return found
+}
+
+// wellKnownProvesOwner accepts the root identifier of the host and the slug
+// of the feed, other names belong to other users of the host.
+func wellKnownProvesOwner(site string, slug string, publicKey string) bool {
+	u, err := url.Parse(site)
+	if err != nil {
+		return false
+	}
+
+	names := []string{"_"}
+	if slug != "" && slug != "_" {
+		names = append(names, slug)
+	}
+
+	for _, name := range names {
+		if wellKnownNameMatches(u, name, publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func wellKnownNameMatches(site *url.URL, name string, publicKey string) bool {
+	wellKnown := url.URL{
+		Scheme:   site.Scheme,
+		Host:     site.Host,
+		Path:     "/.well-known/nostr.json",
+		RawQuery: url.Values{"name": {name}}.Encode(),
+	}
+
+	resp, err := client.Get(wellKnown.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	var response nip05.WellKnownResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWellKnownSize)).Decode(&response); err != nil {
+		return false
+	}
+
+	return matchesPublicKey(response.Names[name], publicKey)
+}
+
+// matchesPublicKey compares a hex or npub value, optionally prefixed with
+// "nostr:", with a hex public key.
+func matchesPublicKey(value string, publicKey string) bool {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "nostr:")
+	if strings.HasPrefix(value, "npub1") {
+		prefix, decoded, err := nip19.Decode(value)
+		if err != nil || prefix != "npub" {
+			return false
+		}
+		value, _ = decoded.(string)
+	}
+	return value != "" && strings.EqualFold(value, publicKey)
+}
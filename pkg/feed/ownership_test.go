@@ -0,0 +1,72 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyOwner(t *testing.T) {
+	npub, err := nip19.EncodePublicKey(samplePubKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		feedExtra string
+		meta      string
+		rootMeta  string
+		wellKnown string
+		expected  string
+	}{
+		{name: "feed element", feedExtra: `<nostr:pubkey>` + npub + `</nostr:pubkey>`, expected: OwnershipProofFeed},
+		{name: "feed link", feedExtra: `<atom:link rel="me" href="nostr:` + npub + `"/>`, expected: OwnershipProofFeed},
+		{name: "meta tag", meta: `<meta name="nostr" content="` + npub + `">`, expected: OwnershipProofMeta},
+		{name: "well-known slug", wellKnown: `{"names":{"example-blog":"` + samplePubKey + `"}}`, expected: OwnershipProofNIP05},
+		{name: "well-known root", wellKnown: `{"names":{"_":"` + samplePubKey + `"}}`, expected: OwnershipProofNIP05},
+		{name: "well-known other name", wellKnown: `{"names":{"someone-else":"` + samplePubKey + `"}}`},
+		{name: "meta tag of the host root", rootMeta: `<meta name="nostr" content="` + npub + `">`},
+		{name: "other key", meta: `<meta name="nostr" content="` + samplePubKey[1:] + `0">`, wellKnown: `{"names":{"_":"abc"}}`},
+		{name: "no proof"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/rss+xml")
+				_, _ = fmt.Fprintf(w, `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:nostr="https://nostr.com/"><channel><title>blog</title><link>%s/blog/</link>%s</channel></rss>`, server.URL, tc.feedExtra)
+			})
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = fmt.Fprintf(w, `<html><head>%s</head></html>`, tc.rootMeta)
+			})
+			mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = fmt.Fprintf(w, `<html><head>%s</head></html>`, tc.meta)
+			})
+			mux.HandleFunc("/.well-known/nostr.json", func(w http.ResponseWriter, r *http.Request) {
+				if tc.wellKnown == "" {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write([]byte(tc.wellKnown))
+			})
+
+			proof, err := VerifyOwner(server.URL+"/feed", "example-blog", samplePubKey)
+			if tc.expected == "" {
+				assert.ErrorIs(t, err, ErrOwnershipNotProven)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, proof)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const prefix = "enc:"
+
+var ErrUnknownKey = errors.New("value is encrypted with an unknown key encryption key")
+
+// Keyring encrypts secrets at rest with AES-256-GCM using versioned key
+// encryption keys. Values are always encrypted with the newest key but can
+// be decrypted with any of them so that keys can be rotated. A keyring
+// without keys stores values in plaintext.
+type Keyring struct {
+	current int
+	keys    map[int]cipher.AEAD
+}
+
+// New creates a keyring from the key encryption keys indexed by their
+// version. The keys should be long random strings.
+func New(keys map[int]string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[int]cipher.AEAD)}
+	for version, key := range keys {
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid key version %d", version)
+		}
+
+		if key == "" {
+			return nil, fmt.Errorf("key %d can't be an empty string", version)
+		}
+
+		sum := sha256.Sum256([]byte(key))
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys[version] = aead
+		if version > k.current {
+			k.current = version
+		}
+	}
+	return k, nil
+}
+
+// Current returns the version of the key used to encrypt values, zero if
+// values are stored in plaintext.
+func (k *Keyring) Current() int {
+	return k.current
+}
+
+// Encrypt encrypts the value with the current key. The associated data isn't
+// encrypted but must be the same when decrypting, which prevents encrypted
+// values from being swapped.
+func (k *Keyring) Encrypt(value, associatedData string) (string, error) {
+	if k.current == 0 {
+		return value, nil
+	}
+
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(associatedData))
+	return prefix + strconv.Itoa(k.current) + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted with any of the keys. Plaintext values
+// are returned as they are.
+func (k *Keyring) Decrypt(stored, associatedData string) (string, error) {
+	version := Version(stored)
+	if version == 0 {
+		return stored, nil
+	}
+
+	aead, ok := k.keys[version]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	_, encoded, _ := strings.Cut(strings.TrimPrefix(stored, prefix), ":")
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+
+	value, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(associatedData))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting the value: %w", err)
+	}
+	return string(value), nil
+}
+
+// Version returns the version of the key which the value was encrypted with,
+// zero if it is stored in plaintext.
+func Version(stored string) int {
+	if !strings.HasPrefix(stored, prefix) {
+		return 0
+	}
+
+	s, _, _ := strings.Cut(strings.TrimPrefix(stored, prefix), ":")
+	version, err := strconv.Atoi(s)
+	if err != nil || version <= 0 {
+		return -1
+	}
+	return version
+}
+
+// HasVersion reports whether values encrypted with the key version can be
+// decrypted, version zero stands for plaintext values.
+func (k *Keyring) HasVersion(version int) bool {
+	if version == 0 {
+		return true
+	}
+	_, ok := k.keys[version]
+	return ok
+}
@@ -0,0 +1,64 @@
+package keyring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleValue = "4d0888c07093941c9db16fcffb96fdf8af49a6839e865ea6110c7ab7cbd2d3d3"
+
+func TestKeyringWithoutKeysStoresPlaintext(t *testing.T) {
+	k, err := New(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, k.Current())
+
+	stored, err := k.Encrypt(sampleValue, "pubkey")
+	require.NoError(t, err)
+	assert.Equal(t, sampleValue, stored)
+
+	value, err := k.Decrypt(stored, "pubkey")
+	require.NoError(t, err)
+	assert.Equal(t, sampleValue, value)
+}
+
+func TestKeyringEncryptsWithTheNewestKey(t *testing.T) {
+	old, err := New(map[int]string{1: "old key"})
+	require.NoError(t, err)
+
+	storedWithOld, err := old.Encrypt(sampleValue, "pubkey")
+	require.NoError(t, err)
+	assert.Equal(t, 1, Version(storedWithOld))
+	assert.NotContains(t, storedWithOld, sampleValue)
+
+	k, err := New(map[int]string{1: "old key", 2: "new key"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, k.Current())
+
+	stored, err := k.Encrypt(sampleValue, "pubkey")
+	require.NoError(t, err)
+	assert.Equal(t, 2, Version(stored))
+
+	for _, s := range []string{stored, storedWithOld, sampleValue} {
+		value, err := k.Decrypt(s, "pubkey")
+		require.NoError(t, err)
+		assert.Equal(t, sampleValue, value)
+	}
+
+	_, err = k.Decrypt(stored, "other pubkey")
+	assert.Error(t, err)
+
+	_, err = old.Decrypt(stored, "pubkey")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.False(t, old.HasVersion(Version(stored)))
+	assert.True(t, old.HasVersion(Version(sampleValue)))
+}
+
+func TestNewRejectsInvalidKeys(t *testing.T) {
+	_, err := New(map[int]string{0: "key"})
+	assert.Error(t, err)
+
+	_, err = New(map[int]string{1: ""})
+	assert.Error(t, err)
+}
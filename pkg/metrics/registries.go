@@ -18,14 +18,34 @@ var (
 		Name: "rsslay_processed_create_ops_total",
 		Help: "The total number of processed create feed requests",
 	})
+	PreviewRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_preview_ops_total",
+		Help: "The total number of processed feed preview requests",
+	})
+	ProfilePageRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_profile_page_ops_total",
+		Help: "The total number of processed feed profile page requests",
+	})
+	ItemPageRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_item_page_ops_total",
+		Help: "The total number of processed feed item page requests",
+	})
 	CreateRequestsAPI = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "rsslay_processed_create_api_ops_total",
 		Help: "The total number of processed create feed requests via API",
 	})
+	ImportRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_import_ops_total",
+		Help: "The total number of processed OPML import requests",
+	})
 	WellKnownRequests = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "rsslay_processed_wellknown_ops_total",
 		Help: "The total number of processed well-known requests",
 	})
+	ManagementRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_management_ops_total",
+		Help: "The total number of processed relay management requests",
+	})
 	RelayInfoRequests = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "rsslay_processed_relay_info_ops_total",
 		Help: "The total number of processed relay info requests",
@@ -38,6 +58,14 @@ var (
 		Name: "rsslay_processed_invalid_events_ops_total",
 		Help: "The total number of processed invalid events requests",
 	})
+	UserEventsSaved = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_user_events_ops_total",
+		Help: "The total number of saved events published by users",
+	})
+	BotMessagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_processed_bot_messages_ops_total",
+		Help: "The total number of direct messages received by the bot",
+	})
 	CacheHits = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "rsslay_processed_cache_hits_ops_total",
 		Help: "The total number of cache hits",
@@ -66,4 +94,44 @@ var (
 		Name: "rsslay_update_results",
 		Help: "Feed update results",
 	}, []string{"result"})
+	FeedFetchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rsslay_feed_fetch_errors_total",
+		Help: "Number of failed feed fetches by kind of error.",
+	}, []string{"kind"})
+	FeedsByHealthState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rsslay_feeds_by_health_state",
+		Help: "Number of feeds in each health state after the last update.",
+	}, []string{"state"})
+	FailingFeedsDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_failing_feeds_deleted_total",
+		Help: "The total number of feeds deleted after failing for too long",
+	})
+	FeedUpdateErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_feed_update_errors_total",
+		Help: "The total number of feed updates which failed for reasons other than the feed, such as signing, without changing its health",
+	})
+	FeedsMoved = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rsslay_feeds_moved_total",
+		Help: "The total number of feeds which followed their publisher to a new address",
+	})
+	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rsslay_processed_api_ops_total",
+		Help: "The total number of processed REST API requests by operation and status code.",
+	}, []string{"operation", "code"})
+	FeedCreationRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rsslay_feed_creation_rejections_total",
+		Help: "Number of rejected feed creation requests by reason.",
+	}, []string{"reason"})
+	FeedModerationDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rsslay_feed_moderation_decisions_total",
+		Help: "Number of pending feeds approved or rejected by the moderators.",
+	}, []string{"decision"})
+	ImportedFeeds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rsslay_imported_feeds_total",
+		Help: "Number of subscriptions of OPML imports by outcome.",
+	}, []string{"outcome"})
+	APIQuotaRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rsslay_api_quota_rejections_total",
+		Help: "Number of API requests rejected by the quotas by kind of client.",
+	}, []string{"kind"})
 )
@@ -0,0 +1,368 @@
+// Package migrations applies versioned schema migrations to the database.
+//
+// Migrations are SQL files named after their version and a short
+// description, e.g. "0002_add_nitter_column.sql". Versions start at 1 and
+// must be consecutive. Every migration runs in its own transaction and is
+// recorded in the schema_migrations table. Instances sharing a database
+// serialize their migrations with a lock taken at the start of each
+// transaction, see SetLock.
+//
+// Databases created before migrations were introduced have no record of the
+// applied migrations. A migration may start with an "-- applied-if: <query>"
+// line, the migration is then considered to be applied if the query succeeds
+// against such a database.
+package migrations
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const appliedIfPrefix = "-- applied-if:"
+
+var ErrSchemaTooNew = errors.New("database schema is newer than the one supported by this version")
+
+type Migration struct {
+	Version   int
+	Name      string
+	sql       string
+	appliedIf string
+}
+
+type Status struct {
+	Migration
+	Applied bool
+
+	// AppliedAt is zero for migrations applied before migrations were
+	// recorded.
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	lock       string
+}
+
+// NewMigrator loads the migrations from the root of files.
+func NewMigrator(db *sql.DB, files fs.FS) (*Migrator, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing migrations")
+	}
+
+	var migrations []Migration
+	for _, name := range names {
+		migration, err := loadMigration(files, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading migration '%s'", name)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be consecutive, expected %d but got %d", i+1, migration.Version)
+		}
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigration(files fs.FS, name string) (Migration, error) {
+	content, err := fs.ReadFile(files, name)
+	if err != nil {
+		return Migration{}, errors.Wrap(err, "error reading the file")
+	}
+
+	prefix, description, ok := strings.Cut(strings.TrimSuffix(path.Base(name), ".sql"), "_")
+	if !ok {
+		return Migration{}, errors.New("file name must look like '0001_description.sql'")
+	}
+
+	version, err := strconv.Atoi(prefix)
+	if err != nil {
+		return Migration{}, errors.Wrap(err, "invalid version")
+	}
+
+	migration := Migration{
+		Version: version,
+		Name:    description,
+		sql:     string(content),
+	}
+
+	firstLine, _ := bufio.NewReader(strings.NewReader(migration.sql)).ReadString('\n')
+	if strings.HasPrefix(firstLine, appliedIfPrefix) {
+		migration.appliedIf = strings.TrimSpace(strings.TrimPrefix(firstLine, appliedIfPrefix))
+	}
+
+	return migration, nil
+}
+
+// SetLock sets the query run at the start of the transactions of the
+// migrator, which must take a lock held until the end of the transaction.
+// Without it concurrent migrations of the same database may race.
+func (m *Migrator) SetLock(query string) {
+	m.lock = query
+}
+
+// Latest returns the version of the schema after applying all migrations.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Status lists all known migrations. It fails with ErrSchemaTooNew if the
+// database was migrated by a newer version.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Status
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		result = append(result, Status{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return result, m.checkNotTooNew(applied)
+}
+
+// Up applies all pending migrations and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.checkNotTooNew(applied); err != nil {
+		return nil, err
+	}
+
+	var result []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		ok, err := m.apply(migration)
+		if err != nil {
+			return result, errors.Wrapf(err, "error applying migration %d (%s)", migration.Version, migration.Name)
+		}
+		if ok {
+			result = append(result, migration)
+		}
+	}
+
+	return result, nil
+}
+
+// apply applies the migration unless another instance applied it since the
+// applied migrations were read, and reports whether it did.
+func (m *Migrator) apply(migration Migration) (bool, error) {
+	tx, err := m.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() // not much we can do here, no-op after a commit
+
+	var count int
+	if err := tx.QueryRow(`SELECT count(*) FROM schema_migrations WHERE version = $1`, migration.Version).Scan(&count); err != nil {
+		return false, errors.Wrap(err, "error checking if the migration was applied")
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(migration.sql); err != nil {
+		return false, errors.Wrap(err, "error executing the migration")
+	}
+
+	if err := m.record(tx, migration, time.Now()); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// begin starts a transaction holding the lock of the migrator.
+func (m *Migrator) begin() (*sql.Tx, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, errors.Wrap(err, "error starting the transaction")
+	}
+
+	if m.lock != "" {
+		if _, err := tx.Exec(m.lock); err != nil {
+			_ = tx.Rollback()
+			return nil, errors.Wrap(err, "error taking the migrations lock")
+		}
+	}
+	return tx, nil
+}
+
+func (m *Migrator) record(tx *sql.Tx, migration Migration, appliedAt time.Time) error {
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+		migration.Version,
+		migration.Name,
+		appliedAt.Unix(),
+	); err != nil {
+		return errors.Wrap(err, "error recording the migration")
+	}
+	return nil
+}
+
+// createTable creates the schema_migrations table and records the migrations
+// which were applied before it existed.
+func (m *Migrator) createTable() error {
+	exists, err := m.tableExists()
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	legacy := m.legacyMigrations()
+
+	tx, err := m.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // not much we can do here, no-op after a commit
+
+	// another instance may have created the table meanwhile
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		   version INTEGER PRIMARY KEY,
+		   name TEXT NOT NULL,
+		   applied_at INTEGER NOT NULL
+		)`,
+	); err != nil {
+		return errors.Wrap(err, "error creating the migrations table")
+	}
+
+	var count int
+	if err := tx.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&count); err != nil {
+		return errors.Wrap(err, "error counting the applied migrations")
+	}
+	if count > 0 {
+		return tx.Commit()
+	}
+
+	for _, migration := range legacy {
+		if err := m.record(tx, migration, time.Unix(0, 0)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) tableExists() (bool, error) {
+	if err := m.db.Ping(); err != nil {
+		return false, errors.Wrap(err, "error connecting to the database")
+	}
+
+	rows, err := m.db.Query(`SELECT version FROM schema_migrations LIMIT 1`)
+	if err != nil {
+		// we can't tell apart a missing table from other errors in a
+		// portable way so the error will surface when creating the table
+		return false, nil
+	}
+	defer rows.Close() // not much we can do here
+
+	return true, rows.Err()
+}
+
+// legacyMigrations returns the migrations which were applied to a database
+// created before migrations were recorded.
+func (m *Migrator) legacyMigrations() []Migration {
+	var result []Migration
+	for _, migration := range m.migrations {
+		if migration.appliedIf == "" || !m.succeeds(migration.appliedIf) {
+			break
+		}
+		result = append(result, migration)
+	}
+	return result
+}
+
+func (m *Migrator) succeeds(query string) bool {
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return false
+	}
+	_ = rows.Close()
+	return true
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	exists, err := m.tableExists()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]time.Time)
+
+	if !exists {
+		for _, migration := range m.legacyMigrations() {
+			result[migration.Version] = time.Time{}
+		}
+		return result, nil
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the applied migrations")
+	}
+	defer rows.Close() // not much we can do here
+
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, errors.Wrap(err, "error scanning the applied migrations")
+		}
+
+		if appliedAt == 0 {
+			result[version] = time.Time{}
+		} else {
+			result[version] = time.Unix(appliedAt, 0)
+		}
+	}
+
+	return result, rows.Err()
+}
+
+func (m *Migrator) checkNotTooNew(applied map[int]time.Time) error {
+	for version := range applied {
+		if version > m.Latest() {
+			return errors.Wrapf(ErrSchemaTooNew, "database is at version %d but the latest known one is %d", version, m.Latest())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/piraces/rsslay/pkg/migrations"
+	"github.com/piraces/rsslay/scripts"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_create_things.sql": {Data: []byte("-- applied-if: SELECT id FROM things\nCREATE TABLE things (id INTEGER PRIMARY KEY);")},
+	"0002_add_name.sql":      {Data: []byte("-- applied-if: SELECT name FROM things\nALTER TABLE things ADD COLUMN name TEXT;")},
+	"0003_add_size.sql":      {Data: []byte("ALTER TABLE things ADD COLUMN size INTEGER;")},
+}
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestUpAppliesPendingMigrations(t *testing.T) {
+	db := openDatabase(t)
+
+	migrator, err := migrations.NewMigrator(db, testMigrations)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Len(t, applied, 3)
+
+	_, err = db.Exec(`INSERT INTO things (id, name, size) VALUES (1, 'a', 2)`)
+	require.NoError(t, err)
+
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	status, err := migrator.Status()
+	require.NoError(t, err)
+	for _, s := range status {
+		assert.True(t, s.Applied)
+		assert.False(t, s.AppliedAt.IsZero())
+	}
+}
+
+func TestUpDetectsMigrationsAppliedToLegacyDatabases(t *testing.T) {
+	db := openDatabase(t)
+
+	_, err := db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT)`)
+	require.NoError(t, err)
+
+	migrator, err := migrations.NewMigrator(db, testMigrations)
+	require.NoError(t, err)
+
+	status, err := migrator.Status()
+	require.NoError(t, err)
+	assert.True(t, status[0].Applied)
+	assert.True(t, status[1].Applied)
+	assert.False(t, status[2].Applied)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 3, applied[0].Version)
+}
+
+func TestUpRefusesNewerSchemas(t *testing.T) {
+	db := openDatabase(t)
+
+	migrator, err := migrations.NewMigrator(db, testMigrations)
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	older, err := migrations.NewMigrator(db, fstest.MapFS{"0001_create_things.sql": testMigrations["0001_create_things.sql"]})
+	require.NoError(t, err)
+
+	_, err = older.Up()
+	assert.True(t, errors.Is(err, migrations.ErrSchemaTooNew))
+
+	_, err = older.Status()
+	assert.True(t, errors.Is(err, migrations.ErrSchemaTooNew))
+}
+
+func TestUpRollsBackFailingMigrations(t *testing.T) {
+	db := openDatabase(t)
+
+	migrator, err := migrations.NewMigrator(db, fstest.MapFS{
+		"0001_create_things.sql": testMigrations["0001_create_things.sql"],
+		"0002_broken.sql":        {Data: []byte("CREATE TABLE others (id INTEGER); SELECT * FROM missing;")},
+	})
+	require.NoError(t, err)
+
+	applied, err := migrator.Up()
+	assert.Error(t, err)
+	assert.Len(t, applied, 1)
+
+	_, err = db.Exec(`SELECT id FROM others`)
+	assert.Error(t, err)
+}
+
+func TestUpTakesTheLockInEveryTransaction(t *testing.T) {
+	db := openDatabase(t)
+	_, err := db.Exec(`CREATE TABLE locks (id INTEGER PRIMARY KEY)`)
+	require.NoError(t, err)
+
+	migrator, err := migrations.NewMigrator(db, testMigrations)
+	require.NoError(t, err)
+	migrator.SetLock(`INSERT INTO locks DEFAULT VALUES`)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Len(t, applied, 3)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM locks`).Scan(&count))
+	assert.Equal(t, 4, count, "creating the migrations table and each migration")
+}
+
+func TestNewMigratorRequiresConsecutiveVersions(t *testing.T) {
+	_, err := migrations.NewMigrator(openDatabase(t), fstest.MapFS{
+		"0001_create_things.sql": testMigrations["0001_create_things.sql"],
+		"0003_add_size.sql":      testMigrations["0003_add_size.sql"],
+	})
+	assert.Error(t, err)
+}
+
+func TestRelayMigrations(t *testing.T) {
+	files, err := fs.Sub(scripts.Migrations, "migrations/sqlite")
+	require.NoError(t, err)
+
+	migrator, err := migrations.NewMigrator(openDatabase(t), files)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Len(t, applied, migrator.Latest())
+
+	postgresFiles, err := fs.Sub(scripts.Migrations, "migrations/postgres")
+	require.NoError(t, err)
+
+	postgresMigrator, err := migrations.NewMigrator(openDatabase(t), postgresFiles)
+	require.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), postgresMigrator.Latest(), "every database must have the same migrations")
+}
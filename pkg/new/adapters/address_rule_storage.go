@@ -0,0 +1,80 @@
+package adapters
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type AddressRuleStorage struct {
+	db *sql.DB
+}
+
+func NewAddressRuleStorage(db *sql.DB) *AddressRuleStorage {
+	return &AddressRuleStorage{db: db}
+}
+
+func (s *AddressRuleStorage) Put(rule domainfeed.AddressRule) error {
+	if _, err := s.db.Exec(
+		`INSERT INTO address_rules (pattern, action, reason, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pattern) DO UPDATE SET action = excluded.action, reason = excluded.reason`,
+		rule.Pattern.String(), string(rule.Action), rule.Reason, rule.CreatedAt.Unix(),
+	); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error saving the address rule")
+	}
+	return nil
+}
+
+func (s *AddressRuleStorage) Delete(pattern domainfeed.AddressPattern) error {
+	if _, err := s.db.Exec(`DELETE FROM address_rules WHERE pattern = $1`, pattern.String()); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the address rule")
+	}
+	return nil
+}
+
+func (s *AddressRuleStorage) List() (domainfeed.AddressRules, error) {
+	rows, err := s.db.Query(`SELECT pattern, action, reason, created_at FROM address_rules ORDER BY pattern`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting address rules")
+	}
+	defer rows.Close() // not much we can do here
+
+	var result domainfeed.AddressRules
+	for rows.Next() {
+		var (
+			tmppattern   string
+			tmpaction    string
+			tmpreason    string
+			tmpcreatedat int64
+		)
+
+		if err := rows.Scan(&tmppattern, &tmpaction, &tmpreason, &tmpcreatedat); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return nil, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+
+		pattern, err := domainfeed.NewAddressPattern(tmppattern)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the pattern")
+		}
+
+		action, err := domainfeed.NewAddressRuleAction(tmpaction)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the action")
+		}
+
+		result = append(result, domainfeed.AddressRule{
+			Pattern:   pattern,
+			Action:    action,
+			Reason:    tmpreason,
+			CreatedAt: time.Unix(tmpcreatedat, 0),
+		})
+	}
+	return result, rows.Err()
+}
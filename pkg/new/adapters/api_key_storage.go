@@ -0,0 +1,105 @@
+package adapters
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type APIKeyStorage struct {
+	db *sql.DB
+}
+
+func NewAPIKeyStorage(db *sql.DB) *APIKeyStorage {
+	return &APIKeyStorage{db: db}
+}
+
+func (s *APIKeyStorage) Put(key auth.APIKey) error {
+	if _, err := s.db.Exec(
+		`INSERT INTO api_keys (id, name, scopes, quota, secret_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		key.ID, key.Name, key.Scopes.String(), key.Quota, key.SecretHash, key.CreatedAt.Unix(),
+	); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error saving the API key")
+	}
+	return nil
+}
+
+func (s *APIKeyStorage) Get(id string) (auth.APIKey, error) {
+	rows, err := s.db.Query(`SELECT id, name, scopes, quota, secret_hash, created_at FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return auth.APIKey{}, errors.Wrap(err, "error getting the API key")
+	}
+	defer rows.Close() // not much we can do here
+
+	keys, err := s.scan(rows)
+	if err != nil {
+		return auth.APIKey{}, err
+	}
+
+	if len(keys) == 0 {
+		return auth.APIKey{}, auth.ErrAPIKeyNotFound
+	}
+
+	return keys[0], nil
+}
+
+func (s *APIKeyStorage) List() ([]auth.APIKey, error) {
+	rows, err := s.db.Query(`SELECT id, name, scopes, quota, secret_hash, created_at FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting API keys")
+	}
+	defer rows.Close() // not much we can do here
+
+	return s.scan(rows)
+}
+
+func (s *APIKeyStorage) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the API key")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "error checking affected rows")
+	}
+
+	if n == 0 {
+		return auth.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (s *APIKeyStorage) scan(rows *sql.Rows) ([]auth.APIKey, error) {
+	var result []auth.APIKey
+	for rows.Next() {
+		var (
+			key          auth.APIKey
+			tmpscopes    string
+			tmpcreatedat int64
+		)
+
+		if err := rows.Scan(&key.ID, &key.Name, &tmpscopes, &key.Quota, &key.SecretHash, &tmpcreatedat); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return nil, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+
+		scopes, err := auth.NewScopes(strings.Split(tmpscopes, ",")...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating the scopes of API key '%s'", key.ID)
+		}
+
+		key.Scopes = scopes
+		key.CreatedAt = time.Unix(tmpcreatedat, 0)
+		result = append(result, key)
+	}
+	return result, rows.Err()
+}
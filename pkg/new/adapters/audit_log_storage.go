@@ -0,0 +1,29 @@
+package adapters
+
+import (
+	"database/sql"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type AuditLogStorage struct {
+	db *sql.DB
+}
+
+func NewAuditLogStorage(db *sql.DB) *AuditLogStorage {
+	return &AuditLogStorage{db: db}
+}
+
+func (a *AuditLogStorage) Put(entry app.AuditLogEntry) error {
+	if _, err := a.db.Exec(
+		`INSERT INTO audit_log (pubkey, method, params, error, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		entry.PublicKey, entry.Method, entry.Params, entry.Error, entry.CreatedAt.Unix(),
+	); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error inserting the audit log entry")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package adapters
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type BannedDomainStorage struct {
+	db *sql.DB
+}
+
+func NewBannedDomainStorage(db *sql.DB) *BannedDomainStorage {
+	return &BannedDomainStorage{db: db}
+}
+
+func (b *BannedDomainStorage) Ban(domain domainfeed.Domain, reason string) error {
+	if _, err := b.db.Exec(
+		`INSERT INTO banned_domains (domain, reason, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (domain) DO UPDATE SET reason = excluded.reason`,
+		domain.String(), reason, time.Now().Unix(),
+	); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error banning the domain")
+	}
+	return nil
+}
+
+func (b *BannedDomainStorage) Allow(domain domainfeed.Domain) error {
+	if _, err := b.db.Exec(`DELETE FROM banned_domains WHERE domain = $1`, domain.String()); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error allowing the domain")
+	}
+	return nil
+}
+
+func (b *BannedDomainStorage) List() ([]domainfeed.BannedDomain, error) {
+	rows, err := b.db.Query(`SELECT domain, reason, created_at FROM banned_domains ORDER BY domain`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting banned domains")
+	}
+	defer rows.Close() // not much we can do here
+
+	var result []domainfeed.BannedDomain
+	for rows.Next() {
+		var (
+			tmpdomain    string
+			tmpreason    string
+			tmpcreatedat int64
+		)
+
+		if err := rows.Scan(&tmpdomain, &tmpreason, &tmpcreatedat); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return nil, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+
+		domain, err := domainfeed.NewDomain(tmpdomain)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating domain")
+		}
+
+		result = append(result, domainfeed.BannedDomain{
+			Domain:    domain,
+			Reason:    tmpreason,
+			CreatedAt: time.Unix(tmpcreatedat, 0),
+		})
+	}
+	return result, rows.Err()
+}
+
+func (b *BannedDomainStorage) IsBanned(address domainfeed.Address) (bool, error) {
+	banned, err := b.List()
+	if err != nil {
+		return false, errors.Wrap(err, "error listing banned domains")
+	}
+
+	for _, bannedDomain := range banned {
+		if bannedDomain.Domain.Matches(address) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
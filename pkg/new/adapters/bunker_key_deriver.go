@@ -0,0 +1,44 @@
+package adapters
+
+import (
+	"context"
+	"sort"
+
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip46"
+	"github.com/pkg/errors"
+)
+
+// BunkerKeyDeriver asks the bunker holding the secrets for the public keys
+// of feeds so that the relay never knows their private keys.
+type BunkerKeyDeriver struct {
+	client *nip46.Client
+}
+
+func NewBunkerKeyDeriver(client *nip46.Client) *BunkerKeyDeriver {
+	return &BunkerKeyDeriver{client: client}
+}
+
+func (b *BunkerKeyDeriver) Derive(addresses ...string) ([]app.DerivedKeys, error) {
+	// the client gives up once the timeout of the requests is reached
+	keys, err := b.client.DerivePublicKeys(context.Background(), addresses...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving the keys with the bunker")
+	}
+
+	var derived []app.DerivedKeys
+	for _, key := range keys {
+		publicKey, err := nostr.NewPublicKeyFromHex(key.PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the public key")
+		}
+		derived = append(derived, app.DerivedKeys{Address: key.Address, Version: key.Version, PublicKey: publicKey})
+	}
+
+	// the bunker lists the keys by address
+	sort.SliceStable(derived, func(i, j int) bool {
+		return derived[i].Version > derived[j].Version
+	})
+	return derived, nil
+}
@@ -35,6 +35,14 @@ func (e *EventStorage) PutEvents(author domain.PublicKey, events []domain.Event)
 	return nil
 }
 
+func (e *EventStorage) DeleteEvents(author domain.PublicKey) error {
+	e.eventsLock.Lock()
+	defer e.eventsLock.Unlock()
+
+	delete(e.events, author.Hex())
+	return nil
+}
+
 func (e *EventStorage) GetEvents(filter domain.Filter) ([]domain.Event, error) {
 	e.eventsLock.RLock()
 	defer e.eventsLock.RUnlock()
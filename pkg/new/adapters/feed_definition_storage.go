@@ -2,22 +2,49 @@ package adapters
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
 	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/keyring"
 	"github.com/piraces/rsslay/pkg/metrics"
 	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
 	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip26"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const feedDefinitionColumns = `publickey, privatekey, key_version, url, nitter, disabled, pending, slug,
+	title, description, link, image, language, item_count, last_fetched_at, last_success_at, last_error,
+	health_state, consecutive_failures, error_kind, failing_since, next_fetch_at,
+	moved_from, moved_at,
+	owner_pubkey, owner_method, owner_verified_at, owner_bunker_url, owner_delegation,
+	profile, submitter, original_url`
+
 type FeedDefinitionStorage struct {
-	db *sql.DB
+	db                *sql.DB
+	keyring           *keyring.Keyring
+	ignorePrivateKeys bool
+}
+
+// NewFeedDefinitionStorage creates a storage which encrypts the private keys
+// of feeds with the keyring.
+func NewFeedDefinitionStorage(db *sql.DB, keyring *keyring.Keyring) *FeedDefinitionStorage {
+	return &FeedDefinitionStorage{db: db, keyring: keyring}
 }
 
-func NewFeedDefinitionStorage(db *sql.DB) *FeedDefinitionStorage {
-	return &FeedDefinitionStorage{db: db}
+// NewFeedDefinitionStorageWithoutPrivateKeys creates a storage which loads
+// the feeds without their private keys, for relays signing with a bunker
+// which don't need them. The keyring only decrypts the bunker URLs of the
+// feed owners.
+func NewFeedDefinitionStorageWithoutPrivateKeys(db *sql.DB, keyring *keyring.Keyring) *FeedDefinitionStorage {
+	return &FeedDefinitionStorage{db: db, keyring: keyring, ignorePrivateKeys: true}
 }
 
 func (f *FeedDefinitionStorage) CountTotal() (int, error) {
@@ -31,9 +58,120 @@ func (f *FeedDefinitionStorage) CountTotal() (int, error) {
 	return count, nil
 }
 
+func (f *FeedDefinitionStorage) Get(publicKey nostr.PublicKey) (*domainfeed.FeedDefinition, error) {
+	rows, err := f.db.Query(`
+		SELECT `+feedDefinitionColumns+`
+		FROM feeds
+		WHERE publickey = $1`,
+		publicKey.Hex(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting feed definition")
+	}
+	defer rows.Close() // not much we can do here
+
+	definitions, err := f.scan(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "error scanning feed definition")
+	}
+
+	if len(definitions) == 0 {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+
+	return definitions[0], nil
+}
+
+func (f *FeedDefinitionStorage) GetByAddress(address domainfeed.Address) (*domainfeed.FeedDefinition, error) {
+	rows, err := f.db.Query(`
+		SELECT `+feedDefinitionColumns+`
+		FROM feeds
+		WHERE url = $1`,
+		address.String(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting feed definition")
+	}
+	defer rows.Close() // not much we can do here
+
+	definitions, err := f.scan(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "error scanning feed definition")
+	}
+
+	if len(definitions) == 0 {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+
+	return definitions[0], nil
+}
+
+// GetByCanonicalAddress finds the feed whose address has the same canonical
+// form as the address.
+func (f *FeedDefinitionStorage) GetByCanonicalAddress(address domainfeed.Address) (*domainfeed.FeedDefinition, error) {
+	rows, err := f.db.Query(`
+		SELECT `+feedDefinitionColumns+`
+		FROM feeds
+		WHERE canonical_url = $1`,
+		address.Canonical().String(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting feed definition")
+	}
+	defer rows.Close() // not much we can do here
+
+	definitions, err := f.scan(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "error scanning feed definition")
+	}
+
+	if len(definitions) == 0 {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+
+	return definitions[0], nil
+}
+
+// FillCanonicalAddresses stores the canonical addresses of the feeds created
+// before they were stored and returns how many were filled.
+func (f *FeedDefinitionStorage) FillCanonicalAddresses() (int, error) {
+	rows, err := f.db.Query(`SELECT publickey, url FROM feeds WHERE canonical_url IS NULL`)
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting the addresses")
+	}
+
+	canonical := make(map[string]string)
+	for rows.Next() {
+		var publicKey, url string
+		if err := rows.Scan(&publicKey, &url); err != nil {
+			rows.Close()
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return 0, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+		address, err := domainfeed.NewAddress(url)
+		if err != nil {
+			log.Printf("[WARN] feed %s has an invalid address %q: %v", publicKey, url, err)
+			continue
+		}
+		canonical[publicKey] = address.Canonical().String()
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "error reading the addresses")
+	}
+
+	for publicKey, url := range canonical {
+		if _, err := f.db.Exec(`UPDATE feeds SET canonical_url = $1 WHERE publickey = $2`, url, publicKey); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+			return 0, errors.Wrap(err, "error updating the canonical address")
+		}
+	}
+	return len(canonical), nil
+}
+
 func (f *FeedDefinitionStorage) List() ([]*domainfeed.FeedDefinition, error) {
 	rows, err := f.db.Query(`
-		SELECT publickey, privatekey, url, nitter
+		SELECT ` + feedDefinitionColumns + `
 		FROM feeds`,
 	)
 	if err != nil {
@@ -44,10 +182,68 @@ func (f *FeedDefinitionStorage) List() ([]*domainfeed.FeedDefinition, error) {
 	return f.scan(rows)
 }
 
+// ListPage returns the page of feeds matching the filter ordered by address.
+func (f *FeedDefinitionStorage) ListPage(filter domainfeed.ListFilter) (domainfeed.Page, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.Health != "" {
+		addCondition("health_state = ?", string(filter.Health))
+	}
+	if filter.Disabled != nil {
+		addCondition("disabled = ?", *filter.Disabled)
+	}
+	if filter.Pending != nil {
+		addCondition("pending = ?", *filter.Pending)
+	}
+	if filter.Owned != nil {
+		if *filter.Owned {
+			conditions = append(conditions, "owner_pubkey <> ''")
+		} else {
+			conditions = append(conditions, "owner_pubkey = ''")
+		}
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var page domainfeed.Page
+	if err := f.db.QueryRow(`SELECT count(*) FROM feeds `+where, args...).Scan(&page.Total); err != nil {
+		return domainfeed.Page{}, errors.Wrap(err, "error counting feeds")
+	}
+
+	rows, err := f.db.Query(
+		`SELECT `+feedDefinitionColumns+`
+		FROM feeds `+where+fmt.Sprintf(`
+		ORDER BY url, publickey
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, filter.Limit, filter.Offset)...,
+	)
+	if err != nil {
+		return domainfeed.Page{}, errors.Wrap(err, "error getting feed definitions")
+	}
+	defer rows.Close() // not much we can do here
+
+	page.Feeds, err = f.scan(rows)
+	if err != nil {
+		return domainfeed.Page{}, err
+	}
+	return page, nil
+}
+
 func (f *FeedDefinitionStorage) ListRandom(limit int) ([]*domainfeed.FeedDefinition, error) {
 	rows, err := f.db.Query(`
-		SELECT publickey, privatekey, url, nitter
+		SELECT `+feedDefinitionColumns+`
 		FROM feeds
+		WHERE NOT disabled AND NOT pending
 		ORDER BY RANDOM()
 		LIMIT $1`,
 		limit,
@@ -62,10 +258,14 @@ func (f *FeedDefinitionStorage) ListRandom(limit int) ([]*domainfeed.FeedDefinit
 
 func (f *FeedDefinitionStorage) Search(query string, limit int) ([]*domainfeed.FeedDefinition, error) {
 	rows, err := f.db.Query(`
-		SELECT publickey, privatekey, url, nitter
+		SELECT `+feedDefinitionColumns+`
 		FROM feeds
-		WHERE url
-		LIKE '%' || $1 || '%' LIMIT $2`,
+		WHERE NOT disabled AND NOT pending AND (
+			LOWER(url) LIKE '%' || LOWER(CAST($1 AS TEXT)) || '%' OR
+			LOWER(title) LIKE '%' || LOWER(CAST($1 AS TEXT)) || '%' OR
+			LOWER(description) LIKE '%' || LOWER(CAST($1 AS TEXT)) || '%'
+		)
+		LIMIT $2`,
 		query,
 		limit,
 	)
@@ -84,7 +284,18 @@ func (f *FeedDefinitionStorage) Put(definition *domainfeed.FeedDefinition) error
 	err := row.Scan(&entity.PrivateKey, &entity.URL)
 	if err != nil && err == sql.ErrNoRows {
 		log.Printf("[DEBUG] not found feed at url %q as publicKey %s", definition.Address().String(), definition.PublicKey().Hex())
-		if _, err := f.db.Exec(`INSERT INTO feeds (publickey, privatekey, url, nitter) VALUES (?, ?, ?, ?)`, definition.PublicKey().Hex(), definition.PrivateKey().Hex(), definition.Address().String(), definition.Nitter()); err != nil {
+		// the private keys of feeds signed by a bunker aren't stored
+		var privateKey string
+		if definition.HasPrivateKey() {
+			privateKey, err = f.keyring.Encrypt(definition.PrivateKey().Hex(), definition.PublicKey().Hex())
+			if err != nil {
+				return errors.Wrap(err, "error encrypting the private key")
+			}
+		}
+		if _, err := f.db.Exec(`INSERT INTO feeds (publickey, privatekey, key_version, url, canonical_url, original_url, nitter, slug, submitter, pending) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, definition.PublicKey().Hex(), privateKey, definition.KeyVersion(), definition.Address().String(), definition.Address().Canonical().String(), definition.OriginalAddress().String(), definition.Nitter(), nullableSlug(definition.Slug()), definition.Submitter(), definition.Pending()); err != nil {
+			if isSlugTakenError(err) {
+				return domainfeed.ErrSlugTaken
+			}
 			log.Printf("[ERROR] failure: %v", err)
 			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
 			return errors.Wrap(err, "error inserting the new feed")
@@ -101,17 +312,512 @@ func (f *FeedDefinitionStorage) Put(definition *domainfeed.FeedDefinition) error
 	return nil
 }
 
+func (f *FeedDefinitionStorage) SetDisabled(publicKey nostr.PublicKey, disabled bool) error {
+	result, err := f.db.Exec(`UPDATE feeds SET disabled = $1 WHERE publickey = $2`, disabled, publicKey.Hex())
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+// SetPending records whether the feed awaits approval.
+func (f *FeedDefinitionStorage) SetPending(publicKey nostr.PublicKey, pending bool) error {
+	result, err := f.db.Exec(`UPDATE feeds SET pending = $1 WHERE publickey = $2`, pending, publicKey.Hex())
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+func (f *FeedDefinitionStorage) GetBySlug(slug domainfeed.Slug) (*domainfeed.FeedDefinition, error) {
+	rows, err := f.db.Query(`
+		SELECT `+feedDefinitionColumns+`
+		FROM feeds
+		WHERE slug = $1`,
+		slug.String(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting feed definition")
+	}
+	defer rows.Close() // not much we can do here
+
+	definitions, err := f.scan(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "error scanning feed definition")
+	}
+
+	if len(definitions) == 0 {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+
+	return definitions[0], nil
+}
+
+func (f *FeedDefinitionStorage) SetSlug(publicKey nostr.PublicKey, slug domainfeed.Slug) error {
+	result, err := f.db.Exec(`UPDATE feeds SET slug = $1 WHERE publickey = $2`, slug.String(), publicKey.Hex())
+	if err != nil {
+		if isSlugTakenError(err) {
+			return domainfeed.ErrSlugTaken
+		}
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+func (f *FeedDefinitionStorage) SetMetadata(publicKey nostr.PublicKey, metadata domainfeed.Metadata) error {
+	result, err := f.db.Exec(`
+		UPDATE feeds SET
+			title = $1, description = $2, link = $3, image = $4, language = $5, item_count = $6,
+			last_fetched_at = $7, last_success_at = $8, last_error = $9
+		WHERE publickey = $10`,
+		metadata.Title,
+		metadata.Description,
+		metadata.Link,
+		metadata.Image,
+		metadata.Language,
+		metadata.ItemCount,
+		nullableTime(metadata.LastFetchedAt),
+		nullableTime(metadata.LastSuccessAt),
+		metadata.LastError,
+		publicKey.Hex(),
+	)
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+func (f *FeedDefinitionStorage) SetHealth(publicKey nostr.PublicKey, health domainfeed.Health) error {
+	result, err := f.db.Exec(`
+		UPDATE feeds SET
+			health_state = $1, consecutive_failures = $2, error_kind = $3, failing_since = $4, next_fetch_at = $5
+		WHERE publickey = $6`,
+		string(health.State),
+		health.ConsecutiveFailures,
+		string(health.ErrorKind),
+		nullableTime(health.FailingSince),
+		nullableTime(health.NextFetchAt),
+		publicKey.Hex(),
+	)
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+func (f *FeedDefinitionStorage) SetAddress(publicKey nostr.PublicKey, address domainfeed.Address, move domainfeed.Move) error {
+	result, err := f.db.Exec(`
+		UPDATE feeds SET
+			url = $1, canonical_url = $2, moved_from = $3, moved_at = $4
+		WHERE publickey = $5`,
+		address.String(),
+		address.Canonical().String(),
+		move.From.String(),
+		nullableTime(move.At),
+		publicKey.Hex(),
+	)
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+// SetOwner records the verified owner of the feed, a zero owner removes it.
+func (f *FeedDefinitionStorage) SetOwner(publicKey nostr.PublicKey, owner domainfeed.Owner) error {
+	var bunkerURL string
+	if owner.BunkerURL != "" {
+		encrypted, err := f.keyring.Encrypt(owner.BunkerURL, bunkerURLAssociatedData(publicKey.Hex()))
+		if err != nil {
+			return errors.Wrap(err, "error encrypting the bunker url")
+		}
+		bunkerURL = encrypted
+	}
+
+	var delegation string
+	if !owner.Delegation.IsZero() {
+		b, err := json.Marshal(owner.Delegation.Tag())
+		if err != nil {
+			return errors.Wrap(err, "error marshaling the delegation")
+		}
+		delegation = string(b)
+	}
+
+	result, err := f.db.Exec(`
+		UPDATE feeds SET
+			owner_pubkey = $1, owner_method = $2, owner_verified_at = $3, owner_bunker_url = $4, owner_delegation = $5
+		WHERE publickey = $6`,
+		owner.PublicKey.Hex(),
+		string(owner.Method),
+		nullableTime(owner.VerifiedAt),
+		bunkerURL,
+		delegation,
+		publicKey.Hex(),
+	)
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+// SetProfile records the profile overrides of the feed, a zero profile
+// removes them.
+func (f *FeedDefinitionStorage) SetProfile(publicKey nostr.PublicKey, profile domainfeed.Profile) error {
+	var value string
+	if !profile.IsZero() {
+		b, err := json.Marshal(storedProfile(profile))
+		if err != nil {
+			return errors.Wrap(err, "error marshaling the profile")
+		}
+		value = string(b)
+	}
+
+	result, err := f.db.Exec(`UPDATE feeds SET profile = $1 WHERE publickey = $2`, value, publicKey.Hex())
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error updating the feed")
+	}
+	return f.checkFound(result)
+}
+
+// ListByOwner returns the feeds owned by the public key.
+func (f *FeedDefinitionStorage) ListByOwner(owner nostr.PublicKey) ([]*domainfeed.FeedDefinition, error) {
+	rows, err := f.db.Query(`
+		SELECT `+feedDefinitionColumns+`
+		FROM feeds
+		WHERE owner_pubkey = $1`,
+		owner.Hex(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the feeds of the owner")
+	}
+	defer rows.Close() // not much we can do here
+
+	return f.scan(rows)
+}
+
+func (f *FeedDefinitionStorage) Delete(publicKey nostr.PublicKey) error {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting a transaction")
+	}
+	defer tx.Rollback() // not much we can do here
+
+	if _, err := tx.Exec(`DELETE FROM feed_items WHERE publickey = $1`, publicKey.Hex()); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the feed items")
+	}
+
+	result, err := tx.Exec(`DELETE FROM feeds WHERE publickey = $1`, publicKey.Hex())
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the feed")
+	}
+
+	if err := f.checkFound(result); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+	return nil
+}
+
+// SetItemGUIDs replaces the remembered items of the feed.
+func (f *FeedDefinitionStorage) SetItemGUIDs(publicKey nostr.PublicKey, guids []string) error {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting a transaction")
+	}
+	defer tx.Rollback() // not much we can do here
+
+	if _, err := tx.Exec(`DELETE FROM feed_items WHERE publickey = $1`, publicKey.Hex()); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the feed items")
+	}
+
+	for _, guid := range guids {
+		if _, err := tx.Exec(`INSERT INTO feed_items (publickey, guid) VALUES ($1, $2) ON CONFLICT DO NOTHING`, publicKey.Hex(), guid); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+			return errors.Wrap(err, "error inserting the feed item")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+	return nil
+}
+
+// FindItemGUIDs returns the feeds which have any of the items, the ones
+// having the most first.
+func (f *FeedDefinitionStorage) FindItemGUIDs(guids []string) ([]domainfeed.ItemMatch, error) {
+	if len(guids) == 0 {
+		return nil, nil
+	}
+
+	var args queryArgs
+	rows, err := f.db.Query(`
+		SELECT publickey, COUNT(*)
+		FROM feed_items
+		WHERE guid IN (`+args.addStrings(guids)+`)
+		GROUP BY publickey
+		ORDER BY COUNT(*) DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding the feed items")
+	}
+	defer rows.Close() // not much we can do here
+
+	var matches []domainfeed.ItemMatch
+	for rows.Next() {
+		var (
+			tmppublickey string
+			match        domainfeed.ItemMatch
+		)
+		if err := rows.Scan(&tmppublickey, &match.Shared); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return nil, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+
+		match.PublicKey, err = nostr.NewPublicKeyFromHex(tmppublickey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating public key")
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// ListSharedItems returns the pairs of feeds which have items in common.
+func (f *FeedDefinitionStorage) ListSharedItems() ([]domainfeed.SharedItems, error) {
+	rows, err := f.db.Query(`
+		SELECT a.publickey, b.publickey, COUNT(*),
+			(SELECT COUNT(*) FROM feed_items WHERE publickey = a.publickey),
+			(SELECT COUNT(*) FROM feed_items WHERE publickey = b.publickey)
+		FROM feed_items a
+		JOIN feed_items b ON a.guid = b.guid AND a.publickey < b.publickey
+		GROUP BY a.publickey, b.publickey`,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the shared feed items")
+	}
+	defer rows.Close() // not much we can do here
+
+	var result []domainfeed.SharedItems
+	for rows.Next() {
+		var (
+			tmpa, tmpb string
+			shared     domainfeed.SharedItems
+		)
+		if err := rows.Scan(&tmpa, &tmpb, &shared.Shared, &shared.ItemsA, &shared.ItemsB); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return nil, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+
+		if shared.A, err = nostr.NewPublicKeyFromHex(tmpa); err != nil {
+			return nil, errors.Wrap(err, "error creating public key")
+		}
+		if shared.B, err = nostr.NewPublicKeyFromHex(tmpb); err != nil {
+			return nil, errors.Wrap(err, "error creating public key")
+		}
+		result = append(result, shared)
+	}
+	return result, rows.Err()
+}
+
+// PrivateKeyStatus counts the stored private keys of feeds.
+type PrivateKeyStatus struct {
+	// KeyVersions counts the feeds by the version of the secret which their
+	// keys were derived from.
+	KeyVersions map[int]int
+
+	// Encryption counts the private keys, and the bunker URLs of the feed
+	// owners, by the version of the key encryption key they are encrypted
+	// with, zero means plaintext.
+	Encryption map[int]int
+
+	// Missing counts the feeds whose private key is only held by a bunker.
+	Missing int
+}
+
+// Outdated counts the private keys which aren't encrypted with the current
+// key encryption key.
+func (s PrivateKeyStatus) Outdated(current int) int {
+	outdated := 0
+	for version, count := range s.Encryption {
+		if version != current {
+			outdated += count
+		}
+	}
+	return outdated
+}
+
+func (f *FeedDefinitionStorage) PrivateKeyStatus() (PrivateKeyStatus, error) {
+	rows, err := f.db.Query(`SELECT privatekey, key_version, owner_bunker_url FROM feeds`)
+	if err != nil {
+		return PrivateKeyStatus{}, errors.Wrap(err, "error getting the private keys")
+	}
+	defer rows.Close() // not much we can do here
+
+	status := PrivateKeyStatus{KeyVersions: make(map[int]int), Encryption: make(map[int]int)}
+	for rows.Next() {
+		var (
+			tmpprivatekey string
+			tmpkeyversion int
+			tmpbunkerurl  string
+		)
+		if err := rows.Scan(&tmpprivatekey, &tmpkeyversion, &tmpbunkerurl); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return PrivateKeyStatus{}, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+		status.KeyVersions[tmpkeyversion]++
+		if tmpprivatekey == "" {
+			status.Missing++
+		} else {
+			status.Encryption[keyring.Version(tmpprivatekey)]++
+		}
+		if tmpbunkerurl != "" {
+			status.Encryption[keyring.Version(tmpbunkerurl)]++
+		}
+	}
+	return status, rows.Err()
+}
+
+// ReencryptPrivateKeys encrypts the private keys, and the bunker URLs of the
+// feed owners, which aren't encrypted with the current key encryption key
+// with it and returns how many were changed.
+func (f *FeedDefinitionStorage) ReencryptPrivateKeys() (int, error) {
+	tx, err := f.db.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "error starting a transaction")
+	}
+	defer tx.Rollback() // not much we can do here
+
+	type storedValue struct {
+		column         string
+		publicKey      string
+		value          string
+		associatedData string
+	}
+
+	rows, err := tx.Query(`SELECT publickey, privatekey, owner_bunker_url FROM feeds`)
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting the private keys")
+	}
+
+	var outdated []storedValue
+	for rows.Next() {
+		var publicKey, privateKey, bunkerURL string
+		if err := rows.Scan(&publicKey, &privateKey, &bunkerURL); err != nil {
+			rows.Close()
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return 0, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+		if privateKey != "" && keyring.Version(privateKey) != f.keyring.Current() {
+			outdated = append(outdated, storedValue{"privatekey", publicKey, privateKey, publicKey})
+		}
+		if bunkerURL != "" && keyring.Version(bunkerURL) != f.keyring.Current() {
+			outdated = append(outdated, storedValue{"owner_bunker_url", publicKey, bunkerURL, bunkerURLAssociatedData(publicKey)})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "error reading the private keys")
+	}
+
+	for _, stored := range outdated {
+		value, err := f.keyring.Decrypt(stored.value, stored.associatedData)
+		if err != nil {
+			return 0, errors.Wrapf(err, "error decrypting the %s of feed '%s'", stored.column, stored.publicKey)
+		}
+
+		encrypted, err := f.keyring.Encrypt(value, stored.associatedData)
+		if err != nil {
+			return 0, errors.Wrapf(err, "error encrypting the %s of feed '%s'", stored.column, stored.publicKey)
+		}
+
+		// the column comes from the constants above
+		if _, err := tx.Exec(`UPDATE feeds SET `+stored.column+` = $1 WHERE publickey = $2`, encrypted, stored.publicKey); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+			return 0, errors.Wrapf(err, "error updating the %s", stored.column)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "error committing the transaction")
+	}
+	return len(outdated), nil
+}
+
+// bunkerURLAssociatedData binds an encrypted bunker URL to its feed so that
+// it can't be swapped with the private key.
+func bunkerURLAssociatedData(publicKey string) string {
+	return publicKey + ":owner_bunker_url"
+}
+
+func (f *FeedDefinitionStorage) checkFound(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "error checking affected rows")
+	}
+
+	if affected == 0 {
+		return domainfeed.ErrFeedDefinitionNotFound
+	}
+
+	return nil
+}
+
 func (f *FeedDefinitionStorage) scan(rows *sql.Rows) ([]*domainfeed.FeedDefinition, error) {
 	var items []*domainfeed.FeedDefinition
 	for rows.Next() {
 		var (
 			tmppublickey  string
 			tmpprivatekey string
+			tmpkeyversion int
 			tmpurl        string
 			tmpnitter     bool
+			tmpdisabled   bool
+			tmppending    bool
+			tmpslug       sql.NullString
+			tmpfetchedat  sql.NullInt64
+			tmpsuccessat  sql.NullInt64
+			metadata      domainfeed.Metadata
+			tmpstate      string
+			tmperrorkind  string
+			tmpfailingat  sql.NullInt64
+			tmpnextat     sql.NullInt64
+			health        domainfeed.Health
+			tmpmovedfrom  string
+			tmpmovedat    sql.NullInt64
+			tmpowner      string
+			tmpmethod     string
+			tmpverifiedat sql.NullInt64
+			tmpbunkerurl  string
+			tmpdelegation string
+			tmpprofile    string
+			tmpsubmitter  string
+			tmporiginal   sql.NullString
 		)
 
-		if err := rows.Scan(&tmppublickey, &tmpprivatekey, &tmpurl, &tmpnitter); err != nil {
+		if err := rows.Scan(
+			&tmppublickey, &tmpprivatekey, &tmpkeyversion, &tmpurl, &tmpnitter, &tmpdisabled, &tmppending, &tmpslug,
+			&metadata.Title, &metadata.Description, &metadata.Link, &metadata.Image, &metadata.Language, &metadata.ItemCount,
+			&tmpfetchedat, &tmpsuccessat, &metadata.LastError,
+			&tmpstate, &health.ConsecutiveFailures, &tmperrorkind, &tmpfailingat, &tmpnextat,
+			&tmpmovedfrom, &tmpmovedat,
+			&tmpowner, &tmpmethod, &tmpverifiedat, &tmpbunkerurl, &tmpdelegation,
+			&tmpprofile, &tmpsubmitter, &tmporiginal,
+		); err != nil {
 			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
 			return nil, errors.Wrap(err, "error scanning the retrieved rows")
 		}
@@ -121,27 +827,162 @@ func (f *FeedDefinitionStorage) scan(rows *sql.Rows) ([]*domainfeed.FeedDefiniti
 			return nil, errors.Wrap(err, "error creating public key")
 		}
 
-		privateKey, err := nostr.NewPrivateKeyFromHex(tmpprivatekey)
-		if err != nil {
-			return nil, errors.Wrap(err, "error creating private key")
-		}
-
 		address, err := domainfeed.NewAddress(tmpurl)
 		if err != nil {
 			return nil, errors.Wrap(err, "error creating address")
 		}
 
-		feedDefinition, err := domainfeed.NewFeedDefinition(
-			publicKey,
-			privateKey,
-			address,
-			tmpnitter,
-		)
-		if err != nil {
-			return nil, errors.Wrap(err, "error loading feed definition")
+		feedDefinition := domainfeed.NewFeedDefinitionWithoutPrivateKey(publicKey, address, tmpnitter)
+		if tmpprivatekey != "" && !f.ignorePrivateKeys {
+			tmpprivatekey, err = f.keyring.Decrypt(tmpprivatekey, tmppublickey)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error decrypting the private key of feed '%s'", tmppublickey)
+			}
+
+			privateKey, err := nostr.NewPrivateKeyFromHex(tmpprivatekey)
+			if err != nil {
+				return nil, errors.Wrap(err, "error creating private key")
+			}
+
+			feedDefinition, err = domainfeed.NewFeedDefinition(
+				publicKey,
+				privateKey,
+				address,
+				tmpnitter,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "error loading feed definition")
+			}
+		}
+		feedDefinition.SetDisabled(tmpdisabled)
+		feedDefinition.SetPending(tmppending)
+		feedDefinition.SetKeyVersion(tmpkeyversion)
+		feedDefinition.SetSubmitter(tmpsubmitter)
+
+		if tmporiginal.Valid && tmporiginal.String != "" {
+			original, err := domainfeed.NewAddress(tmporiginal.String)
+			if err != nil {
+				return nil, errors.Wrap(err, "error creating the original address")
+			}
+			feedDefinition.SetOriginalAddress(original)
+		}
+
+		if tmpslug.Valid {
+			slug, err := domainfeed.NewSlug(tmpslug.String)
+			if err != nil {
+				return nil, errors.Wrap(err, "error creating slug")
+			}
+			feedDefinition.SetSlug(slug)
+		}
+
+		metadata.LastFetchedAt = timeFromNullable(tmpfetchedat)
+		metadata.LastSuccessAt = timeFromNullable(tmpsuccessat)
+		feedDefinition.SetMetadata(metadata)
+
+		health.State = domainfeed.HealthState(tmpstate)
+		health.ErrorKind = domainfeed.ErrorKind(tmperrorkind)
+		health.FailingSince = timeFromNullable(tmpfailingat)
+		health.NextFetchAt = timeFromNullable(tmpnextat)
+		feedDefinition.SetHealth(health)
+
+		if tmpmovedat.Valid {
+			movedFrom, err := domainfeed.NewAddress(tmpmovedfrom)
+			if err != nil {
+				return nil, errors.Wrap(err, "error creating the previous address")
+			}
+			feedDefinition.SetMove(domainfeed.Move{From: movedFrom, At: timeFromNullable(tmpmovedat)})
+		}
+
+		if tmpowner != "" {
+			owner, err := f.scanOwner(tmppublickey, tmpowner, tmpmethod, tmpverifiedat, tmpbunkerurl, tmpdelegation)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error loading the owner of feed '%s'", tmppublickey)
+			}
+			feedDefinition.SetOwner(owner)
+		}
+
+		if tmpprofile != "" {
+			var profile storedProfile
+			if err := json.Unmarshal([]byte(tmpprofile), &profile); err != nil {
+				return nil, errors.Wrapf(err, "error unmarshaling the profile of feed '%s'", tmppublickey)
+			}
+			feedDefinition.SetProfile(domainfeed.Profile(profile))
 		}
 
 		items = append(items, feedDefinition)
 	}
 	return items, nil
 }
+
+func (f *FeedDefinitionStorage) scanOwner(publicKey, owner, method string, verifiedAt sql.NullInt64, bunkerURL, delegation string) (domainfeed.Owner, error) {
+	ownerPublicKey, err := nostr.NewPublicKeyFromHex(owner)
+	if err != nil {
+		return domainfeed.Owner{}, errors.Wrap(err, "error creating the owner public key")
+	}
+
+	result := domainfeed.Owner{
+		PublicKey:  ownerPublicKey,
+		Method:     domainfeed.VerificationMethod(method),
+		VerifiedAt: timeFromNullable(verifiedAt),
+	}
+
+	if bunkerURL != "" {
+		result.BunkerURL, err = f.keyring.Decrypt(bunkerURL, bunkerURLAssociatedData(publicKey))
+		if err != nil {
+			return domainfeed.Owner{}, errors.Wrap(err, "error decrypting the bunker url")
+		}
+	}
+
+	if delegation != "" {
+		var tag []string
+		if err := json.Unmarshal([]byte(delegation), &tag); err != nil {
+			return domainfeed.Owner{}, errors.Wrap(err, "error unmarshaling the delegation")
+		}
+		result.Delegation, err = nip26.Parse(tag)
+		if err != nil {
+			return domainfeed.Owner{}, errors.Wrap(err, "error parsing the delegation")
+		}
+	}
+
+	return result, nil
+}
+
+// storedProfile is the JSON representation of the profile overrides.
+type storedProfile struct {
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+	Lud16   string `json:"lud16,omitempty"`
+	Website string `json:"website,omitempty"`
+}
+
+func nullableSlug(slug domainfeed.Slug) sql.NullString {
+	return sql.NullString{String: slug.String(), Valid: !slug.IsZero()}
+}
+
+func nullableTime(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.Unix(), Valid: !t.IsZero()}
+}
+
+func timeFromNullable(t sql.NullInt64) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return time.Unix(t.Int64, 0)
+}
+
+// isSlugTakenError tells whether the unique index on the slugs was violated,
+// other unique violations such as the primary key aren't about the slug.
+func isSlugTakenError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		// SQLite only names the columns, "UNIQUE constraint failed: feeds.slug"
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique && strings.HasSuffix(sqliteErr.Error(), ": feeds.slug")
+	}
+
+	var postgresErr *pgconn.PgError
+	return errors.As(err, &postgresErr) &&
+		postgresErr.Code == "23505" && // unique_violation
+		postgresErr.ConstraintName == "feeds_slug"
+}
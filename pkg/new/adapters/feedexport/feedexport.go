@@ -0,0 +1,451 @@
+// Package feedexport reads and writes the documents with which operators
+// move the feeds of an instance to another one.
+package feedexport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/keyring"
+	"github.com/piraces/rsslay/pkg/new/app"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip26"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the version of the format of the documents, documents of newer
+// versions are rejected.
+const Version = 1
+
+const (
+	opmlTitle = "rsslay feeds"
+
+	saltLength   = 16
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptLength = 32
+)
+
+var (
+	ErrPassphraseRequired = errors.New("a passphrase is required to export or import private keys")
+	ErrWrongPassphrase    = errors.New("the passphrase doesn't decrypt the private keys")
+)
+
+// document is the JSON document. Private keys and bunker URLs are encrypted
+// with a key derived from a passphrase with scrypt and the salt.
+type document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Salt       string    `json:"salt,omitempty"`
+	Feeds      []feed    `json:"feeds"`
+}
+
+type feed struct {
+	PublicKey  string `json:"pubkey"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Url        string `json:"url"`
+	// OriginalUrl is only set for feeds which moved
+	OriginalUrl string   `json:"original_url,omitempty"`
+	Nitter      bool     `json:"nitter,omitempty"`
+	Disabled    bool     `json:"disabled,omitempty"`
+	Pending     bool     `json:"pending,omitempty"`
+	Slug        string   `json:"slug,omitempty"`
+	Submitter   string   `json:"submitter,omitempty"`
+	Metadata    metadata `json:"metadata"`
+	Health      health   `json:"health"`
+	Move        *move    `json:"move,omitempty"`
+	Owner       *owner   `json:"owner,omitempty"`
+	Profile     *profile `json:"profile,omitempty"`
+}
+
+type metadata struct {
+	Title         string     `json:"title,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	Link          string     `json:"link,omitempty"`
+	Image         string     `json:"image,omitempty"`
+	Language      string     `json:"language,omitempty"`
+	ItemCount     int        `json:"item_count,omitempty"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+type health struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	ErrorKind           string     `json:"error_kind,omitempty"`
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+	NextFetchAt         *time.Time `json:"next_fetch_at,omitempty"`
+}
+
+type move struct {
+	From string    `json:"from"`
+	At   time.Time `json:"at"`
+}
+
+type owner struct {
+	PublicKey  string    `json:"pubkey"`
+	Method     string    `json:"method"`
+	VerifiedAt time.Time `json:"verified_at"`
+	BunkerURL  string    `json:"bunker_url,omitempty"`
+	Delegation []string  `json:"delegation,omitempty"`
+}
+
+type profile struct {
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+	Lud16   string `json:"lud16,omitempty"`
+	Website string `json:"website,omitempty"`
+}
+
+// Encode writes the feeds as a JSON document. The passphrase is only
+// required if private keys or bunker URLs are exported.
+func Encode(w io.Writer, feeds []app.ExportedFeed, passphrase string, exportedAt time.Time) error {
+	doc := document{Version: Version, ExportedAt: exportedAt.UTC(), Feeds: []feed{}}
+
+	var keys *keyring.Keyring
+	if hasSecrets(feeds) {
+		if passphrase == "" {
+			return ErrPassphraseRequired
+		}
+
+		salt := make([]byte, saltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return fmt.Errorf("error generating the salt: %w", err)
+		}
+		doc.Salt = hex.EncodeToString(salt)
+
+		var err error
+		if keys, err = passphraseKeyring(passphrase, salt); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range feeds {
+		encoded, err := encodeFeed(f, keys)
+		if err != nil {
+			return fmt.Errorf("error encoding feed '%s': %w", f.PublicKey.Hex(), err)
+		}
+		doc.Feeds = append(doc.Feeds, encoded)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// Decode reads a JSON document written by Encode. The passphrase is only
+// required if the document contains private keys or bunker URLs.
+func Decode(r io.Reader, passphrase string) ([]app.ExportedFeed, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid export document: %w", err)
+	}
+
+	if doc.Version <= 0 || doc.Version > Version {
+		return nil, fmt.Errorf("unsupported export version %d", doc.Version)
+	}
+
+	var keys *keyring.Keyring
+	if doc.Salt != "" {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+
+		salt, err := hex.DecodeString(doc.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid salt: %w", err)
+		}
+
+		if keys, err = passphraseKeyring(passphrase, salt); err != nil {
+			return nil, err
+		}
+	}
+
+	var feeds []app.ExportedFeed
+	for i, f := range doc.Feeds {
+		decoded, err := decodeFeed(f, keys)
+		if err != nil {
+			if errors.Is(err, ErrWrongPassphrase) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("invalid feed %d: %w", i+1, err)
+		}
+		feeds = append(feeds, decoded)
+	}
+	return feeds, nil
+}
+
+// OPML lists the addresses of the feeds for feed readers.
+func OPML(feeds []app.ExportedFeed, exportedAt time.Time) feeddomain.OPML {
+	var outlines []feeddomain.OPMLOutline
+	for _, f := range feeds {
+		outline := feeddomain.OPMLOutline{
+			Text:    f.Metadata.Title,
+			Type:    "rss",
+			XMLURL:  f.Address.String(),
+			HTMLURL: f.Metadata.Link,
+		}
+		if outline.Text == "" {
+			outline.Text = f.Address.String()
+		}
+		outlines = append(outlines, outline)
+	}
+	return feeddomain.NewOPML(opmlTitle, outlines, exportedAt)
+}
+
+func hasSecrets(feeds []app.ExportedFeed) bool {
+	for _, f := range feeds {
+		if f.PrivateKey != nil || f.Owner.BunkerURL != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func passphraseKeyring(passphrase string, salt []byte) (*keyring.Keyring, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptLength)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving the key from the passphrase: %w", err)
+	}
+	return keyring.New(map[int]string{1: hex.EncodeToString(key)})
+}
+
+// bunkerURLAssociatedData binds an encrypted bunker URL to its feed so that
+// it can't be swapped with the private key.
+func bunkerURLAssociatedData(publicKey string) string {
+	return publicKey + ":owner_bunker_url"
+}
+
+func encodeFeed(f app.ExportedFeed, keys *keyring.Keyring) (feed, error) {
+	encoded := feed{
+		PublicKey: f.PublicKey.Hex(),
+		Url:       f.Address.String(),
+		Nitter:    f.Nitter,
+		Disabled:  f.Disabled,
+		Pending:   f.Pending,
+		Slug:      f.Slug.String(),
+		Submitter: f.Submitter,
+		Metadata: metadata{
+			Title:         f.Metadata.Title,
+			Description:   f.Metadata.Description,
+			Link:          f.Metadata.Link,
+			Image:         f.Metadata.Image,
+			Language:      f.Metadata.Language,
+			ItemCount:     f.Metadata.ItemCount,
+			LastFetchedAt: optionalTime(f.Metadata.LastFetchedAt),
+			LastSuccessAt: optionalTime(f.Metadata.LastSuccessAt),
+			LastError:     f.Metadata.LastError,
+		},
+		Health: health{
+			State:               string(f.Health.State),
+			ConsecutiveFailures: f.Health.ConsecutiveFailures,
+			ErrorKind:           string(f.Health.ErrorKind),
+			FailingSince:        optionalTime(f.Health.FailingSince),
+			NextFetchAt:         optionalTime(f.Health.NextFetchAt),
+		},
+	}
+
+	if f.PrivateKey != nil {
+		privateKey, err := keys.Encrypt(f.PrivateKey.Hex(), encoded.PublicKey)
+		if err != nil {
+			return feed{}, fmt.Errorf("error encrypting the private key: %w", err)
+		}
+		encoded.PrivateKey = privateKey
+	}
+
+	if !f.Move.IsZero() {
+		encoded.Move = &move{From: f.Move.From.String(), At: f.Move.At.UTC()}
+	}
+
+	if !f.OriginalAddress.IsZero() && f.OriginalAddress != f.Address {
+		encoded.OriginalUrl = f.OriginalAddress.String()
+	}
+
+	if !f.Owner.IsZero() {
+		encoded.Owner = &owner{
+			PublicKey:  f.Owner.PublicKey.Hex(),
+			Method:     string(f.Owner.Method),
+			VerifiedAt: f.Owner.VerifiedAt.UTC(),
+		}
+		if f.Owner.BunkerURL != "" {
+			bunkerURL, err := keys.Encrypt(f.Owner.BunkerURL, bunkerURLAssociatedData(encoded.PublicKey))
+			if err != nil {
+				return feed{}, fmt.Errorf("error encrypting the bunker url: %w", err)
+			}
+			encoded.Owner.BunkerURL = bunkerURL
+		}
+		if !f.Owner.Delegation.IsZero() {
+			encoded.Owner.Delegation = f.Owner.Delegation.Tag()
+		}
+	}
+
+	if !f.Profile.IsZero() {
+		encoded.Profile = &profile{
+			Name:    f.Profile.Name,
+			About:   f.Profile.About,
+			Picture: f.Profile.Picture,
+			Banner:  f.Profile.Banner,
+			Lud16:   f.Profile.Lud16,
+			Website: f.Profile.Website,
+		}
+	}
+
+	return encoded, nil
+}
+
+func decodeFeed(f feed, keys *keyring.Keyring) (app.ExportedFeed, error) {
+	publicKey, err := domain.NewPublicKeyFromHex(f.PublicKey)
+	if err != nil {
+		return app.ExportedFeed{}, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	address, err := feeddomain.NewAddress(f.Url)
+	if err != nil {
+		return app.ExportedFeed{}, fmt.Errorf("invalid url: %w", err)
+	}
+
+	decoded := app.ExportedFeed{
+		PublicKey: publicKey,
+		Address:   address,
+		Nitter:    f.Nitter,
+		Disabled:  f.Disabled,
+		Pending:   f.Pending,
+		Submitter: f.Submitter,
+		Metadata: feeddomain.Metadata{
+			Title:         f.Metadata.Title,
+			Description:   f.Metadata.Description,
+			Link:          f.Metadata.Link,
+			Image:         f.Metadata.Image,
+			Language:      f.Metadata.Language,
+			ItemCount:     f.Metadata.ItemCount,
+			LastFetchedAt: fromOptionalTime(f.Metadata.LastFetchedAt),
+			LastSuccessAt: fromOptionalTime(f.Metadata.LastSuccessAt),
+			LastError:     f.Metadata.LastError,
+		},
+		Health: feeddomain.Health{
+			State:               feeddomain.HealthState(f.Health.State),
+			ConsecutiveFailures: f.Health.ConsecutiveFailures,
+			ErrorKind:           feeddomain.ErrorKind(f.Health.ErrorKind),
+			FailingSince:        fromOptionalTime(f.Health.FailingSince),
+			NextFetchAt:         fromOptionalTime(f.Health.NextFetchAt),
+		},
+	}
+
+	if decoded.Health.State == "" {
+		decoded.Health.State = feeddomain.HealthStateHealthy
+	}
+
+	if f.Slug != "" {
+		if decoded.Slug, err = feeddomain.NewSlug(f.Slug); err != nil {
+			return app.ExportedFeed{}, fmt.Errorf("invalid slug: %w", err)
+		}
+	}
+
+	if f.PrivateKey != "" {
+		s, err := decrypt(keys, f.PrivateKey, f.PublicKey)
+		if err != nil {
+			return app.ExportedFeed{}, err
+		}
+
+		privateKey, err := domain.NewPrivateKeyFromHex(s)
+		if err != nil {
+			return app.ExportedFeed{}, fmt.Errorf("invalid private key: %w", err)
+		}
+		decoded.PrivateKey = &privateKey
+	}
+
+	if f.Move != nil {
+		from, err := feeddomain.NewAddress(f.Move.From)
+		if err != nil {
+			return app.ExportedFeed{}, fmt.Errorf("invalid move: %w", err)
+		}
+		decoded.Move = feeddomain.Move{From: from, At: f.Move.At}
+	}
+
+	if f.OriginalUrl != "" {
+		if decoded.OriginalAddress, err = feeddomain.NewAddress(f.OriginalUrl); err != nil {
+			return app.ExportedFeed{}, fmt.Errorf("invalid original url: %w", err)
+		}
+	}
+
+	if f.Owner != nil {
+		if decoded.Owner, err = decodeOwner(*f.Owner, f.PublicKey, keys); err != nil {
+			return app.ExportedFeed{}, err
+		}
+	}
+
+	if f.Profile != nil {
+		decoded.Profile, err = feeddomain.NewProfile(f.Profile.Name, f.Profile.About, f.Profile.Picture, f.Profile.Banner, f.Profile.Lud16, f.Profile.Website)
+		if err != nil {
+			return app.ExportedFeed{}, fmt.Errorf("invalid profile: %w", err)
+		}
+	}
+
+	return decoded, nil
+}
+
+func decodeOwner(o owner, feedPublicKey string, keys *keyring.Keyring) (feeddomain.Owner, error) {
+	publicKey, err := domain.NewPublicKeyFromHex(o.PublicKey)
+	if err != nil {
+		return feeddomain.Owner{}, fmt.Errorf("invalid owner public key: %w", err)
+	}
+
+	decoded := feeddomain.Owner{
+		PublicKey:  publicKey,
+		Method:     feeddomain.VerificationMethod(o.Method),
+		VerifiedAt: o.VerifiedAt,
+	}
+
+	if o.BunkerURL != "" {
+		if decoded.BunkerURL, err = decrypt(keys, o.BunkerURL, bunkerURLAssociatedData(feedPublicKey)); err != nil {
+			return feeddomain.Owner{}, err
+		}
+	}
+
+	if len(o.Delegation) > 0 {
+		if decoded.Delegation, err = nip26.Parse(o.Delegation); err != nil {
+			return feeddomain.Owner{}, fmt.Errorf("invalid owner delegation: %w", err)
+		}
+	}
+
+	return decoded, nil
+}
+
+// decrypt rejects plaintext values, which would be accepted by the keyring,
+// as secrets are never exported in plaintext.
+func decrypt(keys *keyring.Keyring, value string, associatedData string) (string, error) {
+	if keys == nil || keyring.Version(value) <= 0 {
+		return "", errors.New("secrets must be encrypted with the passphrase")
+	}
+
+	decrypted, err := keys.Decrypt(value, associatedData)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return decrypted, nil
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func fromOptionalTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,220 @@
+package feedexport_test
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/database"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/keyring"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/adapters/feedexport"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domainnostr "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+var exportedAt = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func TestEncodeAndDecode(t *testing.T) {
+	feeds := []app.ExportedFeed{someExportedFeed(t, "https://example.com/feed", "a")}
+	feeds[0].Disabled = true
+	feeds[0].Metadata = domainfeed.Metadata{Title: "Example", LastFetchedAt: exportedAt, LastError: "timeout"}
+	feeds[0].Move = domainfeed.Move{From: someAddress(t, "https://example.com/previous"), At: exportedAt}
+	feeds[0].OriginalAddress = someAddress(t, "https://example.com/original")
+	feeds[0].Owner = domainfeed.Owner{
+		PublicKey:  someExportedFeed(t, "https://owner.example.com", "a").PublicKey,
+		Method:     domainfeed.VerificationMethodNIP05,
+		VerifiedAt: exportedAt,
+		BunkerURL:  "bunker://" + nostr.GeneratePrivateKey() + "?relay=wss://relay.example.com",
+	}
+
+	t.Run("without keys", func(t *testing.T) {
+		withoutKeys := append([]app.ExportedFeed(nil), feeds...)
+		withoutKeys[0].PrivateKey = nil
+		withoutKeys[0].Owner.BunkerURL = ""
+
+		var buf bytes.Buffer
+		require.NoError(t, feedexport.Encode(&buf, withoutKeys, "", exportedAt))
+		require.NotContains(t, buf.String(), "salt")
+
+		decoded, err := feedexport.Decode(&buf, "")
+		require.NoError(t, err)
+		require.Equal(t, withoutKeys, decoded)
+	})
+
+	t.Run("with keys", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.ErrorIs(t, feedexport.Encode(&buf, feeds, "", exportedAt), feedexport.ErrPassphraseRequired)
+
+		buf.Reset()
+		require.NoError(t, feedexport.Encode(&buf, feeds, "passphrase", exportedAt))
+		require.NotContains(t, buf.String(), feeds[0].PrivateKey.Hex())
+		require.NotContains(t, buf.String(), feeds[0].Owner.BunkerURL)
+		document := buf.Bytes()
+
+		_, err := feedexport.Decode(bytes.NewReader(document), "")
+		require.ErrorIs(t, err, feedexport.ErrPassphraseRequired)
+
+		_, err = feedexport.Decode(bytes.NewReader(document), "wrong")
+		require.ErrorIs(t, err, feedexport.ErrWrongPassphrase)
+
+		decoded, err := feedexport.Decode(bytes.NewReader(document), "passphrase")
+		require.NoError(t, err)
+		require.Equal(t, feeds, decoded)
+	})
+
+	t.Run("rejects newer versions", func(t *testing.T) {
+		_, err := feedexport.Decode(bytes.NewReader([]byte(`{"version": 2, "feeds": []}`)), "")
+		require.Error(t, err)
+	})
+}
+
+func TestOPML(t *testing.T) {
+	feeds := []app.ExportedFeed{
+		someExportedFeed(t, "https://example.com/feed", "a"),
+		someExportedFeed(t, "https://example.org/rss", "a"),
+	}
+	feeds[0].Metadata = domainfeed.Metadata{Title: "Example", Link: "https://example.com"}
+
+	opml := feedexport.OPML(feeds, exportedAt)
+	require.Equal(t, []domainfeed.OPMLOutline{
+		{Text: "Example", Type: "rss", XMLURL: "https://example.com/feed", HTMLURL: "https://example.com"},
+		{Text: "https://example.org/rss", Type: "rss", XMLURL: "https://example.org/rss"},
+	}, opml.Subscriptions())
+}
+
+func TestImport(t *testing.T) {
+	kept := someExportedFeed(t, "https://example.com/feed", "b")
+	rederived := someExportedFeed(t, "https://example.org/feed", "a")
+	rederived.Slug = someSlug(t, "example-org")
+	exported := someExportedFeed(t, "https://example.net/feed", "a")
+	conflicting := someExportedFeed(t, "https://www.example.com/feed/", "a")
+	moved := someExportedFeed(t, "https://example.com/original", "b")
+	moved.OriginalAddress = moved.Address
+	moved.Address = someAddress(t, "https://example.com/latest")
+	moved.Move = domainfeed.Move{From: someAddress(t, "https://example.com/previous"), At: exportedAt}
+	feeds := []app.ExportedFeed{kept, rederived, exported, conflicting, moved}
+
+	// the keys of the first feeds and of the feed which moved twice were not
+	// exported
+	feeds[0].PrivateKey = nil
+	feeds[1].PrivateKey = nil
+	feeds[4].PrivateKey = nil
+
+	var buf bytes.Buffer
+	require.NoError(t, feedexport.Encode(&buf, feeds, "passphrase", exportedAt))
+	decoded, err := feedexport.Decode(&buf, "passphrase")
+	require.NoError(t, err)
+
+	db := migratedDatabase(t)
+	k, err := keyring.New(map[int]string{1: "key encryption key"})
+	require.NoError(t, err)
+	storage := adapters.NewFeedDefinitionStorage(db, k)
+	handler := app.NewHandlerImportFeedDefinitions(app.NewSecretKeyDeriver(someSecrets(t, "b")), storage, adapters.NewBannedDomainStorage(db), adapters.NewAddressRuleStorage(db))
+
+	results, err := handler.Handle(decoded)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	require.Equal(t, app.ImportStatusCreated, results[0].Status)
+	require.Equal(t, kept.PublicKey, results[0].Definition.PublicKey())
+	require.Equal(t, 1, results[0].Definition.KeyVersion())
+
+	require.Equal(t, app.ImportStatusRederived, results[1].Status)
+	require.NotEqual(t, rederived.PublicKey, results[1].Definition.PublicKey())
+	require.Equal(t, "example-org", results[1].Definition.Slug().String())
+
+	require.Equal(t, app.ImportStatusCreated, results[2].Status)
+	require.Equal(t, exported.PublicKey, results[2].Definition.PublicKey())
+	require.Equal(t, 0, results[2].Definition.KeyVersion())
+
+	require.Equal(t, app.ImportStatusConflict, results[3].Status)
+	require.Equal(t, kept.PublicKey, results[3].ConflictsWith.PublicKey())
+
+	require.Equal(t, app.ImportStatusCreated, results[4].Status)
+	require.Equal(t, moved.PublicKey, results[4].Definition.PublicKey(), "the keys are derived from the original address")
+	require.Equal(t, moved.OriginalAddress, results[4].Definition.OriginalAddress())
+
+	stored, err := storage.Get(exported.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, exported.PrivateKey.Hex(), stored.PrivateKey().Hex())
+
+	// importing again changes nothing
+	results, err = handler.Handle(decoded)
+	require.NoError(t, err)
+	require.Equal(t, app.ImportStatusExists, results[0].Status)
+	require.Equal(t, app.ImportStatusExists, results[1].Status)
+	require.Equal(t, app.ImportStatusExists, results[2].Status)
+	require.Equal(t, app.ImportStatusConflict, results[3].Status)
+	require.Equal(t, app.ImportStatusExists, results[4].Status)
+
+	count, err := storage.CountTotal()
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+}
+
+// someExportedFeed returns a feed whose keys are derived from its address
+// with the secret.
+func someExportedFeed(t *testing.T, address string, secret string) app.ExportedFeed {
+	a, err := domainfeed.NewAddress(address)
+	require.NoError(t, err)
+
+	privateKeyHex := feed.PrivateKeyFromFeed(a.Canonical().String(), secret)
+	publicKeyHex, err := nostr.GetPublicKey(privateKeyHex)
+	require.NoError(t, err)
+
+	privateKey, err := domainnostr.NewPrivateKeyFromHex(privateKeyHex)
+	require.NoError(t, err)
+	publicKey, err := domainnostr.NewPublicKeyFromHex(publicKeyHex)
+	require.NoError(t, err)
+
+	return app.ExportedFeed{
+		PublicKey:  publicKey,
+		PrivateKey: &privateKey,
+		Address:    a,
+		Health:     domainfeed.Health{State: domainfeed.HealthStateHealthy},
+	}
+}
+
+func someAddress(t *testing.T, address string) domainfeed.Address {
+	a, err := domainfeed.NewAddress(address)
+	require.NoError(t, err)
+	return a
+}
+
+func someSlug(t *testing.T, s string) domainfeed.Slug {
+	slug, err := domainfeed.NewSlug(s)
+	require.NoError(t, err)
+	return slug
+}
+
+func someSecrets(t *testing.T, s string) domain.Secrets {
+	secret, err := domain.NewSecret(s)
+	require.NoError(t, err)
+	secrets, err := domain.NewSecrets(domain.VersionedSecret{Version: 1, Secret: secret})
+	require.NoError(t, err)
+	return secrets
+}
+
+func migratedDatabase(t *testing.T) *sql.DB {
+	db, err := database.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// every connection would get its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	migrator, err := database.NewMigrator(db, ":memory:")
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	return db
+}
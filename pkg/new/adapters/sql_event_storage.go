@@ -0,0 +1,73 @@
+package adapters
+
+import (
+	"database/sql"
+	"log"
+
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+// SQLEventStorage is a persistent alternative to EventStorage, feed events
+// survive restarts and are shared by all instances using the same database.
+type SQLEventStorage struct {
+	db *sql.DB
+}
+
+func NewSQLEventStorage(db *sql.DB) *SQLEventStorage {
+	return &SQLEventStorage{db: db}
+}
+
+func (s *SQLEventStorage) PutEvents(author domain.PublicKey, events []domain.Event) error {
+	log.Printf("saving %d events for feed %s", len(events), author.Hex())
+
+	for _, event := range events {
+		if !author.Equal(event.PublicKey()) {
+			return errors.New("one or more events weren't created by this author")
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting a transaction")
+	}
+	defer tx.Rollback() // not much we can do here
+
+	if err := feedEventTables.deleteByAuthor(tx, author); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if _, err := feedEventTables.insert(tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+
+	return nil
+}
+
+func (s *SQLEventStorage) DeleteEvents(author domain.PublicKey) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting a transaction")
+	}
+	defer tx.Rollback() // not much we can do here
+
+	if err := feedEventTables.deleteByAuthor(tx, author); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+
+	return nil
+}
+
+func (s *SQLEventStorage) GetEvents(filter domain.Filter) ([]domain.Event, error) {
+	return feedEventTables.query(s.db, filter)
+}
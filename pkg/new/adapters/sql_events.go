@@ -0,0 +1,196 @@
+package adapters
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/metrics"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultEventsQueryLimit = 500
+
+// eventTables stores events in a table holding the raw events and a table
+// holding their single letter tags so that filters can be turned into
+// queries.
+type eventTables struct {
+	events string
+	tags   string
+}
+
+var (
+	userEventTables = eventTables{events: "user_events", tags: "user_event_tags"}
+	feedEventTables = eventTables{events: "feed_events", tags: "feed_event_tags"}
+)
+
+// insert returns false if the event was already stored.
+func (t eventTables) insert(tx *sql.Tx, event domain.Event) (bool, error) {
+	libevent := event.Libevent()
+
+	raw, err := json.Marshal(libevent)
+	if err != nil {
+		return false, errors.Wrap(err, "error marshaling the event")
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO `+t.events+` (id, pubkey, kind, created_at, event) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`,
+		libevent.ID, libevent.PubKey, libevent.Kind, int64(libevent.CreatedAt), string(raw),
+	)
+	if err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return false, errors.Wrap(err, "error inserting the event")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "error checking affected rows")
+	}
+
+	if affected == 0 {
+		return false, nil
+	}
+
+	for _, tag := range libevent.Tags {
+		if len(tag) < 2 || len(tag[0]) != 1 {
+			continue
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO `+t.tags+` (event_id, name, value) VALUES ($1, $2, $3)`,
+			libevent.ID, tag[0], tag[1],
+		); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+			return false, errors.Wrap(err, "error inserting the event tag")
+		}
+	}
+
+	return true, nil
+}
+
+// deleteByAuthor doesn't rely on cascading deletes as SQLite doesn't enforce
+// foreign keys by default.
+func (t eventTables) deleteByAuthor(tx *sql.Tx, author domain.PublicKey) error {
+	if _, err := tx.Exec(
+		`DELETE FROM `+t.tags+` WHERE event_id IN (SELECT id FROM `+t.events+` WHERE pubkey = $1)`,
+		author.Hex(),
+	); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the event tags")
+	}
+
+	if _, err := tx.Exec(`DELETE FROM `+t.events+` WHERE pubkey = $1`, author.Hex()); err != nil {
+		metrics.AppErrors.With(prometheus.Labels{"type": "SQL_WRITE"}).Inc()
+		return errors.Wrap(err, "error deleting the events")
+	}
+
+	return nil
+}
+
+func (t eventTables) query(db *sql.DB, filter domain.Filter) ([]domain.Event, error) {
+	query, args := t.buildQuery(filter.Libfilter())
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying events")
+	}
+	defer rows.Close() // not much we can do here
+
+	var results []domain.Event
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			metrics.AppErrors.With(prometheus.Labels{"type": "SQL_SCAN"}).Inc()
+			return nil, errors.Wrap(err, "error scanning the retrieved rows")
+		}
+
+		var libevent nostr.Event
+		if err := json.Unmarshal([]byte(raw), &libevent); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling the event")
+		}
+
+		event, err := domain.NewEvent(libevent)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a domain event")
+		}
+
+		// the query only narrows the results down, the filter has the final say
+		if filter.Matches(event) {
+			results = append(results, event)
+		}
+	}
+
+	return results, rows.Err()
+}
+
+func (t eventTables) buildQuery(filter nostr.Filter) (string, []any) {
+	var (
+		conditions []string
+		args       queryArgs
+	)
+
+	if len(filter.IDs) > 0 {
+		conditions = append(conditions, "id IN ("+args.addStrings(filter.IDs)+")")
+	}
+
+	if len(filter.Authors) > 0 {
+		conditions = append(conditions, "pubkey IN ("+args.addStrings(filter.Authors)+")")
+	}
+
+	if len(filter.Kinds) > 0 {
+		var kinds []string
+		for _, kind := range filter.Kinds {
+			kinds = append(kinds, args.add(kind))
+		}
+		conditions = append(conditions, "kind IN ("+strings.Join(kinds, ", ")+")")
+	}
+
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= "+args.add(int64(*filter.Since)))
+	}
+
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at <= "+args.add(int64(*filter.Until)))
+	}
+
+	for name, values := range filter.Tags {
+		if len(values) == 0 {
+			continue
+		}
+		conditions = append(conditions, "id IN (SELECT event_id FROM "+t.tags+" WHERE name = "+args.add(name)+" AND value IN ("+args.addStrings(values)+"))")
+	}
+
+	query := "SELECT event FROM " + t.events
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > defaultEventsQueryLimit {
+		limit = defaultEventsQueryLimit
+	}
+	query += " ORDER BY created_at DESC LIMIT " + args.add(limit)
+
+	return query, args
+}
+
+// queryArgs collects query arguments and returns numbered placeholders which
+// are understood by both SQLite and PostgreSQL.
+type queryArgs []any
+
+func (q *queryArgs) add(value any) string {
+	*q = append(*q, value)
+	return fmt.Sprintf("$%d", len(*q))
+}
+
+func (q *queryArgs) addStrings(values []string) string {
+	var placeholders []string
+	for _, value := range values {
+		placeholders = append(placeholders, q.add(value))
+	}
+	return strings.Join(placeholders, ", ")
+}
@@ -0,0 +1,993 @@
+package adapters_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/database"
+	"github.com/piraces/rsslay/pkg/keyring"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip26"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postgresDSNEnv points to a PostgreSQL database used to run the storage
+// tests, every test creates its own schema in it.
+const postgresDSNEnv = "RSSLAY_TEST_POSTGRES_DSN"
+
+type openDatabaseFunc func(t *testing.T) *sql.DB
+
+func TestSQLiteStorages(t *testing.T) {
+	testSQLStorages(t, func(t *testing.T) *sql.DB {
+		return migratedDatabase(t, ":memory:")
+	})
+}
+
+func TestPostgresStorages(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	testSQLStorages(t, func(t *testing.T) *sql.DB {
+		return migratedDatabase(t, postgresSchema(t, dsn))
+	})
+}
+
+func TestMemoryEventStorage(t *testing.T) {
+	testEventStorage(t, func(t *testing.T) app.EventStorage {
+		return adapters.NewEventStorage()
+	})
+}
+
+func testSQLStorages(t *testing.T, open openDatabaseFunc) {
+	t.Run("feed definitions", func(t *testing.T) {
+		testFeedDefinitionStorage(t, func(t *testing.T) app.FeedDefinitionStorage {
+			return adapters.NewFeedDefinitionStorage(open(t), someKeyring(t, 1))
+		})
+	})
+
+	t.Run("private key encryption", func(t *testing.T) {
+		testPrivateKeyEncryption(t, open)
+	})
+
+	t.Run("encrypted private keys", func(t *testing.T) {
+		testEncryptedPrivateKeys(t, open)
+	})
+
+	t.Run("private keys held by a bunker", func(t *testing.T) {
+		testPrivateKeysHeldByBunker(t, open)
+	})
+
+	t.Run("canonical addresses of existing feeds", func(t *testing.T) {
+		testFillCanonicalAddresses(t, open)
+	})
+
+	t.Run("feed events", func(t *testing.T) {
+		testEventStorage(t, func(t *testing.T) app.EventStorage {
+			return adapters.NewSQLEventStorage(open(t))
+		})
+	})
+
+	t.Run("user events", func(t *testing.T) {
+		testUserEventStorage(t, func(t *testing.T) app.UserEventStorage {
+			return adapters.NewUserEventStorage(open(t))
+		})
+	})
+
+	t.Run("banned domains", func(t *testing.T) {
+		testBannedDomainStorage(t, func(t *testing.T) app.BannedDomainStorage {
+			return adapters.NewBannedDomainStorage(open(t))
+		})
+	})
+
+	t.Run("address rules", func(t *testing.T) {
+		testAddressRuleStorage(t, func(t *testing.T) app.AddressRuleStorage {
+			return adapters.NewAddressRuleStorage(open(t))
+		})
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		testAPIKeyStorage(t, func(t *testing.T) app.APIKeyStorage {
+			return adapters.NewAPIKeyStorage(open(t))
+		})
+	})
+}
+
+func testFeedDefinitionStorage(t *testing.T, newStorage func(t *testing.T) app.FeedDefinitionStorage) {
+	t.Run("put and get", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "example-com")
+		definition.SetKeyVersion(2)
+		definition.SetSubmitter("key:0123456789abcdef")
+
+		require.NoError(t, storage.Put(definition))
+		require.NoError(t, storage.Put(definition), "putting an existing feed is a no-op")
+
+		stored, err := storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, definition.PublicKey().Hex(), stored.PublicKey().Hex())
+		assert.Equal(t, definition.PrivateKey().Hex(), stored.PrivateKey().Hex())
+		assert.Equal(t, 2, stored.KeyVersion())
+		assert.Equal(t, "key:0123456789abcdef", stored.Submitter())
+		assert.Equal(t, definition.Address(), stored.Address())
+		assert.Equal(t, definition.Slug(), stored.Slug())
+		assert.False(t, stored.Nitter())
+		assert.False(t, stored.Disabled())
+
+		count, err := storage.CountTotal()
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		_, err = storage.Get(someFeedDefinition(t, "https://example.com/other", "").PublicKey())
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("list and search skip disabled feeds", func(t *testing.T) {
+		storage := newStorage(t)
+		enabled := someFeedDefinition(t, "https://example.com/feed", "")
+		disabled := someFeedDefinition(t, "https://example.org/feed", "")
+		require.NoError(t, storage.Put(enabled))
+		require.NoError(t, storage.Put(disabled))
+		require.NoError(t, storage.SetDisabled(disabled.PublicKey(), true))
+
+		all, err := storage.List()
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		random, err := storage.ListRandom(10)
+		require.NoError(t, err)
+		assertFeeds(t, []*domainfeed.FeedDefinition{enabled}, random)
+
+		found, err := storage.Search("example", 10)
+		require.NoError(t, err)
+		assertFeeds(t, []*domainfeed.FeedDefinition{enabled}, found)
+
+		found, err = storage.Search("missing", 10)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		stored, err := storage.Get(disabled.PublicKey())
+		require.NoError(t, err)
+		assert.True(t, stored.Disabled())
+
+		err = storage.SetDisabled(someFeedDefinition(t, "https://example.net/feed", "").PublicKey(), true)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("pending feeds", func(t *testing.T) {
+		storage := newStorage(t)
+		approved := someFeedDefinition(t, "https://example.com/feed", "")
+		pending := someFeedDefinition(t, "https://example.org/feed", "")
+		pending.SetPending(true)
+		require.NoError(t, storage.Put(approved))
+		require.NoError(t, storage.Put(pending))
+
+		stored, err := storage.Get(pending.PublicKey())
+		require.NoError(t, err)
+		assert.True(t, stored.Pending())
+
+		random, err := storage.ListRandom(10)
+		require.NoError(t, err)
+		assertFeeds(t, []*domainfeed.FeedDefinition{approved}, random)
+
+		found, err := storage.Search("example", 10)
+		require.NoError(t, err)
+		assertFeeds(t, []*domainfeed.FeedDefinition{approved}, found)
+
+		isPending := true
+		page, err := storage.ListPage(domainfeed.ListFilter{Pending: &isPending, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assertFeeds(t, []*domainfeed.FeedDefinition{pending}, page.Feeds)
+
+		require.NoError(t, storage.SetPending(pending.PublicKey(), false))
+
+		stored, err = storage.Get(pending.PublicKey())
+		require.NoError(t, err)
+		assert.False(t, stored.Pending())
+
+		page, err = storage.ListPage(domainfeed.ListFilter{Pending: &isPending, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 0, page.Total)
+
+		err = storage.SetPending(someFeedDefinition(t, "https://example.net/feed", "").PublicKey(), true)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("slugs are unique", func(t *testing.T) {
+		storage := newStorage(t)
+		first := someFeedDefinition(t, "https://example.com/feed", "example")
+		second := someFeedDefinition(t, "https://example.org/feed", "")
+		require.NoError(t, storage.Put(first))
+		require.NoError(t, storage.Put(second))
+
+		err := storage.Put(someFeedDefinition(t, "https://example.net/feed", "example"))
+		assert.True(t, errors.Is(err, domainfeed.ErrSlugTaken))
+
+		err = storage.SetSlug(second.PublicKey(), first.Slug())
+		assert.True(t, errors.Is(err, domainfeed.ErrSlugTaken))
+
+		slug, err := domainfeed.NewSlug("other")
+		require.NoError(t, err)
+		require.NoError(t, storage.SetSlug(second.PublicKey(), slug))
+
+		stored, err := storage.GetBySlug(slug)
+		require.NoError(t, err)
+		assert.Equal(t, second.PublicKey().Hex(), stored.PublicKey().Hex())
+
+		missing, err := domainfeed.NewSlug("missing")
+		require.NoError(t, err)
+		_, err = storage.GetBySlug(missing)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "")
+		other := someFeedDefinition(t, "https://example.org/feed", "")
+		require.NoError(t, storage.Put(definition))
+		require.NoError(t, storage.Put(other))
+
+		stored, err := storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, domainfeed.Metadata{}, stored.Metadata())
+
+		metadata := domainfeed.Metadata{
+			Title:         "Gardening Weekly",
+			Description:   "Notes about Tomatoes",
+			Link:          "https://example.com/",
+			Image:         "https://example.com/logo.png",
+			Language:      "en",
+			ItemCount:     12,
+			LastFetchedAt: time.Unix(2000, 0),
+			LastSuccessAt: time.Unix(1000, 0),
+			LastError:     "timeout",
+		}
+		require.NoError(t, storage.SetMetadata(definition.PublicKey(), metadata))
+
+		stored, err = storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, metadata, stored.Metadata())
+
+		for _, query := range []string{"gardening", "TOMATOES", "example.com"} {
+			found, err := storage.Search(query, 10)
+			require.NoError(t, err)
+			assertFeeds(t, []*domainfeed.FeedDefinition{definition}, found)
+		}
+
+		err = storage.SetMetadata(someFeedDefinition(t, "https://example.net/feed", "").PublicKey(), metadata)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("health", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "")
+		require.NoError(t, storage.Put(definition))
+
+		stored, err := storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, domainfeed.Health{State: domainfeed.HealthStateHealthy}, stored.Health())
+
+		health := domainfeed.Health{
+			State:               domainfeed.HealthStateSuspended,
+			ConsecutiveFailures: 5,
+			ErrorKind:           domainfeed.ErrorKindTLS,
+			FailingSince:        time.Unix(1000, 0),
+			NextFetchAt:         time.Unix(3000, 0),
+		}
+		require.NoError(t, storage.SetHealth(definition.PublicKey(), health))
+
+		stored, err = storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, health, stored.Health())
+
+		err = storage.SetHealth(someFeedDefinition(t, "https://example.net/feed", "").PublicKey(), health)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("move", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "")
+		require.NoError(t, storage.Put(definition))
+
+		newAddress, err := domainfeed.NewAddress("https://example.org/feed.xml")
+		require.NoError(t, err)
+		definition.MoveTo(newAddress, time.Unix(1000, 0))
+		require.NoError(t, storage.SetAddress(definition.PublicKey(), definition.Address(), definition.Move()))
+
+		stored, err := storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, newAddress, stored.Address())
+		assert.Equal(t, definition.Move(), stored.Move())
+
+		stored, err = storage.GetByAddress(newAddress)
+		require.NoError(t, err)
+		assert.Equal(t, definition.PublicKey(), stored.PublicKey())
+
+		_, err = storage.GetByAddress(definition.Move().From)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+
+		stored, err = storage.GetByCanonicalAddress(someAddress(t, "http://www.example.org/feed.xml/"))
+		require.NoError(t, err)
+		assert.Equal(t, definition.PublicKey(), stored.PublicKey())
+
+		_, err = storage.GetByCanonicalAddress(definition.Move().From)
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+
+		originalAddress := definition.Move().From
+		definition.MoveTo(someAddress(t, "https://example.net/atom.xml"), time.Unix(2000, 0))
+		require.NoError(t, storage.SetAddress(definition.PublicKey(), definition.Address(), definition.Move()))
+
+		stored, err = storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, newAddress, stored.Move().From)
+		assert.Equal(t, originalAddress, stored.OriginalAddress(), "moves keep the original address")
+	})
+
+	t.Run("canonical address", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "http://www.example.com/feed/?utm_source=newsletter", "")
+		require.NoError(t, storage.Put(definition))
+
+		stored, err := storage.GetByCanonicalAddress(someAddress(t, "https://example.com/feed"))
+		require.NoError(t, err)
+		assert.Equal(t, definition.PublicKey(), stored.PublicKey())
+		assert.Equal(t, definition.Address(), stored.Address())
+
+		_, err = storage.GetByCanonicalAddress(someAddress(t, "https://example.com/other"))
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "")
+		other := someFeedDefinition(t, "https://example.net/feed", "")
+		require.NoError(t, storage.Put(definition))
+		require.NoError(t, storage.Put(other))
+
+		ownerKey := somePrivateKey()
+		delegation, err := nip26.Create(ownerKey.privateKey, definition.PublicKey().Hex(), "kind=1")
+		require.NoError(t, err)
+		owner := domainfeed.Owner{
+			PublicKey:  publicKeyOf(t, ownerKey),
+			Method:     domainfeed.VerificationMethodMeta,
+			VerifiedAt: time.Unix(1000, 0),
+			BunkerURL:  "bunker://" + publicKeyOf(t, somePrivateKey()).Hex() + "?relay=wss%3A%2F%2Frelay.example.com&secret=abc",
+			Delegation: delegation,
+		}
+		require.NoError(t, storage.SetOwner(definition.PublicKey(), owner))
+
+		stored, err := storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, owner, stored.Owner())
+
+		owned, err := storage.ListByOwner(owner.PublicKey)
+		require.NoError(t, err)
+		assertFeeds(t, []*domainfeed.FeedDefinition{stored}, owned)
+
+		require.NoError(t, storage.SetOwner(definition.PublicKey(), domainfeed.Owner{}))
+		stored, err = storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.True(t, stored.Owner().IsZero())
+
+		owned, err = storage.ListByOwner(owner.PublicKey)
+		require.NoError(t, err)
+		assert.Empty(t, owned)
+	})
+
+	t.Run("page", func(t *testing.T) {
+		storage := newStorage(t)
+		definition1 := someFeedDefinition(t, "https://a.example.com/feed", "")
+		definition2 := someFeedDefinition(t, "https://b.example.com/feed", "")
+		definition3 := someFeedDefinition(t, "https://c.example.com/feed", "")
+		for _, definition := range []*domainfeed.FeedDefinition{definition1, definition2, definition3} {
+			require.NoError(t, storage.Put(definition))
+		}
+		require.NoError(t, storage.SetDisabled(definition2.PublicKey(), true))
+		require.NoError(t, storage.SetHealth(definition3.PublicKey(), domainfeed.Health{State: domainfeed.HealthStateSuspended}))
+
+		page, err := storage.ListPage(domainfeed.ListFilter{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 3, page.Total)
+		require.Len(t, page.Feeds, 2)
+		assert.Equal(t, definition1.PublicKey(), page.Feeds[0].PublicKey())
+		assert.Equal(t, definition2.PublicKey(), page.Feeds[1].PublicKey())
+
+		page, err = storage.ListPage(domainfeed.ListFilter{Limit: 2, Offset: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 3, page.Total)
+		assertFeeds(t, []*domainfeed.FeedDefinition{definition3}, page.Feeds)
+
+		enabled := false
+		page, err = storage.ListPage(domainfeed.ListFilter{Disabled: &enabled, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 2, page.Total)
+		assertFeeds(t, []*domainfeed.FeedDefinition{definition1, definition3}, page.Feeds)
+
+		page, err = storage.ListPage(domainfeed.ListFilter{Health: domainfeed.HealthStateSuspended, Disabled: &enabled, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assertFeeds(t, []*domainfeed.FeedDefinition{definition3}, page.Feeds)
+
+		owned := true
+		page, err = storage.ListPage(domainfeed.ListFilter{Owned: &owned, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 0, page.Total)
+		assert.Empty(t, page.Feeds)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "")
+		require.NoError(t, storage.Put(definition))
+
+		stored, err := storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.True(t, stored.Profile().IsZero())
+
+		profile := domainfeed.Profile{Name: "Example", About: "About", Banner: "https://example.com/banner.png", Lud16: "tips@example.com"}
+		require.NoError(t, storage.SetProfile(definition.PublicKey(), profile))
+
+		stored, err = storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.Equal(t, profile, stored.Profile())
+
+		require.NoError(t, storage.SetProfile(definition.PublicKey(), domainfeed.Profile{}))
+		stored, err = storage.Get(definition.PublicKey())
+		require.NoError(t, err)
+		assert.True(t, stored.Profile().IsZero())
+
+		err = storage.SetProfile(someFeedDefinition(t, "https://example.net/feed", "").PublicKey(), profile)
+		assert.ErrorIs(t, err, domainfeed.ErrFeedDefinitionNotFound)
+	})
+
+	t.Run("items", func(t *testing.T) {
+		storage := newStorage(t)
+		definition1 := someFeedDefinition(t, "https://example.com/feed", "")
+		definition2 := someFeedDefinition(t, "https://example.net/feed", "")
+		require.NoError(t, storage.Put(definition1))
+		require.NoError(t, storage.Put(definition2))
+
+		require.NoError(t, storage.SetItemGUIDs(definition1.PublicKey(), []string{"old"}))
+		require.NoError(t, storage.SetItemGUIDs(definition1.PublicKey(), []string{"a", "b", "c"}))
+		require.NoError(t, storage.SetItemGUIDs(definition2.PublicKey(), []string{"b", "c", "d", "d"}))
+
+		matches, err := storage.FindItemGUIDs([]string{"a", "b", "old"})
+		require.NoError(t, err)
+		assert.Equal(t, []domainfeed.ItemMatch{
+			{PublicKey: definition1.PublicKey(), Shared: 2},
+			{PublicKey: definition2.PublicKey(), Shared: 1},
+		}, matches)
+
+		shared, err := storage.ListSharedItems()
+		require.NoError(t, err)
+		require.Len(t, shared, 1)
+		assert.Equal(t, 2, shared[0].Shared)
+		assert.Equal(t, 3, shared[0].ItemsA)
+		assert.Equal(t, 3, shared[0].ItemsB)
+
+		require.NoError(t, storage.Delete(definition1.PublicKey()))
+
+		matches, err = storage.FindItemGUIDs([]string{"a", "b"})
+		require.NoError(t, err)
+		assert.Equal(t, []domainfeed.ItemMatch{{PublicKey: definition2.PublicKey(), Shared: 1}}, matches)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		storage := newStorage(t)
+		definition := someFeedDefinition(t, "https://example.com/feed", "")
+		require.NoError(t, storage.Put(definition))
+
+		require.NoError(t, storage.Delete(definition.PublicKey()))
+
+		_, err := storage.Get(definition.PublicKey())
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+
+		err = storage.Delete(definition.PublicKey())
+		assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+	})
+}
+
+func testPrivateKeyEncryption(t *testing.T, open openDatabaseFunc) {
+	db := open(t)
+	definition := someFeedDefinition(t, "https://example.com/feed", "")
+
+	owner := domainfeed.Owner{
+		PublicKey: publicKeyOf(t, somePrivateKey()),
+		Method:    domainfeed.VerificationMethodNIP05,
+		BunkerURL: "bunker://" + publicKeyOf(t, somePrivateKey()).Hex() + "?relay=wss%3A%2F%2Frelay.example.com&secret=abc",
+	}
+
+	plaintext := adapters.NewFeedDefinitionStorage(db, someKeyring(t))
+	require.NoError(t, plaintext.Put(definition))
+	require.NoError(t, plaintext.SetOwner(definition.PublicKey(), owner))
+	assert.Equal(t, definition.PrivateKey().Hex(), storedPrivateKey(t, db, definition))
+
+	encrypted := adapters.NewFeedDefinitionStorage(db, someKeyring(t, 1))
+	status, err := encrypted.PrivateKeyStatus()
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 1}, status.KeyVersions)
+	assert.Equal(t, 2, status.Outdated(1))
+
+	reencrypted, err := encrypted.ReencryptPrivateKeys()
+	require.NoError(t, err)
+	assert.Equal(t, 2, reencrypted)
+	assert.NotContains(t, storedPrivateKey(t, db, definition), definition.PrivateKey().Hex())
+
+	rotated := adapters.NewFeedDefinitionStorage(db, someKeyring(t, 1, 2))
+	reencrypted, err = rotated.ReencryptPrivateKeys()
+	require.NoError(t, err)
+	assert.Equal(t, 2, reencrypted)
+
+	status, err = rotated.PrivateKeyStatus()
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{2: 2}, status.Encryption)
+
+	reencrypted, err = rotated.ReencryptPrivateKeys()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reencrypted)
+
+	stored, err := rotated.Get(definition.PublicKey())
+	require.NoError(t, err)
+	assert.Equal(t, definition.PrivateKey().Hex(), stored.PrivateKey().Hex())
+	assert.Equal(t, owner.BunkerURL, stored.Owner().BunkerURL)
+
+	_, err = encrypted.Get(definition.PublicKey())
+	assert.ErrorIs(t, err, keyring.ErrUnknownKey)
+}
+
+// testEncryptedPrivateKeys makes sure that the column fits encrypted keys,
+// which are longer than hex keys.
+func testEncryptedPrivateKeys(t *testing.T, open openDatabaseFunc) {
+	db := open(t)
+	storage := adapters.NewFeedDefinitionStorage(db, someKeyring(t, 1))
+
+	definition := someFeedDefinition(t, "https://example.com/feed", "")
+	require.NoError(t, storage.Put(definition))
+	assert.Greater(t, len(storedPrivateKey(t, db, definition)), len(definition.PrivateKey().Hex()))
+
+	stored, err := storage.Get(definition.PublicKey())
+	require.NoError(t, err)
+	assert.Equal(t, definition.PrivateKey().Hex(), stored.PrivateKey().Hex())
+}
+
+func testPrivateKeysHeldByBunker(t *testing.T, open openDatabaseFunc) {
+	db := open(t)
+	keys := someKeyring(t, 1)
+	storage := adapters.NewFeedDefinitionStorage(db, keys)
+
+	stored := someFeedDefinition(t, "https://example.com/stored", "")
+	require.NoError(t, storage.Put(stored))
+
+	remote := someFeedDefinition(t, "https://example.com/remote", "")
+	withoutKey := domainfeed.NewFeedDefinitionWithoutPrivateKey(remote.PublicKey(), remote.Address(), false)
+	require.NoError(t, storage.Put(withoutKey))
+	assert.Empty(t, storedPrivateKey(t, db, withoutKey))
+
+	definition, err := storage.Get(withoutKey.PublicKey())
+	require.NoError(t, err)
+	assert.False(t, definition.HasPrivateKey())
+
+	status, err := storage.PrivateKeyStatus()
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Missing)
+	assert.Equal(t, map[int]int{1: 1}, status.Encryption)
+
+	// a relay signing with a bunker can't decrypt the stored keys
+	withoutPrivateKeys := adapters.NewFeedDefinitionStorageWithoutPrivateKeys(db, someKeyring(t, 2))
+	definition, err = withoutPrivateKeys.Get(stored.PublicKey())
+	require.NoError(t, err)
+	assert.False(t, definition.HasPrivateKey())
+}
+
+func testFillCanonicalAddresses(t *testing.T, open openDatabaseFunc) {
+	db := open(t)
+	storage := adapters.NewFeedDefinitionStorage(db, someKeyring(t, 1))
+
+	definition := someFeedDefinition(t, "http://www.example.com/feed/", "")
+	require.NoError(t, storage.Put(definition))
+
+	filled, err := storage.FillCanonicalAddresses()
+	require.NoError(t, err)
+	assert.Equal(t, 0, filled)
+
+	// feeds created before the canonical addresses were stored
+	_, err = db.Exec(`UPDATE feeds SET canonical_url = NULL`)
+	require.NoError(t, err)
+
+	_, err = storage.GetByCanonicalAddress(definition.Address())
+	assert.True(t, errors.Is(err, domainfeed.ErrFeedDefinitionNotFound))
+
+	filled, err = storage.FillCanonicalAddresses()
+	require.NoError(t, err)
+	assert.Equal(t, 1, filled)
+
+	stored, err := storage.GetByCanonicalAddress(someAddress(t, "https://example.com/feed"))
+	require.NoError(t, err)
+	assert.Equal(t, definition.PublicKey(), stored.PublicKey())
+}
+
+func storedPrivateKey(t *testing.T, db *sql.DB, definition *domainfeed.FeedDefinition) string {
+	var privateKey string
+	err := db.QueryRow(`SELECT privatekey FROM feeds WHERE publickey = $1`, definition.PublicKey().Hex()).Scan(&privateKey)
+	require.NoError(t, err)
+	return privateKey
+}
+
+func someKeyring(t *testing.T, versions ...int) *keyring.Keyring {
+	keys := make(map[int]string)
+	for _, version := range versions {
+		keys[version] = fmt.Sprintf("key encryption key %d", version)
+	}
+
+	k, err := keyring.New(keys)
+	require.NoError(t, err)
+	return k
+}
+
+func testEventStorage(t *testing.T, newStorage func(t *testing.T) app.EventStorage) {
+	t.Run("put replaces the events of the author", func(t *testing.T) {
+		storage := newStorage(t)
+		author, other := somePrivateKey(), somePrivateKey()
+
+		first := someEvent(t, author, 1, "first", nostr.Tags{{"t", "news"}})
+		second := someEvent(t, author, 1, "second", nil)
+		require.NoError(t, storage.PutEvents(publicKeyOf(t, author), []domain.Event{first, second}))
+		require.NoError(t, storage.PutEvents(publicKeyOf(t, other), []domain.Event{someEvent(t, other, 1, "other", nil)}))
+
+		events, err := storage.GetEvents(authorFilter(author))
+		require.NoError(t, err)
+		assertEvents(t, []domain.Event{first, second}, events)
+
+		third := someEvent(t, author, 1, "third", nil)
+		require.NoError(t, storage.PutEvents(publicKeyOf(t, author), []domain.Event{second, third}))
+
+		events, err = storage.GetEvents(authorFilter(author))
+		require.NoError(t, err)
+		assertEvents(t, []domain.Event{second, third}, events)
+
+		events, err = storage.GetEvents(domain.NewFilter(&nostr.Filter{Tags: nostr.TagMap{"t": {"news"}}}))
+		require.NoError(t, err)
+		assert.Empty(t, events, "tags of replaced events must be gone")
+
+		events, err = storage.GetEvents(domain.NewFilter(&nostr.Filter{}))
+		require.NoError(t, err)
+		assert.Len(t, events, 3)
+	})
+
+	t.Run("put rejects events of other authors", func(t *testing.T) {
+		storage := newStorage(t)
+		author, other := somePrivateKey(), somePrivateKey()
+
+		err := storage.PutEvents(publicKeyOf(t, author), []domain.Event{someEvent(t, other, 1, "other", nil)})
+		assert.Error(t, err)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		storage := newStorage(t)
+		author := somePrivateKey()
+
+		note := someEvent(t, author, 1, "note", nostr.Tags{{"t", "news"}})
+		metadata := someEvent(t, author, 0, "{}", nil)
+		require.NoError(t, storage.PutEvents(publicKeyOf(t, author), []domain.Event{note, metadata}))
+
+		events, err := storage.GetEvents(domain.NewFilter(&nostr.Filter{Kinds: []int{0}}))
+		require.NoError(t, err)
+		assertEvents(t, []domain.Event{metadata}, events)
+
+		events, err = storage.GetEvents(domain.NewFilter(&nostr.Filter{IDs: []string{note.ID().Hex()}}))
+		require.NoError(t, err)
+		assertEvents(t, []domain.Event{note}, events)
+
+		events, err = storage.GetEvents(domain.NewFilter(&nostr.Filter{Tags: nostr.TagMap{"t": {"news", "other"}}}))
+		require.NoError(t, err)
+		assertEvents(t, []domain.Event{note}, events)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		storage := newStorage(t)
+		author := somePrivateKey()
+
+		require.NoError(t, storage.PutEvents(publicKeyOf(t, author), []domain.Event{someEvent(t, author, 1, "note", nil)}))
+		require.NoError(t, storage.DeleteEvents(publicKeyOf(t, author)))
+
+		events, err := storage.GetEvents(authorFilter(author))
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		require.NoError(t, storage.DeleteEvents(publicKeyOf(t, author)))
+	})
+}
+
+func testUserEventStorage(t *testing.T, newStorage func(t *testing.T) app.UserEventStorage) {
+	t.Run("save and get", func(t *testing.T) {
+		storage := newStorage(t)
+		author := somePrivateKey()
+
+		event := someEvent(t, author, 7, "+", nostr.Tags{{"e", "abc"}, {"p", "def"}})
+		require.NoError(t, storage.SaveEvent(event))
+
+		err := storage.SaveEvent(event)
+		assert.True(t, errors.Is(err, domain.ErrDuplicateEvent))
+
+		events, err := storage.GetEvents(domain.NewFilter(&nostr.Filter{Tags: nostr.TagMap{"e": {"abc"}}}))
+		require.NoError(t, err)
+		assertEvents(t, []domain.Event{event}, events)
+
+		events, err = storage.GetEvents(domain.NewFilter(&nostr.Filter{Tags: nostr.TagMap{"e": {"def"}}}))
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("time range and limit", func(t *testing.T) {
+		storage := newStorage(t)
+		author := somePrivateKey()
+
+		var saved []domain.Event
+		for i := 0; i < 3; i++ {
+			event := someEventAt(t, author, 1, fmt.Sprintf("note %d", i), nil, time.Unix(int64(1000+i), 0))
+			require.NoError(t, storage.SaveEvent(event))
+			saved = append(saved, event)
+		}
+
+		since := nostr.Timestamp(1001)
+		until := nostr.Timestamp(1001)
+		events, err := storage.GetEvents(domain.NewFilter(&nostr.Filter{Since: &since, Until: &until}))
+		require.NoError(t, err)
+		assertEvents(t, saved[1:2], events)
+
+		events, err = storage.GetEvents(domain.NewFilter(&nostr.Filter{Authors: []string{author.publicKey}, Limit: 2}))
+		require.NoError(t, err)
+		assertEvents(t, saved[1:], events, "the newest events come first")
+	})
+}
+
+func testBannedDomainStorage(t *testing.T, newStorage func(t *testing.T) app.BannedDomainStorage) {
+	storage := newStorage(t)
+
+	banned, err := domainfeed.NewDomain("example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Ban(banned, "spam"))
+	require.NoError(t, storage.Ban(banned, "more spam"))
+
+	domains, err := storage.List()
+	require.NoError(t, err)
+	require.Len(t, domains, 1)
+	assert.Equal(t, "more spam", domains[0].Reason)
+
+	address, err := domainfeed.NewAddress("https://blog.example.com/feed")
+	require.NoError(t, err)
+
+	isBanned, err := storage.IsBanned(address)
+	require.NoError(t, err)
+	assert.True(t, isBanned)
+
+	require.NoError(t, storage.Allow(banned))
+
+	isBanned, err = storage.IsBanned(address)
+	require.NoError(t, err)
+	assert.False(t, isBanned)
+}
+
+func testAddressRuleStorage(t *testing.T, newStorage func(t *testing.T) app.AddressRuleStorage) {
+	storage := newStorage(t)
+
+	spam, err := domainfeed.NewAddressPattern("*.example.com/spam/*")
+	require.NoError(t, err)
+	allowed, err := domainfeed.NewAddressPattern("example.org")
+	require.NoError(t, err)
+
+	createdAt := time.Unix(1700000000, 0)
+	require.NoError(t, storage.Put(domainfeed.AddressRule{Pattern: spam, Action: domainfeed.AddressRuleAllow, CreatedAt: createdAt}))
+	require.NoError(t, storage.Put(domainfeed.AddressRule{Pattern: spam, Action: domainfeed.AddressRuleDeny, Reason: "spam", CreatedAt: createdAt}))
+	require.NoError(t, storage.Put(domainfeed.AddressRule{Pattern: allowed, Action: domainfeed.AddressRuleAllow, CreatedAt: createdAt}))
+
+	rules, err := storage.List()
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, spam.String(), rules[0].Pattern.String())
+	assert.Equal(t, domainfeed.AddressRuleDeny, rules[0].Action)
+	assert.Equal(t, "spam", rules[0].Reason)
+	assert.Equal(t, createdAt, rules[0].CreatedAt)
+	assert.Equal(t, allowed.String(), rules[1].Pattern.String())
+
+	address, err := domainfeed.NewAddress("https://blog.example.com/spam/feed")
+	require.NoError(t, err)
+	accepted, _ := rules.Accepts(address)
+	assert.False(t, accepted)
+
+	require.NoError(t, storage.Delete(spam))
+
+	rules, err = storage.List()
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, allowed.String(), rules[0].Pattern.String())
+}
+
+func testAPIKeyStorage(t *testing.T, newStorage func(t *testing.T) app.APIKeyStorage) {
+	storage := newStorage(t)
+
+	createdAt := time.Unix(1700000000, 0)
+	importer, token, err := auth.NewAPIKey("importer", auth.MustNewScopes("create", "manage"), 500, createdAt)
+	require.NoError(t, err)
+	admin, _, err := auth.NewAPIKey("admin", auth.MustNewScopes("admin"), 0, createdAt.Add(time.Second))
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Put(importer))
+	require.NoError(t, storage.Put(admin))
+
+	stored, err := storage.Get(importer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "importer", stored.Name)
+	assert.Equal(t, "create,manage", stored.Scopes.String())
+	assert.Equal(t, 500, stored.Quota)
+	assert.Equal(t, createdAt, stored.CreatedAt)
+
+	_, secret, err := auth.ParseAPIKeyToken(token)
+	require.NoError(t, err)
+	assert.True(t, stored.Verify(secret))
+
+	keys, err := storage.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, importer.ID, keys[0].ID)
+	assert.Equal(t, admin.ID, keys[1].ID)
+
+	require.NoError(t, storage.Delete(importer.ID))
+	assert.ErrorIs(t, storage.Delete(importer.ID), auth.ErrAPIKeyNotFound)
+
+	_, err = storage.Get(importer.ID)
+	assert.ErrorIs(t, err, auth.ErrAPIKeyNotFound)
+}
+
+func migratedDatabase(t *testing.T, dsn string) *sql.DB {
+	db, err := database.Open(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if database.DialectOf(dsn) == database.SQLite {
+		// every connection would get its own in-memory database
+		db.SetMaxOpenConns(1)
+	}
+
+	migrator, err := database.NewMigrator(db, dsn)
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	return db
+}
+
+// postgresSchema creates an empty schema which is dropped after the test and
+// returns a datasource name using it.
+func postgresSchema(t *testing.T, dsn string) string {
+	db, err := database.Open(dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	schema := fmt.Sprintf("rsslay_test_%d", time.Now().UnixNano())
+	_, err = db.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		db, err := database.Open(dsn)
+		require.NoError(t, err)
+		defer db.Close()
+
+		_, err = db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		require.NoError(t, err)
+	})
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+type privateKey struct {
+	privateKey string
+	publicKey  string
+}
+
+func somePrivateKey() privateKey {
+	sk := nostr.GeneratePrivateKey()
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		panic(err)
+	}
+	return privateKey{privateKey: sk, publicKey: pk}
+}
+
+func publicKeyOf(t *testing.T, key privateKey) domain.PublicKey {
+	publicKey, err := domain.NewPublicKeyFromHex(key.publicKey)
+	require.NoError(t, err)
+	return publicKey
+}
+
+func someFeedDefinition(t *testing.T, rawAddress string, rawSlug string) *domainfeed.FeedDefinition {
+	key := somePrivateKey()
+
+	privateKey, err := domain.NewPrivateKeyFromHex(key.privateKey)
+	require.NoError(t, err)
+
+	definition, err := domainfeed.NewFeedDefinition(publicKeyOf(t, key), privateKey, someAddress(t, rawAddress), false)
+	require.NoError(t, err)
+
+	if rawSlug != "" {
+		slug, err := domainfeed.NewSlug(rawSlug)
+		require.NoError(t, err)
+		definition.SetSlug(slug)
+	}
+
+	return definition
+}
+
+func someAddress(t *testing.T, rawAddress string) domainfeed.Address {
+	address, err := domainfeed.NewAddress(rawAddress)
+	require.NoError(t, err)
+	return address
+}
+
+func someEvent(t *testing.T, author privateKey, kind int, content string, tags nostr.Tags) domain.Event {
+	return someEventAt(t, author, kind, content, tags, time.Now())
+}
+
+func someEventAt(t *testing.T, author privateKey, kind int, content string, tags nostr.Tags, createdAt time.Time) domain.Event {
+	libevent := nostr.Event{
+		PubKey:    author.publicKey,
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+	require.NoError(t, libevent.Sign(author.privateKey))
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+	return event
+}
+
+func authorFilter(author privateKey) domain.Filter {
+	return domain.NewFilter(&nostr.Filter{Authors: []string{author.publicKey}})
+}
+
+func assertEvents(t *testing.T, expected, actual []domain.Event, msgAndArgs ...any) {
+	assert.ElementsMatch(t, eventIDs(expected), eventIDs(actual), msgAndArgs...)
+}
+
+func eventIDs(events []domain.Event) []string {
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID().Hex())
+	}
+	return ids
+}
+
+func assertFeeds(t *testing.T, expected, actual []*domainfeed.FeedDefinition) {
+	var expectedKeys, actualKeys []string
+	for _, definition := range expected {
+		expectedKeys = append(expectedKeys, definition.PublicKey().Hex())
+	}
+	for _, definition := range actual {
+		actualKeys = append(actualKeys, definition.PublicKey().Hex())
+	}
+	assert.ElementsMatch(t, expectedKeys, actualKeys)
+}
@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"database/sql"
+
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+// UserEventStorage persists events published by users (replies, reactions,
+// zap receipts) which, unlike feed events, can't be regenerated.
+type UserEventStorage struct {
+	db *sql.DB
+}
+
+func NewUserEventStorage(db *sql.DB) *UserEventStorage {
+	return &UserEventStorage{db: db}
+}
+
+func (u *UserEventStorage) SaveEvent(event domain.Event) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error starting a transaction")
+	}
+	defer tx.Rollback() // not much we can do here
+
+	inserted, err := userEventTables.insert(tx, event)
+	if err != nil {
+		return err
+	}
+
+	if !inserted {
+		return domain.ErrDuplicateEvent
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+
+	return nil
+}
+
+func (u *UserEventStorage) GetEvents(filter domain.Filter) ([]domain.Event, error) {
+	return userEventTables.query(u.db, filter)
+}
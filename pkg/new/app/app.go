@@ -1,37 +1,178 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/mmcdole/gofeed"
 	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
 	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
 	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/signer"
+)
+
+var (
+	ErrDomainBanned              = errors.New("feeds from this domain are not accepted")
+	ErrAddressNotAccepted        = errors.New("feeds from this address are not accepted")
+	ErrCreationRateLimited       = errors.New("too many feeds submitted, try again later")
+	ErrImportRateLimited         = errors.New("too many imports, try again later")
+	ErrFeedLimitReached          = errors.New("the relay doesn't accept more feeds")
+	ErrMissingScope              = errors.New("the credentials don't allow this")
+	ErrNoFeedFound               = errors.New("could not find a feed URL in there")
+	ErrFeedDisabled              = errors.New("feed is disabled")
+	ErrFeedPending               = errors.New("feed awaits approval")
+	ErrFeedNotPending            = errors.New("feed doesn't await approval")
+	ErrItemNotFound              = errors.New("item not found")
+	ErrInvalidAddress            = errors.New("invalid feed address")
+	ErrNotFeedOwner              = errors.New("only the owner of the feed can do this")
+	ErrOwnerPublishesAnotherFeed = errors.New("the owner already publishes another feed through their remote signer")
 )
 
 type App struct {
-	CreateFeedDefinition *HandlerCreateFeedDefinition
-	UpdateFeeds          *HandlerUpdateFeeds
+	CreateFeedDefinition     *HandlerCreateFeedDefinition
+	ImportFeeds              *HandlerImportFeeds
+	ImportFeedDefinitions    *HandlerImportFeedDefinitions
+	UpdateFeeds              *HandlerUpdateFeeds
+	SaveUserEvent            *HandlerSaveUserEvent
+	SetFeedDisabled          *HandlerSetFeedDisabled
+	DeleteFeed               *HandlerDeleteFeed
+	RefreshFeed              *HandlerRefreshFeed
+	BanDomain                *HandlerBanDomain
+	AllowDomain              *HandlerAllowDomain
+	AddAuditLogEntry         *HandlerAddAuditLogEntry
+	ProcessDirectMessage     *HandlerProcessDirectMessage
+	SetFeedSlug              *HandlerSetFeedSlug
+	AssignFeedSlugs          *HandlerAssignFeedSlugs
+	RegisterFeedOwner        *HandlerRegisterFeedOwner
+	RemoveFeedOwner          *HandlerRemoveFeedOwner
+	SetFeedProfile           *HandlerSetFeedProfile
+	AddAddressRule           *HandlerAddAddressRule
+	RemoveAddressRule        *HandlerRemoveAddressRule
+	ApplyConfiguredDenyLists *HandlerApplyConfiguredDenyLists
+	CreateAPIKey             *HandlerCreateAPIKey
+	RevokeAPIKey             *HandlerRevokeAPIKey
+	ApproveFeed              *HandlerApproveFeed
+	RejectFeed               *HandlerRejectFeed
 
-	GetEvents         *HandlerGetEvents
-	GetTotalFeedCount *HandlerGetTotalFeedCount
-	GetRandomFeeds    *HandlerGetRandomFeeds
-	SearchFeeds       *HandlerSearchFeeds
+	GetEvents             *HandlerGetEvents
+	GetTotalFeedCount     *HandlerGetTotalFeedCount
+	GetRandomFeeds        *HandlerGetRandomFeeds
+	SearchFeeds           *HandlerSearchFeeds
+	ListFeeds             *HandlerListFeeds
+	ListFeedsPage         *HandlerListFeedsPage
+	GetFeedItem           *HandlerGetFeedItem
+	GetFeed               *HandlerGetFeed
+	PreviewFeed           *HandlerPreviewFeed
+	ListBannedDomains     *HandlerListBannedDomains
+	ListAddressRules      *HandlerListAddressRules
+	ListAPIKeys           *HandlerListAPIKeys
+	AuthenticateAPIKey    *HandlerAuthenticateAPIKey
+	ListFailingFeeds      *HandlerListFailingFeeds
+	ListDuplicateFeeds    *HandlerListDuplicateFeeds
+	GetFeedBySlug         *HandlerGetFeedBySlug
+	ExportFeedDefinitions *HandlerExportFeedDefinitions
 }
 
 type FeedDefinitionStorage interface {
 	Put(definition *feeddomain.FeedDefinition) error
+	Get(publicKey domain.PublicKey) (*feeddomain.FeedDefinition, error)
 	CountTotal() (int, error)
 	List() ([]*feeddomain.FeedDefinition, error)
+	ListPage(filter feeddomain.ListFilter) (feeddomain.Page, error)
 	ListRandom(limit int) ([]*feeddomain.FeedDefinition, error)
 	Search(query string, limit int) ([]*feeddomain.FeedDefinition, error)
+	SetDisabled(publicKey domain.PublicKey, disabled bool) error
+	SetPending(publicKey domain.PublicKey, pending bool) error
+	GetBySlug(slug feeddomain.Slug) (*feeddomain.FeedDefinition, error)
+	GetByAddress(address feeddomain.Address) (*feeddomain.FeedDefinition, error)
+	GetByCanonicalAddress(address feeddomain.Address) (*feeddomain.FeedDefinition, error)
+	SetSlug(publicKey domain.PublicKey, slug feeddomain.Slug) error
+	SetMetadata(publicKey domain.PublicKey, metadata feeddomain.Metadata) error
+	SetHealth(publicKey domain.PublicKey, health feeddomain.Health) error
+	SetAddress(publicKey domain.PublicKey, address feeddomain.Address, move feeddomain.Move) error
+	SetOwner(publicKey domain.PublicKey, owner feeddomain.Owner) error
+	ListByOwner(owner domain.PublicKey) ([]*feeddomain.FeedDefinition, error)
+	SetProfile(publicKey domain.PublicKey, profile feeddomain.Profile) error
+	SetItemGUIDs(publicKey domain.PublicKey, guids []string) error
+	FindItemGUIDs(guids []string) ([]feeddomain.ItemMatch, error)
+	ListSharedItems() ([]feeddomain.SharedItems, error)
+	Delete(publicKey domain.PublicKey) error
 }
 
 type EventStorage interface {
 	GetEvents(filter domain.Filter) ([]domain.Event, error)
 	PutEvents(author domain.PublicKey, events []domain.Event) error
+	DeleteEvents(author domain.PublicKey) error
+}
+
+type UserEventStorage interface {
+	GetEvents(filter domain.Filter) ([]domain.Event, error)
+	SaveEvent(event domain.Event) error
+}
+
+type BannedDomainStorage interface {
+	Ban(domain feeddomain.Domain, reason string) error
+	Allow(domain feeddomain.Domain) error
+	List() ([]feeddomain.BannedDomain, error)
+	IsBanned(address feeddomain.Address) (bool, error)
+}
+
+type AddressRuleStorage interface {
+	Put(rule feeddomain.AddressRule) error
+	Delete(pattern feeddomain.AddressPattern) error
+	List() (feeddomain.AddressRules, error)
+}
+
+type APIKeyStorage interface {
+	Put(key auth.APIKey) error
+	Get(id string) (auth.APIKey, error)
+	List() ([]auth.APIKey, error)
+	Delete(id string) error
+}
+
+type AuditLogEntry struct {
+	PublicKey string
+	Method    string
+	Params    string
+	Error     string
+	CreatedAt time.Time
+}
+
+type AuditLogStorage interface {
+	Put(entry AuditLogEntry) error
+}
+
+type FeedUpdater interface {
+	UpdateFeed(ctx context.Context, definition *feeddomain.FeedDefinition) error
 }
 
 type ConverterSelector interface {
+	Mode(feed *gofeed.Feed) feed.OutputMode
 	Select(feed *gofeed.Feed) feed.ItemToEventConverter
+	SelectMode(mode feed.OutputMode) (feed.ItemToEventConverter, error)
+}
+
+// OwnerSigners connects to the remote signers of the feed owners.
+type OwnerSigners interface {
+	Signer(ctx context.Context, bunkerURL string) (signer.Signer, error)
+	Remove(bunkerURL string)
+}
+
+// KeyDeriver derives the keys of feeds from their addresses, either in
+// process or in the bunker holding the secrets.
+type KeyDeriver interface {
+	// Derive returns the keys derived from each of the addresses with every
+	// version of the secret, the newest version first.
+	Derive(addresses ...string) ([]DerivedKeys, error)
+}
+
+// SubmitterNotifier tells the submitters of feeds about the decisions of the
+// moderators.
+type SubmitterNotifier interface {
+	FeedApproved(submitter domain.PublicKey, definition *feeddomain.FeedDefinition) error
+	FeedRejected(submitter domain.PublicKey, address feeddomain.Address, reason string) error
 }
 
 type EventPublisher interface {
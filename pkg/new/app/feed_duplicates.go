@@ -0,0 +1,82 @@
+package app
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/piraces/rsslay/pkg/feed"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+// getFeedByKeys finds the feed whose keys were derived from the address with
+// any version of the secret. Feeds created before addresses were canonicalized have
+// keys derived from the address exactly as it was submitted.
+func getFeedByKeys(feedDefinitionStorage FeedDefinitionStorage, address feeddomain.Address, keyDeriver KeyDeriver) (*feeddomain.FeedDefinition, error) {
+	derived, err := keyDeriver.Derive(address.Canonical().String(), address.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving the feed keys")
+	}
+
+	for _, keys := range derived {
+		definition, err := feedDefinitionStorage.Get(keys.PublicKey)
+		if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+			return definition, err
+		}
+	}
+
+	return nil, feeddomain.ErrFeedDefinitionNotFound
+}
+
+// findDuplicateFeed finds an existing feed which serves the same content as
+// the parsed feed found at the address. Feeds are duplicated if their
+// canonical addresses match the address, the address they were redirected to
+// or their self link or if they have most of the items of the parsed feed.
+func findDuplicateFeed(feedDefinitionStorage FeedDefinitionStorage, address feeddomain.Address, parsedFeed *gofeed.Feed) (*feeddomain.FeedDefinition, error) {
+	addresses := []feeddomain.Address{address}
+	for _, s := range []string{feed.PermanentRedirect(parsedFeed), parsedFeed.FeedLink} {
+		if other, err := feeddomain.NewAddress(s); err == nil {
+			addresses = append(addresses, other)
+		}
+	}
+
+	for _, candidate := range addresses {
+		definition, err := feedDefinitionStorage.GetByCanonicalAddress(candidate)
+		if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+			return definition, err
+		}
+	}
+
+	guids := itemGUIDs(parsedFeed)
+	matches, err := feedDefinitionStorage.FindItemGUIDs(guids)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding feeds with the same items")
+	}
+
+	if len(matches) > 0 && feeddomain.IsDuplicate(matches[0].Shared, len(guids)) {
+		return feedDefinitionStorage.Get(matches[0].PublicKey)
+	}
+
+	return nil, feeddomain.ErrFeedDefinitionNotFound
+}
+
+// itemGUIDs identifies the latest items of a feed, items without a GUID are
+// identified by their link.
+func itemGUIDs(parsedFeed *gofeed.Feed) []string {
+	var guids []string
+	seen := make(map[string]bool)
+	for _, item := range parsedFeed.Items {
+		guid := item.GUID
+		if guid == "" {
+			guid = item.Link
+		}
+
+		if guid != "" && !seen[guid] {
+			seen[guid] = true
+			guids = append(guids, guid)
+		}
+
+		if len(guids) == feeddomain.MaxItemGUIDs {
+			break
+		}
+	}
+	return guids
+}
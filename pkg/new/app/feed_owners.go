@@ -0,0 +1,27 @@
+package app
+
+import (
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+// deleteFeedEvents deletes the events published with the key of the feed as
+// well as the ones signed by its owner.
+func deleteFeedEvents(eventStorage EventStorage, definition *feeddomain.FeedDefinition) error {
+	if err := eventStorage.DeleteEvents(definition.PublicKey()); err != nil {
+		return err
+	}
+	return deleteOwnerEvents(eventStorage, definition.Owner())
+}
+
+// deleteOwnerEvents deletes the events which the owner signed through their
+// remote signer, owners publish a single feed this way.
+func deleteOwnerEvents(eventStorage EventStorage, owner feeddomain.Owner) error {
+	if !owner.SignsRemotely() {
+		return nil
+	}
+	if err := eventStorage.DeleteEvents(owner.PublicKey); err != nil {
+		return errors.Wrap(err, "error deleting the events of the owner")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"time"
+
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerAddAddressRule struct {
+	addressRuleStorage AddressRuleStorage
+}
+
+func NewHandlerAddAddressRule(addressRuleStorage AddressRuleStorage) *HandlerAddAddressRule {
+	return &HandlerAddAddressRule{
+		addressRuleStorage: addressRuleStorage,
+	}
+}
+
+// Handle adds the rule or replaces the one with the same pattern.
+func (h *HandlerAddAddressRule) Handle(pattern domainfeed.AddressPattern, action domainfeed.AddressRuleAction, reason string) error {
+	return h.addressRuleStorage.Put(domainfeed.AddressRule{
+		Pattern:   pattern,
+		Action:    action,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
+}
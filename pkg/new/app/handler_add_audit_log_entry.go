@@ -0,0 +1,15 @@
+package app
+
+type HandlerAddAuditLogEntry struct {
+	auditLogStorage AuditLogStorage
+}
+
+func NewHandlerAddAuditLogEntry(auditLogStorage AuditLogStorage) *HandlerAddAuditLogEntry {
+	return &HandlerAddAuditLogEntry{
+		auditLogStorage: auditLogStorage,
+	}
+}
+
+func (h *HandlerAddAuditLogEntry) Handle(entry AuditLogEntry) error {
+	return h.auditLogStorage.Put(entry)
+}
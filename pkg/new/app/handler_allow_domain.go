@@ -0,0 +1,19 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerAllowDomain struct {
+	bannedDomainStorage BannedDomainStorage
+}
+
+func NewHandlerAllowDomain(bannedDomainStorage BannedDomainStorage) *HandlerAllowDomain {
+	return &HandlerAllowDomain{
+		bannedDomainStorage: bannedDomainStorage,
+	}
+}
+
+func (h *HandlerAllowDomain) Handle(domain domainfeed.Domain) error {
+	return h.bannedDomainStorage.Allow(domain)
+}
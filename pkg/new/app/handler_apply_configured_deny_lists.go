@@ -0,0 +1,116 @@
+package app
+
+import (
+	"time"
+
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+// ConfiguredReason is the reason of the banned domains and the address rules
+// which come from the configuration. They are replaced whenever the
+// configuration is applied while the ones added through the management API
+// are left alone.
+const ConfiguredReason = "configuration"
+
+type ConfiguredDenyLists struct {
+	BannedDomains []domainfeed.Domain
+	AddressRules  []domainfeed.AddressRule
+}
+
+type HandlerApplyConfiguredDenyLists struct {
+	bannedDomainStorage BannedDomainStorage
+	addressRuleStorage  AddressRuleStorage
+}
+
+func NewHandlerApplyConfiguredDenyLists(bannedDomainStorage BannedDomainStorage, addressRuleStorage AddressRuleStorage) *HandlerApplyConfiguredDenyLists {
+	return &HandlerApplyConfiguredDenyLists{
+		bannedDomainStorage: bannedDomainStorage,
+		addressRuleStorage:  addressRuleStorage,
+	}
+}
+
+func (h *HandlerApplyConfiguredDenyLists) Handle(lists ConfiguredDenyLists) error {
+	if err := h.applyBannedDomains(lists.BannedDomains); err != nil {
+		return errors.Wrap(err, "error applying the banned domains")
+	}
+
+	if err := h.applyAddressRules(lists.AddressRules); err != nil {
+		return errors.Wrap(err, "error applying the address rules")
+	}
+
+	return nil
+}
+
+func (h *HandlerApplyConfiguredDenyLists) applyBannedDomains(domains []domainfeed.Domain) error {
+	banned, err := h.bannedDomainStorage.List()
+	if err != nil {
+		return err
+	}
+
+	configured := make(map[string]bool)
+	for _, domain := range domains {
+		configured[domain.String()] = true
+	}
+
+	existing := make(map[string]bool)
+	for _, b := range banned {
+		existing[b.Domain.String()] = true
+		if b.Reason == ConfiguredReason && !configured[b.Domain.String()] {
+			if err := h.bannedDomainStorage.Allow(b.Domain); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, domain := range domains {
+		// the domains banned through the management API keep their reason
+		if existing[domain.String()] {
+			continue
+		}
+		if err := h.bannedDomainStorage.Ban(domain, ConfiguredReason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HandlerApplyConfiguredDenyLists) applyAddressRules(rules []domainfeed.AddressRule) error {
+	current, err := h.addressRuleStorage.List()
+	if err != nil {
+		return err
+	}
+
+	configured := make(map[string]bool)
+	for _, rule := range rules {
+		configured[rule.Pattern.String()] = true
+	}
+
+	managed := make(map[string]bool)
+	for _, rule := range current {
+		if rule.Reason != ConfiguredReason {
+			managed[rule.Pattern.String()] = true
+			continue
+		}
+		if !configured[rule.Pattern.String()] {
+			if err := h.addressRuleStorage.Delete(rule.Pattern); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		// the rules added through the management API take precedence
+		if managed[rule.Pattern.String()] {
+			continue
+		}
+		rule.Reason = ConfiguredReason
+		rule.CreatedAt = time.Now()
+		if err := h.addressRuleStorage.Put(rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
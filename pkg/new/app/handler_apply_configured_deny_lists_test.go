@@ -0,0 +1,67 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyConfiguredDenyListsKeepsTheManagedEntries(t *testing.T) {
+	db := migratedDatabase(t)
+	bannedDomainStorage := adapters.NewBannedDomainStorage(db)
+	addressRuleStorage := adapters.NewAddressRuleStorage(db)
+	handler := app.NewHandlerApplyConfiguredDenyLists(bannedDomainStorage, addressRuleStorage)
+
+	require.NoError(t, bannedDomainStorage.Ban(someDomain(t, "managed.example.com"), "spam"))
+	require.NoError(t, addressRuleStorage.Put(someAddressRule(t, "managed.example.com/*", "spam")))
+
+	err := handler.Handle(app.ConfiguredDenyLists{
+		BannedDomains: []domainfeed.Domain{someDomain(t, "managed.example.com"), someDomain(t, "removed.example.com")},
+		AddressRules:  []domainfeed.AddressRule{someAddressRule(t, "managed.example.com/*", ""), someAddressRule(t, "removed.example.com/*", "")},
+	})
+	require.NoError(t, err)
+
+	err = handler.Handle(app.ConfiguredDenyLists{
+		BannedDomains: []domainfeed.Domain{someDomain(t, "added.example.com")},
+		AddressRules:  []domainfeed.AddressRule{someAddressRule(t, "added.example.com/*", "")},
+	})
+	require.NoError(t, err)
+
+	banned, err := bannedDomainStorage.List()
+	require.NoError(t, err)
+	bannedReasons := make(map[string]string)
+	for _, b := range banned {
+		bannedReasons[b.Domain.String()] = b.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"managed.example.com": "spam",
+		"added.example.com":   app.ConfiguredReason,
+	}, bannedReasons)
+
+	rules, err := addressRuleStorage.List()
+	require.NoError(t, err)
+	ruleReasons := make(map[string]string)
+	for _, rule := range rules {
+		ruleReasons[rule.Pattern.String()] = rule.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"managed.example.com/*": "spam",
+		"added.example.com/*":   app.ConfiguredReason,
+	}, ruleReasons)
+}
+
+func someDomain(t *testing.T, s string) domainfeed.Domain {
+	domain, err := domainfeed.NewDomain(s)
+	require.NoError(t, err)
+	return domain
+}
+
+func someAddressRule(t *testing.T, pattern, reason string) domainfeed.AddressRule {
+	p, err := domainfeed.NewAddressPattern(pattern)
+	require.NoError(t, err)
+	return domainfeed.AddressRule{Pattern: p, Action: domainfeed.AddressRuleDeny, Reason: reason}
+}
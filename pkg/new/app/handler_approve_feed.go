@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type HandlerApproveFeed struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	feedUpdater           FeedUpdater
+	notifier              SubmitterNotifier
+}
+
+// NewHandlerApproveFeed creates the handler, the notifier is nil if the
+// submitters can't be notified.
+func NewHandlerApproveFeed(
+	feedDefinitionStorage FeedDefinitionStorage,
+	feedUpdater FeedUpdater,
+	notifier SubmitterNotifier,
+) *HandlerApproveFeed {
+	return &HandlerApproveFeed{
+		feedDefinitionStorage: feedDefinitionStorage,
+		feedUpdater:           feedUpdater,
+		notifier:              notifier,
+	}
+}
+
+// Handle starts serving a pending feed and sends its profile to the submitter
+// if they submitted it through nostr.
+func (h *HandlerApproveFeed) Handle(ctx context.Context, publicKey domain.PublicKey) (*domainfeed.FeedDefinition, error) {
+	definition, err := h.feedDefinitionStorage.Get(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the feed definition")
+	}
+
+	if !definition.Pending() {
+		return nil, ErrFeedNotPending
+	}
+
+	if err := h.feedDefinitionStorage.SetPending(publicKey, false); err != nil {
+		return nil, errors.Wrap(err, "error approving the feed")
+	}
+	definition.SetPending(false)
+	metrics.FeedModerationDecisions.With(prometheus.Labels{"decision": "approved"}).Inc()
+
+	// failures are recorded in the health of the feed which is retried later
+	if err := h.feedUpdater.UpdateFeed(ctx, definition); err != nil {
+		log.Printf("[ERROR] failure to fetch approved feed %s: %v", publicKey.Hex(), err)
+	}
+
+	if submitter, ok := auth.NostrPublicKey(definition.Submitter()); ok && h.notifier != nil {
+		if err := h.notifier.FeedApproved(submitter, definition); err != nil {
+			log.Printf("[ERROR] failure to notify the submitter of feed %s: %v", publicKey.Hex(), err)
+		}
+	}
+
+	return definition, nil
+}
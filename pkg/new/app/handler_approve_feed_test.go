@@ -0,0 +1,146 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerationTransitions(t *testing.T) {
+	testCases := []struct {
+		name           string
+		moderated      bool
+		decide         func(f *feedCreation, notifier app.SubmitterNotifier, updater *recordingFeedUpdater, definition *domainfeed.FeedDefinition) error
+		expectedErr    error
+		expectDeleted  bool
+		expectPending  bool
+		expectUpdated  bool
+		expectedNotice string
+	}{
+		{
+			name:      "approving a pending feed",
+			moderated: true,
+			decide: func(f *feedCreation, notifier app.SubmitterNotifier, updater *recordingFeedUpdater, definition *domainfeed.FeedDefinition) error {
+				_, err := app.NewHandlerApproveFeed(f.storage, updater, notifier).Handle(context.Background(), definition.PublicKey())
+				return err
+			},
+			expectUpdated:  true,
+			expectedNotice: "approved",
+		},
+		{
+			name:      "rejecting a pending feed",
+			moderated: true,
+			decide: func(f *feedCreation, notifier app.SubmitterNotifier, updater *recordingFeedUpdater, definition *domainfeed.FeedDefinition) error {
+				return app.NewHandlerRejectFeed(f.storage, notifier).Handle(definition.PublicKey(), "spam")
+			},
+			expectDeleted:  true,
+			expectedNotice: "rejected: spam",
+		},
+		{
+			name: "approving a feed which isn't pending",
+			decide: func(f *feedCreation, notifier app.SubmitterNotifier, updater *recordingFeedUpdater, definition *domainfeed.FeedDefinition) error {
+				_, err := app.NewHandlerApproveFeed(f.storage, updater, notifier).Handle(context.Background(), definition.PublicKey())
+				return err
+			},
+			expectedErr: app.ErrFeedNotPending,
+		},
+		{
+			name: "rejecting a feed which isn't pending",
+			decide: func(f *feedCreation, notifier app.SubmitterNotifier, updater *recordingFeedUpdater, definition *domainfeed.FeedDefinition) error {
+				return app.NewHandlerRejectFeed(f.storage, notifier).Handle(definition.PublicKey(), "spam")
+			},
+			expectedErr: app.ErrFeedNotPending,
+		},
+		{
+			name:      "approving a feed twice",
+			moderated: true,
+			decide: func(f *feedCreation, notifier app.SubmitterNotifier, updater *recordingFeedUpdater, definition *domainfeed.FeedDefinition) error {
+				handler := app.NewHandlerApproveFeed(f.storage, updater, notifier)
+				if _, err := handler.Handle(context.Background(), definition.PublicKey()); err != nil {
+					return err
+				}
+				_, err := handler.Handle(context.Background(), definition.PublicKey())
+				return err
+			},
+			expectedErr:    app.ErrFeedNotPending,
+			expectUpdated:  true,
+			expectedNotice: "approved",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newFeedCreation(t, testCase.moderated)
+			submitter := somePublicKey(t)
+			definition := f.createAs("/a", auth.NewNostrIdentity(submitter, false))
+			require.Equal(t, testCase.moderated, definition.Pending())
+
+			notifier := &recordingNotifier{}
+			updater := &recordingFeedUpdater{}
+			err := testCase.decide(f, notifier, updater, definition)
+			if testCase.expectedErr != nil {
+				require.ErrorIs(t, err, testCase.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			stored, err := f.storage.Get(definition.PublicKey())
+			if testCase.expectDeleted {
+				require.ErrorIs(t, err, domainfeed.ErrFeedDefinitionNotFound)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectPending, stored.Pending())
+			}
+
+			assert.Equal(t, testCase.expectUpdated, updater.updated)
+
+			if testCase.expectedNotice == "" {
+				assert.Empty(t, notifier.notices)
+			} else {
+				assert.Equal(t, []string{submitter.Hex() + " " + testCase.expectedNotice}, notifier.notices)
+			}
+		})
+	}
+}
+
+func TestModerationWithoutNotifier(t *testing.T) {
+	f := newFeedCreation(t, true)
+	definition := f.createAs("/a", auth.NewNostrIdentity(somePublicKey(t), false))
+
+	updater := &recordingFeedUpdater{err: errors.New("the feed is offline")}
+	approved, err := app.NewHandlerApproveFeed(f.storage, updater, nil).Handle(context.Background(), definition.PublicKey())
+	require.NoError(t, err, "failing to fetch an approved feed is recorded in its health")
+	assert.False(t, approved.Pending())
+	assert.True(t, updater.updated)
+}
+
+type recordingFeedUpdater struct {
+	err     error
+	updated bool
+}
+
+func (u *recordingFeedUpdater) UpdateFeed(ctx context.Context, definition *domainfeed.FeedDefinition) error {
+	u.updated = true
+	return u.err
+}
+
+type recordingNotifier struct {
+	notices []string
+}
+
+func (n *recordingNotifier) FeedApproved(submitter domain.PublicKey, definition *domainfeed.FeedDefinition) error {
+	n.notices = append(n.notices, submitter.Hex()+" approved")
+	return nil
+}
+
+func (n *recordingNotifier) FeedRejected(submitter domain.PublicKey, address domainfeed.Address, reason string) error {
+	n.notices = append(n.notices, submitter.Hex()+" rejected: "+reason)
+	return nil
+}
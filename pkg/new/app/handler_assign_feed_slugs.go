@@ -0,0 +1,77 @@
+package app
+
+import (
+	"log"
+
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+const maxSlugAttempts = 100
+
+// HandlerAssignFeedSlugs generates slugs for the feeds created before slugs
+// were introduced.
+type HandlerAssignFeedSlugs struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerAssignFeedSlugs(feedDefinitionStorage FeedDefinitionStorage) *HandlerAssignFeedSlugs {
+	return &HandlerAssignFeedSlugs{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+func (h *HandlerAssignFeedSlugs) Handle() error {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return errors.Wrap(err, "error listing feeds")
+	}
+
+	assigned := 0
+	for _, definition := range definitions {
+		if !definition.Slug().IsZero() {
+			continue
+		}
+
+		slug, err := uniqueSlug(h.feedDefinitionStorage, definition.Address())
+		if err != nil {
+			return errors.Wrapf(err, "error creating the slug for feed '%s'", definition.PublicKey().Hex())
+		}
+
+		if err := h.feedDefinitionStorage.SetSlug(definition.PublicKey(), slug); err != nil {
+			return errors.Wrapf(err, "error saving the slug for feed '%s'", definition.PublicKey().Hex())
+		}
+		assigned++
+	}
+
+	if assigned > 0 {
+		log.Printf("[INFO] assigned slugs to %d feeds", assigned)
+	}
+
+	return nil
+}
+
+// uniqueSlug generates a slug from the address which isn't used by any other
+// feed by appending a number to it if needed.
+func uniqueSlug(feedDefinitionStorage FeedDefinitionStorage, address domainfeed.Address) (domainfeed.Slug, error) {
+	base, err := domainfeed.NewSlugFromAddress(address)
+	if err != nil {
+		return domainfeed.Slug{}, errors.Wrap(err, "error generating the slug")
+	}
+
+	slug := base
+	for i := 2; i <= maxSlugAttempts; i++ {
+		_, err := feedDefinitionStorage.GetBySlug(slug)
+		if errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+			return slug, nil
+		}
+
+		if err != nil {
+			return domainfeed.Slug{}, errors.Wrap(err, "error checking if the slug is taken")
+		}
+
+		slug = base.WithSuffix(i)
+	}
+
+	return domainfeed.Slug{}, domainfeed.ErrSlugTaken
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	"github.com/pkg/errors"
+)
+
+type HandlerAuthenticateAPIKey struct {
+	apiKeyStorage APIKeyStorage
+}
+
+func NewHandlerAuthenticateAPIKey(apiKeyStorage APIKeyStorage) *HandlerAuthenticateAPIKey {
+	return &HandlerAuthenticateAPIKey{
+		apiKeyStorage: apiKeyStorage,
+	}
+}
+
+// Handle returns the identity of the client which the token was issued to or
+// auth.ErrInvalidAPIKey if the token doesn't belong to any key.
+func (h *HandlerAuthenticateAPIKey) Handle(token string) (auth.Identity, error) {
+	id, secret, err := auth.ParseAPIKeyToken(token)
+	if err != nil {
+		return auth.Identity{}, err
+	}
+
+	key, err := h.apiKeyStorage.Get(id)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			return auth.Identity{}, auth.ErrInvalidAPIKey
+		}
+		return auth.Identity{}, errors.Wrap(err, "error getting the API key")
+	}
+
+	if !key.Verify(secret) {
+		return auth.Identity{}, auth.ErrInvalidAPIKey
+	}
+
+	return auth.NewAPIKeyIdentity(key), nil
+}
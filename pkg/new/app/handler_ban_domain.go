@@ -0,0 +1,19 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerBanDomain struct {
+	bannedDomainStorage BannedDomainStorage
+}
+
+func NewHandlerBanDomain(bannedDomainStorage BannedDomainStorage) *HandlerBanDomain {
+	return &HandlerBanDomain{
+		bannedDomainStorage: bannedDomainStorage,
+	}
+}
+
+func (h *HandlerBanDomain) Handle(domain domainfeed.Domain, reason string) error {
+	return h.bannedDomainStorage.Ban(domain, reason)
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/signer"
+	"github.com/pkg/errors"
+)
+
+type ConvertFeed struct {
+	Address feeddomain.Address
+	// Mode defaults to the output mode which would be selected for the feed.
+	Mode feed.OutputMode
+}
+
+// ConvertedFeed holds the signed events which a feed is converted to.
+type ConvertedFeed struct {
+	FeedURL    string
+	Definition *feeddomain.FeedDefinition
+	Mode       feed.OutputMode
+	// Events starts with the metadata event followed by the relay list
+	// event, if the relay lists relays, and the items.
+	Events []nostr.Event
+}
+
+// HandlerConvertFeed converts and signs a feed with the keys which the relay
+// would derive for it without using the database, the feed doesn't have to
+// be served by the relay. Events of feeds served by the relay can differ as
+// those know their slug, owner and profile overrides.
+type HandlerConvertFeed struct {
+	enableAutoNIP05Registration bool
+	defaultProfilePictureUrl    string
+	mainDomainName              string
+	relays                      []string
+
+	keyDeriver        KeyDeriver
+	converterSelector ConverterSelector
+	signer            signer.Signer
+}
+
+func NewHandlerConvertFeed(
+	enableAutoNIP05Registration bool,
+	defaultProfilePictureUrl string,
+	mainDomainName string,
+	relays []string,
+	keyDeriver KeyDeriver,
+	converterSelector ConverterSelector,
+	signer signer.Signer,
+) *HandlerConvertFeed {
+	return &HandlerConvertFeed{
+		enableAutoNIP05Registration: enableAutoNIP05Registration,
+		defaultProfilePictureUrl:    defaultProfilePictureUrl,
+		mainDomainName:              mainDomainName,
+		relays:                      relays,
+		keyDeriver:                  keyDeriver,
+		converterSelector:           converterSelector,
+		signer:                      signer,
+	}
+}
+
+func (h *HandlerConvertFeed) Handle(ctx context.Context, cmd ConvertFeed) (ConvertedFeed, error) {
+	feedUrl := feed.GetFeedURL(cmd.Address.String())
+	if feedUrl == "" {
+		return ConvertedFeed{}, ErrNoFeedFound
+	}
+
+	parsedFeed, err := feed.ParseFeed(feedUrl)
+	if err != nil {
+		return ConvertedFeed{}, errors.Wrap(err, "error parsing feed")
+	}
+
+	domainFeedUrl, err := feeddomain.NewAddress(feedUrl)
+	if err != nil {
+		return ConvertedFeed{}, errors.Wrap(err, "error creating address from feed url")
+	}
+
+	mode := cmd.Mode
+	if mode == "" {
+		mode = h.converterSelector.Mode(parsedFeed)
+	}
+
+	converter, err := h.converterSelector.SelectMode(mode)
+	if err != nil {
+		return ConvertedFeed{}, err
+	}
+
+	keys, err := feedKeys(domainFeedUrl, h.keyDeriver)
+	if err != nil {
+		return ConvertedFeed{}, errors.Wrap(err, "error creating the feed keys")
+	}
+	publicKey := keys.PublicKey
+
+	isNitterFeed := strings.Contains(parsedFeed.Description, "Twitter feed")
+	definition, err := newFeedDefinition(keys, domainFeedUrl, isNitterFeed)
+	if err != nil {
+		return ConvertedFeed{}, errors.Wrap(err, "error creating feed definition")
+	}
+
+	converted := ConvertedFeed{
+		FeedURL:    feedUrl,
+		Definition: definition,
+		Mode:       mode,
+	}
+
+	unsigned := []nostr.Event{metadataEvent(definition, parsedFeed, feed.MetadataOptions{
+		EnableAutoRegistration:   h.enableAutoNIP05Registration,
+		DefaultProfilePictureUrl: h.defaultProfilePictureUrl,
+		MainDomainName:           h.mainDomainName,
+	})}
+	if len(h.relays) > 0 {
+		unsigned = append(unsigned, feed.EntryFeedToRelayList(publicKey.Hex(), h.relays))
+	}
+
+	for _, item := range parsedFeed.Items {
+		defaultCreatedAt := time.Unix(time.Now().Unix(), 0)
+		evt := converter.Convert(publicKey.Hex(), item, parsedFeed, defaultCreatedAt, feedUrl)
+
+		// items without a date are skipped when updating the feeds as well
+		if evt.CreatedAt == nostr.Timestamp(defaultCreatedAt.Unix()) {
+			continue
+		}
+
+		unsigned = append(unsigned, evt)
+	}
+
+	key := signingKey(definition)
+	for _, evt := range unsigned {
+		if err := h.signer.Sign(ctx, key, &evt); err != nil {
+			return ConvertedFeed{}, errors.Wrap(err, "error signing the event")
+		}
+		converted.Events = append(converted.Events, evt)
+	}
+
+	return converted, nil
+}
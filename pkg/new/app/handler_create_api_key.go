@@ -0,0 +1,39 @@
+package app
+
+import (
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+)
+
+type CreateAPIKey struct {
+	Name   string
+	Scopes auth.Scopes
+	// Quota overrides the default API quota, 0 keeps the default.
+	Quota int
+}
+
+type HandlerCreateAPIKey struct {
+	apiKeyStorage APIKeyStorage
+}
+
+func NewHandlerCreateAPIKey(apiKeyStorage APIKeyStorage) *HandlerCreateAPIKey {
+	return &HandlerCreateAPIKey{
+		apiKeyStorage: apiKeyStorage,
+	}
+}
+
+// Handle returns the key along with its token, the token is not stored and
+// can't be retrieved again.
+func (h *HandlerCreateAPIKey) Handle(cmd CreateAPIKey) (auth.APIKey, string, error) {
+	key, token, err := auth.NewAPIKey(cmd.Name, cmd.Scopes, cmd.Quota, time.Now())
+	if err != nil {
+		return auth.APIKey{}, "", err
+	}
+
+	if err := h.apiKeyStorage.Put(key); err != nil {
+		return auth.APIKey{}, "", err
+	}
+
+	return key, token, nil
+}
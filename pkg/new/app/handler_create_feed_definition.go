@@ -1,29 +1,97 @@
 package app
 
 import (
+	"log"
 	"strings"
+	"time"
 
-	"github.com/nbd-wtf/go-nostr"
 	"github.com/piraces/rsslay/pkg/feed"
-	"github.com/piraces/rsslay/pkg/new/domain"
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
 	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
-	nostrdomain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/ratelimit"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+type FeedCreationPolicy struct {
+	// MaxFeeds caps the total number of feeds, 0 means no cap.
+	MaxFeeds int
+	// RateLimit is the number of feeds a submitter can submit within the
+	// window, existing feeds count as well as every submission fetches the
+	// address.
+	RateLimit       int
+	RateLimitWindow time.Duration
+	// Moderated relays keep new feeds pending until they are approved.
+	Moderated bool
+}
+
+type CreateFeedDefinition struct {
+	Address feeddomain.Address
+	// Submitter is who submits the feed, it is recorded on the created feed
+	// unless anonymous.
+	Submitter auth.Identity
+}
+
 type HandlerCreateFeedDefinition struct {
-	secret                domain.Secret
+	policy                FeedCreationPolicy
+	limiter               *ratelimit.Limiter
+	keyDeriver            KeyDeriver
 	feedDefinitionStorage FeedDefinitionStorage
+	bannedDomainStorage   BannedDomainStorage
+	addressRuleStorage    AddressRuleStorage
 }
 
-func NewHandlerCreateFeedDefinition(secret domain.Secret, feedDefinitionStorage FeedDefinitionStorage) *HandlerCreateFeedDefinition {
-	return &HandlerCreateFeedDefinition{secret: secret, feedDefinitionStorage: feedDefinitionStorage}
+func NewHandlerCreateFeedDefinition(
+	policy FeedCreationPolicy,
+	keyDeriver KeyDeriver,
+	feedDefinitionStorage FeedDefinitionStorage,
+	bannedDomainStorage BannedDomainStorage,
+	addressRuleStorage AddressRuleStorage,
+) *HandlerCreateFeedDefinition {
+	return &HandlerCreateFeedDefinition{
+		policy:                policy,
+		limiter:               ratelimit.New(policy.RateLimit, policy.RateLimitWindow),
+		keyDeriver:            keyDeriver,
+		feedDefinitionStorage: feedDefinitionStorage,
+		bannedDomainStorage:   bannedDomainStorage,
+		addressRuleStorage:    addressRuleStorage,
+	}
 }
 
-func (h *HandlerCreateFeedDefinition) Handle(address feeddomain.Address) (*feeddomain.FeedDefinition, error) {
+// SetRateLimit changes the number of feeds a submitter can submit within the
+// window.
+func (h *HandlerCreateFeedDefinition) SetRateLimit(limit int, window time.Duration) {
+	h.limiter.SetLimit(limit, window)
+}
+
+// Handle returns the created feed, or the existing one if the feed was
+// already submitted. Callers must not disclose the keys of pending feeds.
+func (h *HandlerCreateFeedDefinition) Handle(cmd CreateFeedDefinition) (*feeddomain.FeedDefinition, error) {
+	if !cmd.Submitter.Has(auth.ScopeCreate) {
+		return nil, ErrMissingScope
+	}
+
+	// API keys are limited by their API quota instead
+	if cmd.Submitter.Kind() != auth.IdentityAPIKey && !h.limiter.Allow(cmd.Submitter.QuotaKey()) {
+		return nil, rejectCreation("rate_limited", ErrCreationRateLimited)
+	}
+
+	return h.create(cmd)
+}
+
+// create creates the feed without charging the rate limit of the submitter,
+// imports are charged once for all their feeds.
+func (h *HandlerCreateFeedDefinition) create(cmd CreateFeedDefinition) (*feeddomain.FeedDefinition, error) {
+	address := cmd.Address
+
+	if err := h.checkAddress(address); err != nil {
+		return nil, err
+	}
+
 	feedUrl := feed.GetFeedURL(address.String())
 	if feedUrl == "" {
-		return nil, errors.New("could not find a feed URL in there")
+		return nil, ErrNoFeedFound
 	}
 
 	parsedFeed, err := feed.ParseFeed(feedUrl)
@@ -31,47 +99,136 @@ func (h *HandlerCreateFeedDefinition) Handle(address feeddomain.Address) (*feedd
 		return nil, errors.Wrap(err, "error parsing feed")
 	}
 
-	privateKey := feed.PrivateKeyFromFeed(feedUrl, h.secret.String())
-	publicKey, err := nostr.GetPublicKey(privateKey)
+	isNitterFeed := strings.Contains(parsedFeed.Description, "Twitter feed") // todo this decision should occur at domain level
+
+	domainFeedUrl, err := feeddomain.NewAddress(feedUrl)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating a public key")
+		return nil, errors.Wrap(err, "error creating address from feed url")
 	}
 
-	publicKey = strings.TrimSpace(publicKey)
-	isNitterFeed := strings.Contains(parsedFeed.Description, "Twitter feed") // todo this decision should occur at domain level
+	// the page may point to a feed hosted somewhere else
+	if err := h.checkAddress(domainFeedUrl); err != nil {
+		return nil, err
+	}
 
-	// this function still calls other functions which do not use appropriate
-	// domain types therefore we need to convert the return values to domain
-	// types here
+	existing, err := getFeedByKeys(h.feedDefinitionStorage, domainFeedUrl, h.keyDeriver)
+	if err == nil {
+		return existing, nil
+	}
 
-	domainPublicKey, err := nostrdomain.NewPublicKeyFromHex(publicKey)
-	if err != nil {
-		return nil, errors.Wrap(err, "error creating address from feed url")
+	if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+		return nil, errors.Wrap(err, "error checking if the feed exists")
 	}
 
-	domainPrivateKey, err := nostrdomain.NewPrivateKeyFromHex(privateKey)
-	if err != nil {
-		return nil, errors.Wrap(err, "error creating address from feed url")
+	// the same feed may also be served from a different address
+	existing, err = findDuplicateFeed(h.feedDefinitionStorage, domainFeedUrl, parsedFeed)
+	if err == nil {
+		log.Printf("[DEBUG] feed at url %q is a duplicate of feed %s", feedUrl, existing.PublicKey().Hex())
+		return existing, nil
 	}
 
-	domainFeedUrl, err := feeddomain.NewAddress(feedUrl)
+	if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+		return nil, errors.Wrap(err, "error checking if the feed is a duplicate")
+	}
+
+	if err := h.checkFeedLimit(); err != nil {
+		return nil, err
+	}
+
+	keys, err := feedKeys(domainFeedUrl, h.keyDeriver)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating address from feed url")
+		return nil, errors.Wrap(err, "error creating the feed keys")
 	}
 
-	definition, err := feeddomain.NewFeedDefinition(
-		domainPublicKey,
-		domainPrivateKey,
-		domainFeedUrl,
-		isNitterFeed,
-	)
+	definition, err := newFeedDefinition(keys, domainFeedUrl, isNitterFeed)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating feed definition")
 	}
 
+	slug, err := uniqueSlug(h.feedDefinitionStorage, domainFeedUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the slug")
+	}
+	definition.SetSlug(slug)
+
+	if !cmd.Submitter.Anonymous() {
+		definition.SetSubmitter(cmd.Submitter.String())
+	}
+
+	if h.policy.Moderated {
+		definition.SetPending(true)
+	}
+
 	if err := h.feedDefinitionStorage.Put(definition); err != nil {
 		return nil, errors.Wrap(err, "error saving the feed definition")
 	}
 
+	// remembered right away so that duplicates submitted before the next
+	// update are detected as well
+	if err := h.feedDefinitionStorage.SetItemGUIDs(definition.PublicKey(), itemGUIDs(parsedFeed)); err != nil {
+		log.Printf("[ERROR] failure to save the feed items: %v", err)
+	}
+
 	return definition, nil
 }
+
+func (h *HandlerCreateFeedDefinition) checkAddress(address feeddomain.Address) error {
+	err := checkAddressAccepted(h.bannedDomainStorage, h.addressRuleStorage, address)
+	switch {
+	case errors.Is(err, ErrDomainBanned):
+		return rejectCreation("domain_banned", err)
+	case errors.Is(err, ErrAddressNotAccepted):
+		return rejectCreation("address_not_accepted", err)
+	default:
+		return err
+	}
+}
+
+func (h *HandlerCreateFeedDefinition) checkFeedLimit() error {
+	if h.policy.MaxFeeds <= 0 {
+		return nil
+	}
+
+	count, err := h.feedDefinitionStorage.CountTotal()
+	if err != nil {
+		return errors.Wrap(err, "error counting the feeds")
+	}
+
+	if count >= h.policy.MaxFeeds {
+		return rejectCreation("feed_limit", ErrFeedLimitReached)
+	}
+
+	return nil
+}
+
+func rejectCreation(reason string, err error) error {
+	metrics.FeedCreationRejections.With(prometheus.Labels{"reason": reason}).Inc()
+	return err
+}
+
+// checkAddressAccepted rejects the addresses of banned domains and the ones
+// which the address rules don't accept.
+func checkAddressAccepted(bannedDomainStorage BannedDomainStorage, addressRuleStorage AddressRuleStorage, address feeddomain.Address) error {
+	banned, err := bannedDomainStorage.IsBanned(address)
+	if err != nil {
+		return errors.Wrap(err, "error checking if the domain is banned")
+	}
+
+	if banned {
+		return ErrDomainBanned
+	}
+
+	rules, err := addressRuleStorage.List()
+	if err != nil {
+		return errors.Wrap(err, "error listing the address rules")
+	}
+
+	if accepted, rule := rules.Accepts(address); !accepted {
+		if !rule.Pattern.IsZero() {
+			log.Printf("[DEBUG] address %q denied by rule %q", address.String(), rule.Pattern.String())
+		}
+		return ErrAddressNotAccepted
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package app_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	rootdomain "github.com/piraces/rsslay/pkg/new/domain"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFeedDefinitionFindsExistingFeeds(t *testing.T) {
+	testCases := []struct {
+		name          string
+		existing      func(f *feedCreation) *domainfeed.FeedDefinition
+		submitted     string
+		expectSameKey bool
+	}{
+		{
+			name:          "same address",
+			existing:      func(f *feedCreation) *domainfeed.FeedDefinition { return f.create("/a") },
+			submitted:     "/a",
+			expectSameKey: true,
+		},
+		{
+			name:          "other spelling of the address",
+			existing:      func(f *feedCreation) *domainfeed.FeedDefinition { return f.create("/a") },
+			submitted:     "/a/?utm_source=newsletter",
+			expectSameKey: true,
+		},
+		{
+			name:          "keys derived from the address as it was submitted",
+			existing:      func(f *feedCreation) *domainfeed.FeedDefinition { return f.putWithKeysOf("/a/") },
+			submitted:     "/a/",
+			expectSameKey: true,
+		},
+		{
+			name:          "self link of the feed",
+			existing:      func(f *feedCreation) *domainfeed.FeedDefinition { return f.create("/a") },
+			submitted:     "/links-to-a",
+			expectSameKey: true,
+		},
+		{
+			name:          "same items",
+			existing:      func(f *feedCreation) *domainfeed.FeedDefinition { return f.create("/a") },
+			submitted:     "/copy-of-a",
+			expectSameKey: true,
+		},
+		{
+			name:      "other feed",
+			existing:  func(f *feedCreation) *domainfeed.FeedDefinition { return f.create("/a") },
+			submitted: "/b",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newFeedCreation(t, false)
+			existing := testCase.existing(f)
+
+			definition := f.create(testCase.submitted)
+			if testCase.expectSameKey {
+				assert.Equal(t, existing.PublicKey(), definition.PublicKey())
+			} else {
+				assert.NotEqual(t, existing.PublicKey(), definition.PublicKey())
+			}
+
+			count, err := f.storage.CountTotal()
+			require.NoError(t, err)
+			if testCase.expectSameKey {
+				assert.Equal(t, 1, count)
+			} else {
+				assert.Equal(t, 2, count)
+			}
+		})
+	}
+}
+
+// feedCreation creates feeds served by a test server:
+//   - /a and /copy-of-a have the same items,
+//   - /links-to-a has its own items but a self link to /a,
+//   - /b has its own items.
+type feedCreation struct {
+	t          *testing.T
+	server     *httptest.Server
+	keyDeriver app.KeyDeriver
+	storage    *adapters.FeedDefinitionStorage
+	handler    *app.HandlerCreateFeedDefinition
+}
+
+func newFeedCreation(t *testing.T, moderated bool) *feedCreation {
+	f := &feedCreation{t: t}
+
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var document string
+		switch strings.TrimRight(r.URL.Path, "/") {
+		case "/a", "/copy-of-a":
+			document = rssFeed("", "a1", "a2", "a3")
+		case "/links-to-a":
+			document = rssFeed(f.server.URL+"/a", "l1")
+		case "/b":
+			document = rssFeed("", "b1", "b2", "b3")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write([]byte(document))
+	}))
+	t.Cleanup(f.server.Close)
+
+	secret, err := rootdomain.NewSecret("some secret")
+	require.NoError(t, err)
+	secrets, err := rootdomain.NewSecrets(rootdomain.VersionedSecret{Version: 1, Secret: secret})
+	require.NoError(t, err)
+	f.keyDeriver = app.NewSecretKeyDeriver(secrets)
+
+	db := migratedDatabase(t)
+	f.storage = adapters.NewFeedDefinitionStorage(db, someKeyring(t))
+	f.handler = app.NewHandlerCreateFeedDefinition(
+		app.FeedCreationPolicy{RateLimit: 100, RateLimitWindow: time.Hour, Moderated: moderated},
+		f.keyDeriver,
+		f.storage,
+		adapters.NewBannedDomainStorage(db),
+		adapters.NewAddressRuleStorage(db),
+	)
+	return f
+}
+
+func (f *feedCreation) address(path string) domainfeed.Address {
+	address, err := domainfeed.NewAddress(f.server.URL + path)
+	require.NoError(f.t, err)
+	return address
+}
+
+func (f *feedCreation) create(path string) *domainfeed.FeedDefinition {
+	return f.createAs(path, auth.NewAnonymousIdentity("192.0.2.1"))
+}
+
+func (f *feedCreation) createAs(path string, submitter auth.Identity) *domainfeed.FeedDefinition {
+	definition, err := f.handler.Handle(app.CreateFeedDefinition{Address: f.address(path), Submitter: submitter})
+	require.NoError(f.t, err)
+	return definition
+}
+
+// putWithKeysOf stores a feed created before the addresses were
+// canonicalized, its keys are derived from the address as it was submitted.
+func (f *feedCreation) putWithKeysOf(path string) *domainfeed.FeedDefinition {
+	address := f.address(path)
+	derived, err := f.keyDeriver.Derive(address.String())
+	require.NoError(f.t, err)
+
+	definition, err := domainfeed.NewFeedDefinition(derived[0].PublicKey, derived[0].PrivateKey, address, false)
+	require.NoError(f.t, err)
+	require.NoError(f.t, f.storage.Put(definition))
+	return definition
+}
+
+func rssFeed(selfLink string, guids ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Example</title><description>An example feed</description>`)
+	if selfLink != "" {
+		fmt.Fprintf(&b, `<atom:link href="%s" rel="self" type="application/rss+xml"/>`, selfLink)
+	}
+	for _, guid := range guids {
+		fmt.Fprintf(&b, `<item><title>%[1]s</title><link>https://example.com/%[1]s</link><guid>https://example.com/%[1]s</guid></item>`, guid)
+	}
+	b.WriteString(`</channel></rss>`)
+	return b.String()
+}
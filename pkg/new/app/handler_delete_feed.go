@@ -0,0 +1,38 @@
+package app
+
+import (
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+type HandlerDeleteFeed struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+}
+
+func NewHandlerDeleteFeed(
+	feedDefinitionStorage FeedDefinitionStorage,
+	eventStorage EventStorage,
+) *HandlerDeleteFeed {
+	return &HandlerDeleteFeed{
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+	}
+}
+
+func (h *HandlerDeleteFeed) Handle(publicKey domain.PublicKey) error {
+	definition, err := h.feedDefinitionStorage.Get(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "error getting the feed definition")
+	}
+
+	if err := h.feedDefinitionStorage.Delete(publicKey); err != nil {
+		return errors.Wrap(err, "error deleting the feed definition")
+	}
+
+	if err := deleteFeedEvents(h.eventStorage, definition); err != nil {
+		return errors.Wrap(err, "error deleting the feed events")
+	}
+
+	return nil
+}
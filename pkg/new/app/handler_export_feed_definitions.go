@@ -0,0 +1,95 @@
+package app
+
+import (
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+// ExportedFeed is a feed moved between instances. The private key of the
+// feed, and the bunker URL of its owner, are only known if they were
+// exported along with it. Without its private key the feed keeps its keys
+// only if the importing instance derives the same ones.
+type ExportedFeed struct {
+	PublicKey  domain.PublicKey
+	PrivateKey *domain.PrivateKey
+	Address    feeddomain.Address
+	// OriginalAddress is the address the keys were derived from, it is zero
+	// in documents exported before it was stored.
+	OriginalAddress feeddomain.Address
+	Nitter          bool
+	Disabled        bool
+	Pending         bool
+	Slug            feeddomain.Slug
+	Submitter       string
+	Metadata        feeddomain.Metadata
+	Health          feeddomain.Health
+	Move            feeddomain.Move
+	Owner           feeddomain.Owner
+	Profile         feeddomain.Profile
+}
+
+// originalAddress is the address the keys were derived from. Documents
+// exported before it was stored only have the address the feed last moved
+// from.
+func (f ExportedFeed) originalAddress() feeddomain.Address {
+	switch {
+	case !f.OriginalAddress.IsZero():
+		return f.OriginalAddress
+	case !f.Move.IsZero():
+		return f.Move.From
+	default:
+		return f.Address
+	}
+}
+
+type HandlerExportFeedDefinitions struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerExportFeedDefinitions(feedDefinitionStorage FeedDefinitionStorage) *HandlerExportFeedDefinitions {
+	return &HandlerExportFeedDefinitions{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+// Handle returns every feed, pending and disabled ones included. Private
+// keys and bunker URLs are left out unless includeKeys is set. Without them
+// owners signing through their remote signer are left out as well, as they
+// couldn't sign anything.
+func (h *HandlerExportFeedDefinitions) Handle(includeKeys bool) ([]ExportedFeed, error) {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feed definitions")
+	}
+
+	var feeds []ExportedFeed
+	for _, definition := range definitions {
+		feed := ExportedFeed{
+			PublicKey:       definition.PublicKey(),
+			Address:         definition.Address(),
+			OriginalAddress: definition.OriginalAddress(),
+			Nitter:          definition.Nitter(),
+			Disabled:        definition.Disabled(),
+			Pending:         definition.Pending(),
+			Slug:            definition.Slug(),
+			Submitter:       definition.Submitter(),
+			Metadata:        definition.Metadata(),
+			Health:          definition.Health(),
+			Move:            definition.Move(),
+			Owner:           definition.Owner(),
+			Profile:         definition.Profile(),
+		}
+
+		if includeKeys && definition.HasPrivateKey() {
+			privateKey := definition.PrivateKey()
+			feed.PrivateKey = &privateKey
+		} else if feed.Owner.SignsRemotely() {
+			feed.Owner = feeddomain.Owner{}
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
@@ -1,17 +1,40 @@
 package app
 
 import (
+	"sort"
+
 	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
 )
 
 type HandlerGetEvents struct {
-	eventStorage EventStorage
+	eventStorage     EventStorage
+	userEventStorage UserEventStorage
 }
 
-func NewHandlerGetEvents(eventStorage EventStorage) *HandlerGetEvents {
-	return &HandlerGetEvents{eventStorage: eventStorage}
+func NewHandlerGetEvents(eventStorage EventStorage, userEventStorage UserEventStorage) *HandlerGetEvents {
+	return &HandlerGetEvents{eventStorage: eventStorage, userEventStorage: userEventStorage}
 }
 
 func (h *HandlerGetEvents) Handle(domainfilter domain.Filter) ([]domain.Event, error) {
-	return h.eventStorage.GetEvents(domainfilter)
+	events, err := h.eventStorage.GetEvents(domainfilter)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting feed events")
+	}
+
+	userEvents, err := h.userEventStorage.GetEvents(domainfilter)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting user events")
+	}
+
+	merged := append(events, userEvents...)
+
+	// each storage may return up to the limit, the newest events are kept
+	if limit := domainfilter.Libfilter().Limit; limit > 0 && len(merged) > limit {
+		sort.SliceStable(merged, func(i, j int) bool {
+			return merged[i].CreatedAt().After(merged[j].CreatedAt())
+		})
+		merged = merged[:limit]
+	}
+	return merged, nil
 }
@@ -0,0 +1,20 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+)
+
+type HandlerGetFeed struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerGetFeed(feedDefinitionStorage FeedDefinitionStorage) *HandlerGetFeed {
+	return &HandlerGetFeed{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+func (h *HandlerGetFeed) Handle(publicKey domain.PublicKey) (*domainfeed.FeedDefinition, error) {
+	return h.feedDefinitionStorage.Get(publicKey)
+}
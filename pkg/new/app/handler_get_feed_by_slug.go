@@ -0,0 +1,29 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerGetFeedBySlug struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerGetFeedBySlug(feedDefinitionStorage FeedDefinitionStorage) *HandlerGetFeedBySlug {
+	return &HandlerGetFeedBySlug{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+// Handle doesn't return disabled or pending feeds as they aren't served.
+func (h *HandlerGetFeedBySlug) Handle(slug domainfeed.Slug) (*domainfeed.FeedDefinition, error) {
+	definition, err := h.feedDefinitionStorage.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if definition.Disabled() || definition.Pending() {
+		return nil, domainfeed.ErrFeedDefinitionNotFound
+	}
+
+	return definition, nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+)
+
+// FeedItem is an event converted from an item of a feed.
+type FeedItem struct {
+	Event      domain.Event
+	Definition *domainfeed.FeedDefinition
+}
+
+type HandlerGetFeedItem struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+}
+
+func NewHandlerGetFeedItem(feedDefinitionStorage FeedDefinitionStorage, eventStorage EventStorage) *HandlerGetFeedItem {
+	return &HandlerGetFeedItem{
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+	}
+}
+
+// Handle returns the newest item matching the filter along with its feed.
+// Only the events converted from feeds which are served are returned, items
+// signed by the owner of a feed through their remote signer included.
+func (h *HandlerGetFeedItem) Handle(filter domain.Filter) (FeedItem, error) {
+	events, err := h.eventStorage.GetEvents(filter)
+	if err != nil {
+		return FeedItem{}, errors.Wrap(err, "error getting the events")
+	}
+
+	var newest *domain.Event
+	for i := range events {
+		if !slices.Contains([]int{nostr.KindTextNote, feed.KindLongFormTextContent}, events[i].Libevent().Kind) {
+			continue
+		}
+		if newest == nil || events[i].Libevent().CreatedAt > newest.Libevent().CreatedAt {
+			newest = &events[i]
+		}
+	}
+
+	if newest == nil {
+		return FeedItem{}, ErrItemNotFound
+	}
+
+	definition, err := h.feedOf(newest.PublicKey())
+	if err != nil {
+		return FeedItem{}, err
+	}
+
+	return FeedItem{Event: *newest, Definition: definition}, nil
+}
+
+func (h *HandlerGetFeedItem) feedOf(author domain.PublicKey) (*domainfeed.FeedDefinition, error) {
+	definition, err := h.feedDefinitionStorage.Get(author)
+	if err != nil && !errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+		return nil, errors.Wrap(err, "error getting the feed definition")
+	}
+
+	if errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+		definitions, err := h.feedDefinitionStorage.ListByOwner(author)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting the feeds of the owner")
+		}
+
+		definition = nil
+		for _, owned := range definitions {
+			if owned.Owner().SignsRemotely() {
+				definition = owned
+				break
+			}
+		}
+	}
+
+	if definition == nil || definition.Disabled() || definition.Pending() {
+		return nil, ErrItemNotFound
+	}
+	return definition, nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	nostrdomain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+// FeedKeys holds the public key of a feed, its private key is never
+// returned.
+type FeedKeys struct {
+	PublicKey nostrdomain.PublicKey
+	// KeyVersion is the version of the secret which the keys are derived
+	// from, 0 if they were imported.
+	KeyVersion int
+	// Definition is nil if the relay doesn't serve the feed, the public key
+	// is then the one it would get once created.
+	Definition *feeddomain.FeedDefinition
+}
+
+type HandlerGetFeedKeys struct {
+	keyDeriver            KeyDeriver
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerGetFeedKeys(keyDeriver KeyDeriver, feedDefinitionStorage FeedDefinitionStorage) *HandlerGetFeedKeys {
+	return &HandlerGetFeedKeys{
+		keyDeriver:            keyDeriver,
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+// Handle returns the keys of the feed at the address without fetching it, so
+// the address must be the one of the feed and not the one of its website.
+func (h *HandlerGetFeedKeys) Handle(address feeddomain.Address) (FeedKeys, error) {
+	// moved and imported feeds don't have the keys derived from their address
+	definition, err := h.feedDefinitionStorage.GetByAddress(address)
+	if errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+		definition, err = getFeedByKeys(h.feedDefinitionStorage, address, h.keyDeriver)
+	}
+
+	switch {
+	case err == nil:
+		return FeedKeys{PublicKey: definition.PublicKey(), KeyVersion: definition.KeyVersion(), Definition: definition}, nil
+	case !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound):
+		return FeedKeys{}, errors.Wrap(err, "error getting the feed definition")
+	}
+
+	keys, err := feedKeys(address, h.keyDeriver)
+	if err != nil {
+		return FeedKeys{}, errors.Wrap(err, "error creating the feed keys")
+	}
+	return FeedKeys{PublicKey: keys.PublicKey, KeyVersion: keys.Version}, nil
+}
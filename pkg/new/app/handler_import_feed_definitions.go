@@ -0,0 +1,261 @@
+package app
+
+import (
+	"log"
+
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+type ImportStatus string
+
+const (
+	// ImportStatusCreated feeds kept their keys.
+	ImportStatusCreated ImportStatus = "created"
+	// ImportStatusRederived feeds got new keys derived from the secret of
+	// this instance, their followers have to follow them again.
+	ImportStatusRederived ImportStatus = "rederived"
+	// ImportStatusExists feeds were already imported.
+	ImportStatusExists ImportStatus = "exists"
+	// ImportStatusConflict feeds have the same canonical address as another
+	// feed with different keys.
+	ImportStatusConflict ImportStatus = "conflict"
+	// ImportStatusRejected feeds have an address which isn't accepted.
+	ImportStatusRejected ImportStatus = "rejected"
+	ImportStatusFailed   ImportStatus = "failed"
+)
+
+type ImportedFeedDefinition struct {
+	Feed   ExportedFeed
+	Status ImportStatus
+	// Definition is the created or existing feed, nil unless the feed was
+	// created or already exists.
+	Definition *feeddomain.FeedDefinition
+	// ConflictsWith is the feed with the same address, nil unless the
+	// status is ImportStatusConflict.
+	ConflictsWith *feeddomain.FeedDefinition
+	Err           error
+}
+
+type HandlerImportFeedDefinitions struct {
+	keyDeriver            KeyDeriver
+	feedDefinitionStorage FeedDefinitionStorage
+	bannedDomainStorage   BannedDomainStorage
+	addressRuleStorage    AddressRuleStorage
+}
+
+func NewHandlerImportFeedDefinitions(
+	keyDeriver KeyDeriver,
+	feedDefinitionStorage FeedDefinitionStorage,
+	bannedDomainStorage BannedDomainStorage,
+	addressRuleStorage AddressRuleStorage,
+) *HandlerImportFeedDefinitions {
+	return &HandlerImportFeedDefinitions{
+		keyDeriver:            keyDeriver,
+		feedDefinitionStorage: feedDefinitionStorage,
+		bannedDomainStorage:   bannedDomainStorage,
+		addressRuleStorage:    addressRuleStorage,
+	}
+}
+
+// Handle creates the exported feeds and returns the outcome of each of them
+// in the same order. Importing the same feeds again changes nothing. Feeds
+// keep their keys if their private key was exported or if this instance
+// derives the same keys, otherwise new keys are derived from the newest
+// secret.
+func (h *HandlerImportFeedDefinitions) Handle(feeds []ExportedFeed) ([]ImportedFeedDefinition, error) {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feed definitions")
+	}
+
+	byPublicKey := make(map[string]*feeddomain.FeedDefinition)
+	byAddress := make(map[feeddomain.Address]*feeddomain.FeedDefinition)
+	for _, definition := range definitions {
+		byPublicKey[definition.PublicKey().Hex()] = definition
+		byAddress[definition.Address().Canonical()] = definition
+	}
+
+	var results []ImportedFeedDefinition
+	for _, feed := range feeds {
+		result := h.importFeed(feed, byPublicKey, byAddress)
+		if result.Err != nil {
+			log.Printf("[DEBUG] failure to import feed at url %q: %v", feed.Address.String(), result.Err)
+		}
+		if result.Status == ImportStatusCreated || result.Status == ImportStatusRederived {
+			byPublicKey[result.Definition.PublicKey().Hex()] = result.Definition
+			byAddress[result.Definition.Address().Canonical()] = result.Definition
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (h *HandlerImportFeedDefinitions) importFeed(
+	feed ExportedFeed,
+	byPublicKey map[string]*feeddomain.FeedDefinition,
+	byAddress map[feeddomain.Address]*feeddomain.FeedDefinition,
+) ImportedFeedDefinition {
+	result := ImportedFeedDefinition{Feed: feed}
+
+	if existing, ok := byPublicKey[feed.PublicKey.Hex()]; ok {
+		result.Status, result.Definition = ImportStatusExists, existing
+		return result
+	}
+
+	definition, err := h.newDefinition(feed)
+	if err != nil {
+		result.Status, result.Err = ImportStatusFailed, err
+		return result
+	}
+
+	result.Status = ImportStatusCreated
+	if !definition.PublicKey().Equal(feed.PublicKey) {
+		result.Status = ImportStatusRederived
+		// the keys are derived from the current address and nothing the
+		// owner signed for the previous keys is valid for the new ones
+		definition.SetMove(feeddomain.Move{})
+		definition.SetOriginalAddress(definition.Address())
+		definition.SetOwner(feeddomain.Owner{})
+
+		// the feed got the same keys when it was imported before
+		if existing, ok := byPublicKey[definition.PublicKey().Hex()]; ok {
+			result.Status, result.Definition = ImportStatusExists, existing
+			return result
+		}
+	}
+
+	if existing, ok := byAddress[feed.Address.Canonical()]; ok {
+		result.Status, result.ConflictsWith = ImportStatusConflict, existing
+		return result
+	}
+
+	if err := checkAddressAccepted(h.bannedDomainStorage, h.addressRuleStorage, feed.Address); err != nil {
+		result.Status, result.Err = ImportStatusRejected, err
+		if !errors.Is(err, ErrDomainBanned) && !errors.Is(err, ErrAddressNotAccepted) {
+			result.Status = ImportStatusFailed
+		}
+		return result
+	}
+
+	if err := h.save(definition); err != nil {
+		result.Status, result.Err = ImportStatusFailed, err
+		return result
+	}
+
+	result.Definition = definition
+	return result
+}
+
+func (h *HandlerImportFeedDefinitions) newDefinition(feed ExportedFeed) (*feeddomain.FeedDefinition, error) {
+	keys, err := h.keys(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, err := newFeedDefinition(keys, feed.Address, feed.Nitter)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating feed definition")
+	}
+	definition.SetDisabled(feed.Disabled)
+	definition.SetPending(feed.Pending)
+	definition.SetSubmitter(feed.Submitter)
+	definition.SetMetadata(feed.Metadata)
+	definition.SetHealth(feed.Health)
+	definition.SetMove(feed.Move)
+	definition.SetOriginalAddress(feed.originalAddress())
+	definition.SetOwner(feed.Owner)
+	definition.SetProfile(feed.Profile)
+
+	slug := feed.Slug
+	if !slug.IsZero() {
+		if _, err := h.feedDefinitionStorage.GetBySlug(slug); err == nil {
+			slug = feeddomain.Slug{}
+		} else if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+			return nil, errors.Wrap(err, "error checking if the slug is taken")
+		}
+	}
+
+	if slug.IsZero() {
+		slug, err = uniqueSlug(h.feedDefinitionStorage, feed.Address)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the slug")
+		}
+	}
+	definition.SetSlug(slug)
+
+	return definition, nil
+}
+
+// keys returns the keys of the feed along with the version of the secret
+// they were derived from. Feeds whose keys can't be derived with any version
+// of the secret get new keys unless their private key was exported, which is
+// then stored as version 0.
+func (h *HandlerImportFeedDefinitions) keys(feed ExportedFeed) (DerivedKeys, error) {
+	// the keys of moved feeds were derived from their original address
+	address := feed.originalAddress()
+
+	derived, err := h.keyDeriver.Derive(address.Canonical().String(), address.String())
+	if err != nil {
+		return DerivedKeys{}, errors.Wrap(err, "error deriving the feed keys")
+	}
+	for _, keys := range derived {
+		if keys.PublicKey.Equal(feed.PublicKey) {
+			return keys, nil
+		}
+	}
+
+	if feed.PrivateKey != nil {
+		if !feed.PublicKey.Matches(*feed.PrivateKey) {
+			return DerivedKeys{}, errors.New("the private key doesn't match the public key")
+		}
+		return DerivedKeys{Address: feed.Address.String(), PublicKey: feed.PublicKey, PrivateKey: *feed.PrivateKey}, nil
+	}
+
+	keys, err := feedKeys(feed.Address, h.keyDeriver)
+	if err != nil {
+		return DerivedKeys{}, errors.Wrap(err, "error creating the feed keys")
+	}
+	return keys, nil
+}
+
+// save stores the feed along with everything which isn't stored on creation.
+func (h *HandlerImportFeedDefinitions) save(definition *feeddomain.FeedDefinition) error {
+	if err := h.feedDefinitionStorage.Put(definition); err != nil {
+		return errors.Wrap(err, "error saving the feed definition")
+	}
+
+	publicKey := definition.PublicKey()
+	if err := h.feedDefinitionStorage.SetDisabled(publicKey, definition.Disabled()); err != nil {
+		return errors.Wrap(err, "error saving the disabled state")
+	}
+
+	if err := h.feedDefinitionStorage.SetMetadata(publicKey, definition.Metadata()); err != nil {
+		return errors.Wrap(err, "error saving the metadata")
+	}
+
+	if err := h.feedDefinitionStorage.SetHealth(publicKey, definition.Health()); err != nil {
+		return errors.Wrap(err, "error saving the health")
+	}
+
+	if move := definition.Move(); !move.IsZero() {
+		if err := h.feedDefinitionStorage.SetAddress(publicKey, definition.Address(), move); err != nil {
+			return errors.Wrap(err, "error saving the move")
+		}
+	}
+
+	if owner := definition.Owner(); !owner.IsZero() {
+		if err := h.feedDefinitionStorage.SetOwner(publicKey, owner); err != nil {
+			return errors.Wrap(err, "error saving the owner")
+		}
+	}
+
+	if profile := definition.Profile(); !profile.IsZero() {
+		if err := h.feedDefinitionStorage.SetProfile(publicKey, profile); err != nil {
+			return errors.Wrap(err, "error saving the profile")
+		}
+	}
+
+	return nil
+}
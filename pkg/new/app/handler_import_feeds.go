@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/ratelimit"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// MaxImportedFeeds is the number of subscriptions which can be imported
+	// at once.
+	MaxImportedFeeds = 500
+
+	numImportWorkers = 4
+)
+
+// ImportPolicy limits the imports of a submitter. An import counts once,
+// whatever the number of its subscriptions, instead of every subscription
+// counting towards the creation limit.
+type ImportPolicy struct {
+	RateLimit       int
+	RateLimitWindow time.Duration
+}
+
+type ImportFeeds struct {
+	Subscriptions []feeddomain.OPMLOutline
+	// Submitter is who imports the feeds, the created feeds are recorded as
+	// submitted by them.
+	Submitter auth.Identity
+}
+
+type ImportedFeed struct {
+	Subscription feeddomain.OPMLOutline
+	// Definition is the created or existing feed, nil if Err is set.
+	Definition *feeddomain.FeedDefinition
+	Err        error
+}
+
+type HandlerImportFeeds struct {
+	limiter              *ratelimit.Limiter
+	createFeedDefinition *HandlerCreateFeedDefinition
+}
+
+func NewHandlerImportFeeds(policy ImportPolicy, createFeedDefinition *HandlerCreateFeedDefinition) *HandlerImportFeeds {
+	return &HandlerImportFeeds{
+		limiter:              ratelimit.New(policy.RateLimit, policy.RateLimitWindow),
+		createFeedDefinition: createFeedDefinition,
+	}
+}
+
+// SetRateLimit changes the number of imports a submitter can make within the
+// window.
+func (h *HandlerImportFeeds) SetRateLimit(limit int, window time.Duration) {
+	h.limiter.SetLimit(limit, window)
+}
+
+// Handle submits every subscription with a few of them in parallel and
+// returns the outcome of each of them in the same order. Failing
+// subscriptions don't stop the import, their error is returned with them.
+func (h *HandlerImportFeeds) Handle(ctx context.Context, cmd ImportFeeds) ([]ImportedFeed, error) {
+	if len(cmd.Subscriptions) == 0 {
+		return nil, errors.New("there are no feeds to import")
+	}
+
+	if len(cmd.Subscriptions) > MaxImportedFeeds {
+		return nil, errors.New("at most " + strconv.Itoa(MaxImportedFeeds) + " feeds can be imported at once")
+	}
+
+	if !cmd.Submitter.Has(auth.ScopeCreate) {
+		return nil, ErrMissingScope
+	}
+
+	// API keys are limited by their API quota instead
+	if cmd.Submitter.Kind() != auth.IdentityAPIKey && !h.limiter.Allow(cmd.Submitter.QuotaKey()) {
+		return nil, rejectCreation("import_rate_limited", ErrImportRateLimited)
+	}
+
+	results := make([]ImportedFeed, len(cmd.Subscriptions))
+	chIn := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numImportWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range chIn {
+				results[index] = h.importFeed(ctx, cmd.Subscriptions[index], cmd.Submitter)
+			}
+		}()
+	}
+
+	for index := range cmd.Subscriptions {
+		chIn <- index
+	}
+	close(chIn)
+	wg.Wait()
+
+	return results, nil
+}
+
+func (h *HandlerImportFeeds) importFeed(ctx context.Context, subscription feeddomain.OPMLOutline, submitter auth.Identity) ImportedFeed {
+	result := ImportedFeed{Subscription: subscription}
+	defer func() {
+		outcome := "imported"
+		if result.Err != nil {
+			outcome = "failed"
+		}
+		metrics.ImportedFeeds.With(prometheus.Labels{"outcome": outcome}).Inc()
+	}()
+
+	if err := ctx.Err(); err != nil {
+		result.Err = errors.Wrap(err, "the import was interrupted")
+		return result
+	}
+
+	address, err := feeddomain.NewAddress(subscription.XMLURL)
+	if err != nil {
+		result.Err = fmt.Errorf("%w: %s", ErrInvalidAddress, err)
+		return result
+	}
+
+	result.Definition, result.Err = h.createFeedDefinition.create(CreateFeedDefinition{
+		Address:   address,
+		Submitter: submitter,
+	})
+	return result
+}
@@ -0,0 +1,44 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/app"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFeedsChargesTheImportQuotaOnce(t *testing.T) {
+	f := newFeedCreation(t, false)
+	f.handler.SetRateLimit(1, time.Hour)
+	handler := app.NewHandlerImportFeeds(app.ImportPolicy{RateLimit: 1, RateLimitWindow: time.Hour}, f.handler)
+
+	cmd := app.ImportFeeds{
+		Subscriptions: []domainfeed.OPMLOutline{
+			{XMLURL: f.server.URL + "/a"},
+			{XMLURL: f.server.URL + "/b"},
+		},
+		Submitter: auth.NewAnonymousIdentity("192.0.2.1"),
+	}
+
+	results, err := handler.Handle(context.Background(), cmd)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.NoError(t, result.Err, result.Subscription.XMLURL)
+	}
+	assert.NotEqual(t, results[0].Definition.PublicKey(), results[1].Definition.PublicKey())
+
+	_, err = handler.Handle(context.Background(), cmd)
+	require.ErrorIs(t, err, app.ErrImportRateLimited)
+
+	// the feeds of the import don't count towards the creation limit
+	_, err = f.handler.Handle(app.CreateFeedDefinition{Address: f.address("/links-to-a"), Submitter: cmd.Submitter})
+	require.NoError(t, err)
+	_, err = f.handler.Handle(app.CreateFeedDefinition{Address: f.address("/b"), Submitter: cmd.Submitter})
+	require.ErrorIs(t, err, app.ErrCreationRateLimited)
+}
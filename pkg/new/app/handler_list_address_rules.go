@@ -0,0 +1,19 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerListAddressRules struct {
+	addressRuleStorage AddressRuleStorage
+}
+
+func NewHandlerListAddressRules(addressRuleStorage AddressRuleStorage) *HandlerListAddressRules {
+	return &HandlerListAddressRules{
+		addressRuleStorage: addressRuleStorage,
+	}
+}
+
+func (h *HandlerListAddressRules) Handle() (domainfeed.AddressRules, error) {
+	return h.addressRuleStorage.List()
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+)
+
+type HandlerListAPIKeys struct {
+	apiKeyStorage APIKeyStorage
+}
+
+func NewHandlerListAPIKeys(apiKeyStorage APIKeyStorage) *HandlerListAPIKeys {
+	return &HandlerListAPIKeys{
+		apiKeyStorage: apiKeyStorage,
+	}
+}
+
+func (h *HandlerListAPIKeys) Handle() ([]auth.APIKey, error) {
+	return h.apiKeyStorage.List()
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerListBannedDomains struct {
+	bannedDomainStorage BannedDomainStorage
+}
+
+func NewHandlerListBannedDomains(bannedDomainStorage BannedDomainStorage) *HandlerListBannedDomains {
+	return &HandlerListBannedDomains{
+		bannedDomainStorage: bannedDomainStorage,
+	}
+}
+
+func (h *HandlerListBannedDomains) Handle() ([]domainfeed.BannedDomain, error) {
+	return h.bannedDomainStorage.List()
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"sort"
+
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+const (
+	DuplicateReasonAddress = "address"
+	DuplicateReasonItems   = "items"
+)
+
+// DuplicateFeeds are feeds which most likely serve the same content.
+type DuplicateFeeds struct {
+	Reason string
+	Feeds  []*feeddomain.FeedDefinition
+}
+
+type HandlerListDuplicateFeeds struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerListDuplicateFeeds(feedDefinitionStorage FeedDefinitionStorage) *HandlerListDuplicateFeeds {
+	return &HandlerListDuplicateFeeds{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+// Handle returns the groups of feeds with the same canonical address followed
+// by the pairs of feeds which have most of their items in common.
+func (h *HandlerListDuplicateFeeds) Handle() ([]DuplicateFeeds, error) {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feed definitions")
+	}
+
+	byPublicKey := make(map[string]*feeddomain.FeedDefinition)
+	byAddress := make(map[feeddomain.Address][]*feeddomain.FeedDefinition)
+	for _, definition := range definitions {
+		byPublicKey[definition.PublicKey().Hex()] = definition
+		canonical := definition.Address().Canonical()
+		byAddress[canonical] = append(byAddress[canonical], definition)
+	}
+
+	var result []DuplicateFeeds
+	for _, feeds := range byAddress {
+		if len(feeds) > 1 {
+			result = append(result, DuplicateFeeds{Reason: DuplicateReasonAddress, Feeds: feeds})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Feeds[0].Address().Canonical().String() < result[j].Feeds[0].Address().Canonical().String()
+	})
+
+	sharedItems, err := h.feedDefinitionStorage.ListSharedItems()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the shared items")
+	}
+
+	for _, shared := range sharedItems {
+		a, b := byPublicKey[shared.A.Hex()], byPublicKey[shared.B.Hex()]
+		if !shared.Duplicate() || a == nil || b == nil {
+			continue
+		}
+
+		// already reported
+		if a.Address().Canonical() == b.Address().Canonical() {
+			continue
+		}
+
+		result = append(result, DuplicateFeeds{Reason: DuplicateReasonItems, Feeds: []*feeddomain.FeedDefinition{a, b}})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"sort"
+
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+type HandlerListFailingFeeds struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerListFailingFeeds(feedDefinitionStorage FeedDefinitionStorage) *HandlerListFailingFeeds {
+	return &HandlerListFailingFeeds{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+// Handle returns the feeds which aren't healthy, the ones failing for the
+// longest time first.
+func (h *HandlerListFailingFeeds) Handle() ([]*feeddomain.FeedDefinition, error) {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feed definitions")
+	}
+
+	var result []*feeddomain.FeedDefinition
+	for _, definition := range definitions {
+		if !definition.Health().Healthy() {
+			result = append(result, definition)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Health().FailingSince.Before(result[j].Health().FailingSince)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerListFeeds struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerListFeeds(feedDefinitionStorage FeedDefinitionStorage) *HandlerListFeeds {
+	return &HandlerListFeeds{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+func (h *HandlerListFeeds) Handle() ([]*domainfeed.FeedDefinition, error) {
+	return h.feedDefinitionStorage.List()
+}
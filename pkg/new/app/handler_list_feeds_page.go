@@ -0,0 +1,30 @@
+package app
+
+import (
+	"fmt"
+
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+const MaxFeedsPageSize = 100
+
+type HandlerListFeedsPage struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerListFeedsPage(feedDefinitionStorage FeedDefinitionStorage) *HandlerListFeedsPage {
+	return &HandlerListFeedsPage{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+func (h *HandlerListFeedsPage) Handle(filter domainfeed.ListFilter) (domainfeed.Page, error) {
+	if filter.Limit <= 0 || filter.Limit > MaxFeedsPageSize {
+		return domainfeed.Page{}, fmt.Errorf("limit must be between 1 and %d", MaxFeedsPageSize)
+	}
+	if filter.Offset < 0 {
+		return domainfeed.Page{}, errors.New("offset can't be negative")
+	}
+	return h.feedDefinitionStorage.ListPage(filter)
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultFeedPreviewItems = 5
+	MaxFeedPreviewItems     = 20
+)
+
+type PreviewFeed struct {
+	Address feeddomain.Address
+	// Mode defaults to the output mode which would be selected for the feed.
+	Mode  feed.OutputMode
+	Items int
+}
+
+// FeedPreview holds the unsigned events which a feed would be converted to.
+type FeedPreview struct {
+	FeedURL string
+	// Existing is true if the feed is already served by the relay.
+	Existing bool
+	// Pending is true if the feed awaits approval or would once created, the
+	// events have no author then as the keys of the feed must not be
+	// disclosed before it is approved.
+	Pending    bool
+	Definition *feeddomain.FeedDefinition
+	Mode       feed.OutputMode
+	Metadata   nostr.Event
+	Items      []nostr.Event
+	TotalItems int
+}
+
+// HandlerPreviewFeed converts a feed the same way that the feeds served by
+// the relay are converted without saving anything.
+type HandlerPreviewFeed struct {
+	enableAutoNIP05Registration bool
+	defaultProfilePictureUrl    string
+	mainDomainName              string
+	moderated                   bool
+
+	keyDeriver            KeyDeriver
+	feedDefinitionStorage FeedDefinitionStorage
+	bannedDomainStorage   BannedDomainStorage
+	addressRuleStorage    AddressRuleStorage
+	converterSelector     ConverterSelector
+}
+
+func NewHandlerPreviewFeed(
+	enableAutoNIP05Registration bool,
+	defaultProfilePictureUrl string,
+	mainDomainName string,
+	moderated bool,
+	keyDeriver KeyDeriver,
+	feedDefinitionStorage FeedDefinitionStorage,
+	bannedDomainStorage BannedDomainStorage,
+	addressRuleStorage AddressRuleStorage,
+	converterSelector ConverterSelector,
+) *HandlerPreviewFeed {
+	return &HandlerPreviewFeed{
+		enableAutoNIP05Registration: enableAutoNIP05Registration,
+		defaultProfilePictureUrl:    defaultProfilePictureUrl,
+		mainDomainName:              mainDomainName,
+		moderated:                   moderated,
+		keyDeriver:                  keyDeriver,
+		feedDefinitionStorage:       feedDefinitionStorage,
+		bannedDomainStorage:         bannedDomainStorage,
+		addressRuleStorage:          addressRuleStorage,
+		converterSelector:           converterSelector,
+	}
+}
+
+func (h *HandlerPreviewFeed) Handle(query PreviewFeed) (FeedPreview, error) {
+	if query.Items <= 0 || query.Items > MaxFeedPreviewItems {
+		return FeedPreview{}, fmt.Errorf("items must be between 1 and %d", MaxFeedPreviewItems)
+	}
+
+	if err := checkAddressAccepted(h.bannedDomainStorage, h.addressRuleStorage, query.Address); err != nil {
+		return FeedPreview{}, err
+	}
+
+	feedUrl := feed.GetFeedURL(query.Address.String())
+	if feedUrl == "" {
+		return FeedPreview{}, ErrNoFeedFound
+	}
+
+	parsedFeed, err := feed.ParseFeed(feedUrl)
+	if err != nil {
+		return FeedPreview{}, errors.Wrap(err, "error parsing feed")
+	}
+
+	domainFeedUrl, err := feeddomain.NewAddress(feedUrl)
+	if err != nil {
+		return FeedPreview{}, errors.Wrap(err, "error creating address from feed url")
+	}
+
+	if err := checkAddressAccepted(h.bannedDomainStorage, h.addressRuleStorage, domainFeedUrl); err != nil {
+		return FeedPreview{}, err
+	}
+
+	mode := query.Mode
+	if mode == "" {
+		mode = h.converterSelector.Mode(parsedFeed)
+	}
+
+	converter, err := h.converterSelector.SelectMode(mode)
+	if err != nil {
+		return FeedPreview{}, err
+	}
+
+	definition, existing, err := h.definition(domainFeedUrl, parsedFeed.Description)
+	if err != nil {
+		return FeedPreview{}, err
+	}
+
+	preview := FeedPreview{
+		FeedURL:    feedUrl,
+		Existing:   existing,
+		Pending:    definition.Pending() || (!existing && h.moderated),
+		Definition: definition,
+		Mode:       mode,
+		Metadata: unsignedEvent(metadataEvent(definition, parsedFeed, feed.MetadataOptions{
+			EnableAutoRegistration:   h.enableAutoNIP05Registration,
+			DefaultProfilePictureUrl: h.defaultProfilePictureUrl,
+			MainDomainName:           h.mainDomainName,
+		})),
+		TotalItems: len(parsedFeed.Items),
+	}
+
+	author := definition.PublicKey()
+	if owner := definition.Owner(); owner.SignsRemotely() {
+		author = owner.PublicKey
+	}
+
+	for _, item := range parsedFeed.Items {
+		if len(preview.Items) == query.Items {
+			break
+		}
+
+		defaultCreatedAt := time.Unix(time.Now().Unix(), 0)
+		evt := converter.Convert(author.Hex(), item, parsedFeed, defaultCreatedAt, feedUrl)
+
+		// items without a date are skipped when updating the feeds as well
+		if evt.CreatedAt == nostr.Timestamp(defaultCreatedAt.Unix()) {
+			continue
+		}
+
+		preview.Items = append(preview.Items, unsignedEvent(evt))
+	}
+
+	if preview.Pending {
+		preview.Metadata = anonymousEvent(preview.Metadata)
+		for i := range preview.Items {
+			preview.Items[i] = anonymousEvent(preview.Items[i])
+		}
+	}
+
+	return preview, nil
+}
+
+// definition returns the definition of the feed if the relay already serves
+// it or a definition which is never saved otherwise.
+func (h *HandlerPreviewFeed) definition(address feeddomain.Address, description string) (*feeddomain.FeedDefinition, bool, error) {
+	existing, err := getFeedByKeys(h.feedDefinitionStorage, address, h.keyDeriver)
+	if err == nil {
+		return existing, true, nil
+	}
+
+	if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+		return nil, false, errors.Wrap(err, "error checking if the feed exists")
+	}
+
+	keys, err := feedKeys(address, h.keyDeriver)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error creating the feed keys")
+	}
+
+	isNitterFeed := strings.Contains(description, "Twitter feed")
+	definition, err := newFeedDefinition(keys, address, isNitterFeed)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error creating feed definition")
+	}
+
+	return definition, false, nil
+}
+
+// unsignedEvent sets the id which the event would have once signed by the
+// author.
+func unsignedEvent(evt nostr.Event) nostr.Event {
+	evt.ID = evt.GetID()
+	evt.Sig = ""
+	return evt
+}
+
+// anonymousEvent removes the author of the event along with its id which is
+// derived from it.
+func anonymousEvent(evt nostr.Event) nostr.Event {
+	evt.PubKey = ""
+	evt.ID = ""
+	return evt
+}
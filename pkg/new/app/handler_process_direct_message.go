@@ -0,0 +1,457 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/helpers"
+	"github.com/piraces/rsslay/pkg/new/domain"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	nostrdomain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip59"
+	"github.com/piraces/rsslay/pkg/ratelimit"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// botKeyLabel is used in place of a feed URL when deriving the key of
+	// the bot so that it can never match the key of a feed.
+	botKeyLabel = "rsslay-bot"
+
+	botSearchLimit = 10
+
+	// kindDirectMessageRelays is the NIP-17 list of relays where the bot
+	// expects to receive direct messages.
+	kindDirectMessageRelays = 10050
+
+	botHelpMessage = `Send me the URL of a website or of a RSS/Atom feed and I'll create a nostr profile for it.
+
+Commands:
+- help: shows this message
+- search <text>: finds existing feeds
+- status <npub or URL>: shows the status of a feed`
+)
+
+// DirectMessageKinds lists the kinds of events which the bot answers to.
+var DirectMessageKinds = []int{nostr.KindEncryptedDirectMessage, nip59.KindGiftWrap}
+
+// HandlerProcessDirectMessage implements a bot which lets users create and
+// look up feeds by sending it NIP-17 or NIP-04 direct messages. Replies are
+// sent using the same protocol as the received message.
+type HandlerProcessDirectMessage struct {
+	keyDeriver            KeyDeriver
+	privateKey            string
+	publicKey             nostrdomain.PublicKey
+	mainDomainName        string
+	limiter               *ratelimit.Limiter
+	createFeedDefinition  *HandlerCreateFeedDefinition
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+	userEventStorage      UserEventStorage
+	updatesCh             chan<- nostr.Event
+}
+
+// BotPrivateKey derives the key of the bot from the secret. The bot must be
+// given the key derived from the same secret on every start, otherwise its
+// npub changes and the conversations with it are lost.
+func BotPrivateKey(secret domain.Secret) string {
+	return feed.PrivateKeyFromFeed(botKeyLabel, secret.String())
+}
+
+func NewHandlerProcessDirectMessage(
+	keyDeriver KeyDeriver,
+	privateKey string,
+	mainDomainName string,
+	rateLimit int,
+	rateLimitWindow time.Duration,
+	createFeedDefinition *HandlerCreateFeedDefinition,
+	feedDefinitionStorage FeedDefinitionStorage,
+	eventStorage EventStorage,
+	userEventStorage UserEventStorage,
+	updatesCh chan<- nostr.Event,
+) (*HandlerProcessDirectMessage, error) {
+	hexPublicKey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the bot public key")
+	}
+
+	publicKey, err := nostrdomain.NewPublicKeyFromHex(hexPublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the bot public key")
+	}
+
+	return &HandlerProcessDirectMessage{
+		keyDeriver:            keyDeriver,
+		privateKey:            privateKey,
+		publicKey:             publicKey,
+		mainDomainName:        mainDomainName,
+		limiter:               ratelimit.New(rateLimit, rateLimitWindow),
+		createFeedDefinition:  createFeedDefinition,
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+		userEventStorage:      userEventStorage,
+		updatesCh:             updatesCh,
+	}, nil
+}
+
+func (h *HandlerProcessDirectMessage) PublicKey() nostrdomain.PublicKey {
+	return h.publicKey
+}
+
+// SetRateLimit changes the number of messages a sender can send to the bot
+// within the window.
+func (h *HandlerProcessDirectMessage) SetRateLimit(limit int, window time.Duration) {
+	h.limiter.SetLimit(limit, window)
+}
+
+// IsAddressedToBot returns true if the event is a direct message which the
+// bot should answer to.
+func (h *HandlerProcessDirectMessage) IsAddressedToBot(event *nostr.Event) bool {
+	if !slices.Contains(DirectMessageKinds, event.Kind) {
+		return false
+	}
+
+	for _, tag := range event.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && tag[1] == h.publicKey.Hex() {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishProfile makes the bot profile and the relay it reads direct
+// messages from available to the clients.
+func (h *HandlerProcessDirectMessage) PublishProfile(name string) error {
+	metadata, err := json.Marshal(map[string]any{
+		"name":  name + " bot",
+		"about": "Send me the URL of a website or of a RSS/Atom feed and I'll create a nostr profile for it. Send 'help' to see all commands.",
+		"bot":   true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the bot metadata")
+	}
+
+	event, err := h.signedEvent(nostr.Event{
+		Kind:      nostr.KindSetMetadata,
+		Content:   string(metadata),
+		Tags:      nostr.Tags{},
+		CreatedAt: nostr.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating the bot metadata event")
+	}
+
+	events := []nostrdomain.Event{event}
+
+	if h.mainDomainName != "" {
+		relays, err := h.signedEvent(nostr.Event{
+			Kind:      kindDirectMessageRelays,
+			Content:   "",
+			Tags:      nostr.Tags{{"relay", "wss://" + h.mainDomainName}},
+			CreatedAt: nostr.Now(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "error creating the bot relay list event")
+		}
+		events = append(events, relays)
+	}
+
+	return h.eventStorage.PutEvents(h.publicKey, events)
+}
+
+func (h *HandlerProcessDirectMessage) Handle(event nostrdomain.Event) error {
+	libevent := event.Libevent()
+	if !h.IsAddressedToBot(&libevent) {
+		return errors.New("event isn't a direct message sent to the bot")
+	}
+
+	sender, message, err := h.open(libevent)
+	if err != nil {
+		return errors.Wrap(err, "error decrypting the message")
+	}
+
+	if !h.limiter.Allow(sender) {
+		return errors.New("rate-limited: slow down")
+	}
+
+	reply, err := h.seal(libevent.Kind, sender, h.respond(sender, message))
+	if err != nil {
+		return errors.Wrap(err, "error creating the reply")
+	}
+
+	if err := h.userEventStorage.SaveEvent(reply); err != nil {
+		return errors.Wrap(err, "error saving the reply")
+	}
+
+	h.updatesCh <- reply.Libevent()
+	return nil
+}
+
+// FeedApproved sends the profile of an approved feed to its submitter.
+func (h *HandlerProcessDirectMessage) FeedApproved(submitter nostrdomain.PublicKey, definition *feeddomain.FeedDefinition) error {
+	return h.notify(submitter, fmt.Sprintf(
+		"Your feed %s was approved, follow it to get its updates:\n\nnostr:%s",
+		definition.Address().String(),
+		h.profile(definition),
+	))
+}
+
+// FeedRejected tells the submitter of a feed that it was rejected.
+func (h *HandlerProcessDirectMessage) FeedRejected(submitter nostrdomain.PublicKey, address feeddomain.Address, reason string) error {
+	message := fmt.Sprintf("Sorry, your feed %s was rejected by the moderators.", address.String())
+	if reason != "" {
+		message += "\n\nReason: " + reason
+	}
+	return h.notify(submitter, message)
+}
+
+// notify sends a message which isn't a reply, NIP-17 is used as the protocol
+// the recipient prefers is unknown.
+func (h *HandlerProcessDirectMessage) notify(recipient nostrdomain.PublicKey, message string) error {
+	event, err := h.seal(nip59.KindGiftWrap, recipient.Hex(), message)
+	if err != nil {
+		return errors.Wrap(err, "error creating the message")
+	}
+
+	if err := h.userEventStorage.SaveEvent(event); err != nil {
+		return errors.Wrap(err, "error saving the message")
+	}
+
+	h.updatesCh <- event.Libevent()
+	return nil
+}
+
+func (h *HandlerProcessDirectMessage) open(event nostr.Event) (string, string, error) {
+	if event.Kind == nip59.KindGiftWrap {
+		rumor, err := nip59.Unwrap(event, h.privateKey)
+		if err != nil {
+			return "", "", err
+		}
+
+		if rumor.Kind != nip59.KindPrivateDirectMessage {
+			return "", "", fmt.Errorf("kind %d is not a direct message", rumor.Kind)
+		}
+
+		return rumor.PubKey, rumor.Content, nil
+	}
+
+	sharedSecret, err := nip04.ComputeSharedSecret(event.PubKey, h.privateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	message, err := nip04.Decrypt(event.Content, sharedSecret)
+	if err != nil {
+		return "", "", err
+	}
+
+	return event.PubKey, message, nil
+}
+
+func (h *HandlerProcessDirectMessage) seal(kind int, recipient string, message string) (nostrdomain.Event, error) {
+	if kind == nip59.KindGiftWrap {
+		rumor := nostr.Event{
+			Kind:      nip59.KindPrivateDirectMessage,
+			Content:   message,
+			Tags:      nostr.Tags{{"p", recipient}},
+			CreatedAt: nostr.Now(),
+		}
+
+		wrap, err := nip59.Wrap(rumor, h.privateKey, recipient)
+		if err != nil {
+			return nostrdomain.Event{}, err
+		}
+
+		return nostrdomain.NewEvent(wrap)
+	}
+
+	sharedSecret, err := nip04.ComputeSharedSecret(recipient, h.privateKey)
+	if err != nil {
+		return nostrdomain.Event{}, err
+	}
+
+	content, err := nip04.Encrypt(message, sharedSecret)
+	if err != nil {
+		return nostrdomain.Event{}, err
+	}
+
+	return h.signedEvent(nostr.Event{
+		Kind:      nostr.KindEncryptedDirectMessage,
+		Content:   content,
+		Tags:      nostr.Tags{{"p", recipient}},
+		CreatedAt: nostr.Now(),
+	})
+}
+
+func (h *HandlerProcessDirectMessage) signedEvent(event nostr.Event) (nostrdomain.Event, error) {
+	if err := event.Sign(h.privateKey); err != nil {
+		return nostrdomain.Event{}, errors.Wrap(err, "error signing the event")
+	}
+	return nostrdomain.NewEvent(event)
+}
+
+func (h *HandlerProcessDirectMessage) respond(sender string, message string) string {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return botHelpMessage
+	}
+
+	argument := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(message), fields[0]))
+
+	switch strings.ToLower(fields[0]) {
+	case "help", "/help":
+		return botHelpMessage
+	case "search", "/search":
+		return h.search(argument)
+	case "status", "/status":
+		return h.status(argument)
+	}
+
+	if len(fields) == 1 && helpers.IsValidHttpUrl(fields[0]) {
+		return h.create(sender, fields[0])
+	}
+
+	return "Sorry, I didn't understand that.\n\n" + botHelpMessage
+}
+
+func (h *HandlerProcessDirectMessage) create(sender string, url string) string {
+	address, err := feeddomain.NewAddress(url)
+	if err != nil {
+		return fmt.Sprintf("Sorry, that URL is not valid: %s.", err)
+	}
+
+	publicKey, err := nostrdomain.NewPublicKeyFromHex(sender)
+	if err != nil {
+		return fmt.Sprintf("Sorry, I couldn't create a feed from that URL: %s.", err)
+	}
+
+	submitter := auth.NewNostrIdentity(publicKey, false)
+	definition, err := h.createFeedDefinition.Handle(CreateFeedDefinition{Address: address, Submitter: submitter})
+	if err != nil {
+		if errors.Is(err, ErrDomainBanned) {
+			return "Sorry, feeds from this domain are not accepted."
+		}
+		if errors.Is(err, ErrAddressNotAccepted) {
+			return "Sorry, feeds from this address are not accepted."
+		}
+		return fmt.Sprintf("Sorry, I couldn't create a feed from that URL: %s.", err)
+	}
+
+	if definition.Pending() {
+		return "Thanks, the feed awaits approval by the moderators, I'll send you its profile once it is approved."
+	}
+
+	return fmt.Sprintf("Here is your feed, follow it to get its updates:\n\nnostr:%s", h.profile(definition))
+}
+
+func (h *HandlerProcessDirectMessage) search(query string) string {
+	if query == "" {
+		return "Usage: search <text>"
+	}
+
+	definitions, err := h.feedDefinitionStorage.Search(query, botSearchLimit)
+	if err != nil {
+		return "Sorry, something went wrong while searching, please try again later."
+	}
+
+	if len(definitions) == 0 {
+		return fmt.Sprintf("No feeds found for '%s'.", query)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Feeds found for '%s':", query)
+	for _, definition := range definitions {
+		b.WriteString("\n\n")
+		if title := definition.Metadata().Title; title != "" {
+			fmt.Fprintf(&b, "%s\n", title)
+		}
+		fmt.Fprintf(&b, "%s\nnostr:%s", definition.Address().String(), h.profile(definition))
+	}
+	return b.String()
+}
+
+func (h *HandlerProcessDirectMessage) status(argument string) string {
+	if argument == "" {
+		return "Usage: status <npub or URL>"
+	}
+
+	definition, err := h.findFeed(argument)
+	if err != nil {
+		if errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+			return "Sorry, I don't know that feed, send me its URL to create it."
+		}
+		return "Sorry, something went wrong while looking for the feed, please try again later."
+	}
+
+	if definition.Pending() {
+		return fmt.Sprintf("Feed: %s\nStatus: awaiting approval", definition.Address().String())
+	}
+
+	status := "active"
+	if health := definition.Health(); definition.Disabled() {
+		status = "disabled"
+	} else if !health.Healthy() {
+		status = fmt.Sprintf(
+			"%s, failing since %s (%s), next attempt at %s",
+			health.State,
+			health.FailingSince.UTC().Format(time.RFC1123),
+			definition.Metadata().LastError,
+			health.NextFetchAt.UTC().Format(time.RFC1123),
+		)
+	}
+
+	return fmt.Sprintf("Feed: %s\nStatus: %s\n\nnostr:%s", definition.Address().String(), status, h.profile(definition))
+}
+
+func (h *HandlerProcessDirectMessage) findFeed(argument string) (*feeddomain.FeedDefinition, error) {
+	if !helpers.IsValidHttpUrl(argument) {
+		publicKey, err := nostrdomain.NewPublicKeyFromHexOrNip19(argument)
+		if err != nil {
+			return nil, feeddomain.ErrFeedDefinitionNotFound
+		}
+		return h.feedDefinitionStorage.Get(publicKey)
+	}
+
+	address, err := feeddomain.NewAddress(argument)
+	if err != nil {
+		return nil, feeddomain.ErrFeedDefinitionNotFound
+	}
+
+	// feed URLs map directly to their keys, website URLs have to be looked up
+	definition, err := getFeedByKeys(h.feedDefinitionStorage, address, h.keyDeriver)
+	if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+		return definition, err
+	}
+
+	definitions, err := h.feedDefinitionStorage.Search(argument, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "error searching feeds")
+	}
+
+	if len(definitions) == 0 {
+		return nil, feeddomain.ErrFeedDefinitionNotFound
+	}
+
+	return definitions[0], nil
+}
+
+func (h *HandlerProcessDirectMessage) profile(definition *feeddomain.FeedDefinition) string {
+	var relays []string
+	if h.mainDomainName != "" {
+		relays = append(relays, "wss://"+h.mainDomainName)
+	}
+
+	profile, err := nip19.EncodeProfile(definition.PublicKey().Hex(), relays)
+	if err != nil {
+		return definition.PublicKey().Nip19()
+	}
+	return profile
+}
@@ -0,0 +1,203 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBotCommands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		message  func(f *feedCreation, existing *domainfeed.FeedDefinition) string
+		expected []string
+	}{
+		{
+			name:     "empty message",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "  " },
+			expected: []string{"Commands:"},
+		},
+		{
+			name:     "help",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "/HELP" },
+			expected: []string{"Commands:"},
+		},
+		{
+			name:     "search without text",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "search" },
+			expected: []string{"Usage: search <text>"},
+		},
+		{
+			name:     "search without results",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "search  nothing here " },
+			expected: []string{"No feeds found for 'nothing here'."},
+		},
+		{
+			name:     "search",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "search copy-of" },
+			expected: []string{"Feeds found for 'copy-of':", "/copy-of-a\nnostr:nprofile"},
+		},
+		{
+			name:     "status without argument",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "status" },
+			expected: []string{"Usage: status <npub or URL>"},
+		},
+		{
+			name: "status of a npub",
+			message: func(_ *feedCreation, existing *domainfeed.FeedDefinition) string {
+				return "status " + existing.PublicKey().Nip19()
+			},
+			expected: []string{"/copy-of-a\nStatus: active"},
+		},
+		{
+			name: "status of a URL",
+			message: func(f *feedCreation, _ *domainfeed.FeedDefinition) string {
+				return "Status " + f.server.URL + "/copy-of-a/"
+			},
+			expected: []string{"/copy-of-a\nStatus: active"},
+		},
+		{
+			name: "status of an unknown feed",
+			message: func(*feedCreation, *domainfeed.FeedDefinition) string {
+				return "status " + nostr.GeneratePrivateKey()
+			},
+			expected: []string{"Sorry, I don't know that feed"},
+		},
+		{
+			name: "URL of a feed",
+			message: func(f *feedCreation, _ *domainfeed.FeedDefinition) string {
+				return f.server.URL + "/b"
+			},
+			expected: []string{"Here is your feed", "nostr:nprofile"},
+		},
+		{
+			name: "URL of an existing feed",
+			message: func(f *feedCreation, _ *domainfeed.FeedDefinition) string {
+				return f.server.URL + "/a"
+			},
+			expected: []string{"Here is your feed"},
+		},
+		{
+			name: "URL without a feed",
+			message: func(f *feedCreation, _ *domainfeed.FeedDefinition) string {
+				return f.server.URL + "/missing"
+			},
+			expected: []string{"Sorry, I couldn't create a feed from that URL"},
+		},
+		{
+			name: "URL followed by text",
+			message: func(f *feedCreation, _ *domainfeed.FeedDefinition) string {
+				return f.server.URL + "/b please"
+			},
+			expected: []string{"Sorry, I didn't understand that."},
+		},
+		{
+			name:     "anything else",
+			message:  func(*feedCreation, *domainfeed.FeedDefinition) string { return "hello" },
+			expected: []string{"Sorry, I didn't understand that.", "Commands:"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newFeedCreation(t, false)
+			existing := f.create("/copy-of-a")
+
+			b := newBot(t, f)
+			reply := b.send(testCase.message(f, existing))
+			for _, expected := range testCase.expected {
+				assert.Contains(t, reply, expected)
+			}
+		})
+	}
+}
+
+func TestBotRateLimit(t *testing.T) {
+	b := newBot(t, newFeedCreation(t, false))
+	b.handler.SetRateLimit(1, time.Minute)
+
+	b.send("help")
+	err := b.handler.Handle(b.directMessage("help"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate-limited")
+}
+
+type bot struct {
+	t                *testing.T
+	handler          *app.HandlerProcessDirectMessage
+	updates          chan nostr.Event
+	publicKey        string
+	senderPrivateKey string
+}
+
+func newBot(t *testing.T, f *feedCreation) *bot {
+	botPrivateKey := nostr.GeneratePrivateKey()
+	publicKey, err := nostr.GetPublicKey(botPrivateKey)
+	require.NoError(t, err)
+
+	db := migratedDatabase(t)
+	updates := make(chan nostr.Event, 1)
+	handler, err := app.NewHandlerProcessDirectMessage(
+		f.keyDeriver,
+		botPrivateKey,
+		"rsslay.example.com",
+		100,
+		time.Minute,
+		f.handler,
+		f.storage,
+		adapters.NewEventStorage(),
+		adapters.NewUserEventStorage(db),
+		updates,
+	)
+	require.NoError(t, err)
+
+	return &bot{
+		t:                t,
+		handler:          handler,
+		updates:          updates,
+		publicKey:        publicKey,
+		senderPrivateKey: nostr.GeneratePrivateKey(),
+	}
+}
+
+// send sends a NIP-04 direct message to the bot and returns its reply.
+func (b *bot) send(message string) string {
+	require.NoError(b.t, b.handler.Handle(b.directMessage(message)))
+
+	reply := <-b.updates
+	require.Equal(b.t, nostr.KindEncryptedDirectMessage, reply.Kind)
+	require.Equal(b.t, b.publicKey, reply.PubKey)
+
+	sharedSecret, err := nip04.ComputeSharedSecret(b.publicKey, b.senderPrivateKey)
+	require.NoError(b.t, err)
+	content, err := nip04.Decrypt(reply.Content, sharedSecret)
+	require.NoError(b.t, err)
+	return content
+}
+
+func (b *bot) directMessage(message string) domain.Event {
+	sharedSecret, err := nip04.ComputeSharedSecret(b.publicKey, b.senderPrivateKey)
+	require.NoError(b.t, err)
+	content, err := nip04.Encrypt(message, sharedSecret)
+	require.NoError(b.t, err)
+
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindEncryptedDirectMessage,
+		Tags:      nostr.Tags{{"p", b.publicKey}},
+		Content:   content,
+	}
+	require.NoError(b.t, event.Sign(b.senderPrivateKey))
+
+	domainEvent, err := domain.NewEvent(event)
+	require.NoError(b.t, err)
+	return domainEvent
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+
+	"github.com/piraces/rsslay/pkg/custom_cache"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+type HandlerRefreshFeed struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	feedUpdater           FeedUpdater
+}
+
+func NewHandlerRefreshFeed(feedDefinitionStorage FeedDefinitionStorage, feedUpdater FeedUpdater) *HandlerRefreshFeed {
+	return &HandlerRefreshFeed{
+		feedDefinitionStorage: feedDefinitionStorage,
+		feedUpdater:           feedUpdater,
+	}
+}
+
+func (h *HandlerRefreshFeed) Handle(ctx context.Context, publicKey domain.PublicKey) error {
+	definition, err := h.feedDefinitionStorage.Get(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "error getting the feed definition")
+	}
+
+	if definition.Disabled() {
+		return ErrFeedDisabled
+	}
+
+	if definition.Pending() {
+		return ErrFeedPending
+	}
+
+	// otherwise the cached copy of the feed would be converted again
+	if err := custom_cache.Delete(definition.Address().String()); err != nil {
+		return errors.Wrap(err, "error invalidating the cached feed")
+	}
+
+	return h.feedUpdater.UpdateFeed(ctx, definition)
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip26"
+	"github.com/piraces/rsslay/pkg/signer"
+	"github.com/pkg/errors"
+)
+
+// RegisterFeedOwner lets the owner of a feed publish it with their own key,
+// either through their NIP-46 remote signer or with a NIP-26 delegation to
+// the key of the feed.
+type RegisterFeedOwner struct {
+	Feed       domain.PublicKey
+	Owner      domain.PublicKey
+	BunkerURL  string
+	Delegation []string
+}
+
+type HandlerRegisterFeedOwner struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+	ownerSigners          OwnerSigners
+	feedUpdater           FeedUpdater
+}
+
+func NewHandlerRegisterFeedOwner(
+	feedDefinitionStorage FeedDefinitionStorage,
+	eventStorage EventStorage,
+	ownerSigners OwnerSigners,
+	feedUpdater FeedUpdater,
+) *HandlerRegisterFeedOwner {
+	return &HandlerRegisterFeedOwner{
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+		ownerSigners:          ownerSigners,
+		feedUpdater:           feedUpdater,
+	}
+}
+
+func (h *HandlerRegisterFeedOwner) Handle(ctx context.Context, cmd RegisterFeedOwner) (*feeddomain.FeedDefinition, error) {
+	if (cmd.BunkerURL == "") == (len(cmd.Delegation) == 0) {
+		return nil, errors.New("either a bunker url or a delegation is required")
+	}
+
+	definition, err := h.feedDefinitionStorage.Get(cmd.Feed)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the feed definition")
+	}
+
+	method, err := feed.VerifyOwner(definition.Address().String(), definition.Slug().String(), cmd.Owner.Hex())
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying the owner")
+	}
+
+	owner := feeddomain.Owner{
+		PublicKey:  cmd.Owner,
+		Method:     feeddomain.VerificationMethod(method),
+		VerifiedAt: time.Now(),
+	}
+
+	if cmd.BunkerURL != "" {
+		if err := h.checkBunker(ctx, definition, cmd.Owner, cmd.BunkerURL); err != nil {
+			return nil, err
+		}
+		owner.BunkerURL = cmd.BunkerURL
+	} else {
+		owner.Delegation, err = checkDelegation(definition, cmd.Owner, cmd.Delegation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	previous := definition.Owner()
+	if err := h.feedDefinitionStorage.SetOwner(definition.PublicKey(), owner); err != nil {
+		return nil, errors.Wrap(err, "error saving the owner")
+	}
+	definition.SetOwner(owner)
+
+	if !previous.IsZero() && !previous.PublicKey.Equal(owner.PublicKey) {
+		// the overrides were chosen by the previous owner
+		if err := h.feedDefinitionStorage.SetProfile(definition.PublicKey(), feeddomain.Profile{}); err != nil {
+			return nil, errors.Wrap(err, "error removing the profile")
+		}
+		definition.SetProfile(feeddomain.Profile{})
+	}
+
+	if previous.SignsRemotely() && previous.BunkerURL != owner.BunkerURL {
+		h.ownerSigners.Remove(previous.BunkerURL)
+		if err := deleteOwnerEvents(h.eventStorage, previous); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("[INFO] feed %s is now published by its owner %s", definition.PublicKey().Hex(), cmd.Owner.Hex())
+
+	// the feed is published with the new key right away
+	if err := h.feedUpdater.UpdateFeed(ctx, definition); err != nil {
+		log.Printf("[WARN] failure to update the feed after registering its owner: %v", err)
+	}
+
+	return definition, nil
+}
+
+// checkBunker makes sure that the remote signer signs as the owner. Owners
+// publish a single feed through their remote signer as the events of the
+// feeds are stored by author.
+func (h *HandlerRegisterFeedOwner) checkBunker(ctx context.Context, definition *feeddomain.FeedDefinition, owner domain.PublicKey, bunkerURL string) error {
+	owned, err := h.feedDefinitionStorage.ListByOwner(owner)
+	if err != nil {
+		return errors.Wrap(err, "error listing the feeds of the owner")
+	}
+	for _, other := range owned {
+		if !other.PublicKey().Equal(definition.PublicKey()) && other.Owner().SignsRemotely() {
+			return ErrOwnerPublishesAnotherFeed
+		}
+	}
+
+	ownerSigner, err := h.ownerSigners.Signer(ctx, bunkerURL)
+	if err != nil {
+		return errors.Wrap(err, "error connecting to the remote signer")
+	}
+
+	check := nostr.Event{
+		Kind:      nostr.KindTextNote,
+		CreatedAt: nostr.Now(),
+		Content:   "rsslay remote signer check",
+	}
+	if err := ownerSigner.Sign(ctx, signer.Key{PublicKey: owner.Hex()}, &check); err != nil {
+		h.ownerSigners.Remove(bunkerURL)
+		return errors.Wrap(err, "the remote signer can't sign as the owner")
+	}
+	return nil
+}
+
+func checkDelegation(definition *feeddomain.FeedDefinition, owner domain.PublicKey, tag []string) (nip26.Delegation, error) {
+	delegation, err := nip26.Parse(tag)
+	if err != nil {
+		return nip26.Delegation{}, err
+	}
+
+	if delegation.Delegator != owner.Hex() {
+		return nip26.Delegation{}, errors.New("the delegation wasn't created by the owner")
+	}
+
+	if err := delegation.Verify(definition.PublicKey().Hex()); err != nil {
+		return nip26.Delegation{}, err
+	}
+
+	now := nostr.Now()
+	if !delegation.Allows(nostr.KindTextNote, now) && !delegation.Allows(feed.KindLongFormTextContent, now) {
+		return nip26.Delegation{}, fmt.Errorf("the delegation must allow kind %d or %d events from now on", nostr.KindTextNote, feed.KindLongFormTextContent)
+	}
+	return delegation, nil
+}
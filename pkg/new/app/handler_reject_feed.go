@@ -0,0 +1,55 @@
+package app
+
+import (
+	"log"
+
+	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type HandlerRejectFeed struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	notifier              SubmitterNotifier
+}
+
+// NewHandlerRejectFeed creates the handler, the notifier is nil if the
+// submitters can't be notified.
+func NewHandlerRejectFeed(
+	feedDefinitionStorage FeedDefinitionStorage,
+	notifier SubmitterNotifier,
+) *HandlerRejectFeed {
+	return &HandlerRejectFeed{
+		feedDefinitionStorage: feedDefinitionStorage,
+		notifier:              notifier,
+	}
+}
+
+// Handle deletes a pending feed, it can be submitted again later. Address
+// rules keep feeds from being submitted again.
+func (h *HandlerRejectFeed) Handle(publicKey domain.PublicKey, reason string) error {
+	definition, err := h.feedDefinitionStorage.Get(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "error getting the feed definition")
+	}
+
+	if !definition.Pending() {
+		return ErrFeedNotPending
+	}
+
+	// pending feeds are never fetched so there are no events to delete
+	if err := h.feedDefinitionStorage.Delete(publicKey); err != nil {
+		return errors.Wrap(err, "error deleting the feed definition")
+	}
+	metrics.FeedModerationDecisions.With(prometheus.Labels{"decision": "rejected"}).Inc()
+
+	if submitter, ok := auth.NostrPublicKey(definition.Submitter()); ok && h.notifier != nil {
+		if err := h.notifier.FeedRejected(submitter, definition.Address(), reason); err != nil {
+			log.Printf("[ERROR] failure to notify the submitter of feed %s: %v", publicKey.Hex(), err)
+		}
+	}
+
+	return nil
+}
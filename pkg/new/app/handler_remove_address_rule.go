@@ -0,0 +1,19 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+)
+
+type HandlerRemoveAddressRule struct {
+	addressRuleStorage AddressRuleStorage
+}
+
+func NewHandlerRemoveAddressRule(addressRuleStorage AddressRuleStorage) *HandlerRemoveAddressRule {
+	return &HandlerRemoveAddressRule{
+		addressRuleStorage: addressRuleStorage,
+	}
+}
+
+func (h *HandlerRemoveAddressRule) Handle(pattern domainfeed.AddressPattern) error {
+	return h.addressRuleStorage.Delete(pattern)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+// HandlerRemoveFeedOwner goes back to publishing the feed with its own key.
+type HandlerRemoveFeedOwner struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+	ownerSigners          OwnerSigners
+	feedUpdater           FeedUpdater
+}
+
+func NewHandlerRemoveFeedOwner(
+	feedDefinitionStorage FeedDefinitionStorage,
+	eventStorage EventStorage,
+	ownerSigners OwnerSigners,
+	feedUpdater FeedUpdater,
+) *HandlerRemoveFeedOwner {
+	return &HandlerRemoveFeedOwner{
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+		ownerSigners:          ownerSigners,
+		feedUpdater:           feedUpdater,
+	}
+}
+
+func (h *HandlerRemoveFeedOwner) Handle(ctx context.Context, publicKey domain.PublicKey, owner domain.PublicKey) error {
+	definition, err := h.feedDefinitionStorage.Get(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "error getting the feed definition")
+	}
+
+	previous := definition.Owner()
+	if previous.IsZero() || !previous.PublicKey.Equal(owner) {
+		return ErrNotFeedOwner
+	}
+
+	if err := h.feedDefinitionStorage.SetOwner(publicKey, feeddomain.Owner{}); err != nil {
+		return errors.Wrap(err, "error removing the owner")
+	}
+	definition.SetOwner(feeddomain.Owner{})
+
+	// the overrides were chosen by the owner
+	if err := h.feedDefinitionStorage.SetProfile(publicKey, feeddomain.Profile{}); err != nil {
+		return errors.Wrap(err, "error removing the profile")
+	}
+	definition.SetProfile(feeddomain.Profile{})
+
+	if previous.SignsRemotely() {
+		h.ownerSigners.Remove(previous.BunkerURL)
+	}
+	if err := deleteOwnerEvents(h.eventStorage, previous); err != nil {
+		return err
+	}
+
+	if err := h.feedUpdater.UpdateFeed(ctx, definition); err != nil {
+		log.Printf("[WARN] failure to update the feed after removing its owner: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package app
+
+type HandlerRevokeAPIKey struct {
+	apiKeyStorage APIKeyStorage
+}
+
+func NewHandlerRevokeAPIKey(apiKeyStorage APIKeyStorage) *HandlerRevokeAPIKey {
+	return &HandlerRevokeAPIKey{
+		apiKeyStorage: apiKeyStorage,
+	}
+}
+
+func (h *HandlerRevokeAPIKey) Handle(id string) error {
+	return h.apiKeyStorage.Delete(id)
+}
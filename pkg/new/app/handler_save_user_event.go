@@ -0,0 +1,139 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip13"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/ratelimit"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+)
+
+const KindZap = 9735
+
+// UserEventKinds lists the kinds of events published by users which are
+// accepted as long as they reference a feed event or a feed profile.
+var UserEventKinds = []int{nostr.KindTextNote, nostr.KindReaction, KindZap}
+
+type UserEventPolicy struct {
+	MaxContentLength int
+	MaxTags          int
+	MinPow           int
+	RateLimit        int
+	RateLimitWindow  time.Duration
+}
+
+type HandlerSaveUserEvent struct {
+	policy                UserEventPolicy
+	limiter               *ratelimit.Limiter
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+	userEventStorage      UserEventStorage
+}
+
+func NewHandlerSaveUserEvent(
+	policy UserEventPolicy,
+	feedDefinitionStorage FeedDefinitionStorage,
+	eventStorage EventStorage,
+	userEventStorage UserEventStorage,
+) *HandlerSaveUserEvent {
+	return &HandlerSaveUserEvent{
+		policy:                policy,
+		limiter:               ratelimit.New(policy.RateLimit, policy.RateLimitWindow),
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+		userEventStorage:      userEventStorage,
+	}
+}
+
+// SetRateLimit changes the number of events an author can send within the
+// window.
+func (h *HandlerSaveUserEvent) SetRateLimit(limit int, window time.Duration) {
+	h.limiter.SetLimit(limit, window)
+}
+
+func (h *HandlerSaveUserEvent) Handle(event domain.Event) error {
+	libevent := event.Libevent()
+
+	if !slices.Contains(UserEventKinds, libevent.Kind) {
+		return fmt.Errorf("blocked: kind %d is not accepted", libevent.Kind)
+	}
+
+	if h.policy.MaxContentLength > 0 && len(libevent.Content) > h.policy.MaxContentLength {
+		return fmt.Errorf("invalid: content is longer than %d bytes", h.policy.MaxContentLength)
+	}
+
+	if h.policy.MaxTags > 0 && len(libevent.Tags) > h.policy.MaxTags {
+		return fmt.Errorf("invalid: event has more than %d tags", h.policy.MaxTags)
+	}
+
+	if h.policy.MinPow > 0 {
+		if err := nip13.Check(libevent.ID, h.policy.MinPow); err != nil {
+			return fmt.Errorf("pow: difficulty %d is required", h.policy.MinPow)
+		}
+	}
+
+	// the storage is only looked up for the events within the rate limit
+	if !h.limiter.Allow(libevent.PubKey) {
+		return errors.New("rate-limited: slow down")
+	}
+
+	referencesFeed, err := h.referencesFeed(libevent)
+	if err != nil {
+		return errors.Wrap(err, "error checking event references")
+	}
+
+	if !referencesFeed {
+		return errors.New("blocked: event doesn't reference any feed event or profile")
+	}
+
+	return h.userEventStorage.SaveEvent(event)
+}
+
+func (h *HandlerSaveUserEvent) referencesFeed(event nostr.Event) (bool, error) {
+	var ids []string
+
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		switch tag[0] {
+		case "p":
+			publicKey, err := domain.NewPublicKeyFromHex(tag[1])
+			if err != nil {
+				continue
+			}
+
+			// pending feeds aren't served so they can't be referenced yet
+			definition, err := h.feedDefinitionStorage.Get(publicKey)
+			if err == nil && !definition.Pending() {
+				return true, nil
+			}
+			if err == nil {
+				continue
+			}
+
+			if !errors.Is(err, feeddomain.ErrFeedDefinitionNotFound) {
+				return false, errors.Wrap(err, "error getting feed definition")
+			}
+		case "e":
+			ids = append(ids, tag[1])
+		}
+	}
+
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	events, err := h.eventStorage.GetEvents(domain.NewFilter(&nostr.Filter{IDs: ids}))
+	if err != nil {
+		return false, errors.Wrap(err, "error getting referenced events")
+	}
+
+	return len(events) > 0, nil
+}
@@ -0,0 +1,211 @@
+package app_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveUserEventPolicy(t *testing.T) {
+	feedPrivateKey := nostr.GeneratePrivateKey()
+	userPrivateKey := nostr.GeneratePrivateKey()
+	userPublicKey, err := nostr.GetPublicKey(userPrivateKey)
+	require.NoError(t, err)
+
+	policy := app.UserEventPolicy{
+		MaxContentLength: 10,
+		MaxTags:          2,
+		RateLimit:        10,
+		RateLimitWindow:  time.Minute,
+	}
+
+	testCases := []struct {
+		name          string
+		kind          int
+		content       string
+		tags          func(feedEvent nostr.Event) nostr.Tags
+		policy        func(policy app.UserEventPolicy) app.UserEventPolicy
+		sentBefore    int
+		expectedError string
+	}{
+		{
+			name: "reply to a feed event",
+			kind: nostr.KindTextNote,
+			tags: referenceEvent,
+		},
+		{
+			name: "reaction to a feed profile",
+			kind: nostr.KindReaction,
+			tags: referenceProfile,
+		},
+		{
+			name:          "kind which isn't accepted",
+			kind:          nostr.KindContactList,
+			tags:          referenceEvent,
+			expectedError: "blocked: kind 3 is not accepted",
+		},
+		{
+			name:          "content which is too long",
+			kind:          nostr.KindTextNote,
+			content:       strings.Repeat("a", 11),
+			tags:          referenceEvent,
+			expectedError: "invalid: content is longer than 10 bytes",
+		},
+		{
+			name:    "too many tags",
+			kind:    nostr.KindTextNote,
+			content: "hi",
+			tags: func(feedEvent nostr.Event) nostr.Tags {
+				return append(referenceEvent(feedEvent), nostr.Tag{"t", "a"}, nostr.Tag{"t", "b"})
+			},
+			expectedError: "invalid: event has more than 2 tags",
+		},
+		{
+			name: "not enough proof of work",
+			kind: nostr.KindTextNote,
+			tags: referenceEvent,
+			policy: func(policy app.UserEventPolicy) app.UserEventPolicy {
+				policy.MinPow = 40
+				return policy
+			},
+			expectedError: "pow: difficulty 40 is required",
+		},
+		{
+			name: "no reference to a feed",
+			kind: nostr.KindTextNote,
+			tags: func(feedEvent nostr.Event) nostr.Tags {
+				return nostr.Tags{{"e", strings.Repeat("0", 64)}, {"p", userPublicKey}}
+			},
+			expectedError: "blocked: event doesn't reference any feed event or profile",
+		},
+		{
+			name: "author above the rate limit",
+			kind: nostr.KindTextNote,
+			tags: referenceEvent,
+			policy: func(policy app.UserEventPolicy) app.UserEventPolicy {
+				policy.RateLimit = 1
+				return policy
+			},
+			sentBefore:    1,
+			expectedError: "rate-limited: slow down",
+		},
+		{
+			name: "author above the rate limit without reference to a feed",
+			kind: nostr.KindTextNote,
+			tags: func(feedEvent nostr.Event) nostr.Tags {
+				return nostr.Tags{{"e", strings.Repeat("0", 64)}}
+			},
+			policy: func(policy app.UserEventPolicy) app.UserEventPolicy {
+				policy.RateLimit = 1
+				return policy
+			},
+			sentBefore:    1,
+			expectedError: "rate-limited: slow down",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			db := migratedDatabase(t)
+			feedDefinitionStorage := adapters.NewFeedDefinitionStorage(db, someKeyring(t))
+			eventStorage := adapters.NewEventStorage()
+			feedEvent := storeFeedEvent(t, feedDefinitionStorage, eventStorage, feedPrivateKey)
+
+			casePolicy := policy
+			if testCase.policy != nil {
+				casePolicy = testCase.policy(policy)
+			}
+
+			handler := app.NewHandlerSaveUserEvent(casePolicy, feedDefinitionStorage, eventStorage, adapters.NewUserEventStorage(db))
+
+			for i := 0; i < testCase.sentBefore; i++ {
+				require.NoError(t, handler.Handle(someUserEvent(t, userPrivateKey, testCase.kind, fmt.Sprint(i), referenceEvent(feedEvent))))
+			}
+
+			event := someUserEvent(t, userPrivateKey, testCase.kind, testCase.content, testCase.tags(feedEvent))
+			err := handler.Handle(event)
+			if testCase.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedError)
+			}
+		})
+	}
+}
+
+func TestGetEventsAppliesTheLimitToAllEvents(t *testing.T) {
+	db := migratedDatabase(t)
+	feedDefinitionStorage := adapters.NewFeedDefinitionStorage(db, someKeyring(t))
+	eventStorage := adapters.NewEventStorage()
+	userEventStorage := adapters.NewUserEventStorage(db)
+	feedEvent := storeFeedEvent(t, feedDefinitionStorage, eventStorage, nostr.GeneratePrivateKey())
+
+	userPrivateKey := nostr.GeneratePrivateKey()
+	for _, content := range []string{"first", "second"} {
+		require.NoError(t, userEventStorage.SaveEvent(someUserEvent(t, userPrivateKey, nostr.KindTextNote, content, referenceEvent(feedEvent))))
+	}
+
+	handler := app.NewHandlerGetEvents(eventStorage, userEventStorage)
+
+	events, err := handler.Handle(domain.NewFilter(&nostr.Filter{Limit: 2}))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.NotEqual(t, feedEvent.ID, event.Libevent().ID, "the oldest event must be left out")
+	}
+
+	events, err = handler.Handle(domain.NewFilter(&nostr.Filter{}))
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+}
+
+// storeFeedEvent stores a feed with one event, older than the events of the
+// users which reference it.
+func storeFeedEvent(t *testing.T, feedDefinitionStorage *adapters.FeedDefinitionStorage, eventStorage *adapters.EventStorage, privateKey string) nostr.Event {
+	definition := someFeedDefinitionWithPrivateKey(t, "https://example.com/"+t.Name(), privateKey)
+	require.NoError(t, feedDefinitionStorage.Put(definition))
+
+	event := nostr.Event{
+		CreatedAt: nostr.Timestamp(time.Now().Add(-time.Hour).Unix()),
+		Kind:      nostr.KindTextNote,
+		Tags:      nostr.Tags{},
+		Content:   "a feed item",
+	}
+	require.NoError(t, event.Sign(privateKey))
+
+	domainEvent, err := domain.NewEvent(event)
+	require.NoError(t, err)
+	require.NoError(t, eventStorage.PutEvents(definition.PublicKey(), []domain.Event{domainEvent}))
+	return event
+}
+
+func someUserEvent(t *testing.T, privateKey string, kind int, content string, tags nostr.Tags) domain.Event {
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      kind,
+		Tags:      tags,
+		Content:   content,
+	}
+	require.NoError(t, event.Sign(privateKey))
+
+	domainEvent, err := domain.NewEvent(event)
+	require.NoError(t, err)
+	return domainEvent
+}
+
+func referenceEvent(feedEvent nostr.Event) nostr.Tags {
+	return nostr.Tags{{"e", feedEvent.ID}}
+}
+
+func referenceProfile(feedEvent nostr.Event) nostr.Tags {
+	return nostr.Tags{{"p", feedEvent.PubKey}}
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+type HandlerSetFeedDisabled struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	eventStorage          EventStorage
+}
+
+func NewHandlerSetFeedDisabled(feedDefinitionStorage FeedDefinitionStorage, eventStorage EventStorage) *HandlerSetFeedDisabled {
+	return &HandlerSetFeedDisabled{
+		feedDefinitionStorage: feedDefinitionStorage,
+		eventStorage:          eventStorage,
+	}
+}
+
+func (h *HandlerSetFeedDisabled) Handle(publicKey domain.PublicKey, disabled bool) error {
+	if err := h.feedDefinitionStorage.SetDisabled(publicKey, disabled); err != nil {
+		return errors.Wrap(err, "error updating the feed definition")
+	}
+
+	// enabled feeds get their events back during the next update
+	if disabled {
+		if err := h.eventStorage.DeleteEvents(publicKey); err != nil {
+			return errors.Wrap(err, "error deleting the feed events")
+		}
+	}
+
+	return nil
+}
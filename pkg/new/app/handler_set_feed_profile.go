@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+// SetFeedProfile replaces the profile overrides of a feed, only its verified
+// owner can set them.
+type SetFeedProfile struct {
+	Feed    domain.PublicKey
+	Owner   domain.PublicKey
+	Profile feeddomain.Profile
+}
+
+type HandlerSetFeedProfile struct {
+	feedDefinitionStorage FeedDefinitionStorage
+	feedUpdater           FeedUpdater
+}
+
+func NewHandlerSetFeedProfile(feedDefinitionStorage FeedDefinitionStorage, feedUpdater FeedUpdater) *HandlerSetFeedProfile {
+	return &HandlerSetFeedProfile{
+		feedDefinitionStorage: feedDefinitionStorage,
+		feedUpdater:           feedUpdater,
+	}
+}
+
+func (h *HandlerSetFeedProfile) Handle(ctx context.Context, cmd SetFeedProfile) (*feeddomain.FeedDefinition, error) {
+	definition, err := h.feedDefinitionStorage.Get(cmd.Feed)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the feed definition")
+	}
+
+	if owner := definition.Owner(); owner.IsZero() || !owner.PublicKey.Equal(cmd.Owner) {
+		return nil, ErrNotFeedOwner
+	}
+
+	if err := h.feedDefinitionStorage.SetProfile(definition.PublicKey(), cmd.Profile); err != nil {
+		return nil, errors.Wrap(err, "error saving the profile")
+	}
+	definition.SetProfile(cmd.Profile)
+
+	// the profile is published right away
+	if err := h.feedUpdater.UpdateFeed(ctx, definition); err != nil {
+		log.Printf("[WARN] failure to update the feed after setting its profile: %v", err)
+	}
+
+	return definition, nil
+}
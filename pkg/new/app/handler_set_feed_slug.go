@@ -0,0 +1,20 @@
+package app
+
+import (
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+)
+
+type HandlerSetFeedSlug struct {
+	feedDefinitionStorage FeedDefinitionStorage
+}
+
+func NewHandlerSetFeedSlug(feedDefinitionStorage FeedDefinitionStorage) *HandlerSetFeedSlug {
+	return &HandlerSetFeedSlug{
+		feedDefinitionStorage: feedDefinitionStorage,
+	}
+}
+
+func (h *HandlerSetFeedSlug) Handle(publicKey domain.PublicKey, slug domainfeed.Slug) error {
+	return h.feedDefinitionStorage.SetSlug(publicKey, slug)
+}
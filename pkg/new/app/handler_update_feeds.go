@@ -2,8 +2,16 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/json"
+	"encoding/xml"
 	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -14,6 +22,7 @@ import (
 	"github.com/piraces/rsslay/pkg/metrics"
 	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
 	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/signer"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -21,50 +30,86 @@ import (
 const numWorkers = 10
 
 type HandlerUpdateFeeds struct {
-	deleteFailingFeeds          bool
-	nitterInstances             []string
+	healthPolicy                domainfeed.HealthPolicy
 	enableAutoNIP05Registration bool
 	defaultProfilePictureUrl    string
 	mainDomainName              string
 
+	// settingsLock guards the settings which change without a restart
+	settingsLock    sync.RWMutex
+	nitterInstances []string
+	relays          []string
+
 	db                    *sql.DB // todo remove!
 	feedDefinitionStorage FeedDefinitionStorage
 	converterSelector     ConverterSelector
 	eventStorage          EventStorage
 	eventPublisher        EventPublisher
+	bannedDomainStorage   BannedDomainStorage
+	signer                signer.Signer
+	ownerSigners          OwnerSigners
 }
 
 func NewHandlerUpdateFeeds(
-	deleteFailingFeeds bool,
+	healthPolicy domainfeed.HealthPolicy,
 	nitterInstances []string,
 	enableAutoNIP05Registration bool,
 	defaultProfilePictureUrl string,
 	mainDomainName string,
+	relays []string,
 	db *sql.DB,
 	feedDefinitionStorage FeedDefinitionStorage,
 	converterSelector ConverterSelector,
 	eventStorage EventStorage,
 	eventPublisher EventPublisher,
+	bannedDomainStorage BannedDomainStorage,
+	signer signer.Signer,
+	ownerSigners OwnerSigners,
 ) *HandlerUpdateFeeds {
 	return &HandlerUpdateFeeds{
-		deleteFailingFeeds:          deleteFailingFeeds,
+		healthPolicy:                healthPolicy,
 		nitterInstances:             nitterInstances,
 		enableAutoNIP05Registration: enableAutoNIP05Registration,
 		defaultProfilePictureUrl:    defaultProfilePictureUrl,
 		mainDomainName:              mainDomainName,
+		relays:                      relays,
 		db:                          db,
 		feedDefinitionStorage:       feedDefinitionStorage,
 		converterSelector:           converterSelector,
 		eventStorage:                eventStorage,
 		eventPublisher:              eventPublisher,
+		bannedDomainStorage:         bannedDomainStorage,
+		signer:                      signer,
+		ownerSigners:                ownerSigners,
 	}
 }
 
+// SetNitterInstances changes the instances which feeds from Twitter are
+// fetched from.
+func (h *HandlerUpdateFeeds) SetNitterInstances(nitterInstances []string) {
+	h.settingsLock.Lock()
+	defer h.settingsLock.Unlock()
+	h.nitterInstances = nitterInstances
+}
+
+// SetRelays changes the relays listed in the relay list events of the feeds.
+func (h *HandlerUpdateFeeds) SetRelays(relays []string) {
+	h.settingsLock.Lock()
+	defer h.settingsLock.Unlock()
+	h.relays = relays
+}
+
+func (h *HandlerUpdateFeeds) settings() (nitterInstances []string, relays []string) {
+	h.settingsLock.RLock()
+	defer h.settingsLock.RUnlock()
+	return h.nitterInstances, h.relays
+}
+
 func (h *HandlerUpdateFeeds) Handle(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	definitions, err := h.feedDefinitionStorage.List()
+	definitions, err := h.listFeedsToUpdate()
 	if err != nil {
 		return errors.Wrap(err, "error getting feed definitions")
 	}
@@ -108,9 +153,34 @@ func (h *HandlerUpdateFeeds) Handle(ctx context.Context) error {
 	metrics.UpdateResults.With(prometheus.Labels{"result": "success"}).Set(float64(counterSuccess))
 	metrics.UpdateResults.With(prometheus.Labels{"result": "error"}).Set(float64(counterError))
 
+	if err := h.reportHealth(); err != nil {
+		log.Printf("[ERROR] failure to report the feeds health: %v", err)
+	}
+
 	return resultErr
 }
 
+func (h *HandlerUpdateFeeds) reportHealth() error {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return errors.Wrap(err, "error listing feed definitions")
+	}
+
+	counts := map[domainfeed.HealthState]int{
+		domainfeed.HealthStateHealthy:   0,
+		domainfeed.HealthStateDegraded:  0,
+		domainfeed.HealthStateSuspended: 0,
+	}
+	for _, definition := range definitions {
+		counts[definition.Health().State]++
+	}
+
+	for state, count := range counts {
+		metrics.FeedsByHealthState.With(prometheus.Labels{"state": string(state)}).Set(float64(count))
+	}
+	return nil
+}
+
 func (h *HandlerUpdateFeeds) startWorkers(ctx context.Context, chIn chan *domainfeed.FeedDefinition, chOut chan definitionWithError) {
 	for i := 0; i < numWorkers; i++ {
 		go h.startWorker(ctx, chIn, chOut)
@@ -121,7 +191,7 @@ func (h *HandlerUpdateFeeds) startWorker(ctx context.Context, chIn chan *domainf
 	for {
 		select {
 		case definition := <-chIn:
-			err := h.updateFeed(ctx, definition)
+			err := h.UpdateFeed(ctx, definition)
 			select {
 			case chOut <- definitionWithError{
 				Definition: definition,
@@ -136,16 +206,61 @@ func (h *HandlerUpdateFeeds) startWorker(ctx context.Context, chIn chan *domainf
 	}
 }
 
+func (h *HandlerUpdateFeeds) listFeedsToUpdate() ([]*domainfeed.FeedDefinition, error) {
+	definitions, err := h.feedDefinitionStorage.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing feed definitions")
+	}
+
+	now := time.Now()
+
+	var result []*domainfeed.FeedDefinition
+	for _, definition := range definitions {
+		if definition.Disabled() || definition.Pending() || !definition.Health().Due(now) {
+			continue
+		}
+
+		banned, err := h.bannedDomainStorage.IsBanned(definition.Address())
+		if err != nil {
+			return nil, errors.Wrap(err, "error checking if the domain is banned")
+		}
+
+		if banned {
+			continue
+		}
+
+		result = append(result, definition)
+	}
+	return result, nil
+}
+
 // todo restore the capability to replay events?
-func (h *HandlerUpdateFeeds) updateFeed(ctx context.Context, definition *domainfeed.FeedDefinition) error {
+func (h *HandlerUpdateFeeds) UpdateFeed(ctx context.Context, definition *domainfeed.FeedDefinition) error {
 	log.Printf("updating feed %s", definition.PublicKey().Hex())
 
-	events, err := h.getFeedEvents(definition)
+	fetchedAt := time.Now()
+	events, fetched, err := h.getFeedEvents(ctx, definition)
 	if err != nil {
+		// only the feed is to blame for failing to fetch or parse it, other
+		// errors such as an offline remote signer are retried on the next
+		// update without making the feed fail towards its deletion
+		var fetchErr fetchError
+		if errors.As(err, &fetchErr) {
+			h.recordFailure(definition, fetchErr.err, fetchedAt)
+		} else {
+			log.Printf("[WARN] feed %s will be updated again, its health is unchanged: %v", definition.PublicKey().Hex(), err)
+			metrics.FeedUpdateErrors.Inc()
+		}
 		return errors.Wrapf(err, "error getting events for feed '%s'", definition.PublicKey().Hex())
 	}
 
-	if err := h.eventStorage.PutEvents(definition.PublicKey(), events); err != nil {
+	h.setMetadata(definition, definition.Metadata().Fetched(fetched, fetchedAt))
+	if !definition.Health().Healthy() {
+		log.Printf("[INFO] feed %s recovered after %d failures", definition.PublicKey().Hex(), definition.Health().ConsecutiveFailures)
+		h.setHealth(definition, h.healthPolicy.Succeeded())
+	}
+
+	if err := h.putEvents(definition, events); err != nil {
 		return errors.Wrap(err, "error saving events")
 	}
 
@@ -156,61 +271,394 @@ func (h *HandlerUpdateFeeds) updateFeed(ctx context.Context, definition *domainf
 	return nil
 }
 
-func (h *HandlerUpdateFeeds) getFeedEvents(definition *domainfeed.FeedDefinition) ([]domain.Event, error) {
-	parsedFeed, entity := events.GetParsedFeedForPubKey(
+// recordFailure updates the health of the feed deleting it if it has been
+// failing for too long.
+func (h *HandlerUpdateFeeds) recordFailure(definition *domainfeed.FeedDefinition, err error, failedAt time.Time) {
+	health := h.healthPolicy.Failed(definition.Health(), classifyFetchError(err), failedAt)
+	metrics.FeedFetchErrors.With(prometheus.Labels{"kind": string(health.ErrorKind)}).Inc()
+
+	if h.healthPolicy.ShouldDelete(health, failedAt) {
+		log.Printf("[INFO] deleting feed %s failing since %s", definition.PublicKey().Hex(), health.FailingSince)
+		if err := h.feedDefinitionStorage.Delete(definition.PublicKey()); err != nil && !errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+			log.Printf("[ERROR] failure to delete the failing feed: %v", err)
+			return
+		}
+		if err := deleteFeedEvents(h.eventStorage, definition); err != nil {
+			log.Printf("[ERROR] failure to delete the failing feed events: %v", err)
+		}
+		metrics.FailingFeedsDeleted.Inc()
+		return
+	}
+
+	h.setMetadata(definition, definition.Metadata().Failed(err, failedAt))
+	h.setHealth(definition, health)
+}
+
+func (h *HandlerUpdateFeeds) setHealth(definition *domainfeed.FeedDefinition, health domainfeed.Health) {
+	definition.SetHealth(health)
+	err := h.feedDefinitionStorage.SetHealth(definition.PublicKey(), health)
+	if err != nil && !errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+		log.Printf("[ERROR] failure to update the feed health: %v", err)
+	}
+}
+
+// setMetadata doesn't fail the update as the metadata is only informative,
+// the feed may also have been deleted in the meantime.
+func (h *HandlerUpdateFeeds) setMetadata(definition *domainfeed.FeedDefinition, metadata domainfeed.Metadata) {
+	definition.SetMetadata(metadata)
+	err := h.feedDefinitionStorage.SetMetadata(definition.PublicKey(), metadata)
+	if err != nil && !errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+		log.Printf("[ERROR] failure to update the feed metadata: %v", err)
+	}
+}
+
+func (h *HandlerUpdateFeeds) getFeedEvents(ctx context.Context, definition *domainfeed.FeedDefinition) ([]domain.Event, domainfeed.Metadata, error) {
+	nitterInstances, relays := h.settings()
+
+	parsedFeed, entity, err := events.GetParsedFeedForPubKey(
 		definition.PublicKey().Hex(),
 		h.db,
-		h.deleteFailingFeeds,
-		h.nitterInstances,
+		nitterInstances,
 	)
-	if parsedFeed == nil {
-		return nil, nil // todo why is this not an error?
+	if errors.Is(err, events.ErrFeedLookup) {
+		return nil, domainfeed.Metadata{}, err
+	}
+	if err != nil {
+		return nil, domainfeed.Metadata{}, fetchError{errors.Wrap(err, "feed could not be fetched or parsed")}
+	}
+
+	if !entity.Nitter {
+		// nitter feeds are fetched from any of the instances
+		h.followMove(definition, parsedFeed)
+	}
+
+	if err := h.feedDefinitionStorage.SetItemGUIDs(definition.PublicKey(), itemGUIDs(parsedFeed)); err != nil {
+		log.Printf("[ERROR] failure to save the feed items: %v", err)
 	}
 
 	var events []domain.Event
 
-	metadataEvent, err := h.makeMetadataEvent(definition, parsedFeed, entity)
+	metadataEvent, err := h.makeMetadataEvent(ctx, definition, parsedFeed, entity)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating the metadata event")
+		return nil, domainfeed.Metadata{}, errors.Wrap(err, "error creating the metadata event")
 	}
 	events = append(events, metadataEvent)
 
+	if len(relays) > 0 {
+		relayListEvent, err := h.stableEvent(ctx, feed.EntryFeedToRelayList(definition.PublicKey().Hex(), relays), definition, entity)
+		if err != nil {
+			return nil, domainfeed.Metadata{}, errors.Wrap(err, "error creating the relay list event")
+		}
+		events = append(events, relayListEvent)
+	}
+
 	converter := h.converterSelector.Select(parsedFeed)
 
+	author := definition.PublicKey()
+	if owner := definition.Owner(); owner.SignsRemotely() {
+		author = owner.PublicKey
+	}
+
 	for _, item := range parsedFeed.Items {
 		defaultCreatedAt := time.Unix(time.Now().Unix(), 0)
-		evt := converter.Convert(definition.PublicKey().Hex(), item, parsedFeed, defaultCreatedAt, entity.URL)
+		evt := converter.Convert(author.Hex(), item, parsedFeed, defaultCreatedAt, entity.URL)
 
 		// Feed need to have a date for each entry...
 		if evt.CreatedAt == nostr.Timestamp(defaultCreatedAt.Unix()) {
 			continue
 		}
 
-		if err = evt.Sign(entity.PrivateKey); err != nil {
-			return nil, errors.Wrap(err, "error signing the event")
+		if err = h.signItem(ctx, definition, &evt); err != nil {
+			return nil, domainfeed.Metadata{}, errors.Wrap(err, "error signing the event")
 		}
 
 		domainEvent, err := domain.NewEvent(evt)
 		if err != nil {
-			return nil, errors.Wrap(err, "error creating a domain event")
+			return nil, domainfeed.Metadata{}, errors.Wrap(err, "error creating a domain event")
 		}
 
 		events = append(events, domainEvent)
 	}
 
-	return events, nil
+	return events, metadataFromFeed(parsedFeed), nil
+}
+
+func metadataFromFeed(parsedFeed *gofeed.Feed) domainfeed.Metadata {
+	metadata := domainfeed.Metadata{
+		Title:       strings.TrimSpace(parsedFeed.Title),
+		Description: strings.TrimSpace(parsedFeed.Description),
+		Link:        parsedFeed.Link,
+		Language:    parsedFeed.Language,
+		ItemCount:   len(parsedFeed.Items),
+	}
+
+	if parsedFeed.Image != nil {
+		metadata.Image = parsedFeed.Image.URL
+	} else if parsedFeed.ITunesExt != nil {
+		metadata.Image = parsedFeed.ITunesExt.Image
+	}
+
+	return metadata
 }
 
-func (h *HandlerUpdateFeeds) makeMetadataEvent(definition *domainfeed.FeedDefinition, parsedFeed *gofeed.Feed, entity feed.Entity) (domain.Event, error) {
-	evt := feed.EntryFeedToSetMetadata(definition.PublicKey().Hex(), parsedFeed, entity.URL, h.enableAutoNIP05Registration, h.defaultProfilePictureUrl, h.mainDomainName)
-	if err := evt.Sign(entity.PrivateKey); err != nil {
+func (h *HandlerUpdateFeeds) makeMetadataEvent(ctx context.Context, definition *domainfeed.FeedDefinition, parsedFeed *gofeed.Feed, entity feed.Entity) (domain.Event, error) {
+	evt := metadataEvent(definition, parsedFeed, feed.MetadataOptions{
+		EnableAutoRegistration:   h.enableAutoNIP05Registration,
+		DefaultProfilePictureUrl: h.defaultProfilePictureUrl,
+		MainDomainName:           h.mainDomainName,
+	})
+	return h.stableEvent(ctx, evt, definition, entity)
+}
+
+// metadataEvent creates the profile of the feed, the options carry the
+// settings of the relay and the rest comes from the feed definition.
+func metadataEvent(definition *domainfeed.FeedDefinition, parsedFeed *gofeed.Feed, options feed.MetadataOptions) nostr.Event {
+	options.OriginalUrl = definition.Address().String()
+	options.Slug = definition.Slug().String()
+	options.Overrides = feed.ProfileOverrides(definition.Profile())
+
+	if parsedFeed.ITunesExt == nil || parsedFeed.ITunesExt.Image == "" {
+		options.SiteImageUrl = feed.GetSiteImageURL(parsedFeed.Link)
+	}
+
+	if move := definition.Move(); !move.IsZero() {
+		options.MovedFrom = move.From.String()
+	}
+
+	if owner := definition.Owner(); !owner.IsZero() {
+		options.MigratedTo = owner.PublicKey.Nip19()
+	}
+
+	return feed.EntryFeedToSetMetadata(definition.PublicKey().Hex(), parsedFeed, options)
+}
+
+// followMove points the feed to its new address if the publisher moved it
+// with a permanent redirect or by changing the self link of the feed. The
+// keys stay the same so that followers don't lose the feed.
+func (h *HandlerUpdateFeeds) followMove(definition *domainfeed.FeedDefinition, parsedFeed *gofeed.Feed) {
+	location, redirected := feed.PermanentRedirect(parsedFeed), true
+	if location == "" {
+		location, redirected = parsedFeed.FeedLink, false
+	}
+
+	if location == "" || sameLocation(definition.Address().String(), location) {
+		return
+	}
+
+	// self links are often stale so they are only trusted if they point to
+	// a feed which agrees with them
+	address, err := h.checkNewLocation(definition, location, !redirected)
+	if err != nil {
+		log.Printf("[DEBUG] not moving feed %s to %q: %v", definition.PublicKey().Hex(), location, err)
+		return
+	}
+
+	previous := definition.Address()
+	definition.MoveTo(address, time.Now())
+	if err := h.feedDefinitionStorage.SetAddress(definition.PublicKey(), definition.Address(), definition.Move()); err != nil {
+		log.Printf("[ERROR] failure to move the feed: %v", err)
+		return
+	}
+
+	log.Printf("[INFO] feed %s moved from %q to %q", definition.PublicKey().Hex(), previous.String(), address.String())
+	metrics.FeedsMoved.Inc()
+}
+
+func (h *HandlerUpdateFeeds) checkNewLocation(definition *domainfeed.FeedDefinition, location string, verify bool) (domainfeed.Address, error) {
+	address, err := domainfeed.NewAddress(location)
+	if err != nil {
+		return domainfeed.Address{}, errors.Wrap(err, "invalid address")
+	}
+
+	if strings.HasPrefix(definition.Address().String(), "https://") && !strings.HasPrefix(address.String(), "https://") {
+		return domainfeed.Address{}, errors.New("feeds can't move from https to http")
+	}
+
+	banned, err := h.bannedDomainStorage.IsBanned(address)
+	if err != nil {
+		return domainfeed.Address{}, errors.Wrap(err, "error checking if the domain is banned")
+	}
+	if banned {
+		return domainfeed.Address{}, ErrDomainBanned
+	}
+
+	existing, err := h.feedDefinitionStorage.GetByAddress(address)
+	if err == nil && !existing.PublicKey().Equal(definition.PublicKey()) {
+		return domainfeed.Address{}, errors.New("the address is used by another feed")
+	}
+	if err != nil && !errors.Is(err, domainfeed.ErrFeedDefinitionNotFound) {
+		return domainfeed.Address{}, errors.Wrap(err, "error checking if the address is used")
+	}
+
+	if verify {
+		movedFeed, err := feed.ParseFeed(address.String())
+		if err != nil {
+			return domainfeed.Address{}, errors.Wrap(err, "error parsing the feed at the new address")
+		}
+
+		if feed.PermanentRedirect(movedFeed) != "" || !sameLocation(address.String(), movedFeed.FeedLink) {
+			return domainfeed.Address{}, errors.New("the feed at the new address points elsewhere")
+		}
+	}
+
+	return address, nil
+}
+
+// sameLocation ignores differences which don't change what a server
+// serves such as the case of the host or trailing slashes.
+func sameLocation(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Host, ub.Host) &&
+		strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/") &&
+		ua.RawQuery == ub.RawQuery
+}
+
+// stableEvent signs a replaceable event unless it didn't change since the
+// last update in which case the previous event is returned. This way clients
+// don't see a new version of the event on every update.
+func (h *HandlerUpdateFeeds) stableEvent(ctx context.Context, evt nostr.Event, definition *domainfeed.FeedDefinition, entity feed.Entity) (domain.Event, error) {
+	previousEvents, err := h.eventStorage.GetEvents(domain.NewFilter(&nostr.Filter{
+		Authors: []string{definition.PublicKey().Hex()},
+		Kinds:   []int{evt.Kind},
+	}))
+	if err != nil {
+		return domain.Event{}, errors.Wrap(err, "error getting the previous event")
+	}
+
+	for _, previousEvent := range previousEvents {
+		previous := previousEvent.Libevent()
+
+		unchanged := evt
+		unchanged.CreatedAt = previous.CreatedAt
+		if unchanged.GetID() == previous.ID {
+			return previousEvent, nil
+		}
+
+		// replaceable events must be newer than the ones they replace
+		if evt.CreatedAt <= previous.CreatedAt {
+			evt.CreatedAt = previous.CreatedAt + 1
+			if now := nostr.Now(); now > evt.CreatedAt {
+				evt.CreatedAt = now
+			}
+		}
+	}
+
+	if err := h.sign(ctx, definition, &evt); err != nil {
 		return domain.Event{}, errors.Wrap(err, "error signing the event")
 	}
-	domainMetadataEvent, err := domain.NewEvent(evt)
+	domainEvent, err := domain.NewEvent(evt)
 	if err != nil {
 		return domain.Event{}, errors.Wrap(err, "error creating a domain event")
 	}
-	return domainMetadataEvent, nil
+	return domainEvent, nil
+}
+
+func (h *HandlerUpdateFeeds) sign(ctx context.Context, definition *domainfeed.FeedDefinition, evt *nostr.Event) error {
+	return h.signer.Sign(ctx, signingKey(definition), evt)
+}
+
+// signItem publishes the items of feeds with a verified owner with the key of
+// the owner, either signing through their remote signer or adding their
+// delegation.
+func (h *HandlerUpdateFeeds) signItem(ctx context.Context, definition *domainfeed.FeedDefinition, evt *nostr.Event) error {
+	owner := definition.Owner()
+	if owner.SignsRemotely() {
+		ownerSigner, err := h.ownerSigners.Signer(ctx, owner.BunkerURL)
+		if err != nil {
+			return errors.Wrap(err, "error connecting to the remote signer of the owner")
+		}
+		return ownerSigner.Sign(ctx, signer.Key{PublicKey: owner.PublicKey.Hex()}, evt)
+	}
+
+	if !owner.Delegation.IsZero() && owner.Delegation.Allows(evt.Kind, evt.CreatedAt) {
+		evt.Tags = append(evt.Tags, owner.Delegation.Tag())
+	}
+	return h.sign(ctx, definition, evt)
+}
+
+// putEvents stores the events signed by the owner of the feed apart from the
+// ones signed with the key of the feed.
+func (h *HandlerUpdateFeeds) putEvents(definition *domainfeed.FeedDefinition, events []domain.Event) error {
+	owner := definition.Owner()
+	if !owner.SignsRemotely() {
+		return h.eventStorage.PutEvents(definition.PublicKey(), events)
+	}
+
+	var feedEvents, ownerEvents []domain.Event
+	for _, event := range events {
+		if event.PublicKey().Equal(owner.PublicKey) {
+			ownerEvents = append(ownerEvents, event)
+		} else {
+			feedEvents = append(feedEvents, event)
+		}
+	}
+
+	if err := h.eventStorage.PutEvents(definition.PublicKey(), feedEvents); err != nil {
+		return err
+	}
+	return h.eventStorage.PutEvents(owner.PublicKey, ownerEvents)
+}
+
+// classifyFetchError tells apart the most common reasons why feeds can't be
+// fetched.
+func classifyFetchError(err error) domainfeed.ErrorKind {
+	var (
+		dnsErr       *net.DNSError
+		certErr      *tls.CertificateVerificationError
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		httpErr      feed.HTTPStatusError
+		netErr       net.Error
+		urlErr       *url.Error
+		xmlErr       *xml.SyntaxError
+		jsonErr      *json.SyntaxError
+	)
+
+	switch {
+	case errors.Is(err, events.ErrInvalidFeedURL):
+		return domainfeed.ErrorKindInvalidURL
+	case errors.As(err, &dnsErr):
+		return domainfeed.ErrorKindDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return domainfeed.ErrorKindTLS
+	case errors.As(err, &httpErr):
+		return domainfeed.ErrorKindHTTP
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return domainfeed.ErrorKindTimeout
+	case errors.As(err, &urlErr):
+		return domainfeed.ErrorKindConnection
+	case errors.Is(err, gofeed.ErrFeedTypeNotDetected), errors.As(err, &xmlErr), errors.As(err, &jsonErr):
+		return domainfeed.ErrorKindParse
+	default:
+		return domainfeed.ErrorKindOther
+	}
+}
+
+// fetchError is returned when the feed can't be fetched or parsed, which
+// counts towards its health unlike the errors of the relay itself.
+type fetchError struct {
+	err error
+}
+
+func (e fetchError) Error() string {
+	return e.err.Error()
+}
+
+func (e fetchError) Unwrap() error {
+	return e.err
 }
 
 type definitionWithError struct {
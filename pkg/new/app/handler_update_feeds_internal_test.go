@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/xml"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/piraces/rsslay/pkg/events"
+	"github.com/piraces/rsslay/pkg/feed"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFetchError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected domainfeed.ErrorKind
+	}{
+		{
+			name:     "invalid URL",
+			err:      errors.Wrap(events.ErrInvalidFeedURL, "error getting the feed"),
+			expected: domainfeed.ErrorKindInvalidURL,
+		},
+		{
+			name:     "unknown host",
+			err:      &url.Error{Op: "Get", URL: "https://example.invalid", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}},
+			expected: domainfeed.ErrorKindDNS,
+		},
+		{
+			name:     "unknown certificate authority",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}},
+			expected: domainfeed.ErrorKindTLS,
+		},
+		{
+			name:     "certificate for another host",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}},
+			expected: domainfeed.ErrorKindTLS,
+		},
+		{
+			name:     "plain HTTP on the HTTPS port",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}},
+			expected: domainfeed.ErrorKindTLS,
+		},
+		{
+			name:     "HTTP status",
+			err:      errors.Wrap(feed.HTTPStatusError{StatusCode: http.StatusNotFound}, "error fetching the feed"),
+			expected: domainfeed.ErrorKindHTTP,
+		},
+		{
+			name:     "timeout",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded},
+			expected: domainfeed.ErrorKindTimeout,
+		},
+		{
+			name:     "connection refused",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("connect: connection refused")},
+			expected: domainfeed.ErrorKindConnection,
+		},
+		{
+			name:     "not a feed",
+			err:      gofeed.ErrFeedTypeNotDetected,
+			expected: domainfeed.ErrorKindParse,
+		},
+		{
+			name:     "invalid XML",
+			err:      &xml.SyntaxError{Msg: "unexpected EOF", Line: 1},
+			expected: domainfeed.ErrorKindParse,
+		},
+		{
+			name:     "invalid JSON",
+			err:      errors.Wrap(&json.SyntaxError{Offset: 1}, "error parsing the feed"),
+			expected: domainfeed.ErrorKindParse,
+		},
+		{
+			name:     "anything else",
+			err:      errors.New("something went wrong"),
+			expected: domainfeed.ErrorKindOther,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, classifyFetchError(testCase.err))
+		})
+	}
+}
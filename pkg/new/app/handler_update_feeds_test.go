@@ -0,0 +1,193 @@
+package app_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/database"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/keyring"
+	"github.com/piraces/rsslay/pkg/new/adapters"
+	"github.com/piraces/rsslay/pkg/new/app"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	domain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/signer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const someRSS = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Example</title>
+<description>An example feed</description>
+<item>
+<title>First</title>
+<link>https://example.com/first</link>
+<guid>https://example.com/first</guid>
+<pubDate>Mon, 01 May 2023 10:00:00 GMT</pubDate>
+<description>Hello</description>
+</item>
+</channel>
+</rss>`
+
+func TestUpdateFeedHealth(t *testing.T) {
+	testCases := []struct {
+		name         string
+		status       int
+		ownerSigner  error
+		expectHealth domainfeed.HealthState
+	}{
+		{
+			name:         "fetched",
+			status:       http.StatusOK,
+			expectHealth: domainfeed.HealthStateHealthy,
+		},
+		{
+			name:         "failing to fetch degrades the feed",
+			status:       http.StatusInternalServerError,
+			expectHealth: domainfeed.HealthStateDegraded,
+		},
+		{
+			name:         "failing to sign keeps the health",
+			status:       http.StatusOK,
+			ownerSigner:  errors.New("the remote signer is offline"),
+			expectHealth: domainfeed.HealthStateHealthy,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(testCase.status)
+				_, _ = w.Write([]byte(someRSS))
+			}))
+			t.Cleanup(server.Close)
+
+			db := migratedDatabase(t)
+			storage := adapters.NewFeedDefinitionStorage(db, someKeyring(t))
+			definition := someFeedDefinition(t, server.URL+"/"+t.Name())
+			require.NoError(t, storage.Put(definition))
+
+			if testCase.ownerSigner != nil {
+				owner := domainfeed.Owner{
+					PublicKey: somePublicKey(t),
+					Method:    domainfeed.VerificationMethodNIP05,
+					BunkerURL: "bunker://" + somePublicKey(t).Hex() + "?relay=wss://relay.example.com",
+				}
+				require.NoError(t, storage.SetOwner(definition.PublicKey(), owner))
+				definition.SetOwner(owner)
+			}
+
+			handler := app.NewHandlerUpdateFeeds(
+				domainfeed.HealthPolicy{SuspendAfter: 5, Backoff: time.Hour, MaxBackoff: 24 * time.Hour, DeleteAfter: time.Nanosecond},
+				nil,
+				false,
+				"",
+				"",
+				nil,
+				db,
+				storage,
+				someConverterSelector(t),
+				adapters.NewEventStorage(),
+				noopEventPublisher{},
+				adapters.NewBannedDomainStorage(db),
+				signer.NewLocal(),
+				failingOwnerSigners{err: testCase.ownerSigner},
+			)
+
+			err := handler.UpdateFeed(context.Background(), definition)
+			if testCase.status == http.StatusOK && testCase.ownerSigner == nil {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			stored, err := storage.Get(definition.PublicKey())
+			require.NoError(t, err, "feeds which can't be signed must not be deleted")
+			assert.Equal(t, testCase.expectHealth, stored.Health().State)
+		})
+	}
+}
+
+type failingOwnerSigners struct {
+	err error
+}
+
+func (s failingOwnerSigners) Signer(ctx context.Context, bunkerURL string) (signer.Signer, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return signer.NewLocal(), nil
+}
+
+func (s failingOwnerSigners) Remove(bunkerURL string) {
+}
+
+type noopEventPublisher struct{}
+
+func (noopEventPublisher) PublishNewEventCreated(evt domain.Event) {
+}
+
+func someConverterSelector(t *testing.T) *feed.ConverterSelector {
+	noteConverter, err := feed.NewNoteConverter(250)
+	require.NoError(t, err)
+	return feed.NewConverterSelector(feed.NewLongFormConverter(), noteConverter)
+}
+
+func someFeedDefinition(t *testing.T, address string) *domainfeed.FeedDefinition {
+	return someFeedDefinitionWithPrivateKey(t, address, nostr.GeneratePrivateKey())
+}
+
+func someFeedDefinitionWithPrivateKey(t *testing.T, address string, privateKeyHex string) *domainfeed.FeedDefinition {
+	a, err := domainfeed.NewAddress(address)
+	require.NoError(t, err)
+
+	privateKey, err := domain.NewPrivateKeyFromHex(privateKeyHex)
+	require.NoError(t, err)
+	publicKeyHex, err := nostr.GetPublicKey(privateKey.Hex())
+	require.NoError(t, err)
+	publicKey, err := domain.NewPublicKeyFromHex(publicKeyHex)
+	require.NoError(t, err)
+
+	definition, err := domainfeed.NewFeedDefinition(publicKey, privateKey, a, false)
+	require.NoError(t, err)
+	return definition
+}
+
+func somePublicKey(t *testing.T) domain.PublicKey {
+	publicKeyHex, err := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+	publicKey, err := domain.NewPublicKeyFromHex(publicKeyHex)
+	require.NoError(t, err)
+	return publicKey
+}
+
+func someKeyring(t *testing.T) *keyring.Keyring {
+	k, err := keyring.New(map[int]string{})
+	require.NoError(t, err)
+	return k
+}
+
+func migratedDatabase(t *testing.T) *sql.DB {
+	db, err := database.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// every connection would get its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	migrator, err := database.NewMigrator(db, ":memory:")
+	require.NoError(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	return db
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/feed"
+	"github.com/piraces/rsslay/pkg/new/domain"
+	feeddomain "github.com/piraces/rsslay/pkg/new/domain/feed"
+	nostrdomain "github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/signer"
+	"github.com/pkg/errors"
+)
+
+// DerivedKeys are the keys of a feed derived from an address with one of the
+// versions of the secret.
+type DerivedKeys struct {
+	Address   string
+	Version   int
+	PublicKey nostrdomain.PublicKey
+	// PrivateKey is zero if the keys were derived by a bunker.
+	PrivateKey nostrdomain.PrivateKey
+}
+
+// SecretKeyDeriver derives the keys of feeds in process with the secrets.
+type SecretKeyDeriver struct {
+	secrets domain.Secrets
+}
+
+func NewSecretKeyDeriver(secrets domain.Secrets) *SecretKeyDeriver {
+	return &SecretKeyDeriver{secrets: secrets}
+}
+
+func (d *SecretKeyDeriver) Derive(addresses ...string) ([]DerivedKeys, error) {
+	var derived []DerivedKeys
+	for _, secret := range d.secrets.All() {
+		for _, address := range addresses {
+			publicKey, privateKey, err := feedKeysFromString(address, secret.Secret)
+			if err != nil {
+				return nil, err
+			}
+			derived = append(derived, DerivedKeys{
+				Address:    address,
+				Version:    secret.Version,
+				PublicKey:  publicKey,
+				PrivateKey: privateKey,
+			})
+		}
+	}
+	return derived, nil
+}
+
+// feedKeys derives the keys of a new feed from its canonical address, so
+// that all the spellings of an address lead to the same profile, using the
+// newest secret.
+func feedKeys(address feeddomain.Address, keyDeriver KeyDeriver) (DerivedKeys, error) {
+	derived, err := keyDeriver.Derive(address.Canonical().String())
+	if err != nil {
+		return DerivedKeys{}, err
+	}
+	if len(derived) == 0 {
+		return DerivedKeys{}, errors.New("no keys were derived")
+	}
+	return derived[0], nil
+}
+
+func feedKeysFromString(address string, secret domain.Secret) (nostrdomain.PublicKey, nostrdomain.PrivateKey, error) {
+	privateKey := feed.PrivateKeyFromFeed(address, secret.String())
+	publicKey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return nostrdomain.PublicKey{}, nostrdomain.PrivateKey{}, errors.Wrap(err, "error creating a public key")
+	}
+
+	domainPublicKey, err := nostrdomain.NewPublicKeyFromHex(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nostrdomain.PublicKey{}, nostrdomain.PrivateKey{}, errors.Wrap(err, "error creating a public key")
+	}
+
+	domainPrivateKey, err := nostrdomain.NewPrivateKeyFromHex(privateKey)
+	if err != nil {
+		return nostrdomain.PublicKey{}, nostrdomain.PrivateKey{}, errors.Wrap(err, "error creating a private key")
+	}
+
+	return domainPublicKey, domainPrivateKey, nil
+}
+
+// newFeedDefinition creates a feed with the derived keys, without its
+// private key if it is held by a bunker.
+func newFeedDefinition(keys DerivedKeys, address feeddomain.Address, nitter bool) (*feeddomain.FeedDefinition, error) {
+	definition := feeddomain.NewFeedDefinitionWithoutPrivateKey(keys.PublicKey, address, nitter)
+	if !keys.PrivateKey.IsZero() {
+		var err error
+		definition, err = feeddomain.NewFeedDefinition(keys.PublicKey, keys.PrivateKey, address, nitter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	definition.SetKeyVersion(keys.Version)
+	return definition, nil
+}
+
+// signingKey identifies the feed to the signer. Feeds without a private key
+// are signed by a bunker which derives the key from the original address of
+// the feed, canonicalized or as it was submitted before addresses were
+// canonicalized.
+func signingKey(definition *feeddomain.FeedDefinition) signer.Key {
+	key := signer.Key{PublicKey: definition.PublicKey().Hex()}
+	if definition.HasPrivateKey() {
+		key.PrivateKey = definition.PrivateKey().Hex()
+		return key
+	}
+
+	original := definition.OriginalAddress()
+	key.Addresses = []string{original.Canonical().String()}
+	if original.String() != key.Addresses[0] {
+		key.Addresses = append(key.Addresses, original.String())
+	}
+	return key
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	rootdomain "github.com/piraces/rsslay/pkg/new/domain"
+	domainfeed "github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningKeyOfFeedsWhichMovedTwice(t *testing.T) {
+	secret, err := rootdomain.NewSecret("some secret")
+	require.NoError(t, err)
+	secrets, err := rootdomain.NewSecrets(rootdomain.VersionedSecret{Version: 1, Secret: secret})
+	require.NoError(t, err)
+	keyDeriver := NewSecretKeyDeriver(secrets)
+
+	original := someAddress(t, "https://example.com/feed/")
+	keys, err := feedKeys(original, keyDeriver)
+	require.NoError(t, err)
+
+	// the bunker holds the private key
+	definition := domainfeed.NewFeedDefinitionWithoutPrivateKey(keys.PublicKey, original, false)
+	definition.MoveTo(someAddress(t, "https://example.org/feed"), time.Unix(1000, 0))
+	definition.MoveTo(someAddress(t, "https://example.net/feed"), time.Unix(2000, 0))
+
+	key := signingKey(definition)
+	assert.Empty(t, key.PrivateKey)
+
+	// like the bunker, derive the keys from the addresses sent along
+	derived, err := keyDeriver.Derive(key.Addresses...)
+	require.NoError(t, err)
+
+	var found bool
+	for _, d := range derived {
+		if d.PublicKey.Equal(keys.PublicKey) {
+			found = true
+		}
+	}
+	assert.True(t, found, "the bunker derives the key from the addresses %v", key.Addresses)
+}
+
+func someAddress(t *testing.T, s string) domainfeed.Address {
+	address, err := domainfeed.NewAddress(s)
+	require.NoError(t, err)
+	return address
+}
@@ -0,0 +1,270 @@
+// Package auth describes who calls the APIs of the relay and what they are
+// allowed to do.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/pkg/errors"
+)
+
+type Scope string
+
+const (
+	// ScopeCreate allows creating feeds.
+	ScopeCreate Scope = "create"
+	// ScopeManage allows disabling, enabling, refreshing and deleting feeds.
+	ScopeManage Scope = "manage"
+	// ScopeAdmin allows everything including managing the relay itself.
+	ScopeAdmin Scope = "admin"
+)
+
+var ErrInvalidScope = errors.New("scopes must be create, manage or admin")
+
+type Scopes struct {
+	scopes map[Scope]bool
+}
+
+func NewScopes(scopes ...string) (Scopes, error) {
+	result := Scopes{scopes: make(map[Scope]bool)}
+	for _, s := range scopes {
+		switch scope := Scope(strings.ToLower(strings.TrimSpace(s))); scope {
+		case ScopeCreate, ScopeManage, ScopeAdmin:
+			result.scopes[scope] = true
+		default:
+			return Scopes{}, ErrInvalidScope
+		}
+	}
+	return result, nil
+}
+
+func MustNewScopes(scopes ...string) Scopes {
+	result, err := NewScopes(scopes...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Has reports whether the scopes include the scope, the admin scope includes
+// all of them.
+func (s Scopes) Has(scope Scope) bool {
+	return s.scopes[scope] || s.scopes[ScopeAdmin]
+}
+
+func (s Scopes) IsZero() bool {
+	return len(s.scopes) == 0
+}
+
+func (s Scopes) List() []string {
+	var result []string
+	for scope := range s.scopes {
+		result = append(result, string(scope))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s Scopes) String() string {
+	return strings.Join(s.List(), ",")
+}
+
+const apiKeyPrefix = "rsslay_"
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey is issued by the operator to programmatic clients. Only the hash of
+// its secret is kept, the token given to the client is "rsslay_<id>_<secret>".
+type APIKey struct {
+	ID     string
+	Name   string
+	Scopes Scopes
+	// Quota is the number of API requests allowed within the quota window, 0
+	// means the default quota.
+	Quota      int
+	SecretHash string
+	CreatedAt  time.Time
+}
+
+// NewAPIKey creates a key along with the token which is only known by the
+// client.
+func NewAPIKey(name string, scopes Scopes, quota int, createdAt time.Time) (APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIKey{}, "", errors.New("name can't be an empty string")
+	}
+
+	if scopes.IsZero() {
+		return APIKey{}, "", errors.New("at least one scope is required")
+	}
+
+	if quota < 0 {
+		return APIKey{}, "", errors.New("quota can't be negative")
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return APIKey{}, "", errors.Wrap(err, "error creating the id")
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return APIKey{}, "", errors.Wrap(err, "error creating the secret")
+	}
+
+	key := APIKey{
+		ID:         id,
+		Name:       name,
+		Scopes:     scopes,
+		Quota:      quota,
+		SecretHash: hashSecret(secret),
+		CreatedAt:  createdAt,
+	}
+	return key, apiKeyPrefix + id + "_" + secret, nil
+}
+
+// ParseAPIKeyToken splits a token into the id of its key and its secret.
+func ParseAPIKeyToken(token string) (string, string, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), "_")
+	if !strings.HasPrefix(token, apiKeyPrefix) || !ok || id == "" || secret == "" {
+		return "", "", ErrInvalidAPIKey
+	}
+	return id, secret, nil
+}
+
+func (k APIKey) Verify(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.SecretHash)) == 1
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type IdentityKind string
+
+const (
+	IdentityAnonymous IdentityKind = "ip"
+	IdentityNostr     IdentityKind = "pubkey"
+	IdentityAPIKey    IdentityKind = "key"
+	IdentityOperator  IdentityKind = "cli"
+)
+
+// anonymousScopes are the scopes of everyone, feeds can be created without
+// authenticating.
+var anonymousScopes = MustNewScopes(string(ScopeCreate))
+
+// Identity is the caller of an API.
+type Identity struct {
+	kind      IdentityKind
+	id        string
+	publicKey nostr.PublicKey
+	scopes    Scopes
+	quota     int
+	// clientIP is the address nostr identities sent their request from
+	clientIP string
+}
+
+// NewAnonymousIdentity identifies a client which didn't authenticate by its
+// IP address.
+func NewAnonymousIdentity(ip string) Identity {
+	return Identity{kind: IdentityAnonymous, id: ip, scopes: anonymousScopes}
+}
+
+// NewNostrIdentity identifies a client by the public key which signed its
+// NIP-98 authorization, the relay owner has the admin scope.
+func NewNostrIdentity(publicKey nostr.PublicKey, owner bool) Identity {
+	scopes := anonymousScopes
+	if owner {
+		scopes = MustNewScopes(string(ScopeAdmin))
+	}
+	return Identity{kind: IdentityNostr, id: publicKey.Hex(), publicKey: publicKey, scopes: scopes}
+}
+
+// WithClientIP records the address the request of a nostr identity was sent
+// from, see QuotaKey.
+func (i Identity) WithClientIP(ip string) Identity {
+	i.clientIP = ip
+	return i
+}
+
+func NewAPIKeyIdentity(key APIKey) Identity {
+	return Identity{kind: IdentityAPIKey, id: key.ID, scopes: key.Scopes, quota: key.Quota}
+}
+
+// NewOperatorIdentity identifies the operator of the relay running the
+// command line, they have the admin scope.
+func NewOperatorIdentity(user string) Identity {
+	return Identity{kind: IdentityOperator, id: user, scopes: MustNewScopes(string(ScopeAdmin))}
+}
+
+func (i Identity) Kind() IdentityKind {
+	return i.kind
+}
+
+// PublicKey is the public key of nostr identities.
+func (i Identity) PublicKey() (nostr.PublicKey, bool) {
+	return i.publicKey, i.kind == IdentityNostr
+}
+
+func (i Identity) Has(scope Scope) bool {
+	return i.scopes.Has(scope)
+}
+
+// Quota is the number of requests allowed within the quota window, 0 means
+// the default quota.
+func (i Identity) Quota() int {
+	return i.quota
+}
+
+func (i Identity) Anonymous() bool {
+	return i.kind == IdentityAnonymous
+}
+
+// QuotaKey identifies whose quota the requests of the caller count against.
+// Anyone can create nostr keys so the nostr identities other than the relay
+// owner share the quota of their address with the anonymous clients.
+func (i Identity) QuotaKey() string {
+	if i.kind == IdentityNostr && !i.Has(ScopeAdmin) && i.clientIP != "" {
+		return string(IdentityAnonymous) + ":" + i.clientIP
+	}
+	return i.String()
+}
+
+// String identifies the caller, for example "key:0123456789abcdef".
+func (i Identity) String() string {
+	return string(i.kind) + ":" + i.id
+}
+
+// NostrPublicKey returns the public key of a nostr identity from the string
+// identifying it.
+func NostrPublicKey(identity string) (nostr.PublicKey, bool) {
+	hex, ok := strings.CutPrefix(identity, string(IdentityNostr)+":")
+	if !ok {
+		return nostr.PublicKey{}, false
+	}
+
+	publicKey, err := nostr.NewPublicKeyFromHex(hex)
+	if err != nil {
+		return nostr.PublicKey{}, false
+	}
+	return publicKey, true
+}
@@ -0,0 +1,95 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/auth"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopes(t *testing.T) {
+	scopes, err := auth.NewScopes("manage", " Create ")
+	require.NoError(t, err)
+	assert.True(t, scopes.Has(auth.ScopeCreate))
+	assert.True(t, scopes.Has(auth.ScopeManage))
+	assert.False(t, scopes.Has(auth.ScopeAdmin))
+	assert.Equal(t, "create,manage", scopes.String())
+
+	admin := auth.MustNewScopes("admin")
+	assert.True(t, admin.Has(auth.ScopeCreate), "admin implies every scope")
+	assert.True(t, admin.Has(auth.ScopeManage), "admin implies every scope")
+
+	_, err = auth.NewScopes("create", "delete")
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+}
+
+func TestAPIKey(t *testing.T) {
+	key, token, err := auth.NewAPIKey("importer", auth.MustNewScopes("create"), 100, time.Unix(1700000000, 0))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "rsslay_"+key.ID+"_"))
+	assert.NotContains(t, key.SecretHash, strings.TrimPrefix(token, "rsslay_"+key.ID+"_"), "only the hash of the secret is kept")
+
+	id, secret, err := auth.ParseAPIKeyToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, id)
+	assert.True(t, key.Verify(secret))
+	assert.False(t, key.Verify(secret+"0"))
+
+	for _, invalid := range []string{"", "rsslay_", "rsslay_id", "rsslay__secret", "other_id_secret"} {
+		_, _, err := auth.ParseAPIKeyToken(invalid)
+		assert.ErrorIs(t, err, auth.ErrInvalidAPIKey, invalid)
+	}
+
+	_, _, err = auth.NewAPIKey(" ", auth.MustNewScopes("create"), 0, time.Now())
+	assert.Error(t, err)
+	_, _, err = auth.NewAPIKey("importer", auth.MustNewScopes(), 0, time.Now())
+	assert.Error(t, err)
+	_, _, err = auth.NewAPIKey("importer", auth.MustNewScopes("create"), -1, time.Now())
+	assert.Error(t, err)
+}
+
+func TestIdentities(t *testing.T) {
+	anonymous := auth.NewAnonymousIdentity("192.0.2.1")
+	assert.True(t, anonymous.Anonymous())
+	assert.True(t, anonymous.Has(auth.ScopeCreate))
+	assert.False(t, anonymous.Has(auth.ScopeManage))
+	assert.Equal(t, "ip:192.0.2.1", anonymous.String())
+
+	publicKey, err := nostr.NewPublicKeyFromHex("73e247ee8c4ff09a50525bed7b0869c371864c0bf2b4d6a2639acaed07613958")
+	require.NoError(t, err)
+	user := auth.NewNostrIdentity(publicKey, false)
+	assert.False(t, user.Anonymous())
+	assert.False(t, user.Has(auth.ScopeManage))
+	assert.Equal(t, "pubkey:"+publicKey.Hex(), user.String())
+	assert.True(t, auth.NewNostrIdentity(publicKey, true).Has(auth.ScopeAdmin), "the relay owner has every scope")
+
+	assert.Equal(t, user.String(), user.QuotaKey())
+	assert.Equal(t, anonymous.QuotaKey(), user.WithClientIP("192.0.2.1").QuotaKey(), "new keys don't get a quota of their own")
+	assert.Equal(t, "pubkey:"+publicKey.Hex(), user.WithClientIP("192.0.2.1").String())
+	owner := auth.NewNostrIdentity(publicKey, true).WithClientIP("192.0.2.1")
+	assert.Equal(t, owner.String(), owner.QuotaKey())
+
+	parsed, ok := auth.NostrPublicKey(user.String())
+	require.True(t, ok)
+	assert.Equal(t, publicKey, parsed)
+	_, ok = auth.NostrPublicKey(anonymous.String())
+	assert.False(t, ok)
+
+	key, _, err := auth.NewAPIKey("importer", auth.MustNewScopes("manage"), 100, time.Now())
+	require.NoError(t, err)
+	client := auth.NewAPIKeyIdentity(key)
+	assert.Equal(t, auth.IdentityAPIKey, client.Kind())
+	assert.True(t, client.Has(auth.ScopeManage))
+	assert.False(t, client.Has(auth.ScopeAdmin))
+	assert.Equal(t, 100, client.Quota())
+	assert.Equal(t, "key:"+key.ID, client.String())
+
+	operator := auth.NewOperatorIdentity("root")
+	assert.False(t, operator.Anonymous())
+	assert.True(t, operator.Has(auth.ScopeAdmin))
+	assert.Equal(t, "cli:root", operator.String())
+}
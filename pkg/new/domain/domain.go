@@ -1,6 +1,10 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
 
 type Secret struct {
 	s string
@@ -17,3 +21,62 @@ func NewSecret(s string) (Secret, error) {
 func (s Secret) String() string {
 	return s.s
 }
+
+// VersionedSecret is one of the secrets used to derive the keys of feeds.
+type VersionedSecret struct {
+	Version int
+	Secret  Secret
+}
+
+// Secrets are all the secrets which the keys of feeds were derived from.
+// New feeds use the newest secret while existing feeds keep the version
+// their keys were derived with so that secrets can be rotated without
+// changing the keys of existing feeds.
+type Secrets struct {
+	versions []VersionedSecret
+}
+
+func NewSecrets(versions ...VersionedSecret) (Secrets, error) {
+	if len(versions) == 0 {
+		return Secrets{}, errors.New("at least one secret is required")
+	}
+
+	seen := make(map[int]bool)
+	for _, v := range versions {
+		if v.Version <= 0 {
+			return Secrets{}, fmt.Errorf("invalid secret version %d", v.Version)
+		}
+
+		if seen[v.Version] {
+			return Secrets{}, fmt.Errorf("duplicated secret version %d", v.Version)
+		}
+		seen[v.Version] = true
+	}
+
+	sorted := append([]VersionedSecret(nil), versions...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version > sorted[j].Version
+	})
+
+	return Secrets{versions: sorted}, nil
+}
+
+// Latest is the secret used for new feeds.
+func (s Secrets) Latest() VersionedSecret {
+	return s.versions[0]
+}
+
+// All returns the secrets, the newest first.
+func (s Secrets) All() []VersionedSecret {
+	return s.versions
+}
+
+// Version returns the secret with the given version.
+func (s Secrets) Version(version int) (VersionedSecret, bool) {
+	for _, v := range s.versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return VersionedSecret{}, false
+}
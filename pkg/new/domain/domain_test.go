@@ -0,0 +1,41 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/new/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecrets(t *testing.T) {
+	secret1, err := domain.NewSecret("one")
+	require.NoError(t, err)
+	secret2, err := domain.NewSecret("two")
+	require.NoError(t, err)
+
+	secrets, err := domain.NewSecrets(
+		domain.VersionedSecret{Version: 1, Secret: secret1},
+		domain.VersionedSecret{Version: 2, Secret: secret2},
+	)
+	require.NoError(t, err)
+	require.Equal(t, domain.VersionedSecret{Version: 2, Secret: secret2}, secrets.Latest())
+	require.Equal(t, []domain.VersionedSecret{{Version: 2, Secret: secret2}, {Version: 1, Secret: secret1}}, secrets.All())
+
+	first, ok := secrets.Version(1)
+	require.True(t, ok)
+	require.Equal(t, domain.VersionedSecret{Version: 1, Secret: secret1}, first)
+	_, ok = secrets.Version(3)
+	require.False(t, ok)
+
+	_, err = domain.NewSecrets()
+	require.Error(t, err)
+
+	_, err = domain.NewSecrets(domain.VersionedSecret{Version: 0, Secret: secret1})
+	require.Error(t, err)
+
+	_, err = domain.NewSecrets(
+		domain.VersionedSecret{Version: 1, Secret: secret1},
+		domain.VersionedSecret{Version: 1, Secret: secret2},
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package feed
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type AddressRuleAction string
+
+const (
+	AddressRuleDeny  AddressRuleAction = "deny"
+	AddressRuleAllow AddressRuleAction = "allow"
+)
+
+func NewAddressRuleAction(s string) (AddressRuleAction, error) {
+	switch action := AddressRuleAction(s); action {
+	case AddressRuleDeny, AddressRuleAllow:
+		return action, nil
+	default:
+		return "", errors.New("action must be deny or allow")
+	}
+}
+
+// AddressPattern is either a bare domain name, which matches the domain and
+// all of its subdomains, or a pattern of the host and path of the addresses
+// where "*" matches anything, for example "*.example.com/spam/*".
+type AddressPattern struct {
+	s      string
+	domain Domain
+	re     *regexp.Regexp
+}
+
+func NewAddressPattern(s string) (AddressPattern, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return AddressPattern{}, errors.New("pattern can't be an empty string")
+	}
+
+	if !strings.ContainsAny(s, "/*") {
+		domain, err := NewDomain(s)
+		if err != nil {
+			return AddressPattern{}, err
+		}
+		return AddressPattern{s: domain.String(), domain: domain}, nil
+	}
+
+	if strings.Contains(s, "://") {
+		return AddressPattern{}, errors.New("pattern must not have a scheme")
+	}
+
+	host, path, hasPath := strings.Cut(s, "/")
+	s = strings.ToLower(host)
+	if hasPath {
+		s += "/" + path
+	}
+
+	// patterns without a path match any path
+	expression := s
+	if !hasPath {
+		expression += "/*"
+	}
+
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(expression), `\*`, `.*`)
+	return AddressPattern{s: s, re: regexp.MustCompile("^" + quoted + "$")}, nil
+}
+
+func (p AddressPattern) String() string {
+	return p.s
+}
+
+func (p AddressPattern) IsZero() bool {
+	return p.s == ""
+}
+
+func (p AddressPattern) Matches(address Address) bool {
+	if p.re == nil {
+		return !p.IsZero() && p.domain.Matches(address)
+	}
+
+	u, err := url.Parse(address.String())
+	if err != nil {
+		return false
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return p.re.MatchString(strings.ToLower(u.Host) + path)
+}
+
+type AddressRule struct {
+	Pattern   AddressPattern
+	Action    AddressRuleAction
+	Reason    string
+	CreatedAt time.Time
+}
+
+type AddressRules []AddressRule
+
+// Accepts reports whether new feeds can be created from the address. Deny
+// rules always win, once there are allow rules only the addresses matching
+// one of them are accepted.
+func (rules AddressRules) Accepts(address Address) (bool, AddressRule) {
+	allowList := false
+	var allowed bool
+
+	for _, rule := range rules {
+		switch rule.Action {
+		case AddressRuleDeny:
+			if rule.Pattern.Matches(address) {
+				return false, rule
+			}
+		case AddressRuleAllow:
+			allowList = true
+			allowed = allowed || rule.Pattern.Matches(address)
+		}
+	}
+
+	return !allowList || allowed, AddressRule{}
+}
@@ -0,0 +1,74 @@
+package feed_test
+
+import (
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressPatternMatches(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		address string
+		matches bool
+	}{
+		{pattern: "example.com", address: "https://example.com/feed", matches: true},
+		{pattern: "example.com", address: "https://blog.example.com/feed", matches: true},
+		{pattern: "example.com", address: "https://notexample.com/feed", matches: false},
+		{pattern: "*.blogspot.com", address: "https://spam.blogspot.com/feeds/posts/default", matches: true},
+		{pattern: "*.blogspot.com", address: "https://blogspot.com/feed", matches: false},
+		{pattern: "Example.com/spam/*", address: "https://example.com/spam/feed.xml", matches: true},
+		{pattern: "example.com/spam/*", address: "https://example.com/ham/feed.xml", matches: false},
+		{pattern: "*/wp-json/*", address: "https://example.org/wp-json/feed", matches: true},
+		{pattern: "example.com/", address: "https://example.com", matches: true},
+		{pattern: "localhost:8080/*", address: "http://localhost:8080/feed", matches: true},
+	}
+
+	for _, tc := range testCases {
+		pattern, err := feed.NewAddressPattern(tc.pattern)
+		require.NoError(t, err, tc.pattern)
+
+		address, err := feed.NewAddress(tc.address)
+		require.NoError(t, err)
+
+		require.Equal(t, tc.matches, pattern.Matches(address), "%s %s", tc.pattern, tc.address)
+	}
+
+	for _, invalid := range []string{"", "https://example.com/*", "exa mple.com"} {
+		_, err := feed.NewAddressPattern(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestAddressRulesAccepts(t *testing.T) {
+	rule := func(pattern string, action feed.AddressRuleAction) feed.AddressRule {
+		p, err := feed.NewAddressPattern(pattern)
+		require.NoError(t, err)
+		return feed.AddressRule{Pattern: p, Action: action}
+	}
+	address := func(s string) feed.Address {
+		a, err := feed.NewAddress(s)
+		require.NoError(t, err)
+		return a
+	}
+
+	accepted, _ := feed.AddressRules(nil).Accepts(address("https://example.com/feed"))
+	require.True(t, accepted)
+
+	rules := feed.AddressRules{
+		rule("example.com", feed.AddressRuleAllow),
+		rule("example.com/spam/*", feed.AddressRuleDeny),
+	}
+
+	accepted, _ = rules.Accepts(address("https://example.com/feed"))
+	require.True(t, accepted)
+
+	accepted, matched := rules.Accepts(address("https://example.com/spam/feed"))
+	require.False(t, accepted)
+	require.Equal(t, "example.com/spam/*", matched.Pattern.String())
+
+	accepted, matched = rules.Accepts(address("https://example.org/feed"))
+	require.False(t, accepted)
+	require.True(t, matched.Pattern.IsZero())
+}
@@ -0,0 +1,31 @@
+package feed
+
+import "github.com/piraces/rsslay/pkg/new/domain/nostr"
+
+// MaxItemGUIDs is the number of items of each feed which are remembered to
+// detect duplicated feeds.
+const MaxItemGUIDs = 100
+
+// ItemMatch is the number of some given item GUIDs which a feed has.
+type ItemMatch struct {
+	PublicKey nostr.PublicKey
+	Shared    int
+}
+
+// SharedItems is the number of item GUIDs which two feeds have in common.
+type SharedItems struct {
+	A, B           nostr.PublicKey
+	Shared         int
+	ItemsA, ItemsB int
+}
+
+func (s SharedItems) Duplicate() bool {
+	return IsDuplicate(s.Shared, min(s.ItemsA, s.ItemsB))
+}
+
+// IsDuplicate tells if a feed with the given number of items is a duplicate
+// of a feed which has most of those items. Feeds often share a few items, for
+// example when one aggregates the other, so some overlap is tolerated.
+func IsDuplicate(shared, items int) bool {
+	return items > 0 && shared*5 >= items*4
+}
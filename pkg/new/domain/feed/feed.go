@@ -2,16 +2,44 @@ package feed
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/piraces/rsslay/pkg/helpers"
 	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"golang.org/x/exp/slices"
+)
+
+// trackingParameters are dropped from canonical addresses along with the
+// utm_* ones.
+var trackingParameters = []string{"fbclid", "gclid", "dclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid", "_ga"}
+
+var (
+	ErrFeedDefinitionNotFound = errors.New("feed definition not found")
+	ErrSlugTaken              = errors.New("slug is already used by another feed")
 )
 
 type FeedDefinition struct {
 	publicKey  nostr.PublicKey
 	privateKey nostr.PrivateKey
+	keyVersion int
 	address    Address
+	original   Address
 	nitter     bool
+	disabled   bool
+	pending    bool
+	slug       Slug
+	metadata   Metadata
+	health     Health
+	move       Move
+	owner      Owner
+	profile    Profile
+	submitter  string
 }
 
 func NewFeedDefinition(publicKey nostr.PublicKey, privateKey nostr.PrivateKey, address Address, nitter bool) (*FeedDefinition, error) {
@@ -19,17 +47,54 @@ func NewFeedDefinition(publicKey nostr.PublicKey, privateKey nostr.PrivateKey, a
 		return nil, errors.New("public/private key mismatch")
 	}
 
-	return &FeedDefinition{publicKey: publicKey, privateKey: privateKey, address: address, nitter: nitter}, nil
+	return &FeedDefinition{
+		publicKey:  publicKey,
+		privateKey: privateKey,
+		keyVersion: 1,
+		address:    address,
+		original:   address,
+		nitter:     nitter,
+		health:     Health{State: HealthStateHealthy},
+	}, nil
+}
+
+// NewFeedDefinitionWithoutPrivateKey creates a feed whose private key is
+// held by a remote signer.
+func NewFeedDefinitionWithoutPrivateKey(publicKey nostr.PublicKey, address Address, nitter bool) *FeedDefinition {
+	return &FeedDefinition{
+		publicKey:  publicKey,
+		keyVersion: 1,
+		address:    address,
+		original:   address,
+		nitter:     nitter,
+		health:     Health{State: HealthStateHealthy},
+	}
 }
 
 func (f FeedDefinition) PublicKey() nostr.PublicKey {
 	return f.publicKey
 }
 
+// PrivateKey is zero if the private key is held by a remote signer.
 func (f FeedDefinition) PrivateKey() nostr.PrivateKey {
 	return f.privateKey
 }
 
+func (f FeedDefinition) HasPrivateKey() bool {
+	return !f.privateKey.IsZero()
+}
+
+// KeyVersion is the version of the secret which the keys of the feed were
+// derived from, zero for keys imported from another instance which aren't
+// derived from any of the secrets.
+func (f FeedDefinition) KeyVersion() int {
+	return f.keyVersion
+}
+
+func (f *FeedDefinition) SetKeyVersion(version int) {
+	f.keyVersion = version
+}
+
 func (f FeedDefinition) Address() Address {
 	return f.address
 }
@@ -38,6 +103,153 @@ func (f FeedDefinition) Nitter() bool {
 	return f.nitter
 }
 
+// Disabled feeds are neither fetched nor served.
+func (f FeedDefinition) Disabled() bool {
+	return f.disabled
+}
+
+func (f *FeedDefinition) SetDisabled(disabled bool) {
+	f.disabled = disabled
+}
+
+// Pending feeds were submitted to a moderated relay and await approval, they
+// are neither fetched nor served until then.
+func (f FeedDefinition) Pending() bool {
+	return f.pending
+}
+
+func (f *FeedDefinition) SetPending(pending bool) {
+	f.pending = pending
+}
+
+// Slug is the name of the feed in its NIP-05 identifier. Feeds created
+// before slugs were introduced may have an empty one.
+func (f FeedDefinition) Slug() Slug {
+	return f.slug
+}
+
+func (f *FeedDefinition) SetSlug(slug Slug) {
+	f.slug = slug
+}
+
+// Metadata describes the feed as of its last fetch.
+func (f FeedDefinition) Metadata() Metadata {
+	return f.metadata
+}
+
+func (f *FeedDefinition) SetMetadata(metadata Metadata) {
+	f.metadata = metadata
+}
+
+func (f FeedDefinition) Health() Health {
+	return f.health
+}
+
+func (f *FeedDefinition) SetHealth(health Health) {
+	f.health = health
+}
+
+// Move is zero if the feed never moved.
+func (f FeedDefinition) Move() Move {
+	return f.move
+}
+
+func (f *FeedDefinition) SetMove(move Move) {
+	f.move = move
+}
+
+// OriginalAddress is the address the feed was created with, which its keys
+// were derived from. Unlike the address it doesn't change when the feed
+// moves.
+func (f FeedDefinition) OriginalAddress() Address {
+	return f.original
+}
+
+func (f *FeedDefinition) SetOriginalAddress(address Address) {
+	f.original = address
+}
+
+// MoveTo changes the address the feed is fetched from and records the
+// previous one. The keys stay the ones derived from the original address so
+// that followers keep receiving the feed.
+func (f *FeedDefinition) MoveTo(address Address, at time.Time) {
+	f.move = Move{From: f.address, At: at}
+	f.address = address
+}
+
+// Owner is zero unless the publisher verified owning the feed.
+func (f FeedDefinition) Owner() Owner {
+	return f.owner
+}
+
+func (f *FeedDefinition) SetOwner(owner Owner) {
+	f.owner = owner
+}
+
+// Profile is set by the owner of the feed to override its profile.
+func (f FeedDefinition) Profile() Profile {
+	return f.profile
+}
+
+func (f *FeedDefinition) SetProfile(profile Profile) {
+	f.profile = profile
+}
+
+// Submitter identifies the authenticated client which created the feed, for
+// example "key:0123456789abcdef". It is empty for feeds created anonymously.
+func (f FeedDefinition) Submitter() string {
+	return f.submitter
+}
+
+func (f *FeedDefinition) SetSubmitter(submitter string) {
+	f.submitter = submitter
+}
+
+// Move records the last time the publisher moved the feed to a new address,
+// From is the address it moved from.
+type Move struct {
+	From Address
+	At   time.Time
+}
+
+func (m Move) IsZero() bool {
+	return m.At.IsZero()
+}
+
+// Metadata is refreshed every time a feed is fetched. The descriptive fields
+// keep the values of the last successful fetch when fetching fails.
+type Metadata struct {
+	Title       string
+	Description string
+	Link        string
+	Image       string
+	Language    string
+	ItemCount   int
+
+	// LastFetchedAt and LastSuccessAt are zero if the feed was never
+	// (successfully) fetched.
+	LastFetchedAt time.Time
+	LastSuccessAt time.Time
+
+	// LastError is empty if the last fetch succeeded.
+	LastError string
+}
+
+// Fetched returns the metadata after a successful fetch.
+func (m Metadata) Fetched(fetched Metadata, fetchedAt time.Time) Metadata {
+	fetched.LastFetchedAt = fetchedAt
+	fetched.LastSuccessAt = fetchedAt
+	fetched.LastError = ""
+	return fetched
+}
+
+// Failed returns the metadata after a failed fetch.
+func (m Metadata) Failed(err error, fetchedAt time.Time) Metadata {
+	m.LastFetchedAt = fetchedAt
+	m.LastError = err.Error()
+	return m
+}
+
 type Address struct {
 	s string
 }
@@ -54,6 +266,171 @@ func NewAddress(s string) (Address, error) {
 	return Address{s: s}, nil
 }
 
+func (a Address) IsZero() bool {
+	return a.s == ""
+}
+
 func (a Address) String() string {
 	return a.s
 }
+
+// Canonical returns the same address for the spellings of an address which
+// most likely serve the same feed. The scheme is always https, the host is
+// lowercased and loses its "www." prefix and default port, trailing slashes,
+// fragments and tracking parameters are dropped and the remaining query
+// parameters are sorted.
+func (a Address) Canonical() Address {
+	u, err := url.Parse(a.s)
+	if err != nil {
+		return a
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	query := u.Query()
+	for key := range query {
+		if isTrackingParameter(key) {
+			query.Del(key)
+		}
+	}
+
+	u.Scheme = "https"
+	u.Host = host
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return Address{s: u.String()}
+}
+
+func isTrackingParameter(key string) bool {
+	key = strings.ToLower(key)
+	return strings.HasPrefix(key, "utm_") || slices.Contains(trackingParameters, key)
+}
+
+func (a Address) Host() string {
+	u, err := url.Parse(a.s)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Domain is a domain name which matches itself and all of its subdomains.
+type Domain struct {
+	s string
+}
+
+func NewDomain(s string) (Domain, error) {
+	s = strings.Trim(strings.ToLower(strings.TrimSpace(s)), ".")
+	if s == "" {
+		return Domain{}, errors.New("domain can't be an empty string")
+	}
+
+	if strings.ContainsAny(s, "/:@ ") {
+		return Domain{}, errors.New("domain must be a bare host name")
+	}
+
+	return Domain{s: s}, nil
+}
+
+func (d Domain) String() string {
+	return d.s
+}
+
+func (d Domain) Matches(address Address) bool {
+	host := address.Host()
+	return host == d.s || strings.HasSuffix(host, "."+d.s)
+}
+
+type BannedDomain struct {
+	Domain    Domain
+	Reason    string
+	CreatedAt time.Time
+}
+
+const maxSlugLength = 64
+
+var (
+	invalidSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+	validSlug             = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+	// ignoredSlugSegments are dropped from the end of the address when
+	// generating a slug as they say nothing about the feed.
+	ignoredSlugSegments = []string{"feed", "feeds", "rss", "atom", "xml", "index"}
+)
+
+// Slug is a valid NIP-05 local part, "_" is reserved for the relay owner.
+type Slug struct {
+	s string
+}
+
+func NewSlug(s string) (Slug, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return Slug{}, errors.New("slug can't be an empty string")
+	}
+
+	if len(s) > maxSlugLength {
+		return Slug{}, fmt.Errorf("slug can't be longer than %d characters", maxSlugLength)
+	}
+
+	if !validSlug.MatchString(s) {
+		return Slug{}, errors.New("slug can only contain letters, numbers, '.', '-' and '_'")
+	}
+
+	return Slug{s: s}, nil
+}
+
+// NewSlugFromAddress generates a readable slug such as "example-com-blog"
+// from an address such as "https://www.example.com/blog/feed.xml".
+func NewSlugFromAddress(address Address) (Slug, error) {
+	u, err := url.Parse(address.String())
+	if err != nil {
+		return Slug{}, errors.New("invalid address")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."), ".")
+	for _, segment := range strings.Split(strings.ToLower(u.Path), "/") {
+		segment = strings.TrimSuffix(segment, path.Ext(segment))
+		if segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+
+	for len(parts) > 1 && slices.Contains(ignoredSlugSegments, parts[len(parts)-1]) {
+		parts = parts[:len(parts)-1]
+	}
+
+	s := strings.Trim(invalidSlugCharacters.ReplaceAllString(strings.Join(parts, "-"), "-"), "-")
+	if len(s) > maxSlugLength {
+		s = strings.TrimRight(s[:maxSlugLength], "-")
+	}
+
+	return NewSlug(s)
+}
+
+// WithSuffix is used to resolve collisions between slugs.
+func (s Slug) WithSuffix(n int) Slug {
+	suffix := fmt.Sprintf("-%d", n)
+	base := s.s
+	if len(base)+len(suffix) > maxSlugLength {
+		base = strings.TrimRight(base[:maxSlugLength-len(suffix)], "-")
+	}
+	return Slug{s: base + suffix}
+}
+
+func (s Slug) IsZero() bool {
+	return s.s == ""
+}
+
+func (s Slug) String() string {
+	return s.s
+}
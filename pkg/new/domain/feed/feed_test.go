@@ -0,0 +1,136 @@
+package feed_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSlugFromAddress(t *testing.T) {
+	testCases := map[string]string{
+		"https://example.com/blog/feed":                 "example-com-blog",
+		"https://www.example.com/blog/feed.xml":         "example-com-blog",
+		"https://example.com/rss":                       "example-com",
+		"https://news.example.org/Tech_News.atom":       "news-example-org-tech-news",
+		"https://example.com/?feed=rss2":                "example-com",
+		"https://" + strings.Repeat("a", 100) + ".com/": strings.Repeat("a", 64),
+	}
+
+	for rawAddress, expected := range testCases {
+		t.Run(rawAddress, func(t *testing.T) {
+			address, err := feed.NewAddress(rawAddress)
+			require.NoError(t, err)
+
+			slug, err := feed.NewSlugFromAddress(address)
+			require.NoError(t, err)
+			require.Equal(t, expected, slug.String())
+		})
+	}
+}
+
+func TestAddressCanonical(t *testing.T) {
+	testCases := []struct {
+		address   string
+		canonical string
+	}{
+		{address: "https://example.com/feed", canonical: "https://example.com/feed"},
+		{address: "http://example.com/feed", canonical: "https://example.com/feed"},
+		{address: "https://www.Example.COM/feed/", canonical: "https://example.com/feed"},
+		{address: "https://example.com:443/feed#top", canonical: "https://example.com/feed"},
+		{address: "http://example.com:8080/feed", canonical: "https://example.com:8080/feed"},
+		{address: "https://example.com/", canonical: "https://example.com"},
+		{address: "https://example.com/feed?b=2&a=1", canonical: "https://example.com/feed?a=1&b=2"},
+		{address: "https://example.com/feed?utm_source=x&format=rss&fbclid=y", canonical: "https://example.com/feed?format=rss"},
+		{address: "http://[::1]/feed", canonical: "https://[::1]/feed"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.address, func(t *testing.T) {
+			address, err := feed.NewAddress(testCase.address)
+			require.NoError(t, err)
+			require.Equal(t, testCase.canonical, address.Canonical().String())
+		})
+	}
+}
+
+func TestNewSlug(t *testing.T) {
+	for _, valid := range []string{"a", "example-com", "my.feed_1", "Example"} {
+		_, err := feed.NewSlug(valid)
+		require.NoError(t, err, valid)
+	}
+
+	for _, invalid := range []string{"", "_", "-a", "a b", "a@b", strings.Repeat("a", 65)} {
+		_, err := feed.NewSlug(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestSlugWithSuffix(t *testing.T) {
+	slug, err := feed.NewSlug("example-com")
+	require.NoError(t, err)
+	require.Equal(t, "example-com-2", slug.WithSuffix(2).String())
+
+	long, err := feed.NewSlug(strings.Repeat("a", 64))
+	require.NoError(t, err)
+	require.Len(t, long.WithSuffix(10).String(), 64)
+}
+
+func TestMetadataKeepsTheLastSuccessfulFetchOnFailures(t *testing.T) {
+	fetchedAt := time.Unix(1000, 0)
+	metadata := feed.Metadata{}.Fetched(feed.Metadata{Title: "title", ItemCount: 2}, fetchedAt)
+	require.Equal(t, feed.Metadata{Title: "title", ItemCount: 2, LastFetchedAt: fetchedAt, LastSuccessAt: fetchedAt}, metadata)
+
+	failedAt := time.Unix(2000, 0)
+	metadata = metadata.Failed(errors.New("timeout"), failedAt)
+	require.Equal(t, feed.Metadata{Title: "title", ItemCount: 2, LastFetchedAt: failedAt, LastSuccessAt: fetchedAt, LastError: "timeout"}, metadata)
+
+	metadata = metadata.Fetched(feed.Metadata{Title: "new title"}, time.Unix(3000, 0))
+	require.Empty(t, metadata.LastError)
+	require.Equal(t, "new title", metadata.Title)
+}
+
+func TestMoveToKeepsTheKeys(t *testing.T) {
+	privateKey, err := nostr.NewPrivateKeyFromHex("4d0888c07093941c9db16fcffb96fdf8af49a6839e865ea6110c7ab7cbd2d3d3")
+	require.NoError(t, err)
+	publicKey, err := nostr.NewPublicKeyFromHex("73e247ee8c4ff09a50525bed7b0869c371864c0bf2b4d6a2639acaed07613958")
+	require.NoError(t, err)
+	oldAddress, err := feed.NewAddress("https://old.example/rss")
+	require.NoError(t, err)
+	newAddress, err := feed.NewAddress("https://new.example/feed.xml")
+	require.NoError(t, err)
+
+	definition, err := feed.NewFeedDefinition(publicKey, privateKey, oldAddress, false)
+	require.NoError(t, err)
+	require.True(t, definition.Move().IsZero())
+
+	movedAt := time.Unix(1000, 0)
+	definition.MoveTo(newAddress, movedAt)
+
+	require.Equal(t, newAddress, definition.Address())
+	require.Equal(t, feed.Move{From: oldAddress, At: movedAt}, definition.Move())
+	require.Equal(t, oldAddress, definition.OriginalAddress())
+	require.Equal(t, publicKey, definition.PublicKey())
+	require.Equal(t, privateKey, definition.PrivateKey())
+
+	latestAddress, err := feed.NewAddress("https://latest.example/atom.xml")
+	require.NoError(t, err)
+	definition.MoveTo(latestAddress, movedAt.Add(time.Hour))
+
+	require.Equal(t, latestAddress, definition.Address())
+	require.Equal(t, feed.Move{From: newAddress, At: movedAt.Add(time.Hour)}, definition.Move())
+	require.Equal(t, oldAddress, definition.OriginalAddress(), "the keys were derived from the first address")
+}
+
+func TestIsDuplicate(t *testing.T) {
+	require.False(t, feed.IsDuplicate(0, 0))
+	require.False(t, feed.IsDuplicate(0, 10))
+	require.False(t, feed.IsDuplicate(7, 10))
+	require.True(t, feed.IsDuplicate(8, 10))
+	require.True(t, feed.IsDuplicate(1, 1))
+	require.True(t, feed.SharedItems{Shared: 4, ItemsA: 5, ItemsB: 50}.Duplicate())
+}
@@ -0,0 +1,107 @@
+package feed
+
+import "time"
+
+type HealthState string
+
+const (
+	HealthStateHealthy   HealthState = "healthy"
+	HealthStateDegraded  HealthState = "degraded"
+	HealthStateSuspended HealthState = "suspended"
+)
+
+// ErrorKind classifies the reason why a feed couldn't be fetched.
+type ErrorKind string
+
+const (
+	ErrorKindDNS        ErrorKind = "dns"
+	ErrorKindTLS        ErrorKind = "tls"
+	ErrorKindHTTP       ErrorKind = "http"
+	ErrorKindTimeout    ErrorKind = "timeout"
+	ErrorKindConnection ErrorKind = "connection"
+	ErrorKindParse      ErrorKind = "parse"
+	ErrorKindInvalidURL ErrorKind = "invalid_url"
+	ErrorKindOther      ErrorKind = "other"
+)
+
+// Health tracks the consecutive failures of a feed.
+type Health struct {
+	State               HealthState
+	ConsecutiveFailures int
+	ErrorKind           ErrorKind
+
+	// FailingSince and NextFetchAt are zero for healthy feeds.
+	FailingSince time.Time
+	NextFetchAt  time.Time
+}
+
+func (h Health) Healthy() bool {
+	return h.ConsecutiveFailures == 0
+}
+
+// Due returns true if the feed should be fetched during an update happening
+// at the given time.
+func (h Health) Due(now time.Time) bool {
+	return !now.Before(h.NextFetchAt)
+}
+
+// HealthPolicy decides how failing feeds are retried. Failing feeds are
+// degraded and retried with an exponential backoff, after SuspendAfter
+// consecutive failures they are suspended and only retried every MaxBackoff.
+type HealthPolicy struct {
+	SuspendAfter int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+
+	// DeleteAfter is the time after which failing feeds are deleted, zero
+	// means they are never deleted.
+	DeleteAfter time.Duration
+}
+
+func (p HealthPolicy) Succeeded() Health {
+	return Health{State: HealthStateHealthy}
+}
+
+func (p HealthPolicy) Failed(previous Health, kind ErrorKind, failedAt time.Time) Health {
+	health := Health{
+		State:               HealthStateDegraded,
+		ConsecutiveFailures: previous.ConsecutiveFailures + 1,
+		ErrorKind:           kind,
+		FailingSince:        previous.FailingSince,
+	}
+
+	if health.FailingSince.IsZero() {
+		health.FailingSince = failedAt
+	}
+
+	if health.ConsecutiveFailures >= p.SuspendAfter {
+		health.State = HealthStateSuspended
+	}
+
+	health.NextFetchAt = failedAt.Add(p.backoff(health))
+	return health
+}
+
+func (p HealthPolicy) backoff(health Health) time.Duration {
+	if health.State == HealthStateSuspended {
+		return p.MaxBackoff
+	}
+
+	backoff := p.Backoff
+	for i := 1; i < health.ConsecutiveFailures && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// ShouldDelete returns true if the feed has been failing for too long.
+func (p HealthPolicy) ShouldDelete(health Health, now time.Time) bool {
+	if p.DeleteAfter <= 0 || health.Healthy() {
+		return false
+	}
+	return now.Sub(health.FailingSince) >= p.DeleteAfter
+}
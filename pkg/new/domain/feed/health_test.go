@@ -0,0 +1,103 @@
+package feed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/require"
+)
+
+var testHealthPolicy = feed.HealthPolicy{
+	SuspendAfter: 3,
+	Backoff:      time.Hour,
+	MaxBackoff:   24 * time.Hour,
+	DeleteAfter:  7 * 24 * time.Hour,
+}
+
+func TestHealthPolicyBacksOffAndSuspends(t *testing.T) {
+	start := time.Unix(1000000, 0)
+
+	health := testHealthPolicy.Failed(testHealthPolicy.Succeeded(), feed.ErrorKindDNS, start)
+	require.Equal(t, feed.HealthStateDegraded, health.State)
+	require.Equal(t, 1, health.ConsecutiveFailures)
+	require.Equal(t, start, health.FailingSince)
+	require.Equal(t, start.Add(time.Hour), health.NextFetchAt)
+	require.False(t, health.Due(start.Add(time.Minute)))
+	require.True(t, health.Due(start.Add(time.Hour)))
+
+	later := start.Add(time.Hour)
+	health = testHealthPolicy.Failed(health, feed.ErrorKindHTTP, later)
+	require.Equal(t, feed.HealthStateDegraded, health.State)
+	require.Equal(t, feed.ErrorKindHTTP, health.ErrorKind)
+	require.Equal(t, start, health.FailingSince)
+	require.Equal(t, later.Add(2*time.Hour), health.NextFetchAt)
+
+	health = testHealthPolicy.Failed(health, feed.ErrorKindHTTP, later)
+	require.Equal(t, feed.HealthStateSuspended, health.State)
+	require.Equal(t, later.Add(24*time.Hour), health.NextFetchAt)
+
+	require.True(t, testHealthPolicy.Succeeded().Healthy())
+	require.True(t, testHealthPolicy.Succeeded().Due(later))
+}
+
+func TestHealthPolicyCapsTheBackoff(t *testing.T) {
+	policy := testHealthPolicy
+	policy.SuspendAfter = 100
+
+	health := policy.Succeeded()
+	for i := 0; i < 50; i++ {
+		health = policy.Failed(health, feed.ErrorKindTimeout, time.Unix(0, 0))
+	}
+	require.Equal(t, time.Unix(0, 0).Add(24*time.Hour), health.NextFetchAt)
+}
+
+func TestHealthPolicyShouldDelete(t *testing.T) {
+	start := time.Unix(1000000, 0)
+	health := testHealthPolicy.Failed(testHealthPolicy.Succeeded(), feed.ErrorKindDNS, start)
+
+	require.False(t, testHealthPolicy.ShouldDelete(health, start.Add(6*24*time.Hour)))
+	require.True(t, testHealthPolicy.ShouldDelete(health, start.Add(7*24*time.Hour)))
+	require.False(t, testHealthPolicy.ShouldDelete(testHealthPolicy.Succeeded(), start.Add(30*24*time.Hour)))
+
+	never := testHealthPolicy
+	never.DeleteAfter = 0
+	require.False(t, never.ShouldDelete(health, start.Add(365*24*time.Hour)))
+}
+
+func TestHealthPolicyTransitions(t *testing.T) {
+	start := time.Unix(1000000, 0)
+
+	testCases := []struct {
+		name              string
+		failures          int
+		expectedState     feed.HealthState
+		expectedNextFetch time.Duration
+	}{
+		{name: "first failure", failures: 1, expectedState: feed.HealthStateDegraded, expectedNextFetch: time.Hour},
+		{name: "second failure", failures: 2, expectedState: feed.HealthStateDegraded, expectedNextFetch: 2 * time.Hour},
+		{name: "suspended", failures: 3, expectedState: feed.HealthStateSuspended, expectedNextFetch: 24 * time.Hour},
+		{name: "still suspended", failures: 10, expectedState: feed.HealthStateSuspended, expectedNextFetch: 24 * time.Hour},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			health := testHealthPolicy.Succeeded()
+			failedAt := start
+			for i := 0; i < testCase.failures; i++ {
+				failedAt = start.Add(time.Duration(i) * time.Minute)
+				health = testHealthPolicy.Failed(health, feed.ErrorKindHTTP, failedAt)
+			}
+
+			require.Equal(t, testCase.expectedState, health.State)
+			require.Equal(t, testCase.failures, health.ConsecutiveFailures)
+			require.Equal(t, start, health.FailingSince, "failing feeds keep the time of their first failure")
+			require.Equal(t, failedAt.Add(testCase.expectedNextFetch), health.NextFetchAt)
+
+			recovered := testHealthPolicy.Succeeded()
+			require.Equal(t, feed.HealthStateHealthy, recovered.State)
+			require.True(t, recovered.FailingSince.IsZero())
+			require.True(t, recovered.Due(failedAt), "recovered feeds are fetched on every update")
+		})
+	}
+}
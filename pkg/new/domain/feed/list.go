@@ -0,0 +1,19 @@
+package feed
+
+// ListFilter selects a page of feeds ordered by address. Zero fields don't
+// filter.
+type ListFilter struct {
+	Health   HealthState
+	Disabled *bool
+	Pending  *bool
+	Owned    *bool
+	Limit    int
+	Offset   int
+}
+
+// Page is a page of feeds along with the number of feeds matching the
+// filter.
+type Page struct {
+	Feeds []*FeedDefinition
+	Total int
+}
@@ -0,0 +1,100 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OPML is the format in which feed readers import and export their
+// subscriptions, as described in http://opml.org/spec2.opml.
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    OPMLHead `xml:"head"`
+	Body    OPMLBody `xml:"body"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type OPMLBody struct {
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// OPMLOutline is either a subscription, which has the address of its feed in
+// XMLURL, or a category grouping other outlines.
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline,omitempty"`
+}
+
+// NewOPML creates an OPML 2.0 document listing the outlines.
+func NewOPML(title string, outlines []OPMLOutline, createdAt time.Time) OPML {
+	return OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       title,
+			DateCreated: createdAt.UTC().Format(time.RFC1123Z),
+		},
+		Body: OPMLBody{Outlines: outlines},
+	}
+}
+
+func ParseOPML(r io.Reader) (OPML, error) {
+	var opml OPML
+	if err := xml.NewDecoder(r).Decode(&opml); err != nil {
+		return OPML{}, errors.Wrap(err, "invalid OPML document")
+	}
+	return opml, nil
+}
+
+// Marshal returns the document along with its XML header.
+func (o OPML) Marshal() ([]byte, error) {
+	b, err := xml.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling the OPML document")
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// Subscriptions returns the outlines with the address of a feed, the ones
+// within categories included. Outlines with an address already seen are left
+// out.
+func (o OPML) Subscriptions() []OPMLOutline {
+	var subscriptions []OPMLOutline
+	seen := make(map[string]bool)
+
+	var walk func(outlines []OPMLOutline)
+	walk = func(outlines []OPMLOutline) {
+		for _, outline := range outlines {
+			if address := strings.TrimSpace(outline.XMLURL); address != "" && !seen[address] {
+				seen[address] = true
+				outline.XMLURL = address
+				outline.Outlines = nil
+				subscriptions = append(subscriptions, outline)
+			}
+			walk(outline.Outlines)
+		}
+	}
+	walk(o.Body.Outlines)
+
+	return subscriptions
+}
+
+// Name returns the title of the outline, or its text if it has no title.
+func (o OPMLOutline) Name() string {
+	if o.Title != "" {
+		return o.Title
+	}
+	return o.Text
+}
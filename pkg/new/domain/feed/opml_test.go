@@ -0,0 +1,67 @@
+package feed_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/require"
+)
+
+const testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>My subscriptions</title></head>
+  <body>
+    <outline text="Blog" type="rss" xmlUrl="https://example.com/feed" htmlUrl="https://example.com"/>
+    <outline text="News">
+      <outline text="Daily" title="The Daily" type="rss" xmlUrl=" https://news.example.com/rss "/>
+      <outline text="Blog again" type="rss" xmlUrl="https://example.com/feed"/>
+      <outline text="Nested">
+        <outline text="Podcast" type="rss" xmlUrl="https://podcast.example.com/feed.xml"/>
+      </outline>
+    </outline>
+    <outline text="Just a link" htmlUrl="https://example.org"/>
+  </body>
+</opml>`
+
+func TestParseOPML(t *testing.T) {
+	opml, err := feed.ParseOPML(strings.NewReader(testOPML))
+	require.NoError(t, err)
+	require.Equal(t, "My subscriptions", opml.Head.Title)
+
+	subscriptions := opml.Subscriptions()
+	require.Len(t, subscriptions, 3)
+	require.Equal(t, "https://example.com/feed", subscriptions[0].XMLURL)
+	require.Equal(t, "Blog", subscriptions[0].Name())
+	require.Equal(t, "https://news.example.com/rss", subscriptions[1].XMLURL)
+	require.Equal(t, "The Daily", subscriptions[1].Name())
+	require.Equal(t, "https://podcast.example.com/feed.xml", subscriptions[2].XMLURL)
+}
+
+func TestParseOPMLRejectsOtherDocuments(t *testing.T) {
+	_, err := feed.ParseOPML(strings.NewReader(`<rss version="2.0"><channel></channel></rss>`))
+	require.Error(t, err)
+
+	_, err = feed.ParseOPML(strings.NewReader("not xml"))
+	require.Error(t, err)
+}
+
+func TestMarshalOPML(t *testing.T) {
+	createdAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	opml := feed.NewOPML("rsslay feeds", []feed.OPMLOutline{
+		{Text: "Blog", Type: "rss", XMLURL: "https://example.com/feed", HTMLURL: "https://example.com"},
+	}, createdAt)
+
+	b, err := opml.Marshal()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(b), `<?xml version="1.0" encoding="UTF-8"?>`))
+
+	parsed, err := feed.ParseOPML(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, "2.0", parsed.Version)
+	require.Equal(t, "rsslay feeds", parsed.Head.Title)
+	require.Equal(t, "Mon, 01 May 2023 10:00:00 +0000", parsed.Head.DateCreated)
+	require.Equal(t, opml.Body.Outlines, parsed.Subscriptions())
+}
@@ -0,0 +1,42 @@
+package feed
+
+import (
+	"time"
+
+	"github.com/piraces/rsslay/pkg/new/domain/nostr"
+	"github.com/piraces/rsslay/pkg/nip26"
+)
+
+// VerificationMethod is the proof with which the owner of a feed showed that
+// they control its site.
+type VerificationMethod string
+
+const (
+	// VerificationMethodNIP05 is a /.well-known/nostr.json entry of the site.
+	VerificationMethodNIP05 VerificationMethod = "nip05"
+	// VerificationMethodMeta is a <meta name="nostr"> tag in the site HTML.
+	VerificationMethodMeta VerificationMethod = "meta"
+	// VerificationMethodFeed is a nostr tag in the feed itself.
+	VerificationMethodFeed VerificationMethod = "feed"
+)
+
+// Owner is the verified publisher of a feed. The feed is published with the
+// key of the owner, either signing through their NIP-46 remote signer or
+// with the key of the feed and a NIP-26 delegation.
+type Owner struct {
+	PublicKey  nostr.PublicKey
+	Method     VerificationMethod
+	VerifiedAt time.Time
+	BunkerURL  string
+	Delegation nip26.Delegation
+}
+
+func (o Owner) IsZero() bool {
+	return o.PublicKey.Hex() == ""
+}
+
+// SignsRemotely tells if the events are signed by the owner through their
+// remote signer instead of by the key of the feed.
+func (o Owner) SignsRemotely() bool {
+	return o.BunkerURL != ""
+}
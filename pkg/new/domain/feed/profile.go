@@ -0,0 +1,69 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/piraces/rsslay/pkg/helpers"
+)
+
+const (
+	maxProfileNameLength  = 100
+	maxProfileAboutLength = 2000
+)
+
+var validLightningAddress = regexp.MustCompile(`^[a-z0-9._+-]+@[a-z0-9-]+(\.[a-z0-9-]+)+$`)
+
+// Profile holds the fields of the profile of a feed which its owner chose to
+// set instead of the ones taken from the feed. Empty fields aren't
+// overridden.
+type Profile struct {
+	Name    string
+	About   string
+	Picture string
+	Banner  string
+	Lud16   string
+	Website string
+}
+
+func NewProfile(name, about, picture, banner, lud16, website string) (Profile, error) {
+	profile := Profile{
+		Name:    strings.TrimSpace(name),
+		About:   strings.TrimSpace(about),
+		Picture: strings.TrimSpace(picture),
+		Banner:  strings.TrimSpace(banner),
+		Lud16:   strings.ToLower(strings.TrimSpace(lud16)),
+		Website: strings.TrimSpace(website),
+	}
+
+	if utf8.RuneCountInString(profile.Name) > maxProfileNameLength {
+		return Profile{}, fmt.Errorf("name can't be longer than %d characters", maxProfileNameLength)
+	}
+
+	if utf8.RuneCountInString(profile.About) > maxProfileAboutLength {
+		return Profile{}, fmt.Errorf("about can't be longer than %d characters", maxProfileAboutLength)
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"picture", profile.Picture},
+		{"banner", profile.Banner},
+		{"website", profile.Website},
+	} {
+		if field.value != "" && !helpers.IsValidHttpUrl(field.value) {
+			return Profile{}, fmt.Errorf("%s must be an http(s) url", field.name)
+		}
+	}
+
+	if profile.Lud16 != "" && !validLightningAddress.MatchString(profile.Lud16) {
+		return Profile{}, errors.New("lud16 must be a lightning address such as name@example.com")
+	}
+
+	return profile, nil
+}
+
+func (p Profile) IsZero() bool {
+	return p == Profile{}
+}
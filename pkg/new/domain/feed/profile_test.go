@@ -0,0 +1,36 @@
+package feed_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/piraces/rsslay/pkg/new/domain/feed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProfile(t *testing.T) {
+	profile, err := feed.NewProfile(" Example ", "", "https://example.com/logo.png", "", "Tips@Example.com", "")
+	require.NoError(t, err)
+	require.Equal(t, feed.Profile{Name: "Example", Picture: "https://example.com/logo.png", Lud16: "tips@example.com"}, profile)
+	require.False(t, profile.IsZero())
+
+	profile, err = feed.NewProfile("", "", "", "", "", "")
+	require.NoError(t, err)
+	require.True(t, profile.IsZero())
+
+	testCases := []struct {
+		name                                                string
+		profileName, about, picture, banner, lud16, website string
+	}{
+		{name: "long name", profileName: strings.Repeat("a", 101)},
+		{name: "long about", about: strings.Repeat("a", 2001)},
+		{name: "picture", picture: "javascript:alert(1)"},
+		{name: "banner", banner: "example.com/banner.png"},
+		{name: "website", website: "ftp://example.com"},
+		{name: "lud16", lud16: "lnurl1dp68gurn8ghj7"},
+	}
+	for _, tc := range testCases {
+		_, err := feed.NewProfile(tc.profileName, tc.about, tc.picture, tc.banner, tc.lud16, tc.website)
+		require.Error(t, err, tc.name)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -20,6 +21,8 @@ const (
 	idBytesLen         = sha256.Size
 )
 
+var ErrDuplicateEvent = errors.New("duplicate event")
+
 type Filter struct {
 	filter *nostr.Filter
 }
@@ -34,6 +37,10 @@ func (f Filter) Matches(event Event) bool {
 	return f.filter.Matches(&event.event)
 }
 
+func (f Filter) Libfilter() nostr.Filter {
+	return *f.filter
+}
+
 type Event struct {
 	id        ID
 	publicKey PublicKey
@@ -112,6 +119,19 @@ func NewPublicKeyFromHex(s string) (PublicKey, error) {
 	return PublicKey{b: b}, nil
 }
 
+// NewPublicKeyFromHexOrNip19 accepts both hex and npub encoded public keys.
+func NewPublicKeyFromHexOrNip19(s string) (PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "npub") {
+		prefix, value, err := nip19.Decode(s)
+		if err != nil || prefix != "npub" {
+			return PublicKey{}, errors.New("invalid npub")
+		}
+		s = value.(string)
+	}
+	return NewPublicKeyFromHex(s)
+}
+
 func (p PublicKey) Hex() string {
 	return hex.EncodeToString(p.b)
 }
@@ -151,6 +171,10 @@ func NewPrivateKeyFromHex(s string) (PrivateKey, error) {
 	return PrivateKey{b: b}, nil
 }
 
-func (k PrivateKey) Hex() any {
+func (k PrivateKey) Hex() string {
 	return hex.EncodeToString(k.b)
 }
+
+func (k PrivateKey) IsZero() bool {
+	return len(k.b) == 0
+}
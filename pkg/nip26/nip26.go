@@ -0,0 +1,187 @@
+// Package nip26 implements delegated event signing which lets a key publish
+// events on behalf of another one. The version of go-nostr in use doesn't
+// ship it.
+// See https://github.com/nostr-protocol/nips/blob/master/26.md for details.
+package nip26
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const tagName = "delegation"
+
+// Delegation allows the delegatee to sign events matching the conditions on
+// behalf of the delegator.
+type Delegation struct {
+	Delegator  string
+	Conditions string
+	Signature  string
+}
+
+// Create signs a delegation token for the delegatee, the conditions look
+// like "kind=1&created_at>1700000000".
+func Create(delegatorPrivateKey string, delegatee string, conditions string) (Delegation, error) {
+	privateKeyBytes, err := hex.DecodeString(delegatorPrivateKey)
+	if err != nil {
+		return Delegation{}, fmt.Errorf("error decoding the private key: %w", err)
+	}
+	privateKey, publicKey := btcec.PrivKeyFromBytes(privateKeyBytes)
+
+	if _, err := parseConditions(conditions); err != nil {
+		return Delegation{}, err
+	}
+
+	hash := token(delegatee, conditions)
+	signature, err := schnorr.Sign(privateKey, hash[:])
+	if err != nil {
+		return Delegation{}, fmt.Errorf("error signing the token: %w", err)
+	}
+
+	return Delegation{
+		Delegator:  hex.EncodeToString(schnorr.SerializePubKey(publicKey)),
+		Conditions: conditions,
+		Signature:  hex.EncodeToString(signature.Serialize()),
+	}, nil
+}
+
+// Parse reads a ["delegation", <delegator>, <conditions>, <signature>] tag.
+func Parse(tag []string) (Delegation, error) {
+	if len(tag) != 4 || tag[0] != tagName {
+		return Delegation{}, errors.New("invalid delegation tag")
+	}
+
+	delegation := Delegation{
+		Delegator:  tag[1],
+		Conditions: tag[2],
+		Signature:  tag[3],
+	}
+	if !nostr.IsValidPublicKeyHex(delegation.Delegator) {
+		return Delegation{}, errors.New("invalid delegator public key")
+	}
+	if _, err := parseConditions(delegation.Conditions); err != nil {
+		return Delegation{}, err
+	}
+	return delegation, nil
+}
+
+func (d Delegation) IsZero() bool {
+	return d == Delegation{}
+}
+
+func (d Delegation) Tag() nostr.Tag {
+	return nostr.Tag{tagName, d.Delegator, d.Conditions, d.Signature}
+}
+
+// Verify checks that the delegator signed the delegation for the delegatee.
+func (d Delegation) Verify(delegatee string) error {
+	publicKeyBytes, err := hex.DecodeString(d.Delegator)
+	if err != nil {
+		return fmt.Errorf("error decoding the delegator: %w", err)
+	}
+	publicKey, err := schnorr.ParsePubKey(publicKeyBytes)
+	if err != nil {
+		return fmt.Errorf("error parsing the delegator: %w", err)
+	}
+
+	signatureBytes, err := hex.DecodeString(d.Signature)
+	if err != nil {
+		return fmt.Errorf("error decoding the signature: %w", err)
+	}
+	signature, err := schnorr.ParseSignature(signatureBytes)
+	if err != nil {
+		return fmt.Errorf("error parsing the signature: %w", err)
+	}
+
+	hash := token(delegatee, d.Conditions)
+	if !signature.Verify(hash[:], publicKey) {
+		return errors.New("the delegation wasn't signed by the delegator for this key")
+	}
+	return nil
+}
+
+// Allows tells if events of the kind created at the given time can be signed
+// with the delegation.
+func (d Delegation) Allows(kind int, createdAt nostr.Timestamp) bool {
+	conditions, err := parseConditions(d.Conditions)
+	if err != nil {
+		return false
+	}
+
+	kindAllowed := len(conditions.kinds) == 0
+	for _, k := range conditions.kinds {
+		if k == kind {
+			kindAllowed = true
+		}
+	}
+	if !kindAllowed {
+		return false
+	}
+
+	if conditions.after != nil && createdAt <= *conditions.after {
+		return false
+	}
+	if conditions.before != nil && createdAt >= *conditions.before {
+		return false
+	}
+	return true
+}
+
+func token(delegatee string, conditions string) [32]byte {
+	return sha256.Sum256([]byte("nostr:delegation:" + delegatee + ":" + conditions))
+}
+
+type conditions struct {
+	kinds  []int
+	after  *nostr.Timestamp
+	before *nostr.Timestamp
+}
+
+func parseConditions(s string) (conditions, error) {
+	var result conditions
+	if s == "" {
+		return result, nil
+	}
+
+	for _, condition := range strings.Split(s, "&") {
+		switch {
+		case strings.HasPrefix(condition, "kind="):
+			kind, err := strconv.Atoi(strings.TrimPrefix(condition, "kind="))
+			if err != nil {
+				return conditions{}, fmt.Errorf("invalid condition %q", condition)
+			}
+			result.kinds = append(result.kinds, kind)
+		case strings.HasPrefix(condition, "created_at>"):
+			after, err := parseTimestamp(strings.TrimPrefix(condition, "created_at>"))
+			if err != nil {
+				return conditions{}, fmt.Errorf("invalid condition %q", condition)
+			}
+			result.after = &after
+		case strings.HasPrefix(condition, "created_at<"):
+			before, err := parseTimestamp(strings.TrimPrefix(condition, "created_at<"))
+			if err != nil {
+				return conditions{}, fmt.Errorf("invalid condition %q", condition)
+			}
+			result.before = &before
+		default:
+			return conditions{}, fmt.Errorf("unsupported condition %q", condition)
+		}
+	}
+	return result, nil
+}
+
+func parseTimestamp(s string) (nostr.Timestamp, error) {
+	timestamp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return nostr.Timestamp(timestamp), nil
+}
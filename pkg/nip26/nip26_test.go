@@ -0,0 +1,70 @@
+package nip26
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	delegatorPrivateKey = "3f0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459da"
+	delegateePrivateKey = "e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b"
+)
+
+func TestParseAndVerifyKnownDelegation(t *testing.T) {
+	// from the NIP-26 tests of go-nostr
+	tag := []string{"delegation", "9ea72be3fcfe38103195a41b67b6f96c14ed92d2091d6d9eb8166a5c27b0c35d", "kind=1&kind=2&kind=3&created_at>1600000000", "8432b8c86f789c2783ef3becb0fabf4def6031c6a615fa7a622f31329d80ed1b2a79ab753c0462f1440503c94e1829158a3a854a1d418ad256ae2cf8aa19fa9a"}
+	delegatee, err := nostr.GetPublicKey(delegateePrivateKey)
+	require.NoError(t, err)
+
+	delegation, err := Parse(tag)
+	require.NoError(t, err)
+	assert.NoError(t, delegation.Verify(delegatee))
+	assert.Equal(t, nostr.Tag(tag), delegation.Tag())
+
+	other, err := nostr.GetPublicKey(delegatorPrivateKey)
+	require.NoError(t, err)
+	assert.Error(t, delegation.Verify(other))
+}
+
+func TestCreateDelegation(t *testing.T) {
+	delegatee, err := nostr.GetPublicKey(delegateePrivateKey)
+	require.NoError(t, err)
+	delegator, err := nostr.GetPublicKey(delegatorPrivateKey)
+	require.NoError(t, err)
+
+	delegation, err := Create(delegatorPrivateKey, delegatee, "kind=1&created_at>1600000000&created_at<1700000000")
+	require.NoError(t, err)
+	assert.Equal(t, delegator, delegation.Delegator)
+	assert.NoError(t, delegation.Verify(delegatee))
+
+	tampered := delegation
+	tampered.Conditions = "kind=1"
+	assert.Error(t, tampered.Verify(delegatee))
+}
+
+func TestDelegationAllows(t *testing.T) {
+	delegation := Delegation{Conditions: "kind=1&kind=30023&created_at>1600000000&created_at<1700000000"}
+
+	assert.True(t, delegation.Allows(1, 1600000001))
+	assert.True(t, delegation.Allows(30023, 1699999999))
+	assert.False(t, delegation.Allows(0, 1600000001))
+	assert.False(t, delegation.Allows(1, 1600000000))
+	assert.False(t, delegation.Allows(1, 1700000000))
+
+	assert.True(t, Delegation{}.Allows(7, 1))
+}
+
+func TestParseRejectsInvalidTags(t *testing.T) {
+	for _, tag := range [][]string{
+		{"delegation"},
+		{"p", "9ea72be3fcfe38103195a41b67b6f96c14ed92d2091d6d9eb8166a5c27b0c35d", "kind=1", "00"},
+		{"delegation", "invalid", "kind=1", "00"},
+		{"delegation", "9ea72be3fcfe38103195a41b67b6f96c14ed92d2091d6d9eb8166a5c27b0c35d", "author=x", "00"},
+	} {
+		_, err := Parse(tag)
+		assert.Error(t, err, tag)
+	}
+}
@@ -0,0 +1,191 @@
+// Package nip44 implements version 2 of the NIP-44 encryption scheme which
+// is required by NIP-17 direct messages. The version shipped with go-nostr
+// only supports the deprecated first version.
+// See https://github.com/nostr-protocol/nips/blob/master/44.md for details.
+package nip44
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	version          = 2
+	minPlaintextSize = 1
+	maxPlaintextSize = 65535
+	nonceSize        = 32
+	macSize          = 32
+)
+
+var salt = []byte("nip44-v2")
+
+// ConversationKey derives the key shared by a private key and a public key,
+// both hex encoded.
+func ConversationKey(privateKey string, publicKey string) ([]byte, error) {
+	privateKeyBytes, err := hex.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding private key: %w", err)
+	}
+	sk, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+
+	publicKeyBytes, err := hex.DecodeString("02" + publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding public key: %w", err)
+	}
+	pk, err := btcec.ParsePubKey(publicKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+
+	sharedX := btcec.GenerateSharedSecret(sk, pk)
+	return hkdf.Extract(sha256.New, sharedX, salt), nil
+}
+
+func Encrypt(plaintext string, conversationKey []byte) (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error creating nonce: %w", err)
+	}
+	return encryptWithNonce(plaintext, conversationKey, nonce)
+}
+
+func encryptWithNonce(plaintext string, conversationKey []byte, nonce []byte) (string, error) {
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := pad(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := chacha(chachaKey, chachaNonce, padded)
+	if err != nil {
+		return "", err
+	}
+
+	payload := make([]byte, 0, 1+nonceSize+len(ciphertext)+macSize)
+	payload = append(payload, version)
+	payload = append(payload, nonce...)
+	payload = append(payload, ciphertext...)
+	payload = append(payload, mac(hmacKey, nonce, ciphertext)...)
+
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+func Decrypt(payload string, conversationKey []byte) (string, error) {
+	if payload == "" || payload[0] == '#' {
+		return "", errors.New("unknown encryption version")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64: %w", err)
+	}
+
+	if len(data) < 1+nonceSize+2+macSize {
+		return "", errors.New("payload is too short")
+	}
+
+	if data[0] != version {
+		return "", fmt.Errorf("unknown encryption version %d", data[0])
+	}
+
+	nonce := data[1 : 1+nonceSize]
+	ciphertext := data[1+nonceSize : len(data)-macSize]
+	givenMac := data[len(data)-macSize:]
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal(givenMac, mac(hmacKey, nonce, ciphertext)) {
+		return "", errors.New("invalid mac")
+	}
+
+	padded, err := chacha(chachaKey, chachaNonce, ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return unpad(padded)
+}
+
+func messageKeys(conversationKey []byte, nonce []byte) ([]byte, []byte, []byte, error) {
+	if len(conversationKey) != 32 {
+		return nil, nil, nil, errors.New("invalid conversation key length")
+	}
+
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey, nonce), keys); err != nil {
+		return nil, nil, nil, fmt.Errorf("error deriving message keys: %w", err)
+	}
+
+	return keys[0:32], keys[32:44], keys[44:76], nil
+}
+
+func chacha(key []byte, nonce []byte, data []byte) ([]byte, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	out := make([]byte, len(data))
+	cipher.XORKeyStream(out, data)
+	return out, nil
+}
+
+func mac(key []byte, nonce []byte, ciphertext []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(nonce)
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+func pad(plaintext string) ([]byte, error) {
+	size := len(plaintext)
+	if size < minPlaintextSize || size > maxPlaintextSize {
+		return nil, errors.New("invalid plaintext length")
+	}
+
+	padded := make([]byte, 2+paddedLen(size))
+	binary.BigEndian.PutUint16(padded, uint16(size))
+	copy(padded[2:], plaintext)
+	return padded, nil
+}
+
+func unpad(padded []byte) (string, error) {
+	size := int(binary.BigEndian.Uint16(padded))
+	if size < minPlaintextSize || size > maxPlaintextSize || len(padded) != 2+paddedLen(size) {
+		return "", errors.New("invalid padding")
+	}
+	return string(padded[2 : 2+size]), nil
+}
+
+func paddedLen(size int) int {
+	if size <= 32 {
+		return 32
+	}
+
+	nextPower := 1 << (int(math.Floor(math.Log2(float64(size-1)))) + 1)
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+
+	return chunk * ((size-1)/chunk + 1)
+}
@@ -0,0 +1,81 @@
+package nip44
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// test vectors come from https://github.com/paulmillr/nip44
+
+func TestConversationKey(t *testing.T) {
+	key, err := ConversationKey(
+		"315e59ff51cb9209768cf7da80791ddcaae56ac9775eb25b6dee1234bc5d2268",
+		"c2f9d9948dc8c7c38321e4b85c8558872eafa0641cd269db76848a6073e69133",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "3dfef0ce2a4d80a25e7a328accf73448ef67096f65f79588e358d9a0eb9013f1", hex.EncodeToString(key))
+}
+
+func TestEncryptWithNonce(t *testing.T) {
+	publicKey, err := nostr.GetPublicKey("0000000000000000000000000000000000000000000000000000000000000002")
+	require.NoError(t, err)
+
+	key, err := ConversationKey("0000000000000000000000000000000000000000000000000000000000000001", publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d", hex.EncodeToString(key))
+
+	nonce, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+	payload, err := encryptWithNonce("a", key, nonce)
+	require.NoError(t, err)
+	assert.Equal(t, "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb", payload)
+
+	plaintext, err := Decrypt(payload, key)
+	require.NoError(t, err)
+	assert.Equal(t, "a", plaintext)
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	sk1 := nostr.GeneratePrivateKey()
+	sk2 := nostr.GeneratePrivateKey()
+	pk1, _ := nostr.GetPublicKey(sk1)
+	pk2, _ := nostr.GetPublicKey(sk2)
+
+	key1, err := ConversationKey(sk1, pk2)
+	require.NoError(t, err)
+	key2, err := ConversationKey(sk2, pk1)
+	require.NoError(t, err)
+	assert.Equal(t, key1, key2)
+
+	for _, plaintext := range []string{"x", strings.Repeat("y", 33), strings.Repeat("z", 1000)} {
+		payload, err := Encrypt(plaintext, key1)
+		require.NoError(t, err)
+
+		decrypted, err := Decrypt(payload, key2)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	}
+}
+
+func TestDecryptRejectsTamperedPayload(t *testing.T) {
+	key, err := ConversationKey(nostr.GeneratePrivateKey(), "c2f9d9948dc8c7c38321e4b85c8558872eafa0641cd269db76848a6073e69133")
+	require.NoError(t, err)
+
+	payload, err := Encrypt("hello", key)
+	require.NoError(t, err)
+
+	tampered := []byte(payload)
+	tampered[10] ^= 1
+	_, err = Decrypt(string(tampered), key)
+	assert.Error(t, err)
+}
+
+func TestPaddedLen(t *testing.T) {
+	for size, expected := range map[int]int{1: 32, 32: 32, 33: 64, 37: 64, 45: 64, 49: 64, 64: 64, 65: 96, 100: 128, 111: 128, 200: 224, 250: 256, 320: 320, 383: 384, 384: 384, 400: 448, 500: 512, 512: 512, 515: 640, 700: 768, 800: 896, 900: 1024, 1020: 1024, 65536: 65536} {
+		assert.Equal(t, expected, paddedLen(size), "size %d", size)
+	}
+}
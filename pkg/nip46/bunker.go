@@ -0,0 +1,213 @@
+package nip46
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Keys returns the private key of one of the public keys held by a bunker.
+type Keys func(publicKey string) (string, bool)
+
+// Derive derives the keys of a feed from its address with every version of
+// the secret held by a bunker, the newest first.
+type Derive func(address string) ([]DerivedKey, error)
+
+// Bunker signs events for the clients which connected with its secret.
+type Bunker struct {
+	transport  Transport
+	privateKey string
+	publicKey  string
+	secret     string
+	keys       Keys
+	derive     Derive
+
+	mutex   sync.Mutex
+	clients map[string]struct{}
+}
+
+// NewBunker creates a bunker signing with the keys it holds and with the
+// keys it derives from the addresses of the feeds, either may be nil.
+func NewBunker(transport Transport, privateKey string, secret string, keys Keys, derive Derive) (*Bunker, error) {
+	if secret == "" {
+		return nil, errors.New("the bunker requires a secret")
+	}
+
+	publicKey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bunker private key: %w", err)
+	}
+
+	return &Bunker{
+		transport:  transport,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		secret:     secret,
+		keys:       keys,
+		derive:     derive,
+		clients:    make(map[string]struct{}),
+	}, nil
+}
+
+// URL returns the bunker URL clients connect with.
+func (b *Bunker) URL(relays ...string) BunkerURL {
+	return BunkerURL{
+		PublicKey: b.publicKey,
+		Relays:    relays,
+		Secret:    b.secret,
+	}
+}
+
+// Start subscribes to the requests of the clients and serves them until ctx
+// is done.
+func (b *Bunker) Start(ctx context.Context) error {
+	requests, err := b.transport.Subscribe(ctx, nostr.Filter{
+		Kinds: []int{KindRemoteSigning},
+		Tags:  nostr.TagMap{"p": []string{b.publicKey}},
+	})
+	if err != nil {
+		return err
+	}
+
+	go b.serve(ctx, requests)
+	return nil
+}
+
+func (b *Bunker) serve(ctx context.Context, requests <-chan nostr.Event) {
+	for event := range requests {
+		var request Request
+		if err := decryptMessage(event, b.privateKey, &request); err != nil {
+			log.Printf("[DEBUG] ignoring invalid request to the bunker: %v", err)
+			continue
+		}
+
+		response := b.respond(event.PubKey, request)
+		message, err := encryptedMessage(response, b.privateKey, event.PubKey)
+		if err != nil {
+			log.Printf("[ERROR] failure to create the bunker response: %v", err)
+			continue
+		}
+		if err := b.transport.Publish(ctx, message); err != nil {
+			log.Printf("[ERROR] failure to send the bunker response: %v", err)
+		}
+	}
+}
+
+func (b *Bunker) respond(client string, request Request) Response {
+	result, err := b.handle(client, request)
+	if err != nil {
+		return Response{ID: request.ID, Error: err.Error()}
+	}
+	return Response{ID: request.ID, Result: result}
+}
+
+func (b *Bunker) handle(client string, request Request) (string, error) {
+	switch request.Method {
+	case MethodConnect:
+		if len(request.Params) < 2 || subtle.ConstantTimeCompare([]byte(request.Params[1]), []byte(b.secret)) != 1 {
+			return "", errors.New("invalid secret")
+		}
+		b.mutex.Lock()
+		b.clients[client] = struct{}{}
+		b.mutex.Unlock()
+		return "ack", nil
+	case MethodPing:
+		return "pong", nil
+	}
+
+	b.mutex.Lock()
+	_, connected := b.clients[client]
+	b.mutex.Unlock()
+	if !connected {
+		return "", errors.New("unauthorized, connect with the secret first")
+	}
+
+	switch request.Method {
+	case MethodGetPublicKey:
+		return b.publicKey, nil
+	case MethodSignEvent:
+		return b.sign(request.Params)
+	case MethodDerivePublicKeys:
+		return b.derivePublicKeys(request.Params)
+	default:
+		return "", fmt.Errorf("unsupported method %q", request.Method)
+	}
+}
+
+func (b *Bunker) sign(params []string) (string, error) {
+	if len(params) < 1 {
+		return "", errors.New("missing event")
+	}
+
+	var event nostr.Event
+	if err := json.Unmarshal([]byte(params[0]), &event); err != nil {
+		return "", fmt.Errorf("invalid event: %w", err)
+	}
+
+	privateKey, err := b.feedPrivateKey(event.PubKey, params[1:])
+	if err != nil {
+		return "", err
+	}
+	if err := event.Sign(privateKey); err != nil {
+		return "", err
+	}
+
+	signed, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+// feedPrivateKey finds the private key of the public key among the keys derived
+// from the addresses the client sent along with the event, falling back to
+// the keys held by the bunker.
+func (b *Bunker) feedPrivateKey(publicKey string, addresses []string) (string, error) {
+	if b.derive != nil {
+		for _, address := range addresses {
+			keys, err := b.derive(address)
+			if err != nil {
+				return "", err
+			}
+			for _, key := range keys {
+				if key.PublicKey == publicKey {
+					return key.PrivateKey, nil
+				}
+			}
+		}
+	}
+
+	if b.keys != nil {
+		if privateKey, ok := b.keys(publicKey); ok {
+			return privateKey, nil
+		}
+	}
+	return "", fmt.Errorf("unknown key %s", publicKey)
+}
+
+func (b *Bunker) derivePublicKeys(addresses []string) (string, error) {
+	if b.derive == nil {
+		return "", errors.New("the bunker doesn't derive keys")
+	}
+
+	derived := []DerivedKey{}
+	for _, address := range addresses {
+		keys, err := b.derive(address)
+		if err != nil {
+			return "", err
+		}
+		derived = append(derived, keys...)
+	}
+
+	result, err := json.Marshal(derived)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
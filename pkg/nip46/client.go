@@ -0,0 +1,192 @@
+package nip46
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/signer"
+)
+
+// Client signs events with a bunker. It implements signer.Signer.
+type Client struct {
+	transport       Transport
+	privateKey      string
+	publicKey       string
+	remotePublicKey string
+	secret          string
+	timeout         time.Duration
+
+	mutex   sync.Mutex
+	pending map[string]chan Response
+}
+
+// NewClient creates a client with a new key, the bunker authorizes it when
+// it connects with the secret.
+func NewClient(transport Transport, remotePublicKey string, secret string, timeout time.Duration) (*Client, error) {
+	if !nostr.IsValidPublicKeyHex(remotePublicKey) {
+		return nil, fmt.Errorf("invalid bunker public key %q", remotePublicKey)
+	}
+
+	privateKey := nostr.GeneratePrivateKey()
+	publicKey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		transport:       transport,
+		privateKey:      privateKey,
+		publicKey:       publicKey,
+		remotePublicKey: remotePublicKey,
+		secret:          secret,
+		timeout:         timeout,
+		pending:         make(map[string]chan Response),
+	}, nil
+}
+
+// Connect listens to the responses of the bunker until ctx is done and
+// asks the bunker to authorize the client.
+func (c *Client) Connect(ctx context.Context) error {
+	responses, err := c.transport.Subscribe(ctx, nostr.Filter{
+		Kinds:   []int{KindRemoteSigning},
+		Authors: []string{c.remotePublicKey},
+		Tags:    nostr.TagMap{"p": []string{c.publicKey}},
+	})
+	if err != nil {
+		return err
+	}
+	go c.listen(responses)
+
+	if _, err := c.call(ctx, MethodConnect, c.remotePublicKey, c.secret); err != nil {
+		return fmt.Errorf("error connecting to the bunker: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) Sign(ctx context.Context, key signer.Key, event *nostr.Event) error {
+	event.PubKey = key.PublicKey
+	event.ID = event.GetID()
+	event.Sig = ""
+
+	unsigned, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// lets a bunker holding the secrets rather than the keys derive the key
+	params := append([]string{string(unsigned)}, key.Addresses...)
+	result, err := c.call(ctx, MethodSignEvent, params...)
+	if err != nil {
+		return err
+	}
+
+	var signed nostr.Event
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return fmt.Errorf("invalid signed event: %w", err)
+	}
+	if signed.ID != event.ID || signed.PubKey != event.PubKey {
+		return errors.New("the bunker signed a different event")
+	}
+	if ok, err := signed.CheckSignature(); err != nil || !ok {
+		return errors.New("the bunker returned an invalid signature")
+	}
+
+	event.Sig = signed.Sig
+	return nil
+}
+
+// DerivePublicKeys asks the bunker for the public keys of the feeds at the
+// addresses, derived with every version of the secret held by the bunker.
+func (c *Client) DerivePublicKeys(ctx context.Context, addresses ...string) ([]DerivedKey, error) {
+	result, err := c.call(ctx, MethodDerivePublicKeys, addresses...)
+	if err != nil {
+		return nil, err
+	}
+
+	var derived []DerivedKey
+	if err := json.Unmarshal([]byte(result), &derived); err != nil {
+		return nil, fmt.Errorf("invalid derived keys: %w", err)
+	}
+	for _, key := range derived {
+		if !nostr.IsValidPublicKeyHex(key.PublicKey) {
+			return nil, fmt.Errorf("the bunker derived an invalid public key %q", key.PublicKey)
+		}
+	}
+	return derived, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params ...string) (string, error) {
+	request := Request{
+		ID:     randomID(),
+		Method: method,
+		Params: params,
+	}
+
+	event, err := encryptedMessage(request, c.privateKey, c.remotePublicKey)
+	if err != nil {
+		return "", fmt.Errorf("error creating the request: %w", err)
+	}
+
+	ch := make(chan Response, 1)
+	c.mutex.Lock()
+	c.pending[request.ID] = ch
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, request.ID)
+		c.mutex.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if err := c.transport.Publish(ctx, event); err != nil {
+		return "", fmt.Errorf("error sending the request: %w", err)
+	}
+
+	select {
+	case response := <-ch:
+		if response.Error != "" {
+			return "", fmt.Errorf("the bunker failed to %s: %s", method, response.Error)
+		}
+		return response.Result, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("no response from the bunker to %s: %w", method, ctx.Err())
+	}
+}
+
+func (c *Client) listen(events <-chan nostr.Event) {
+	for event := range events {
+		var response Response
+		if err := decryptMessage(event, c.privateKey, &response); err != nil {
+			log.Printf("[DEBUG] ignoring invalid response from the bunker: %v", err)
+			continue
+		}
+
+		c.mutex.Lock()
+		ch, ok := c.pending[response.ID]
+		c.mutex.Unlock()
+		if !ok {
+			continue
+		}
+
+		select {
+		case ch <- response:
+		default:
+		}
+	}
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,261 @@
+// Package nip46 implements remote signing so that the private keys of the
+// feeds can be kept in a separate process, the bunker, which is reached
+// through a relay.
+// See https://github.com/nostr-protocol/nips/blob/master/46.md for details.
+//
+// Unlike most bunkers which hold a single key, the bunker of this package
+// holds the keys of many feeds and signs events with the key matching the
+// pubkey of the event to sign. It can also hold the secrets which the keys
+// of the feeds are derived from instead of the keys themselves, in which
+// case it answers the derive_public_keys requests, which aren't part of
+// NIP-46, and derives the key of a feed from the address sent along with
+// the event to sign.
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/nip44"
+)
+
+const (
+	KindRemoteSigning = 24133
+
+	MethodConnect      = "connect"
+	MethodSignEvent    = "sign_event"
+	MethodGetPublicKey = "get_public_key"
+	MethodPing         = "ping"
+
+	// MethodDerivePublicKeys returns the public keys derived from the
+	// addresses given as params, it isn't part of NIP-46.
+	MethodDerivePublicKeys = "derive_public_keys"
+
+	// resubscribeDelay is the time waited before subscribing again after
+	// losing the connection to the relay
+	resubscribeDelay = 5 * time.Second
+)
+
+type Request struct {
+	ID     string   `json:"id"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+type Response struct {
+	ID     string `json:"id"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DerivedKey is the key of a feed derived from its address with one of the
+// versions of the secret. The private key never leaves the bunker.
+type DerivedKey struct {
+	Address    string `json:"address"`
+	Version    int    `json:"version"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"-"`
+}
+
+// BunkerURL holds the details of a bunker URL such as
+// bunker://<public key>?relay=wss://relay.example.com&secret=<secret>.
+type BunkerURL struct {
+	PublicKey string
+	Relays    []string
+	Secret    string
+}
+
+func ParseBunkerURL(s string) (BunkerURL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return BunkerURL{}, fmt.Errorf("invalid bunker url: %w", err)
+	}
+	if u.Scheme != "bunker" {
+		return BunkerURL{}, fmt.Errorf("invalid bunker url scheme %q", u.Scheme)
+	}
+	if !nostr.IsValidPublicKeyHex(u.Host) {
+		return BunkerURL{}, fmt.Errorf("invalid bunker public key %q", u.Host)
+	}
+
+	bunkerURL := BunkerURL{
+		PublicKey: u.Host,
+		Secret:    u.Query().Get("secret"),
+	}
+	for _, relay := range u.Query()["relay"] {
+		if relay != "" {
+			bunkerURL.Relays = append(bunkerURL.Relays, relay)
+		}
+	}
+	if len(bunkerURL.Relays) == 0 {
+		return BunkerURL{}, fmt.Errorf("the bunker url has no relay")
+	}
+	return bunkerURL, nil
+}
+
+func (b BunkerURL) String() string {
+	query := url.Values{}
+	for _, relay := range b.Relays {
+		query.Add("relay", relay)
+	}
+	if b.Secret != "" {
+		query.Set("secret", b.Secret)
+	}
+	return "bunker://" + b.PublicKey + "?" + query.Encode()
+}
+
+// Transport carries the requests and responses between clients and bunkers.
+// Subscriptions only receive the events published after subscribing.
+type Transport interface {
+	Publish(ctx context.Context, event nostr.Event) error
+	Subscribe(ctx context.Context, filter nostr.Filter) (<-chan nostr.Event, error)
+}
+
+// RelayTransport exchanges the messages through a relay, reconnecting to it
+// when the connection is lost.
+type RelayTransport struct {
+	url string
+
+	mutex sync.Mutex
+	relay *nostr.Relay
+}
+
+func NewRelayTransport(url string) *RelayTransport {
+	return &RelayTransport{url: url}
+}
+
+func (t *RelayTransport) Publish(ctx context.Context, event nostr.Event) error {
+	relay, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := relay.Publish(ctx, event)
+	if err != nil {
+		return fmt.Errorf("error publishing to %s: %w", t.url, err)
+	}
+	if status == nostr.PublishStatusFailed {
+		return fmt.Errorf("error publishing to %s", t.url)
+	}
+	return nil
+}
+
+func (t *RelayTransport) Subscribe(ctx context.Context, filter nostr.Filter) (<-chan nostr.Event, error) {
+	sub, err := t.subscribe(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan nostr.Event)
+	go func() {
+		defer close(events)
+		for {
+			if sub != nil {
+				for event := range sub.Events {
+					select {
+					case events <- *event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeDelay):
+			}
+
+			if sub, err = t.subscribe(ctx, filter); err != nil {
+				log.Printf("[WARN] failure to subscribe to the remote signing relay: %v", err)
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (t *RelayTransport) subscribe(ctx context.Context, filter nostr.Filter) (*nostr.Subscription, error) {
+	relay, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	since := nostr.Now()
+	filter.Since = &since
+	sub, err := relay.Subscribe(ctx, nostr.Filters{filter})
+	if err != nil {
+		return nil, fmt.Errorf("error subscribing to %s: %w", t.url, err)
+	}
+	return sub, nil
+}
+
+func (t *RelayTransport) connect(ctx context.Context) (*nostr.Relay, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.relay != nil && t.relay.IsConnected() {
+		return t.relay, nil
+	}
+
+	relay, err := nostr.RelayConnect(ctx, t.url)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", t.url, err)
+	}
+	t.relay = relay
+	return relay, nil
+}
+
+// encryptedMessage creates the event carrying a request or a response.
+func encryptedMessage(message any, privateKey string, recipientPublicKey string) (nostr.Event, error) {
+	conversationKey, err := nip44.ConversationKey(privateKey, recipientPublicKey)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	plaintext, err := json.Marshal(message)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	content, err := nip44.Encrypt(string(plaintext), conversationKey)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	event := nostr.Event{
+		Kind:      KindRemoteSigning,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", recipientPublicKey}},
+		Content:   content,
+	}
+	if err := event.Sign(privateKey); err != nil {
+		return nostr.Event{}, err
+	}
+	return event, nil
+}
+
+// decryptMessage reads the request or response carried by an event.
+func decryptMessage(event nostr.Event, privateKey string, target any) error {
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return fmt.Errorf("invalid signature")
+	}
+
+	conversationKey, err := nip44.ConversationKey(privateKey, event.PubKey)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := nip44.Decrypt(event.Content, conversationKey)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(plaintext))
+	return decoder.Decode(target)
+}
@@ -0,0 +1,199 @@
+package nip46
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/signer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleSecret = "some secret"
+
+func TestClientSignsWithTheBunker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key := someKey(t)
+	client := startBunkerAndClient(ctx, t, sampleSecret, key)
+
+	event := nostr.Event{
+		Kind:      nostr.KindTextNote,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"t", "rss"}},
+		Content:   "hello",
+	}
+	require.NoError(t, client.Sign(ctx, signer.Key{PublicKey: key.PublicKey}, &event))
+
+	assert.Equal(t, key.PublicKey, event.PubKey)
+	assert.Equal(t, event.GetID(), event.ID)
+	ok, err := event.CheckSignature()
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestClientCantSignWithUnknownKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := startBunkerAndClient(ctx, t, sampleSecret, someKey(t))
+
+	event := nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "hello"}
+	err := client.Sign(ctx, someKey(t), &event)
+	assert.ErrorContains(t, err, "unknown key")
+}
+
+func TestClientSignsWithDerivedKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport := newMemoryTransport()
+	derived := map[string]DerivedKey{}
+	for _, address := range []string{"https://example.com/feed", "https://example.com/old"} {
+		key := someKey(t)
+		derived[address] = DerivedKey{Address: address, Version: 2, PublicKey: key.PublicKey, PrivateKey: key.PrivateKey}
+	}
+
+	bunker, err := NewBunker(transport, nostr.GeneratePrivateKey(), sampleSecret, nil, func(address string) ([]DerivedKey, error) {
+		if key, ok := derived[address]; ok {
+			return []DerivedKey{key}, nil
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, bunker.Start(ctx))
+
+	client, err := NewClient(transport, bunker.URL().PublicKey, sampleSecret, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(ctx))
+
+	publicKeys, err := client.DerivePublicKeys(ctx, "https://example.com/feed", "https://example.com/unknown")
+	require.NoError(t, err)
+	expected := derived["https://example.com/feed"]
+	expected.PrivateKey = ""
+	assert.Equal(t, []DerivedKey{expected}, publicKeys)
+
+	old := derived["https://example.com/old"]
+	event := nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "hello"}
+	require.NoError(t, client.Sign(ctx, signer.Key{PublicKey: old.PublicKey, Addresses: []string{"https://example.com/feed", "https://example.com/old"}}, &event))
+	ok, err := event.CheckSignature()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	event = nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "hello"}
+	err = client.Sign(ctx, signer.Key{PublicKey: old.PublicKey, Addresses: []string{"https://example.com/feed"}}, &event)
+	assert.ErrorContains(t, err, "unknown key")
+}
+
+func TestBunkerRejectsInvalidSecrets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport := newMemoryTransport()
+	bunker, err := NewBunker(transport, nostr.GeneratePrivateKey(), sampleSecret, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, bunker.Start(ctx))
+
+	client, err := NewClient(transport, bunker.URL().PublicKey, "wrong secret", time.Second)
+	require.NoError(t, err)
+	assert.ErrorContains(t, client.Connect(ctx), "invalid secret")
+
+	event := nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "hello"}
+	assert.ErrorContains(t, client.Sign(ctx, someKey(t), &event), "unauthorized")
+}
+
+func TestParseBunkerURL(t *testing.T) {
+	publicKey := someKey(t).PublicKey
+
+	bunkerURL, err := ParseBunkerURL("bunker://" + publicKey + "?relay=wss%3A%2F%2Frelay.example.com&secret=abc")
+	require.NoError(t, err)
+	assert.Equal(t, BunkerURL{PublicKey: publicKey, Relays: []string{"wss://relay.example.com"}, Secret: "abc"}, bunkerURL)
+
+	parsed, err := ParseBunkerURL(bunkerURL.String())
+	require.NoError(t, err)
+	assert.Equal(t, bunkerURL, parsed)
+
+	for _, invalid := range []string{
+		"nostrconnect://" + publicKey + "?relay=wss://relay.example.com",
+		"bunker://invalid?relay=wss://relay.example.com",
+		"bunker://" + publicKey,
+	} {
+		_, err := ParseBunkerURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func startBunkerAndClient(ctx context.Context, t *testing.T, secret string, keys ...signer.Key) *Client {
+	transport := newMemoryTransport()
+
+	bunker, err := NewBunker(transport, nostr.GeneratePrivateKey(), sampleSecret, func(publicKey string) (string, bool) {
+		for _, key := range keys {
+			if key.PublicKey == publicKey {
+				return key.PrivateKey, true
+			}
+		}
+		return "", false
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, bunker.Start(ctx))
+
+	client, err := NewClient(transport, bunker.URL().PublicKey, secret, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(ctx))
+	return client
+}
+
+func someKey(t *testing.T) signer.Key {
+	privateKey := nostr.GeneratePrivateKey()
+	publicKey, err := nostr.GetPublicKey(privateKey)
+	require.NoError(t, err)
+	return signer.Key{PublicKey: publicKey, PrivateKey: privateKey}
+}
+
+// memoryTransport delivers the events to the subscribers in process,
+// standing in for a relay.
+type memoryTransport struct {
+	mutex       sync.Mutex
+	subscribers map[*memorySubscriber]struct{}
+}
+
+type memorySubscriber struct {
+	filter nostr.Filter
+	events chan nostr.Event
+}
+
+func newMemoryTransport() *memoryTransport {
+	return &memoryTransport{subscribers: make(map[*memorySubscriber]struct{})}
+}
+
+func (m *memoryTransport) Publish(_ context.Context, event nostr.Event) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for subscriber := range m.subscribers {
+		if subscriber.filter.Matches(&event) {
+			subscriber.events <- event
+		}
+	}
+	return nil
+}
+
+func (m *memoryTransport) Subscribe(ctx context.Context, filter nostr.Filter) (<-chan nostr.Event, error) {
+	subscriber := &memorySubscriber{filter: filter, events: make(chan nostr.Event, 100)}
+
+	m.mutex.Lock()
+	m.subscribers[subscriber] = struct{}{}
+	m.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mutex.Lock()
+		delete(m.subscribers, subscriber)
+		close(subscriber.events)
+		m.mutex.Unlock()
+	}()
+	return subscriber.events, nil
+}
@@ -0,0 +1,74 @@
+package nip46
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/piraces/rsslay/pkg/signer"
+)
+
+// Pool keeps a connected client for each bunker URL so that events can be
+// signed with the remote signers of many users.
+type Pool struct {
+	timeout      time.Duration
+	newTransport func(relay string) Transport
+
+	mutex   sync.Mutex
+	clients map[string]pooledClient
+}
+
+type pooledClient struct {
+	client *Client
+	cancel context.CancelFunc
+}
+
+func NewPool(timeout time.Duration, newTransport func(relay string) Transport) *Pool {
+	return &Pool{
+		timeout:      timeout,
+		newTransport: newTransport,
+		clients:      make(map[string]pooledClient),
+	}
+}
+
+// Signer returns the client connected to the bunker, connecting to it on
+// first use.
+func (p *Pool) Signer(_ context.Context, rawBunkerURL string) (signer.Signer, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if pooled, ok := p.clients[rawBunkerURL]; ok {
+		return pooled.client, nil
+	}
+
+	bunkerURL, err := ParseBunkerURL(rawBunkerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(p.newTransport(bunkerURL.Relays[0]), bunkerURL.PublicKey, bunkerURL.Secret, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// the client keeps listening to the bunker for as long as the pool is used
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := client.Connect(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	p.clients[rawBunkerURL] = pooledClient{client: client, cancel: cancel}
+	return client, nil
+}
+
+// Remove disconnects from the bunker.
+func (p *Pool) Remove(rawBunkerURL string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if pooled, ok := p.clients[rawBunkerURL]; ok {
+		pooled.cancel()
+		delete(p.clients, rawBunkerURL)
+	}
+}
@@ -0,0 +1,141 @@
+// Package nip59 implements gift wrapping which is used by NIP-17 private
+// direct messages.
+// See https://github.com/nostr-protocol/nips/blob/master/59.md for details.
+package nip59
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/piraces/rsslay/pkg/nip44"
+)
+
+const (
+	KindSeal     = 13
+	KindGiftWrap = 1059
+
+	// KindPrivateDirectMessage is the kind of the rumors carried by NIP-17
+	// direct messages.
+	KindPrivateDirectMessage = 14
+
+	// timestamps of seals and gift wraps are randomized up to two days in
+	// the past so that they can't be correlated with the rumor
+	maxTimestampTweak = 2 * 24 * time.Hour
+)
+
+// Wrap seals the rumor with the private key of its author and wraps the seal
+// with an ephemeral key so that it can only be read by the recipient.
+func Wrap(rumor nostr.Event, senderPrivateKey string, recipientPublicKey string) (nostr.Event, error) {
+	senderPublicKey, err := nostr.GetPublicKey(senderPrivateKey)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("error getting the sender public key: %w", err)
+	}
+
+	rumor.PubKey = senderPublicKey
+	rumor.ID = rumor.GetID()
+	rumor.Sig = ""
+
+	seal, err := encryptedEvent(KindSeal, rumor, senderPrivateKey, recipientPublicKey, nil)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("error creating the seal: %w", err)
+	}
+
+	wrap, err := encryptedEvent(KindGiftWrap, seal, nostr.GeneratePrivateKey(), recipientPublicKey, nostr.Tags{{"p", recipientPublicKey}})
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("error creating the gift wrap: %w", err)
+	}
+
+	return wrap, nil
+}
+
+// Unwrap opens a gift wrap addressed to the owner of the private key and
+// returns the rumor after checking that it was sealed by its author.
+func Unwrap(wrap nostr.Event, recipientPrivateKey string) (nostr.Event, error) {
+	if wrap.Kind != KindGiftWrap {
+		return nostr.Event{}, fmt.Errorf("kind %d is not a gift wrap", wrap.Kind)
+	}
+
+	var seal nostr.Event
+	if err := decryptEvent(wrap, recipientPrivateKey, &seal); err != nil {
+		return nostr.Event{}, fmt.Errorf("error opening the gift wrap: %w", err)
+	}
+
+	if seal.Kind != KindSeal {
+		return nostr.Event{}, fmt.Errorf("kind %d is not a seal", seal.Kind)
+	}
+
+	if ok, err := seal.CheckSignature(); err != nil || !ok {
+		return nostr.Event{}, errors.New("invalid seal signature")
+	}
+
+	var rumor nostr.Event
+	if err := decryptEvent(seal, recipientPrivateKey, &rumor); err != nil {
+		return nostr.Event{}, fmt.Errorf("error opening the seal: %w", err)
+	}
+
+	if rumor.PubKey != seal.PubKey {
+		return nostr.Event{}, errors.New("rumor wasn't sealed by its author")
+	}
+
+	if rumor.ID != rumor.GetID() {
+		return nostr.Event{}, errors.New("invalid rumor id")
+	}
+
+	return rumor, nil
+}
+
+func encryptedEvent(kind int, content nostr.Event, privateKey string, recipientPublicKey string, tags nostr.Tags) (nostr.Event, error) {
+	plaintext, err := json.Marshal(content)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("error marshaling the event: %w", err)
+	}
+
+	conversationKey, err := nip44.ConversationKey(privateKey, recipientPublicKey)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	ciphertext, err := nip44.Encrypt(string(plaintext), conversationKey)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+
+	event := nostr.Event{
+		Kind:      kind,
+		Content:   ciphertext,
+		Tags:      tags,
+		CreatedAt: randomPastTimestamp(),
+	}
+	if event.Tags == nil {
+		event.Tags = nostr.Tags{}
+	}
+
+	if err := event.Sign(privateKey); err != nil {
+		return nostr.Event{}, fmt.Errorf("error signing the event: %w", err)
+	}
+
+	return event, nil
+}
+
+func decryptEvent(event nostr.Event, privateKey string, target *nostr.Event) error {
+	conversationKey, err := nip44.ConversationKey(privateKey, event.PubKey)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := nip44.Decrypt(event.Content, conversationKey)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(plaintext), target)
+}
+
+func randomPastTimestamp() nostr.Timestamp {
+	tweak := time.Duration(rand.Int63n(int64(maxTimestampTweak)))
+	return nostr.Timestamp(time.Now().Add(-tweak).Unix())
+}
@@ -0,0 +1,42 @@
+package nip59
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapUnwrap(t *testing.T) {
+	senderPrivateKey := nostr.GeneratePrivateKey()
+	senderPublicKey, _ := nostr.GetPublicKey(senderPrivateKey)
+	recipientPrivateKey := nostr.GeneratePrivateKey()
+	recipientPublicKey, _ := nostr.GetPublicKey(recipientPrivateKey)
+
+	rumor := nostr.Event{
+		Kind:      KindPrivateDirectMessage,
+		Content:   "hello",
+		Tags:      nostr.Tags{{"p", recipientPublicKey}},
+		CreatedAt: nostr.Now(),
+	}
+
+	wrap, err := Wrap(rumor, senderPrivateKey, recipientPublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, KindGiftWrap, wrap.Kind)
+	assert.NotEqual(t, senderPublicKey, wrap.PubKey)
+	assert.Equal(t, recipientPublicKey, wrap.Tags.GetFirst([]string{"p"}).Value())
+
+	ok, err := wrap.CheckSignature()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	unwrapped, err := Unwrap(wrap, recipientPrivateKey)
+	require.NoError(t, err)
+	assert.Equal(t, senderPublicKey, unwrapped.PubKey)
+	assert.Equal(t, "hello", unwrapped.Content)
+	assert.Empty(t, unwrapped.Sig)
+
+	_, err = Unwrap(wrap, nostr.GeneratePrivateKey())
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+// Package nip98 implements validation of NIP-98 HTTP auth events.
+// See https://github.com/nostr-protocol/nips/blob/master/98.md for details.
+package nip98
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	Kind         = 27235
+	headerPrefix = "Nostr "
+	maxClockSkew = 60 * time.Second
+)
+
+var (
+	ErrMissingAuthorization = errors.New("missing nostr authorization header")
+	ErrInvalidEvent         = errors.New("invalid authorization event")
+)
+
+// ValidateRequest checks the authorization header of the request and returns
+// the public key which signed it. The "u" tag is compared ignoring the scheme
+// so that events created for the websocket URL of the relay are accepted too.
+// If the request has a body then the event must commit to it with a
+// "payload" tag.
+func ValidateRequest(r *http.Request, body []byte) (string, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, headerPrefix) {
+		return "", ErrMissingAuthorization
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, headerPrefix))
+	if err != nil {
+		return "", ErrInvalidEvent
+	}
+
+	var event nostr.Event
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return "", ErrInvalidEvent
+	}
+
+	if event.Kind != Kind {
+		return "", errors.New("authorization event has the wrong kind")
+	}
+
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return "", errors.New("authorization event has an invalid signature")
+	}
+
+	if skew := time.Since(event.CreatedAt.Time()); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", errors.New("authorization event is too old or too far in the future")
+	}
+
+	if !sameURL(tagValue(event, "u"), RequestURL(r)) {
+		return "", errors.New("authorization event is for a different url")
+	}
+
+	if !strings.EqualFold(tagValue(event, "method"), r.Method) {
+		return "", errors.New("authorization event is for a different method")
+	}
+
+	if len(body) > 0 {
+		hash := sha256.Sum256(body)
+		if !strings.EqualFold(tagValue(event, "payload"), hex.EncodeToString(hash[:])) {
+			return "", errors.New("authorization event payload doesn't match the body")
+		}
+	}
+
+	return event.PubKey, nil
+}
+
+// NewEvent creates an unsigned authorization event for a request.
+func NewEvent(method, rawUrl string, body []byte) nostr.Event {
+	tags := nostr.Tags{{"u", rawUrl}, {"method", method}}
+	if len(body) > 0 {
+		hash := sha256.Sum256(body)
+		tags = append(tags, nostr.Tag{"payload", hex.EncodeToString(hash[:])})
+	}
+
+	return nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      Kind,
+		Tags:      tags,
+	}
+}
+
+// Header builds the value of the authorization header for a signed event.
+func Header(event nostr.Event) string {
+	raw, _ := json.Marshal(event)
+	return headerPrefix + base64.StdEncoding.EncodeToString(raw)
+}
+
+// RequestURL reconstructs the absolute URL a client used to reach us.
+func RequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func tagValue(event nostr.Event, name string) string {
+	tag := event.Tags.GetFirst([]string{name, ""})
+	if tag == nil {
+		return ""
+	}
+	return tag.Value()
+}
+
+func sameURL(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(ua.Host, ub.Host) &&
+		strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/") &&
+		ua.RawQuery == ub.RawQuery
+}
@@ -0,0 +1,63 @@
+package nip98
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedHeader(t *testing.T, privateKey string, event nostr.Event) string {
+	require.NoError(t, event.Sign(privateKey))
+	return Header(event)
+}
+
+func TestValidateRequestReturnsSigner(t *testing.T) {
+	privateKey := nostr.GeneratePrivateKey()
+	publicKey, _ := nostr.GetPublicKey(privateKey)
+	body := []byte(`{"method":"supportedmethods","params":[]}`)
+
+	r := httptest.NewRequest("POST", "http://relay.example.com/", strings.NewReader(string(body)))
+	r.Header.Set("Authorization", signedHeader(t, privateKey, NewEvent("POST", "wss://relay.example.com", body)))
+
+	signer, err := ValidateRequest(r, body)
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, signer)
+}
+
+func TestValidateRequestRejectsInvalidEvents(t *testing.T) {
+	privateKey := nostr.GeneratePrivateKey()
+	body := []byte(`{}`)
+
+	oldEvent := NewEvent("POST", "https://relay.example.com/", body)
+	oldEvent.CreatedAt = nostr.Timestamp(time.Now().Add(-time.Hour).Unix())
+
+	wrongKindEvent := NewEvent("POST", "https://relay.example.com/", body)
+	wrongKindEvent.Kind = nostr.KindTextNote
+
+	testCases := map[string]string{
+		"missing header": "",
+		"garbage":        "Nostr !!!",
+		"wrong url":      signedHeader(t, privateKey, NewEvent("POST", "https://other.example.com/", body)),
+		"wrong method":   signedHeader(t, privateKey, NewEvent("GET", "https://relay.example.com/", body)),
+		"wrong payload":  signedHeader(t, privateKey, NewEvent("POST", "https://relay.example.com/", []byte(`{"a":1}`))),
+		"old event":      signedHeader(t, privateKey, oldEvent),
+		"wrong kind":     signedHeader(t, privateKey, wrongKindEvent),
+	}
+
+	for name, header := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "https://relay.example.com/", strings.NewReader(string(body)))
+			if header != "" {
+				r.Header.Set("Authorization", header)
+			}
+
+			_, err := ValidateRequest(r, body)
+			assert.Error(t, err)
+		})
+	}
+}
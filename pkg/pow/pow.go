@@ -0,0 +1,85 @@
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip13"
+)
+
+var (
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	ErrExpiredChallenge = errors.New("the challenge has expired")
+	ErrNotEnoughWork    = errors.New("not enough proof of work")
+)
+
+// Challenges issues proof of work challenges and verifies their solutions
+// without keeping any state. A challenge is the time it was issued signed
+// with the secret, a solution is a nonce such that the SHA-256 hash of the
+// challenge, the subject and the nonce joined by ":" starts with the required
+// number of zero bits. The subject ties the work to what is being submitted.
+// Challenges with a non-positive difficulty are disabled.
+type Challenges struct {
+	secret     []byte
+	difficulty int
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+func New(secret string, difficulty int, ttl time.Duration) *Challenges {
+	return &Challenges{
+		secret:     []byte(secret),
+		difficulty: difficulty,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+func (c *Challenges) Enabled() bool {
+	return c.difficulty > 0
+}
+
+func (c *Challenges) Difficulty() int {
+	return c.difficulty
+}
+
+func (c *Challenges) Issue() string {
+	issuedAt := strconv.FormatInt(c.now().Unix(), 10)
+	return issuedAt + "." + c.sign(issuedAt)
+}
+
+func (c *Challenges) Verify(challenge string, subject string, nonce string) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	issuedAt, signature, ok := strings.Cut(challenge, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(issuedAt))) {
+		return ErrInvalidChallenge
+	}
+
+	unix, err := strconv.ParseInt(issuedAt, 10, 64)
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+	if c.now().Sub(time.Unix(unix, 0)) > c.ttl {
+		return ErrExpiredChallenge
+	}
+
+	hash := sha256.Sum256([]byte(challenge + ":" + subject + ":" + nonce))
+	if nip13.Difficulty(hex.EncodeToString(hash[:])) < c.difficulty {
+		return ErrNotEnoughWork
+	}
+	return nil
+}
+
+func (c *Challenges) sign(s string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte("pow:" + s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package pow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip13"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func solve(challenge string, subject string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		hash := sha256.Sum256([]byte(challenge + ":" + subject + ":" + nonce))
+		if nip13.Difficulty(hex.EncodeToString(hash[:])) >= difficulty {
+			return nonce
+		}
+	}
+}
+
+func TestChallengesVerifiesSolutions(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New("secret", 8, time.Minute)
+	c.now = func() time.Time { return now }
+
+	challenge := c.Issue()
+	nonce := solve(challenge, "https://example.com/feed", 8)
+
+	require.NoError(t, c.Verify(challenge, "https://example.com/feed", nonce))
+	assert.ErrorIs(t, c.Verify(challenge, "https://example.com/other", solve(challenge, "https://example.com/other", 0)), ErrNotEnoughWork)
+	assert.ErrorIs(t, c.Verify(challenge+"0", "https://example.com/feed", nonce), ErrInvalidChallenge)
+	assert.ErrorIs(t, New("other", 8, time.Minute).Verify(challenge, "https://example.com/feed", nonce), ErrInvalidChallenge)
+
+	now = now.Add(2 * time.Minute)
+	assert.ErrorIs(t, c.Verify(challenge, "https://example.com/feed", nonce), ErrExpiredChallenge)
+}
+
+func TestDisabledChallengesAcceptEverything(t *testing.T) {
+	c := New("secret", 0, time.Minute)
+	assert.False(t, c.Enabled())
+	assert.NoError(t, c.Verify("", "", ""))
+}
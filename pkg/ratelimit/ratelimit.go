@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to limit operations per key within a sliding window. A
+// limiter with a non-positive limit allows everything.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	lock      sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+		now:    time.Now,
+	}
+}
+
+// SetLimit changes the limit and the window, operations recorded so far
+// count towards the new limit.
+func (l *Limiter) SetLimit(limit int, window time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.limit = limit
+	l.window = window
+}
+
+// Allow records an operation for the given key and reports whether it fits
+// within the limit.
+func (l *Limiter) Allow(key string) bool {
+	l.lock.Lock()
+	limit := l.limit
+	l.lock.Unlock()
+
+	return l.AllowN(key, limit)
+}
+
+// AllowN is like Allow with a limit of its own for the key, for example for
+// clients with a larger quota.
+func (l *Limiter) AllowN(key string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	hits := l.prune(l.hits[key], now)
+	if len(hits) >= limit {
+		l.hits[key] = hits
+		return false
+	}
+
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+func (l *Limiter) prune(hits []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
+
+// sweep drops keys which haven't been seen for a whole window so that the map
+// doesn't grow forever.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, hits := range l.hits {
+		if hits = l.prune(hits, now); len(hits) == 0 {
+			delete(l.hits, key)
+		} else {
+			l.hits[key] = hits
+		}
+	}
+}
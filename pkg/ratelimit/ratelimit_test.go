@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllowsUpToLimitWithinWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	assert.True(t, l.Allow("b"))
+
+	now = now.Add(time.Minute)
+	assert.True(t, l.Allow("a"))
+}
+
+func TestLimiterWithNonPositiveLimitAllowsEverything(t *testing.T) {
+	l := New(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.Allow("a"))
+	}
+}
+
+func TestLimiterAllowsKeysWithTheirOwnLimit(t *testing.T) {
+	l := New(1, time.Minute)
+
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	assert.True(t, l.AllowN("b", 2))
+	assert.True(t, l.AllowN("b", 2))
+	assert.False(t, l.AllowN("b", 2))
+}
+
+func TestLimiterSweepsStaleKeys(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := New(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	now = now.Add(2 * time.Minute)
+	l.Allow("b")
+
+	assert.NotContains(t, l.hits, "a")
+	assert.Contains(t, l.hits, "b")
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := New(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	l.SetLimit(2, time.Hour)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	now = now.Add(time.Minute)
+	assert.False(t, l.Allow("a"), "the new window applies to the recorded operations")
+
+	l.SetLimit(0, time.Hour)
+	assert.True(t, l.Allow("a"))
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/piraces/rsslay/pkg/metrics"
+	"github.com/piraces/rsslay/pkg/signer"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -20,6 +21,7 @@ type ReplayParameters struct {
 	WaitTime                 int64
 	WaitTimeForRelayResponse int64
 	Events                   []EventWithPrivateKey
+	Signer                   signer.Signer
 }
 
 type EventWithPrivateKey struct {
@@ -46,7 +48,7 @@ func ReplayEventsToRelays(parameters *ReplayParameters) {
 		for _, url := range parameters.RelaysToPublish {
 			statusSummary := 0
 			for _, ev := range parameters.Events {
-				relay := connectToRelay(url, ev.PrivateKey)
+				relay := connectToRelay(url, parameters.Signer, signer.Key{PublicKey: ev.Event.PubKey, PrivateKey: ev.PrivateKey})
 				if relay == nil {
 					continue
 				}
@@ -85,9 +87,9 @@ func publishEvent(relay *nostr.Relay, ev nostr.Event, url string) nostr.Status {
 	return publishStatus
 }
 
-func connectToRelay(url string, privateKey string) *nostr.Relay {
+func connectToRelay(url string, eventSigner signer.Signer, key signer.Key) *nostr.Relay {
 	relay, e := nostr.RelayConnect(context.Background(), url, nostr.WithAuthHandler(func(ctx context.Context, authEvent *nostr.Event) (ok bool) {
-		err := authEvent.Sign(privateKey)
+		err := eventSigner.Sign(ctx, key, authEvent)
 		if err != nil {
 			log.Printf("[ERROR] Error while trying to authenticate with relay '%s': %v", url, err)
 			return false
@@ -0,0 +1,43 @@
+// Package signer signs the events published on behalf of the feeds.
+package signer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Key identifies the feed signing an event. Signers which keep the keys in
+// a separate process only use the public key and the addresses.
+type Key struct {
+	PublicKey  string
+	PrivateKey string
+
+	// Addresses may have been used to derive the key of the feed, they let
+	// signers holding the secrets rather than the keys derive the key.
+	Addresses []string
+}
+
+// Signer signs events, setting their public key, id and signature.
+type Signer interface {
+	Sign(ctx context.Context, key Key, event *nostr.Event) error
+}
+
+// Local signs events in process with the private key of the feed.
+type Local struct {
+}
+
+func NewLocal() *Local {
+	return &Local{}
+}
+
+func (l *Local) Sign(_ context.Context, key Key, event *nostr.Event) error {
+	if err := event.Sign(key.PrivateKey); err != nil {
+		return err
+	}
+	if key.PublicKey != "" && event.PubKey != key.PublicKey {
+		return fmt.Errorf("the private key doesn't belong to %s", key.PublicKey)
+	}
+	return nil
+}
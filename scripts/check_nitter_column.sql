@@ -1 +0,0 @@
-SELECT nitter from feeds
\ No newline at end of file
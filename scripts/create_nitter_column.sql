@@ -1 +0,0 @@
-ALTER TABLE feeds ADD COLUMN nitter INTEGER DEFAULT 0
\ No newline at end of file
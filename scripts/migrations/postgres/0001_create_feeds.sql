@@ -0,0 +1,5 @@
+CREATE TABLE feeds (
+   publickey VARCHAR(64) PRIMARY KEY,
+   privatekey VARCHAR(64) NOT NULL,
+   url TEXT NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN nitter BOOLEAN NOT NULL DEFAULT FALSE;
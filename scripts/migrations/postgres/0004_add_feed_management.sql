@@ -0,0 +1,16 @@
+ALTER TABLE feeds ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE banned_domains (
+   domain TEXT PRIMARY KEY,
+   reason TEXT NOT NULL DEFAULT '',
+   created_at BIGINT NOT NULL
+);
+
+CREATE TABLE audit_log (
+   id BIGSERIAL PRIMARY KEY,
+   pubkey VARCHAR(64) NOT NULL,
+   method TEXT NOT NULL,
+   params TEXT NOT NULL,
+   error TEXT NOT NULL DEFAULT '',
+   created_at BIGINT NOT NULL
+);
@@ -0,0 +1,18 @@
+CREATE TABLE feed_events (
+   id VARCHAR(64) PRIMARY KEY,
+   pubkey VARCHAR(64) NOT NULL,
+   kind INTEGER NOT NULL,
+   created_at BIGINT NOT NULL,
+   event TEXT NOT NULL
+);
+
+CREATE INDEX feed_events_pubkey ON feed_events (pubkey);
+
+CREATE TABLE feed_event_tags (
+   event_id VARCHAR(64) NOT NULL REFERENCES feed_events (id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   value TEXT NOT NULL
+);
+
+CREATE INDEX feed_event_tags_name_value ON feed_event_tags (name, value);
+CREATE INDEX feed_event_tags_event_id ON feed_event_tags (event_id);
@@ -0,0 +1,9 @@
+ALTER TABLE feeds ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN link TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN image TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN item_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN last_fetched_at BIGINT;
+ALTER TABLE feeds ADD COLUMN last_success_at BIGINT;
+ALTER TABLE feeds ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
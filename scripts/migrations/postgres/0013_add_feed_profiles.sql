@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN profile TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+CREATE TABLE api_keys (
+   id TEXT PRIMARY KEY,
+   name TEXT NOT NULL,
+   scopes TEXT NOT NULL,
+   quota INTEGER NOT NULL DEFAULT 0,
+   secret_hash TEXT NOT NULL,
+   created_at BIGINT NOT NULL
+);
+
+ALTER TABLE feeds ADD COLUMN submitter TEXT NOT NULL DEFAULT '';
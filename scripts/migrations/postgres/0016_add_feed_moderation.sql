@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- encrypted private keys are longer than the 64 characters of a hex key
+ALTER TABLE feeds ALTER COLUMN privatekey TYPE TEXT;
@@ -0,0 +1,6 @@
+-- applied-if: SELECT publickey FROM feeds
+CREATE TABLE feeds (
+   publickey VARCHAR(64) PRIMARY KEY,
+   privatekey VARCHAR(64) NOT NULL,
+   url TEXT NOT NULL
+);
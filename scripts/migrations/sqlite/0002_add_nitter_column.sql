@@ -0,0 +1,2 @@
+-- applied-if: SELECT nitter FROM feeds
+ALTER TABLE feeds ADD COLUMN nitter INTEGER DEFAULT 0;
@@ -0,0 +1,15 @@
+CREATE TABLE user_events (
+   id VARCHAR(64) PRIMARY KEY,
+   pubkey VARCHAR(64) NOT NULL,
+   kind INTEGER NOT NULL,
+   created_at INTEGER NOT NULL,
+   event TEXT NOT NULL
+);
+
+CREATE TABLE user_event_tags (
+   event_id VARCHAR(64) NOT NULL REFERENCES user_events (id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   value TEXT NOT NULL
+);
+
+CREATE INDEX user_event_tags_name_value ON user_event_tags (name, value);
@@ -0,0 +1,16 @@
+ALTER TABLE feeds ADD COLUMN disabled INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS banned_domains (
+   domain TEXT PRIMARY KEY,
+   reason TEXT NOT NULL DEFAULT '',
+   created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   pubkey VARCHAR(64) NOT NULL,
+   method TEXT NOT NULL,
+   params TEXT NOT NULL,
+   error TEXT NOT NULL DEFAULT '',
+   created_at INTEGER NOT NULL
+);
@@ -0,0 +1,3 @@
+ALTER TABLE feeds ADD COLUMN slug TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS feeds_slug ON feeds (slug);
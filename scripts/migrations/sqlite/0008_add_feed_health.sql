@@ -0,0 +1,5 @@
+ALTER TABLE feeds ADD COLUMN health_state TEXT NOT NULL DEFAULT 'healthy';
+ALTER TABLE feeds ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN error_kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN failing_since INTEGER;
+ALTER TABLE feeds ADD COLUMN next_fetch_at INTEGER;
@@ -0,0 +1,4 @@
+ALTER TABLE feeds ADD COLUMN moved_from TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN moved_at INTEGER;
+
+CREATE INDEX IF NOT EXISTS feeds_url ON feeds (url);
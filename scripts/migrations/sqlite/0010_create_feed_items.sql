@@ -0,0 +1,7 @@
+CREATE TABLE feed_items (
+   publickey VARCHAR(64) NOT NULL,
+   guid TEXT NOT NULL,
+   PRIMARY KEY (publickey, guid)
+);
+
+CREATE INDEX feed_items_guid ON feed_items (guid);
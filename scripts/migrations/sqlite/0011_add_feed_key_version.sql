@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+ALTER TABLE feeds ADD COLUMN owner_pubkey TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN owner_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN owner_verified_at INTEGER;
+ALTER TABLE feeds ADD COLUMN owner_bunker_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN owner_delegation TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS feeds_owner_pubkey ON feeds (owner_pubkey);
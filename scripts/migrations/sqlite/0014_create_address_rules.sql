@@ -0,0 +1,6 @@
+CREATE TABLE address_rules (
+   pattern TEXT PRIMARY KEY,
+   action TEXT NOT NULL,
+   reason TEXT NOT NULL DEFAULT '',
+   created_at INTEGER NOT NULL
+);
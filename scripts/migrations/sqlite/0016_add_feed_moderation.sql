@@ -0,0 +1 @@
+ALTER TABLE feeds ADD COLUMN pending INTEGER NOT NULL DEFAULT 0;
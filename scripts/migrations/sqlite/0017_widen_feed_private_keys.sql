@@ -0,0 +1,3 @@
+-- SQLite doesn't enforce the length of VARCHAR columns so encrypted private
+-- keys already fit, the migration keeps the versions of both databases aligned
+SELECT 1;
@@ -0,0 +1,5 @@
+-- the canonical addresses of existing feeds are filled by the relay when it
+-- starts since canonicalizing them can't be done in SQL
+ALTER TABLE feeds ADD COLUMN canonical_url TEXT;
+
+CREATE INDEX feeds_canonical_url ON feeds (canonical_url);
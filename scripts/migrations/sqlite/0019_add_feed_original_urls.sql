@@ -0,0 +1,6 @@
+-- the keys of feeds are derived from the address they were created with,
+-- which moves don't change. Feeds which moved more than once before this
+-- migration only kept their previous address.
+ALTER TABLE feeds ADD COLUMN original_url TEXT;
+
+UPDATE feeds SET original_url = CASE WHEN moved_from <> '' THEN moved_from ELSE url END;
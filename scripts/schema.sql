@@ -1,7 +0,0 @@
-CREATE TABLE IF NOT EXISTS feeds (
-   publickey VARCHAR(64) PRIMARY KEY,
-   privatekey VARCHAR(64) NOT NULL,
-   url TEXT NOT NULL,
-   nitter INTEGER DEFAULT 0
-);
-
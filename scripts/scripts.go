@@ -1,12 +1,9 @@
 package scripts
 
-import _ "embed"
+import "embed"
 
-//go:embed schema.sql
-var SchemaSQL string
-
-//go:embed check_nitter_column.sql
-var CheckNitterColumnSQL string
-
-//go:embed create_nitter_column.sql
-var CreateNitterColumnSQL string
+// Migrations contains the schema migrations of every supported database, one
+// directory per database, see the migrations package for the file format.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var Migrations embed.FS
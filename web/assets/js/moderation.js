@@ -0,0 +1,180 @@
+// Lists the feeds awaiting approval and approves or rejects them through the
+// API. Requests are authenticated with an API key kept for the session or
+// with NIP-98 events signed by the NIP-07 extension of the browser.
+const moderationKeyStorageKey = "rsslay.apiKey";
+
+async function sha256Hex(text) {
+    const digest = await crypto.subtle.digest('SHA-256', new TextEncoder().encode(text));
+    return [...new Uint8Array(digest)].map(b => b.toString(16).padStart(2, '0')).join('');
+}
+
+async function authorization(method, url, body) {
+    const apiKey = sessionStorage.getItem(moderationKeyStorageKey);
+    if (apiKey) {
+        return `Bearer ${apiKey}`;
+    }
+
+    if (typeof window.nostr === 'undefined') {
+        throw new Error("Enter an API key or install a NIP-07 extension to sign in.");
+    }
+
+    const tags = [["u", url], ["method", method]];
+    if (body) {
+        tags.push(["payload", await sha256Hex(body)]);
+    }
+    const event = await window.nostr.signEvent({
+        kind: 27235,
+        created_at: Math.floor(Date.now() / 1000),
+        tags: tags,
+        content: "",
+    });
+    return `Nostr ${btoa(JSON.stringify(event))}`;
+}
+
+async function apiRequest(method, path, body) {
+    const url = new URL(path, window.location.origin).toString();
+    const raw = body ? JSON.stringify(body) : undefined;
+    const response = await fetch(url, {
+        method: method,
+        headers: {
+            'Authorization': await authorization(method, url, raw),
+            'Content-Type': 'application/json',
+        },
+        body: raw,
+    });
+
+    if (response.status === 204) {
+        return null;
+    }
+
+    const result = await response.json();
+    if (!response.ok) {
+        throw new Error(result.error ? result.error.message : response.statusText);
+    }
+    return result;
+}
+
+function element(tag, className, text) {
+    const el = document.createElement(tag);
+    if (className) {
+        el.className = className;
+    }
+    if (text) {
+        el.textContent = text;
+    }
+    return el;
+}
+
+function profileOf(feed) {
+    if (!feed.preview) {
+        return {};
+    }
+    try {
+        return JSON.parse(feed.preview.metadata.content);
+    } catch (e) {
+        return {};
+    }
+}
+
+function previewOf(feed, profile) {
+    const preview = element('div', 'content');
+    if (feed.preview_error) {
+        preview.appendChild(element('p', 'has-text-danger', `The feed couldn't be previewed: ${feed.preview_error}`));
+        return preview;
+    }
+    if (!feed.preview) {
+        return preview;
+    }
+
+    preview.appendChild(element('p', '', profile.about || ''));
+    const items = element('ul');
+    feed.preview.items.forEach(item => {
+        const title = item.tags.find(tag => tag[0] === 'title');
+        items.appendChild(element('li', '', title ? title[1] : item.content.slice(0, 200)));
+    });
+    preview.appendChild(items);
+    preview.appendChild(element('p', 'is-size-7', `${feed.preview.total_items} items, ${feed.preview.mode} output`));
+    return preview;
+}
+
+function feedBox(feed) {
+    const profile = profileOf(feed);
+    const box = element('div', 'box');
+    box.appendChild(element('p', 'title is-5', profile.name || feed.url));
+    const link = element('a', '', feed.url);
+    link.href = feed.url;
+    link.target = '_blank';
+    link.rel = 'noopener';
+    box.appendChild(link);
+    if (feed.submitter) {
+        box.appendChild(element('p', 'is-size-7', `Submitted by ${feed.submitter}`));
+    }
+    box.appendChild(previewOf(feed, profile));
+
+    const buttons = element('div', 'buttons');
+    const approve = element('button', 'button is-success', 'Approve');
+    approve.addEventListener('click', () => decide(approve, `/api/v1/feeds/${feed.pubkey}/approve`));
+    const reject = element('button', 'button is-danger', 'Reject');
+    reject.addEventListener('click', () => {
+        const reason = prompt("Reason sent to the submitter (optional):", "");
+        if (reason !== null) {
+            decide(reject, `/api/v1/feeds/${feed.pubkey}/reject`, reason ? {reason: reason} : undefined);
+        }
+    });
+    buttons.appendChild(approve);
+    buttons.appendChild(reject);
+    box.appendChild(buttons);
+    return box;
+}
+
+async function decide(button, path, body) {
+    button.classList.add('is-loading');
+    try {
+        await apiRequest('POST', path, body);
+        await loadPendingFeeds();
+    } catch (e) {
+        showError(e);
+    } finally {
+        button.classList.remove('is-loading');
+    }
+}
+
+function showError(e) {
+    const notification = document.getElementById('moderation-error');
+    notification.textContent = e.message;
+    notification.classList.remove('is-hidden');
+}
+
+async function loadPendingFeeds() {
+    const list = document.getElementById('pending-feeds');
+    document.getElementById('moderation-error').classList.add('is-hidden');
+    list.replaceChildren(element('progress', 'progress is-small is-primary'));
+
+    try {
+        const page = await apiRequest('GET', '/api/v1/pending');
+        list.replaceChildren();
+        document.getElementById('pending-count').textContent = page.total;
+        if (page.feeds.length === 0) {
+            list.appendChild(element('p', '', "No feeds await approval."));
+        }
+        page.feeds.forEach(feed => list.appendChild(feedBox(feed)));
+    } catch (e) {
+        list.replaceChildren();
+        showError(e);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    const form = document.getElementById('api-key-form');
+    form.elements['key'].value = sessionStorage.getItem(moderationKeyStorageKey) || '';
+    form.addEventListener('submit', event => {
+        event.preventDefault();
+        const key = form.elements['key'].value.trim();
+        if (key) {
+            sessionStorage.setItem(moderationKeyStorageKey, key);
+        } else {
+            sessionStorage.removeItem(moderationKeyStorageKey);
+        }
+        loadPendingFeeds();
+    });
+});
@@ -150,6 +150,65 @@ async function tryFollow(pubKey) {
     }
 }
 
+// Signs and publishes the list following the imported feeds. Contact lists
+// replace the previous one so the imported feeds are added to it, follow sets
+// are published as they are.
+async function publishFollowList(unsignedEvent) {
+    const loggedIn = checkLogin();
+    if (!loggedIn){
+        await performLogin();
+    }
+
+    let event = {
+        kind: unsignedEvent.kind,
+        created_at: Math.floor(Date.now() / 1000),
+        tags: unsignedEvent.tags,
+        content: unsignedEvent.content,
+    }
+    if (event.kind === 3) {
+        if (!followListEvent) {
+            swal({
+                title: "Connecting...",
+                text: "Waiting for relays to retrieve your contact list. Please wait a few seconds and try again...",
+                icon: "error",
+                button: "Ok",
+            });
+            return;
+        }
+        const following = new Set(followListEvent.tags.map(tag => tag[1]));
+        event.tags = [...followListEvent.tags, ...unsignedEvent.tags.filter(tag => !following.has(tag[1]))];
+        event.content = JSON.stringify(relays);
+    }
+    const signedEvent = await window.nostr.signEvent(event);
+
+    let ok = window.NostrTools.validateEvent(signedEvent);
+    let veryOk = window.NostrTools.verifySignature(signedEvent);
+    if (ok && veryOk){
+        let alerted = false;
+        let pubs = pool.publish(relaysUrls, signedEvent);
+        pubs.forEach(pub => {
+            pub.on('ok', () => {
+                if (!alerted){
+                    alerted = true;
+                    if (signedEvent.kind === 3) {
+                        followListEvent = signedEvent;
+                        parseFollowList(followListEvent);
+                    }
+                    swal({
+                        title: "Published!",
+                        text: "You're now following the imported feeds.",
+                        icon: "success",
+                        button: "Ok",
+                    });
+                }
+            })
+            pub.on('failed', reason => {
+                console.log(`failed to publish: ${reason}`);
+            })
+        });
+    }
+}
+
 function parseFollowList(followListEvent) {
     const profilesPubKeys = new Set();
     followListEvent.tags.forEach((tag) => {
@@ -0,0 +1,61 @@
+// Solves the proof of work challenge of the forms which have one before
+// submitting them, the server checks it as described in pkg/pow.
+function leadingZeroBits(bytes) {
+    let bits = 0;
+    for (const b of bytes) {
+        if (b !== 0) {
+            return bits + Math.clz32(b) - 24;
+        }
+        bits += 8;
+    }
+    return bits;
+}
+
+async function solveChallenge(challenge, subject, difficulty) {
+    const encoder = new TextEncoder();
+    for (let nonce = 0; ; nonce++) {
+        const data = encoder.encode(`${challenge}:${subject}:${nonce}`);
+        const digest = new Uint8Array(await crypto.subtle.digest('SHA-256', data));
+        if (leadingZeroBits(digest) >= difficulty) {
+            return nonce.toString();
+        }
+    }
+}
+
+// The work of forms uploading a file is bound to the hex SHA-256 hash of its
+// content.
+async function fileHash(file) {
+    const digest = new Uint8Array(await crypto.subtle.digest('SHA-256', await file.arrayBuffer()));
+    return Array.from(digest, b => b.toString(16).padStart(2, '0')).join('');
+}
+
+async function challengeSubject(form) {
+    if (form.dataset.powFile) {
+        return fileHash(form.elements[form.dataset.powFile].files[0]);
+    }
+    return form.elements['url'].value;
+}
+
+function enableProofOfWork(form) {
+    form.addEventListener('submit', async event => {
+        // other actions of the form, like previews, don't need it
+        if (event.submitter && event.submitter.hasAttribute('formaction')) {
+            return;
+        }
+
+        event.preventDefault();
+        const button = event.submitter || form.querySelector('button');
+        button.classList.add('is-loading');
+
+        form.elements['nonce'].value = await solveChallenge(
+            form.elements['challenge'].value,
+            await challengeSubject(form),
+            parseInt(form.dataset.powDifficulty, 10),
+        );
+        form.submit();
+    });
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    document.querySelectorAll('form[data-pow-difficulty]').forEach(enableProofOfWork);
+});